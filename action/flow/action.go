@@ -30,14 +30,19 @@ const (
 )
 
 type FlowAction struct {
-	flowURI    string
-	ioMetadata *data.IOMetadata
+	flowURI     string
+	flowVersion string
+	ioMetadata  *data.IOMetadata
+	states      instanceStates
 }
 
 type ActionData struct {
 	// The flow is a URI
 	FlowURI string `json:"flowURI"`
 
+	// FlowVersion pins the version of the flow executed, the current version is used if not set
+	FlowVersion string `json:"flowVersion"`
+
 	// The flow is embedded and uncompressed
 	//DEPRECATED
 	Flow json.RawMessage `json:"flow"`
@@ -96,6 +101,14 @@ func (ff *ActionFactory) Init() error {
 		idGenerator, _ = util.NewGenerator()
 	}
 
+	limits, err := limitsFromEnv()
+	if err == nil {
+		err = SetLimits(limits)
+	}
+	if err != nil {
+		logger.Warnf("Ignoring the flow concurrency limits, %s", err.Error())
+	}
+
 	model.RegisterDefault(ep.GetDefaultFlowModel())
 	manager = support.NewFlowManager(ep.GetFlowProvider())
 	resource.RegisterManager(support.RESTYPE_FLOW, manager)
@@ -134,6 +147,7 @@ func (ff *ActionFactory) New(config *action.Config) (action.Action, error) {
 	if len(actionData.FlowURI) > 0 {
 
 		flowAction.flowURI = actionData.FlowURI
+		flowAction.flowVersion = actionData.FlowVersion
 	} else {
 		uri, err := createResource(&actionData)
 		if err != nil {
@@ -146,7 +160,7 @@ func (ff *ActionFactory) New(config *action.Config) (action.Action, error) {
 		flowAction.ioMetadata = config.Metadata
 	} else {
 		//todo add flag to remove startup validation
-		def, err := manager.GetFlow(flowAction.flowURI)
+		def, err := manager.GetFlowVersion(flowAction.flowURI, flowAction.flowVersion)
 		if err != nil {
 			return nil, err
 		} else {
@@ -196,7 +210,7 @@ func (fa *FlowAction) IOMetadata() *data.IOMetadata {
 
 // Run implements action.Action.Run
 //func (fa *FlowAction) Run(context context.Context, uri string, options interface{}, handler action.ResultHandler) error {
-func (fa *FlowAction) Run(context context.Context, inputs map[string]*data.Attribute, handler action.ResultHandler) error {
+func (fa *FlowAction) Run(ctx context.Context, inputs map[string]*data.Attribute, handler action.ResultHandler) error {
 
 	op := instance.OpStart
 	retID := false
@@ -221,8 +235,10 @@ func (fa *FlowAction) Run(context context.Context, inputs map[string]*data.Attri
 
 	delete(inputs, "_run_options")
 
+	flowVersion := ""
 	if flowURI == "" {
 		flowURI = fa.flowURI
+		flowVersion = fa.flowVersion
 	}
 
 	logger.Infof("Running FlowAction for URI: '%s'", flowURI)
@@ -234,7 +250,7 @@ func (fa *FlowAction) Run(context context.Context, inputs map[string]*data.Attri
 
 	switch op {
 	case instance.OpStart:
-		flowDef, err := manager.GetFlow(flowURI)
+		flowDef, err := manager.GetFlowVersion(flowURI, flowVersion)
 		if err != nil {
 			return err
 		}
@@ -297,11 +313,54 @@ func (fa *FlowAction) Run(context context.Context, inputs map[string]*data.Attri
 	stepCount := 0
 	hasWork := true
 
-	inst.SetResultHandler(handler)
+	// the replies go to the reply handler of the trigger when it streams them
+	inst.SetResultHandler(instance.ReplyResultHandler(ctx, handler))
+
+	// the instance waits in the work queue when it is over the concurrency limits
+	slot, err := limiter.acquire(ctx, inst.FlowURI(), inst.FlowDefinition().Name(), inst.FlowDefinition().MaxConcurrency())
+	if err != nil {
+		if debugger := inst.Debugger(); debugger != nil {
+			debugger.Detach()
+		}
+		return err
+	}
+
+	fa.startInstance(inst.ID())
 
 	go func() {
 
 		defer handler.Done()
+		defer fa.finishInstance(inst.ID())
+
+		if debugger := inst.Debugger(); debugger != nil {
+			// the instance is no longer debugged once it's done executing
+			defer debugger.Detach()
+		}
+
+		if !slot.wait() {
+			logger.Warnf("Flow instance [%s] shed from the work queue", inst.ID())
+			handler.HandleResult(nil, ErrShed)
+			return
+		}
+		defer slot.release()
+
+		if fa.isCancelled(inst.ID()) {
+			logger.Infof("Flow instance [%s] cancelled before it started", inst.ID())
+			handler.HandleResult(nil, ErrCancelled)
+			return
+		}
+
+		// the timeout of the instance starts once it is dispatched
+		stopRunning := startRunning(ctx, inst)
+		recordInstance := instrument(inst)
+
+		if fa.isCancelled(inst.ID()) {
+			// cancelled before it could be interrupted
+			cancelRunning(inst.ID())
+		}
+
+		defer stopRunning()
+		defer recordInstance()
 
 		if !inst.FlowDefinition().ExplicitReply() || retID {
 
@@ -330,15 +389,16 @@ func (fa *FlowAction) Run(context context.Context, inputs map[string]*data.Attri
 				ep.GetStateRecorder().RecordSnapshot(inst)
 				ep.GetStateRecorder().RecordStep(inst)
 			}
+
+			fa.checkpoint(inst)
 		}
 
-		if inst.Status() == model.FlowStatusCompleted {
-			returnData, err := inst.GetReturnData()
-			handler.HandleResult(returnData, err)
-		} else if inst.Status() == model.FlowStatusFailed {
-			handler.HandleResult(nil, inst.GetError())
+		if inst.Status() >= model.FlowStatusCompleted || fa.isCancelled(inst.ID()) {
+			fa.releaseInstance(inst)
 		}
 
+		fa.handleResult(inst, handler)
+
 		logger.Debugf("Done Executing flow instance [%s] - Status: %d", inst.ID(), inst.Status())
 
 		if inst.Status() == model.FlowStatusCompleted {
@@ -351,6 +411,21 @@ func (fa *FlowAction) Run(context context.Context, inputs map[string]*data.Attri
 	return nil
 }
 
+// handleResult passes the result of the instance done executing to the handler, a
+// cancelled instance which neither completed nor failed gets ErrCancelled
+func (fa *FlowAction) handleResult(inst *instance.IndependentInstance, handler action.ResultHandler) {
+
+	switch {
+	case inst.Status() == model.FlowStatusCompleted:
+		returnData, err := inst.GetReturnData()
+		handler.HandleResult(returnData, err)
+	case inst.Status() == model.FlowStatusFailed:
+		handler.HandleResult(nil, inst.GetError())
+	case inst.Status() == model.FlowStatusCancelled || fa.isCancelled(inst.ID()):
+		handler.HandleResult(nil, ErrCancelled)
+	}
+}
+
 func logInputs(attrs map[string]*data.Attribute) {
 	if len(attrs) > 0 {
 		logger.Debug("Input Attributes:")
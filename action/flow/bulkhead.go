@@ -0,0 +1,324 @@
+package flow
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/support"
+)
+
+const (
+	ENV_FLOW_MAX_CONCURRENCY = "FLOGO_FLOW_MAX_CONCURRENCY"
+	ENV_FLOW_QUEUE_SIZE      = "FLOGO_FLOW_QUEUE_SIZE"
+	ENV_FLOW_OVERFLOW        = "FLOGO_FLOW_OVERFLOW"
+)
+
+// Overflow is the behavior of Run when an instance can't start and the work queue is full
+type Overflow string
+
+const (
+	// OverflowBlock blocks Run until the instance can be queued, applying backpressure to the trigger
+	OverflowBlock Overflow = "block"
+	// OverflowReject returns ErrRejected to the trigger
+	OverflowReject Overflow = "reject"
+	// OverflowShedOldest drops the oldest queued instance, its handler gets ErrShed
+	OverflowShedOldest Overflow = "shedOldest"
+)
+
+var (
+	// ErrRejected is returned by Run when the work queue is full
+	ErrRejected = errors.New("flow instance rejected, the work queue is full")
+	// ErrShed is the result of a queued instance dropped for a newer one
+	ErrShed = errors.New("flow instance shed from the work queue")
+)
+
+// Limits are the limits of the concurrent instances started by the flow action, the
+// instances of a flow are also limited by the maxConcurrency of its definition
+type Limits struct {
+	// MaxConcurrency is the maximum number of concurrent instances of all the flows, 0 is unlimited
+	MaxConcurrency int
+	// QueueSize is the maximum number of instances waiting to start
+	QueueSize int
+	// Overflow is the behavior when the queue is full, OverflowBlock by default
+	Overflow Overflow
+}
+
+// RunStats are the counts of the instances started by the flow action
+type RunStats struct {
+	InFlight int
+	Queued   int
+	// Flows are the counts of each flow, keyed by uri
+	Flows map[string]FlowRunStats
+}
+
+// FlowRunStats are the counts of the instances of a flow
+type FlowRunStats struct {
+	InFlight int
+	Queued   int
+}
+
+var limiter = newBulkhead()
+
+// SetLimits sets the concurrency limits of the flow instances, instances already queued
+// are started as the new limits allow
+func SetLimits(limits Limits) error {
+
+	switch limits.Overflow {
+	case "":
+		limits.Overflow = OverflowBlock
+	case OverflowBlock, OverflowReject, OverflowShedOldest:
+	default:
+		return fmt.Errorf("unsupported overflow '%s'", limits.Overflow)
+	}
+
+	if limits.MaxConcurrency < 0 || limits.QueueSize < 0 {
+		return fmt.Errorf("invalid limits, maxConcurrency and queueSize must not be negative")
+	}
+
+	limiter.setLimits(limits)
+	return nil
+}
+
+// Stats returns the current in-flight and queued counts of the flow instances
+func Stats() RunStats {
+	return limiter.stats()
+}
+
+// limitsFromEnv gets the limits set by the environment variables
+func limitsFromEnv() (Limits, error) {
+
+	var limits Limits
+	var err error
+
+	if value := os.Getenv(ENV_FLOW_MAX_CONCURRENCY); value != "" {
+		if limits.MaxConcurrency, err = strconv.Atoi(value); err != nil {
+			return limits, fmt.Errorf("invalid %s '%s'", ENV_FLOW_MAX_CONCURRENCY, value)
+		}
+	}
+
+	if value := os.Getenv(ENV_FLOW_QUEUE_SIZE); value != "" {
+		if limits.QueueSize, err = strconv.Atoi(value); err != nil {
+			return limits, fmt.Errorf("invalid %s '%s'", ENV_FLOW_QUEUE_SIZE, value)
+		}
+	}
+
+	limits.Overflow = Overflow(os.Getenv(ENV_FLOW_OVERFLOW))
+
+	return limits, nil
+}
+
+// bulkhead admits the instances within the global and per flow limits, the others wait
+// in a bounded queue for an instance to finish
+type bulkhead struct {
+	mu       sync.Mutex
+	limits   Limits
+	inFlight int
+	perFlow  map[string]*FlowRunStats
+	queue    *list.List
+	// changed is closed when a slot or queue space may have freed up
+	changed chan struct{}
+}
+
+func newBulkhead() *bulkhead {
+	return &bulkhead{limits: Limits{Overflow: OverflowBlock}, perFlow: make(map[string]*FlowRunStats), queue: list.New(), changed: make(chan struct{})}
+}
+
+// slot is the admission of an instance, a queued instance waits for its slot to be ready
+type slot struct {
+	b        *bulkhead
+	flowURI  string
+	flowName string
+	ready    chan bool
+}
+
+type queuedRun struct {
+	slot  *slot
+	limit int
+}
+
+// wait waits until the instance can start, returns false if it was shed from the queue
+func (s *slot) wait() bool {
+	if s.ready == nil {
+		return true
+	}
+	return <-s.ready
+}
+
+// release frees the slot of the finished instance
+func (s *slot) release() {
+	s.b.release(s.flowURI)
+}
+
+// acquire admits an instance of the flow, limited to flowLimit concurrent instances
+func (b *bulkhead) acquire(ctx context.Context, flowURI, flowName string, flowLimit int) (*slot, error) {
+
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+
+	b.mu.Lock()
+
+	for {
+		if b.canStart(flowURI, flowLimit) {
+			b.start(flowURI)
+			b.mu.Unlock()
+			return &slot{b: b, flowURI: flowURI, flowName: flowName}, nil
+		}
+
+		if b.queue.Len() < b.limits.QueueSize {
+			s := b.enqueue(flowURI, flowName, flowLimit)
+			b.mu.Unlock()
+			return s, nil
+		}
+
+		switch b.limits.Overflow {
+		case OverflowReject:
+			b.mu.Unlock()
+			recordOverflow(flowName, support.MetricInstancesRejected)
+			return nil, ErrRejected
+
+		case OverflowShedOldest:
+			oldest := b.queue.Front()
+			if oldest == nil {
+				// nothing to shed with an empty queue
+				b.mu.Unlock()
+				recordOverflow(flowName, support.MetricInstancesRejected)
+				return nil, ErrRejected
+			}
+
+			shed := b.queue.Remove(oldest).(*queuedRun).slot
+			b.flowStats(shed.flowURI).Queued--
+			b.pruneStats(shed.flowURI)
+			s := b.enqueue(flowURI, flowName, flowLimit)
+			b.mu.Unlock()
+
+			recordOverflow(shed.flowName, support.MetricInstancesShed)
+			shed.ready <- false
+			return s, nil
+		}
+
+		// block until an instance finishes
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-done:
+			return nil, ctx.Err()
+		}
+
+		b.mu.Lock()
+	}
+}
+
+func (b *bulkhead) release(flowURI string) {
+
+	b.mu.Lock()
+
+	b.inFlight--
+	b.flowStats(flowURI).InFlight--
+	b.pruneStats(flowURI)
+
+	b.dispatch()
+}
+
+func (b *bulkhead) setLimits(limits Limits) {
+
+	b.mu.Lock()
+	b.limits = limits
+
+	b.dispatch()
+}
+
+// dispatch starts the queued instances which are now within the limits, in order, and
+// wakes up the blocked acquires.  It is called with the lock held, which it releases.
+func (b *bulkhead) dispatch() {
+
+	var ready []*slot
+
+	for e := b.queue.Front(); e != nil; {
+		next := e.Next()
+		run := e.Value.(*queuedRun)
+		if b.canStart(run.slot.flowURI, run.limit) {
+			b.queue.Remove(e)
+			b.flowStats(run.slot.flowURI).Queued--
+			b.start(run.slot.flowURI)
+			ready = append(ready, run.slot)
+		}
+		e = next
+	}
+
+	close(b.changed)
+	b.changed = make(chan struct{})
+	b.mu.Unlock()
+
+	for _, s := range ready {
+		s.ready <- true
+	}
+}
+
+func (b *bulkhead) stats() RunStats {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := RunStats{InFlight: b.inFlight, Queued: b.queue.Len(), Flows: make(map[string]FlowRunStats, len(b.perFlow))}
+	for flowURI, flowStats := range b.perFlow {
+		stats.Flows[flowURI] = *flowStats
+	}
+
+	return stats
+}
+
+func (b *bulkhead) canStart(flowURI string, flowLimit int) bool {
+
+	if b.limits.MaxConcurrency > 0 && b.inFlight >= b.limits.MaxConcurrency {
+		return false
+	}
+
+	if flowLimit > 0 && b.flowStats(flowURI).InFlight >= flowLimit {
+		return false
+	}
+
+	return true
+}
+
+func (b *bulkhead) start(flowURI string) {
+	b.inFlight++
+	b.flowStats(flowURI).InFlight++
+}
+
+func (b *bulkhead) enqueue(flowURI, flowName string, flowLimit int) *slot {
+	s := &slot{b: b, flowURI: flowURI, flowName: flowName, ready: make(chan bool, 1)}
+	b.queue.PushBack(&queuedRun{slot: s, limit: flowLimit})
+	b.flowStats(flowURI).Queued++
+	return s
+}
+
+func (b *bulkhead) flowStats(flowURI string) *FlowRunStats {
+	flowStats, exists := b.perFlow[flowURI]
+	if !exists {
+		flowStats = &FlowRunStats{}
+		b.perFlow[flowURI] = flowStats
+	}
+	return flowStats
+}
+
+// pruneStats removes the counts of the flow once it has no instances
+func (b *bulkhead) pruneStats(flowURI string) {
+	if flowStats := b.perFlow[flowURI]; flowStats != nil && flowStats.InFlight == 0 && flowStats.Queued == 0 {
+		delete(b.perFlow, flowURI)
+	}
+}
+
+func recordOverflow(flowName string, metric string) {
+	if metrics := currentMetrics(); metrics != nil {
+		metrics.Inc(metric, map[string]string{support.LabelFlow: flowName})
+	}
+}
@@ -0,0 +1,209 @@
+package flow
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkheadUnlimited(t *testing.T) {
+
+	b := newBulkhead()
+
+	for i := 0; i < 10; i++ {
+		s, err := b.acquire(nil, "flow1", "Flow1", 0)
+		assert.Nil(t, err)
+		assert.True(t, s.wait())
+	}
+
+	stats := b.stats()
+	assert.Equal(t, 10, stats.InFlight)
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, 10, stats.Flows["flow1"].InFlight)
+}
+
+func TestBulkheadQueue(t *testing.T) {
+
+	b := newBulkhead()
+	b.setLimits(Limits{MaxConcurrency: 1, QueueSize: 1, Overflow: OverflowReject})
+
+	s1, err := b.acquire(nil, "flow1", "Flow1", 0)
+	assert.Nil(t, err)
+	assert.True(t, s1.wait())
+
+	s2, err := b.acquire(nil, "flow1", "Flow1", 0)
+	assert.Nil(t, err)
+
+	stats := b.stats()
+	assert.Equal(t, 1, stats.InFlight)
+	assert.Equal(t, 1, stats.Queued)
+
+	// the queued instance starts once the running one finishes
+	s1.release()
+	assert.True(t, s2.wait())
+
+	stats = b.stats()
+	assert.Equal(t, 1, stats.InFlight)
+	assert.Equal(t, 0, stats.Queued)
+
+	s2.release()
+	stats = b.stats()
+	assert.Equal(t, 0, stats.InFlight)
+	assert.Empty(t, stats.Flows)
+}
+
+func TestBulkheadFlowLimit(t *testing.T) {
+
+	b := newBulkhead()
+	b.setLimits(Limits{QueueSize: 1, Overflow: OverflowReject})
+
+	s1, err := b.acquire(nil, "flow1", "Flow1", 1)
+	assert.Nil(t, err)
+	assert.True(t, s1.wait())
+
+	// the other flows aren't limited
+	s2, err := b.acquire(nil, "flow2", "Flow2", 1)
+	assert.Nil(t, err)
+	assert.True(t, s2.wait())
+
+	_, err = b.acquire(nil, "flow1", "Flow1", 1)
+	assert.Nil(t, err)
+
+	stats := b.stats()
+	assert.Equal(t, 2, stats.InFlight)
+	assert.Equal(t, FlowRunStats{InFlight: 1, Queued: 1}, stats.Flows["flow1"])
+	assert.Equal(t, FlowRunStats{InFlight: 1}, stats.Flows["flow2"])
+}
+
+func TestBulkheadReject(t *testing.T) {
+
+	b := newBulkhead()
+	b.setLimits(Limits{MaxConcurrency: 1, QueueSize: 1, Overflow: OverflowReject})
+
+	b.acquire(nil, "flow1", "Flow1", 0)
+	b.acquire(nil, "flow1", "Flow1", 0)
+
+	_, err := b.acquire(nil, "flow1", "Flow1", 0)
+	assert.Equal(t, ErrRejected, err)
+}
+
+func TestBulkheadShedOldest(t *testing.T) {
+
+	b := newBulkhead()
+	b.setLimits(Limits{MaxConcurrency: 1, QueueSize: 1, Overflow: OverflowShedOldest})
+
+	s1, _ := b.acquire(nil, "flow1", "Flow1", 0)
+	s2, _ := b.acquire(nil, "flow2", "Flow2", 0)
+
+	s3, err := b.acquire(nil, "flow3", "Flow3", 0)
+	assert.Nil(t, err)
+	assert.False(t, s2.wait())
+
+	stats := b.stats()
+	assert.Equal(t, 1, stats.Queued)
+	_, exists := stats.Flows["flow2"]
+	assert.False(t, exists)
+
+	s1.release()
+	assert.True(t, s3.wait())
+}
+
+func TestBulkheadShedOldestNoQueue(t *testing.T) {
+
+	b := newBulkhead()
+	b.setLimits(Limits{MaxConcurrency: 1, Overflow: OverflowShedOldest})
+
+	b.acquire(nil, "flow1", "Flow1", 0)
+
+	_, err := b.acquire(nil, "flow1", "Flow1", 0)
+	assert.Equal(t, ErrRejected, err)
+}
+
+func TestBulkheadBlock(t *testing.T) {
+
+	b := newBulkhead()
+	b.setLimits(Limits{MaxConcurrency: 1, Overflow: OverflowBlock})
+
+	s1, _ := b.acquire(nil, "flow1", "Flow1", 0)
+
+	acquired := make(chan *slot)
+	go func() {
+		s, _ := b.acquire(nil, "flow1", "Flow1", 0)
+		acquired <- s
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire should block while the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s1.release()
+
+	select {
+	case s := <-acquired:
+		assert.True(t, s.wait())
+	case <-time.After(time.Second):
+		t.Fatal("acquire should return once an instance finished")
+	}
+}
+
+func TestBulkheadBlockCancelled(t *testing.T) {
+
+	b := newBulkhead()
+	b.setLimits(Limits{MaxConcurrency: 1, Overflow: OverflowBlock})
+
+	b.acquire(nil, "flow1", "Flow1", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := b.acquire(ctx, "flow1", "Flow1", 0)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestBulkheadSetLimits(t *testing.T) {
+
+	b := newBulkhead()
+	b.setLimits(Limits{MaxConcurrency: 1, QueueSize: 1})
+
+	b.acquire(nil, "flow1", "Flow1", 0)
+	s2, _ := b.acquire(nil, "flow1", "Flow1", 0)
+
+	// raising the limit starts the queued instance
+	b.setLimits(Limits{MaxConcurrency: 2, QueueSize: 1})
+	assert.True(t, s2.wait())
+	assert.Equal(t, 2, b.stats().InFlight)
+}
+
+func TestSetLimitsInvalid(t *testing.T) {
+
+	err := SetLimits(Limits{Overflow: "drop"})
+	assert.NotNil(t, err)
+
+	err = SetLimits(Limits{MaxConcurrency: -1})
+	assert.NotNil(t, err)
+}
+
+func TestLimitsFromEnv(t *testing.T) {
+
+	os.Setenv(ENV_FLOW_MAX_CONCURRENCY, "100")
+	os.Setenv(ENV_FLOW_QUEUE_SIZE, "1000")
+	os.Setenv(ENV_FLOW_OVERFLOW, "reject")
+	defer func() {
+		os.Unsetenv(ENV_FLOW_MAX_CONCURRENCY)
+		os.Unsetenv(ENV_FLOW_QUEUE_SIZE)
+		os.Unsetenv(ENV_FLOW_OVERFLOW)
+	}()
+
+	limits, err := limitsFromEnv()
+	assert.Nil(t, err)
+	assert.Equal(t, Limits{MaxConcurrency: 100, QueueSize: 1000, Overflow: OverflowReject}, limits)
+
+	os.Setenv(ENV_FLOW_QUEUE_SIZE, "big")
+	_, err = limitsFromEnv()
+	assert.NotNil(t, err)
+}
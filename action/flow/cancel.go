@@ -0,0 +1,89 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/instance"
+)
+
+var (
+	runningMu sync.Mutex
+	running   = make(map[string]context.CancelFunc)
+)
+
+// Cancel cancels the running flow instance with the specified id, the instance is
+// routed to its error handler with the error code 'cancelled' (see instance.ErrorCodeCancelled)
+func (fa *FlowAction) Cancel(instanceID string) error {
+
+	if !cancelRunning(instanceID) {
+		return fmt.Errorf("flow instance '%s' is not running", instanceID)
+	}
+
+	return nil
+}
+
+// startRunning sets the context of the instance, bounded by the timeout of its flow,
+// and registers it so it can be cancelled.  The values of the context of the trigger
+// are propagated, but not its cancellation as instances outlive the trigger requests.
+func startRunning(ctx context.Context, inst *instance.IndependentInstance) context.CancelFunc {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var cancel context.CancelFunc
+	if timeout := inst.FlowDefinition().Timeout(); timeout > 0 {
+		ctx, cancel = context.WithTimeout(detachedContext{ctx}, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(detachedContext{ctx})
+	}
+
+	inst.SetContext(ctx)
+
+	runningMu.Lock()
+	running[inst.ID()] = cancel
+	runningMu.Unlock()
+
+	return func() {
+		runningMu.Lock()
+		delete(running, inst.ID())
+		runningMu.Unlock()
+
+		cancel()
+	}
+}
+
+// cancelRunning cancels the context of the running instance, returns false if the
+// instance isn't running
+func cancelRunning(instanceID string) bool {
+
+	runningMu.Lock()
+	cancel, exists := running[instanceID]
+	runningMu.Unlock()
+
+	if exists {
+		cancel()
+	}
+
+	return exists
+}
+
+// detachedContext is a context carrying the values of its parent, but which is never done
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -2,6 +2,8 @@ package definition
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/TIBCOSoftware/flogo-lib/core/activity"
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
@@ -14,9 +16,20 @@ import (
 type Definition struct {
 	name          string
 	modelID       string
+	version       string
+	schemaVersion string
 	explicitReply bool
 	//flowModel     model.FlowModel
 
+	maxConcurrency int
+
+	// parallelMerge is the merge policy of the parallel branches, empty if the flow isn't parallel
+	parallelMerge string
+
+	timeout time.Duration
+
+	exprLanguage string
+
 	attrs map[string]*data.Attribute
 
 	links map[int]*Link
@@ -27,6 +40,11 @@ type Definition struct {
 	linkExprMgr LinkExprManager
 
 	errorHandler *ErrorHandler
+
+	triggers []*Trigger
+
+	// rep is the representation the definition was created from
+	rep *DefinitionRep
 }
 
 // Name returns the name of the definition
@@ -39,6 +57,27 @@ func (d *Definition) ModelID() string {
 	return d.modelID
 }
 
+// Version returns the version of the flow declared by the definition
+func (d *Definition) Version() string {
+	return d.version
+}
+
+// SchemaVersion returns the schema version declared by the definition
+func (d *Definition) SchemaVersion() string {
+	return d.schemaVersion
+}
+
+// Timeout returns the maximum execution time of the instances of the flow, 0 indicates no limit
+func (d *Definition) Timeout() time.Duration {
+	return d.timeout
+}
+
+// ExprLanguage returns the language of the link expressions of the flow, empty if the
+// default link expression manager is used
+func (d *Definition) ExprLanguage() string {
+	return d.exprLanguage
+}
+
 // Metadata returns IO metadata for the flow
 func (d *Definition) Metadata() *data.IOMetadata {
 	return d.metadata
@@ -56,6 +95,12 @@ func (d *Definition) GetLink(linkID int) *Link {
 	return task
 }
 
+// MaxConcurrency returns the maximum number of concurrent instances of the flow
+// the engine should run, 0 indicates no limit
+func (d *Definition) MaxConcurrency() int {
+	return d.maxConcurrency
+}
+
 func (d *Definition) ExplicitReply() bool {
 	return d.explicitReply
 }
@@ -64,6 +109,24 @@ func (d *Definition) GetErrorHandler() *ErrorHandler {
 	return d.errorHandler
 }
 
+// HasErrorHandler determines if the flow defines an error handler with at least one task
+func (d *Definition) HasErrorHandler() bool {
+	return d.errorHandler != nil && len(d.errorHandler.tasks) > 0
+}
+
+// ErrorHandlerTasks returns the tasks of the error handler of the flow sorted by id,
+// nil is returned if the flow doesn't define an error handler
+func (d *Definition) ErrorHandlerTasks() []*Task {
+
+	if d.errorHandler == nil {
+		return nil
+	}
+
+	tasks := d.errorHandler.Tasks()
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].id < tasks[j].id })
+	return tasks
+}
+
 // GetAttr gets the specified attribute
 func (d *Definition) GetAttr(attrName string) (attr *data.Attribute, exists bool) {
 
@@ -95,6 +158,30 @@ func (d *Definition) Links() []*Link {
 	return links
 }
 
+// RootTask returns the start task of the flow, the task without incoming links.  Nil
+// is returned if the flow has no tasks or if it starts with several tasks.
+func (d *Definition) RootTask() *Task {
+
+	var root *Task
+
+	for _, task := range d.tasks {
+		if len(task.fromLinks) > 0 {
+			continue
+		}
+		if root != nil {
+			return nil
+		}
+		root = task
+	}
+
+	return root
+}
+
+// Triggers returns the triggers declared by the flow
+func (d *Definition) Triggers() []*Trigger {
+	return d.triggers
+}
+
 // SetLinkExprManager sets the LinkOld Expression Manager for the definition
 func (d *Definition) SetLinkExprManager(mgr LinkExprManager) {
 	// todo revisit
@@ -114,6 +201,8 @@ type ActivityConfig struct {
 
 	inputMapper  data.Mapper
 	outputMapper data.Mapper
+
+	inputMappings []*data.MappingDef
 }
 
 // GetSetting gets the specified setting
@@ -165,6 +254,11 @@ func (ac *ActivityConfig) OutputMapper() data.Mapper {
 	return ac.outputMapper
 }
 
+// InputMappings returns the mappings of the InputMapper of the task
+func (ac *ActivityConfig) InputMappings() []*data.MappingDef {
+	return ac.inputMappings
+}
+
 func (ac *ActivityConfig) Ref() string {
 	return ac.Activity.Metadata().ID
 }
@@ -191,6 +285,9 @@ type Task struct {
 
 	toLinks   []*Link
 	fromLinks []*Link
+
+	retryPolicy *RetryPolicy
+	timeout     time.Duration
 }
 
 // ID gets the id of the task
@@ -271,11 +368,48 @@ func (task *Task) String() string {
 	return fmt.Sprintf("Task[%s] '%s'", task.id, task.name)
 }
 
+// Timeout returns the maximum evaluation time of the activity of the task, 0 indicates no limit
+func (task *Task) Timeout() time.Duration {
+	return task.timeout
+}
+
 // IsScope returns flag indicating if the Task is a scope task (a container of attributes)
 func (task *Task) IsScope() bool {
 	return task.isScope
 }
 
+////////////////////////////////////////////////////////////////////////////
+// Trigger
+
+// Trigger is the object that describes the configuration of a
+// trigger declared by a flow.
+type Trigger struct {
+	id              string
+	ref             string
+	settings        map[string]interface{}
+	handlerSettings map[string]interface{}
+}
+
+// ID gets the id of the trigger
+func (trigger *Trigger) ID() string {
+	return trigger.id
+}
+
+// Ref gets the ref of the trigger
+func (trigger *Trigger) Ref() string {
+	return trigger.ref
+}
+
+// Settings gets the settings of the trigger
+func (trigger *Trigger) Settings() map[string]interface{} {
+	return trigger.settings
+}
+
+// HandlerSettings gets the settings of the trigger handler for the flow
+func (trigger *Trigger) HandlerSettings() map[string]interface{} {
+	return trigger.handlerSettings
+}
+
 ////////////////////////////////////////////////////////////////////////////
 // Link
 
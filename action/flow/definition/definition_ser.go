@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	flowutil "github.com/TIBCOSoftware/flogo-contrib/action/flow/util"
 	"github.com/TIBCOSoftware/flogo-lib/core/activity"
@@ -18,6 +19,8 @@ type DefinitionRep struct {
 	ExplicitReply bool   `json:"explicitReply"`
 	Name          string `json:"name"`
 	ModelID       string `json:"model"`
+	Version       string `json:"version,omitempty"`
+	SchemaVersion string `json:"schemaVersion,omitempty"`
 
 	Metadata   *data.IOMetadata  `json:"metadata"`
 	Attributes []*data.Attribute `json:"attributes,omitempty"`
@@ -27,6 +30,26 @@ type DefinitionRep struct {
 
 	ErrorHandler *ErrorHandlerRep `json:"errorHandler"`
 
+	Cache *CacheRep `json:"cache,omitempty"`
+
+	// MaxConcurrency is the maximum number of concurrent instances of the flow, 0 is unlimited
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+
+	// Parallel enables the concurrent execution of the branches of the flow
+	Parallel *ParallelRep `json:"parallel,omitempty"`
+
+	// Timeout bounds the execution time of the instances of the flow (ex. "30s"), not bounded if not set
+	Timeout string `json:"timeout,omitempty"`
+
+	// ExprLanguage is the language of the link expressions of the flow (ex. "expr"), the
+	// default link expression manager is used if not set
+	ExprLanguage string `json:"exprLanguage,omitempty"`
+
+	Triggers []*TriggerRep `json:"triggers,omitempty"`
+
+	// Includes are the uris of the flow fragments merged into the flow
+	Includes []string `json:"$include,omitempty"`
+
 	//deprecated
 	RootTask         *TaskRepOld `json:"rootTask"`
 	ErrorHandlerTask *TaskRepOld `json:"errorHandlerTask"`
@@ -38,6 +61,33 @@ type ErrorHandlerRep struct {
 	Links []*LinkRep `json:"links"`
 }
 
+// CacheRep is a serializable representation of the caching policy of a flow
+type CacheRep struct {
+	// Disabled indicates that the flow should not be cached
+	Disabled bool `json:"disabled,omitempty"`
+	// TTL is the duration the flow should be cached for (ex. "5m")
+	TTL string `json:"ttl,omitempty"`
+}
+
+// ParallelRep is a serializable representation of the parallel execution policy of a flow.
+// The branches advance in steps: the activities of the ready tasks are evaluated
+// concurrently, and the next tasks of the branches are only entered once all of them
+// completed, so a slow activity holds back the other branches.
+type ParallelRep struct {
+	// Merge is how the outputs of concurrent branches are merged into the flow, one of
+	// 'overwrite' (the default), 'keep' or 'error'
+	Merge string `json:"merge,omitempty"`
+}
+
+// TriggerRep is a serializable representation of a trigger declared by a flow
+type TriggerRep struct {
+	ID       string                 `json:"id"`
+	Ref      string                 `json:"ref"`
+	Settings map[string]interface{} `json:"settings,omitempty"`
+
+	HandlerSettings map[string]interface{} `json:"handler,omitempty"`
+}
+
 // TaskRep is a serializable representation of a flow task
 type TaskRep struct {
 	ID       string                 `json:"id"`
@@ -45,6 +95,15 @@ type TaskRep struct {
 	Name     string                 `json:"name"`
 	Settings map[string]interface{} `json:"settings"`
 
+	// FeatureFlag is the feature flag gating the task, the task is always enabled if not set
+	FeatureFlag string `json:"featureFlag,omitempty"`
+
+	// Retry is the retry policy of the activity of the task, a failed activity isn't retried if not set
+	Retry *RetryRep `json:"retry,omitempty"`
+
+	// Timeout bounds the evaluation time of the activity of the task (ex. "5s"), not bounded if not set
+	Timeout string `json:"timeout,omitempty"`
+
 	ActivityCfgRep *ActivityConfigRep `json:"activity"`
 }
 
@@ -84,10 +143,27 @@ func NewDefinition(rep *DefinitionRep) (def *Definition, err error) {
 	}
 
 	def = &Definition{}
+	def.rep = rep
 	def.name = rep.Name
 	def.modelID = rep.ModelID
+	def.version = rep.Version
+	def.schemaVersion = rep.SchemaVersion
+	def.exprLanguage = rep.ExprLanguage
 	def.metadata = rep.Metadata
 	def.explicitReply = rep.ExplicitReply
+	if rep.MaxConcurrency > 0 {
+		def.maxConcurrency = rep.MaxConcurrency
+	}
+	if rep.Parallel != nil {
+		if def.parallelMerge, err = parallelMerge(rep.Parallel); err != nil {
+			return nil, err
+		}
+	}
+	if rep.Timeout != "" {
+		if def.timeout, err = time.ParseDuration(rep.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout '%s', %s", rep.Timeout, err.Error())
+		}
+	}
 	if len(rep.Attributes) > 0 {
 		def.attrs = make(map[string]*data.Attribute, len(rep.Attributes))
 
@@ -99,6 +175,13 @@ func NewDefinition(rep *DefinitionRep) (def *Definition, err error) {
 	def.tasks = make(map[string]*Task)
 	def.links = make(map[int]*Link)
 
+	for i, triggerRep := range rep.Triggers {
+		if triggerRep == nil {
+			return nil, fmt.Errorf("invalid trigger at index %d, trigger is null", i)
+		}
+		def.triggers = append(def.triggers, createTrigger(triggerRep))
+	}
+
 	if len(rep.Tasks) != 0 {
 
 		for _, taskRep := range rep.Tasks {
@@ -164,6 +247,16 @@ func NewDefinition(rep *DefinitionRep) (def *Definition, err error) {
 	return def, nil
 }
 
+func createTrigger(rep *TriggerRep) *Trigger {
+	trigger := &Trigger{}
+	trigger.id = rep.ID
+	trigger.ref = rep.Ref
+	trigger.settings = rep.Settings
+	trigger.handlerSettings = rep.HandlerSettings
+
+	return trigger
+}
+
 func createTask(def *Definition, rep *TaskRep) (*Task, error) {
 	task := &Task{}
 	task.id = rep.ID
@@ -185,6 +278,22 @@ func createTask(def *Definition, rep *TaskRep) (*Task, error) {
 		}
 	}
 
+	if rep.Timeout != "" {
+		timeout, err := time.ParseDuration(rep.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("task '%s': invalid timeout '%s', %s", task.id, rep.Timeout, err.Error())
+		}
+		task.timeout = timeout
+	}
+
+	if rep.Retry != nil {
+		policy, err := createRetryPolicy(task, rep.Retry)
+		if err != nil {
+			return nil, err
+		}
+		task.retryPolicy = policy
+	}
+
 	if rep.ActivityCfgRep != nil {
 
 		actCfg, err := createActivityConfig(task, rep.ActivityCfgRep)
@@ -274,6 +383,7 @@ func createActivityConfig(task *Task, rep *ActivityConfigRep) (*ActivityConfig,
 	if rep.Mappings != nil {
 		if rep.Mappings.Input != nil {
 			activityCfg.inputMapper = GetMapperFactory().NewActivityInputMapper(task, &data.MapperDef{Mappings: rep.Mappings.Input})
+			activityCfg.inputMappings = rep.Mappings.Input
 		}
 		if rep.Mappings.Output != nil {
 			activityCfg.outputMapper = GetMapperFactory().NewActivityOutputMapper(task, &data.MapperDef{Mappings: rep.Mappings.Output})
@@ -537,6 +647,7 @@ func createActivityConfigFromOld(task *Task, rep *TaskRepOld) (*ActivityConfig,
 			fixupMappings(rep.Mappings.Input)
 
 			activityCfg.inputMapper = GetMapperFactory().NewActivityInputMapper(task, &data.MapperDef{Mappings: rep.Mappings.Input})
+			activityCfg.inputMappings = rep.Mappings.Input
 		}
 		if rep.Mappings.Output != nil {
 			activityCfg.outputMapper = GetMapperFactory().NewActivityOutputMapper(task, &data.MapperDef{Mappings: rep.Mappings.Output})
@@ -550,6 +661,7 @@ func createActivityConfigFromOld(task *Task, rep *TaskRepOld) (*ActivityConfig,
 		if rep.Mappings.Input != nil {
 			fixupMappings(rep.Mappings.Input)
 			activityCfg.inputMapper = GetMapperFactory().NewActivityInputMapper(task, &data.MapperDef{Mappings: rep.Mappings.Input})
+			activityCfg.inputMappings = rep.Mappings.Input
 		}
 		if rep.Mappings.Output != nil {
 			activityCfg.outputMapper = GetMapperFactory().NewActivityOutputMapper(task, &data.MapperDef{Mappings: rep.Mappings.Output})
@@ -559,6 +671,7 @@ func createActivityConfigFromOld(task *Task, rep *TaskRepOld) (*ActivityConfig,
 		if rep.InputMappings != nil {
 			fixupMappings(rep.InputMappings)
 			activityCfg.inputMapper = GetMapperFactory().NewActivityInputMapper(task, &data.MapperDef{Mappings: rep.InputMappings})
+			activityCfg.inputMappings = rep.InputMappings
 		}
 		if rep.OutputMappings != nil {
 			activityCfg.outputMapper = GetMapperFactory().NewActivityOutputMapper(task, &data.MapperDef{Mappings: rep.OutputMappings})
@@ -12,6 +12,7 @@ import (
 
 func init() {
 	activity.Register(NewLogActivity())
+	activity.Register(NewRestActivity())
 }
 
 const defJSON = `
@@ -182,3 +183,31 @@ func (a *LogActivity) Eval(context activity.Context) (done bool, err error) {
 	fmt.Println("Message :", message)
 	return true, nil
 }
+
+type RestActivity struct {
+	metadata *activity.Metadata
+}
+
+// NewRestActivity creates a new RestActivity
+func NewRestActivity() activity.Activity {
+	metadata := &activity.Metadata{ID: "rest"}
+	metadata.Settings = map[string]*data.Attribute{
+		"proxy": data.NewZeroAttribute("proxy", data.TypeString),
+	}
+	metadata.Input = map[string]*data.Attribute{
+		"method":  data.NewZeroAttribute("method", data.TypeString),
+		"uri":     data.NewZeroAttribute("uri", data.TypeString),
+		"headers": data.NewZeroAttribute("headers", data.TypeParams),
+	}
+	return &RestActivity{metadata: metadata}
+}
+
+// Metadata returns the activity's metadata
+func (a *RestActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+// Eval implements api.Activity.Eval
+func (a *RestActivity) Eval(context activity.Context) (done bool, err error) {
+	return true, nil
+}
@@ -0,0 +1,563 @@
+package definition
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+const triggerDefJSON = `
+{
+  "name": "Trigger Flow",
+  "model": "simple",
+  "triggers": [
+    {
+      "id": "receive_http_message",
+      "ref": "github.com/TIBCOSoftware/flogo-contrib/trigger/rest",
+      "settings": {
+        "port": 9233
+      },
+      "handler": {
+        "method": "GET",
+        "path": "/pets/:petId"
+      }
+    }
+  ],
+  "tasks": [
+    {
+      "id": "LogStart",
+      "activity": {
+        "ref": "log",
+        "input": { "message": "Find Pet Flow Started!" }
+      }
+    }
+  ]
+}
+`
+
+func newTestDefinition(t *testing.T, defJSON string) *Definition {
+
+	defRep := &DefinitionRep{}
+	err := json.Unmarshal([]byte(defJSON), defRep)
+	assert.Nil(t, err)
+
+	def, err := NewDefinition(defRep)
+	assert.Nil(t, err)
+	assert.NotNil(t, def)
+
+	return def
+}
+
+func TestDefinitionTriggers(t *testing.T) {
+
+	def := newTestDefinition(t, triggerDefJSON)
+
+	triggers := def.Triggers()
+	assert.Len(t, triggers, 1)
+
+	trigger := triggers[0]
+	assert.Equal(t, "receive_http_message", trigger.ID())
+	assert.Equal(t, "github.com/TIBCOSoftware/flogo-contrib/trigger/rest", trigger.Ref())
+	assert.Equal(t, float64(9233), trigger.Settings()["port"])
+	assert.Equal(t, "GET", trigger.HandlerSettings()["method"])
+	assert.Equal(t, "/pets/:petId", trigger.HandlerSettings()["path"])
+}
+
+func TestDefinitionNullTrigger(t *testing.T) {
+
+	defRep := &DefinitionRep{}
+	err := json.Unmarshal([]byte(strings.Replace(triggerDefJSON, `"triggers": [`, `"triggers": [ null,`, 1)), defRep)
+	assert.Nil(t, err)
+
+	_, err = NewDefinition(defRep)
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid trigger at index 0, trigger is null", err.Error())
+}
+
+func TestDefinitionNoTriggers(t *testing.T) {
+
+	def := newTestDefinition(t, defJSON)
+	assert.Len(t, def.Triggers(), 0)
+}
+
+const restDefJSON = `
+{
+  "name": "Rest Flow",
+  "model": "simple",
+  "tasks": [
+    {
+      "id": "GetPet",
+      "activity": {
+        "ref": "rest",
+        "settings": { "proxy": "http://proxy.corp:3128" },
+        "input": { "method": "GET", "uri": "https://petstore.swagger.io/v2/pet/1" }
+      }
+    },
+    {
+      "id": "GetOrder",
+      "activity": {
+        "ref": "rest",
+        "input": { "method": "GET", "uri": "https://petstore.swagger.io/v2/store/order/1" }
+      }
+    },
+    {
+      "id": "GetPetAgain",
+      "activity": {
+        "ref": "rest",
+        "input": { "method": "GET", "uri": "https://petstore.swagger.io/v2/pet/1" }
+      }
+    },
+    {
+      "id": "LogDone",
+      "activity": {
+        "ref": "log",
+        "input": { "message": "done" }
+      }
+    }
+  ],
+  "errorHandler": {
+    "tasks": [
+      {
+        "id": "Notify",
+        "activity": {
+          "ref": "rest",
+          "input": { "method": "POST", "uri": "http://alerts.corp/notify" }
+        }
+      }
+    ]
+  }
+}
+`
+
+func TestDefinitionExternalEndpoints(t *testing.T) {
+
+	def := newTestDefinition(t, restDefJSON)
+
+	assert.Equal(t, []string{
+		"http://alerts.corp/notify",
+		"http://proxy.corp:3128",
+		"https://petstore.swagger.io/v2/pet/1",
+		"https://petstore.swagger.io/v2/store/order/1",
+	}, def.ExternalEndpoints())
+
+	assert.Len(t, newTestDefinition(t, triggerDefJSON).ExternalEndpoints(), 0)
+}
+
+const linkExprDefJSON = `
+{
+  "name": "Conditional Flow",
+  "model": "simple",
+  "tasks": [
+    { "id": "Start", "activity": { "ref": "log", "input": { "message": "start" } } },
+    { "id": "Small", "activity": { "ref": "log", "input": { "message": "small" } } },
+    { "id": "Large", "activity": { "ref": "log", "input": { "message": "large" } } },
+    { "id": "Done", "activity": { "ref": "log", "input": { "message": "done" } } }
+  ],
+  "links": [
+    { "from": "Start", "to": "Small", "type": "expression", "value": "$flow.amount < 100" },
+    { "from": "Start", "to": "Large", "type": "expression", "value": "$flow.amount >= 100" },
+    { "from": "Small", "to": "Done" },
+    { "from": "Large", "to": "Done", "type": "1", "value": "$flow.approved == true" }
+  ]
+}
+`
+
+func TestDefinitionLinkExpressions(t *testing.T) {
+
+	def := newTestDefinition(t, linkExprDefJSON)
+
+	assert.Equal(t, []string{
+		"Start->Small: $flow.amount < 100",
+		"Start->Large: $flow.amount >= 100",
+		"Large->Done: $flow.approved == true",
+	}, def.LinkExpressions())
+
+	assert.Len(t, newTestDefinition(t, triggerDefJSON).LinkExpressions(), 0)
+}
+
+const secretDefJSON = `
+{
+  "name": "Secret Flow",
+  "model": "simple",
+  "tasks": [
+    {
+      "id": "GetAccount",
+      "activity": {
+        "ref": "rest",
+        "settings": { "proxy": "secret://proxy-url" },
+        "input": { "method": "GET", "uri": "https://bank.example.com/accounts", "headers": { "Authorization": "secret://bank-token" } }
+      }
+    },
+    {
+      "id": "GetBalance",
+      "activity": {
+        "ref": "rest",
+        "input": { "method": "GET", "uri": "https://bank.example.com/balance", "headers": { "Authorization": "secret://bank-token" } }
+      }
+    }
+  ]
+}
+`
+
+func TestDefinitionSecretRefs(t *testing.T) {
+
+	def := newTestDefinition(t, secretDefJSON)
+
+	assert.Equal(t, []string{"bank-token", "proxy-url"}, def.SecretRefs())
+	assert.Equal(t, []string{"https://bank.example.com/accounts", "https://bank.example.com/balance"}, def.ExternalEndpoints())
+
+	assert.Len(t, newTestDefinition(t, restDefJSON).SecretRefs(), 0)
+}
+
+func TestDefinitionMaxConcurrency(t *testing.T) {
+
+	def := newTestDefinition(t, `{"name": "Limited Flow", "model": "simple", "maxConcurrency": 5, "tasks": []}`)
+	assert.Equal(t, 5, def.MaxConcurrency())
+
+	def = newTestDefinition(t, triggerDefJSON)
+	assert.Equal(t, 0, def.MaxConcurrency())
+}
+
+func TestDefinitionRetryPolicy(t *testing.T) {
+
+	def := newTestDefinition(t, `{"name": "Retry Flow", "model": "simple", "tasks": [
+	  {"id": "fetch", "retry": {"maxAttempts": 4, "backoff": "exponential", "interval": "100ms", "maxInterval": "300ms", "retryOn": ["503"]}},
+	  {"id": "log"}
+	]}`)
+
+	policy := def.GetTask("fetch").RetryPolicy()
+	assert.NotNil(t, policy)
+	assert.Equal(t, 4, policy.MaxAttempts())
+	assert.Equal(t, 100*time.Millisecond, policy.Delay(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Delay(2))
+	assert.Equal(t, 300*time.Millisecond, policy.Delay(3))
+
+	assert.True(t, policy.Retryable(errors.New("status 503 service unavailable")))
+	assert.False(t, policy.Retryable(errors.New("status 400 bad request")))
+	assert.False(t, policy.Retryable(nil))
+
+	assert.Nil(t, def.GetTask("log").RetryPolicy())
+
+	defRep := &DefinitionRep{}
+	err := json.Unmarshal([]byte(`{"name": "Retry Flow", "model": "simple", "tasks": [{"id": "fetch", "retry": {"maxAttempts": 2, "backoff": "linear"}}]}`), defRep)
+	assert.Nil(t, err)
+
+	_, err = NewDefinition(defRep)
+	assert.NotNil(t, err)
+	assert.Equal(t, "task 'fetch': unsupported retry backoff 'linear'", err.Error())
+}
+
+func TestDefinitionParallel(t *testing.T) {
+
+	def := newTestDefinition(t, `{"name": "Parallel Flow", "model": "simple", "parallel": {}, "tasks": []}`)
+	assert.True(t, def.Parallel())
+	assert.Equal(t, MergeOverwrite, def.ParallelMerge())
+
+	def = newTestDefinition(t, `{"name": "Parallel Flow", "model": "simple", "parallel": {"merge": "error"}, "tasks": []}`)
+	assert.Equal(t, MergeError, def.ParallelMerge())
+
+	def = newTestDefinition(t, triggerDefJSON)
+	assert.False(t, def.Parallel())
+
+	defRep := &DefinitionRep{}
+	err := json.Unmarshal([]byte(`{"name": "Parallel Flow", "model": "simple", "parallel": {"merge": "append"}, "tasks": []}`), defRep)
+	assert.Nil(t, err)
+
+	_, err = NewDefinition(defRep)
+	assert.NotNil(t, err)
+	assert.Equal(t, "unsupported parallel merge 'append'", err.Error())
+}
+
+func TestDefinitionTimeout(t *testing.T) {
+
+	def := newTestDefinition(t, `{"name": "Timeout Flow", "model": "simple", "timeout": "30s", "tasks": [{"id": "fetch", "timeout": "5s"}, {"id": "log"}]}`)
+	assert.Equal(t, 30*time.Second, def.Timeout())
+	assert.Equal(t, 5*time.Second, def.GetTask("fetch").Timeout())
+	assert.Equal(t, time.Duration(0), def.GetTask("log").Timeout())
+
+	defRep := &DefinitionRep{}
+	err := json.Unmarshal([]byte(`{"name": "Timeout Flow", "model": "simple", "tasks": [{"id": "fetch", "timeout": "soon"}]}`), defRep)
+	assert.Nil(t, err)
+
+	_, err = NewDefinition(defRep)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "task 'fetch': invalid timeout 'soon'")
+}
+
+const inputsDefJSON = `
+{
+  "name": "Order Flow",
+  "model": "simple",
+  "metadata": {
+    "input": [
+      { "name": "orderId", "type": "string" },
+      { "name": "quantity", "type": "long" },
+      { "name": "payload", "type": "object" }
+    ]
+  },
+  "tasks": []
+}
+`
+
+func newTestAttr(name string, dataType data.Type) *data.Attribute {
+	return data.NewZeroAttribute(name, dataType)
+}
+
+func TestDefinitionMapTriggerOutputs(t *testing.T) {
+
+	def := newTestDefinition(t, inputsDefJSON)
+
+	outputs := []*data.Attribute{
+		newTestAttr("id", data.TypeString),
+		newTestAttr("quantity", data.TypeInteger),
+		newTestAttr("content", data.TypeComplexObject),
+		newTestAttr("headers", data.TypeParams),
+	}
+
+	mapping, errs := def.MapTriggerOutputs(outputs, map[string]string{"id": "orderId", "content": "payload"})
+	assert.Len(t, errs, 0)
+	assert.Equal(t, map[string]string{"id": "orderId", "quantity": "quantity", "content": "payload"}, mapping)
+}
+
+func TestDefinitionMapTriggerOutputsConflicts(t *testing.T) {
+
+	def := newTestDefinition(t, inputsDefJSON)
+
+	outputs := []*data.Attribute{
+		newTestAttr("orderId", data.TypeString),
+		newTestAttr("id", data.TypeString),
+		newTestAttr("quantity", data.TypeBoolean),
+		newTestAttr("body", data.TypeString),
+	}
+
+	mapping, errs := def.MapTriggerOutputs(outputs, map[string]string{"id": "orderId", "body": "content", "missing": "payload"})
+	assert.Nil(t, mapping)
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	assert.Equal(t, []string{
+		"trigger output 'missing' not found",
+		"flow input 'orderId' mapped from both trigger outputs 'orderId' and 'id'",
+		"trigger output 'quantity' of type boolean is incompatible with flow input 'quantity' of type long",
+		"trigger output 'body' mapped to unknown flow input 'content'",
+	}, msgs)
+}
+
+func TestDefinitionRootTask(t *testing.T) {
+
+	def := newTestDefinition(t, defJSON)
+	root := def.RootTask()
+	assert.NotNil(t, root)
+	assert.Equal(t, "LogStart", root.ID())
+
+	def = newTestDefinition(t, triggerDefJSON)
+	assert.Equal(t, "LogStart", def.RootTask().ID())
+
+	parallelDefJSON := `{
+  "name": "Parallel Flow",
+  "model": "simple",
+  "tasks": [
+    { "id": "LogA", "activity": { "ref": "log", "input": { "message": "a" } } },
+    { "id": "LogB", "activity": { "ref": "log", "input": { "message": "b" } } }
+  ]
+}`
+
+	def = newTestDefinition(t, parallelDefJSON)
+	assert.Nil(t, def.RootTask())
+
+	def = newTestDefinition(t, `{"name": "Empty Flow", "model": "simple", "tasks": []}`)
+	assert.Nil(t, def.RootTask())
+}
+
+func TestValidate(t *testing.T) {
+
+	assert.Nil(t, Validate(newTestDefinition(t, defJSON)))
+	assert.Nil(t, Validate(newTestDefinition(t, `{"name": "Empty Flow", "model": "simple", "tasks": []}`)))
+
+	cyclicDefJSON := `{
+  "name": "Cyclic Flow",
+  "model": "simple",
+  "tasks": [
+    { "id": "LogA", "activity": { "ref": "log", "input": { "message": "a" } } },
+    { "id": "LogB", "activity": { "ref": "log", "input": { "message": "b" } } }
+  ],
+  "links": [
+    { "from": "LogA", "to": "LogB" },
+    { "from": "LogB", "to": "LogA" }
+  ]
+}`
+
+	def := newTestDefinition(t, cyclicDefJSON)
+
+	// a link whose task was removed from the flow
+	delete(def.tasks, "LogB")
+
+	err := Validate(def)
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid flow 'Cyclic Flow': link[0]: to task not found; link[1]: from task not found; "+
+		"no starting task, every task has an incoming link", err.Error())
+}
+
+const linkExprDOT = `digraph "Conditional Flow" {
+  "Done" [label="Done"];
+  "Large" [label="Large"];
+  "Small" [label="Small"];
+  "Start" [label="Start"];
+  "Start" -> "Small" [label="$flow.amount < 100"];
+  "Start" -> "Large" [label="$flow.amount >= 100"];
+  "Small" -> "Done";
+  "Large" -> "Done" [label="$flow.approved == true"];
+}
+`
+
+func TestDefinitionToDOT(t *testing.T) {
+
+	def := newTestDefinition(t, linkExprDefJSON)
+	assert.Equal(t, linkExprDOT, def.ToDOT())
+}
+
+func TestLinkWarnings(t *testing.T) {
+
+	assert.Len(t, LinkWarnings(newTestDefinition(t, linkExprDefJSON)), 0)
+
+	branchDefJSON := `{
+  "name": "Branch Flow",
+  "model": "simple",
+  "tasks": [
+    { "id": "Start", "activity": { "ref": "log", "input": { "message": "start" } } },
+    { "id": "LogA", "activity": { "ref": "log", "input": { "message": "a" } } },
+    { "id": "LogB", "activity": { "ref": "log", "input": { "message": "b" } } },
+    { "id": "LogC", "activity": { "ref": "log", "input": { "message": "c" } } }
+  ],
+  "links": [
+    { "from": "Start", "to": "LogA" },
+    { "from": "Start", "to": "LogB" },
+    { "from": "Start", "to": "LogC", "type": "expression", "value": "$flow.amount > 100" }
+  ]
+}`
+
+	warnings := LinkWarnings(newTestDefinition(t, branchDefJSON))
+	assert.Equal(t, []string{"task 'Start': ambiguous outgoing links, 2 unconditional links to 'LogA', 'LogB'"}, warnings)
+}
+
+func TestDefinitionHasErrorHandler(t *testing.T) {
+
+	def := newTestDefinition(t, restDefJSON)
+	assert.True(t, def.HasErrorHandler())
+
+	tasks := def.ErrorHandlerTasks()
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, "Notify", tasks[0].ID())
+
+	def = newTestDefinition(t, linkExprDefJSON)
+	assert.False(t, def.HasErrorHandler())
+	assert.Nil(t, def.ErrorHandlerTasks())
+
+	def = newTestDefinition(t, `{"name": "Empty Handler", "model": "simple", "tasks": [], "errorHandler": {"tasks": []}}`)
+	assert.False(t, def.HasErrorHandler())
+}
+
+func TestDefinitionMinify(t *testing.T) {
+
+	namedDefJSON := strings.Replace(restDefJSON, `"id": "GetOrder",`, `"id": "GetOrder", "name": "Get the order",`, 1)
+	namedDefJSON = strings.Replace(namedDefJSON, `"tasks": [`, `"links": [{ "from": "GetPet", "to": "GetOrder", "name": "then", "type": "expression", "value": "$flow.ok" }], "tasks": [`, 1)
+
+	def := newTestDefinition(t, namedDefJSON)
+
+	minified := def.Minify()
+	assert.NotNil(t, minified)
+	assert.Equal(t, "", minified.Tasks[1].Name)
+	assert.Equal(t, "", minified.Links[0].Name)
+
+	// the original definition is unchanged
+	assert.Equal(t, "Get the order", def.GetTask("GetOrder").Name())
+
+	minDef, err := NewDefinition(minified)
+	assert.Nil(t, err)
+
+	assert.Equal(t, def.Name(), minDef.Name())
+	assert.Equal(t, def.LinkExpressions(), minDef.LinkExpressions())
+	assert.Equal(t, def.ExternalEndpoints(), minDef.ExternalEndpoints())
+	assert.Len(t, minDef.Tasks(), len(def.Tasks()))
+	assert.Len(t, minDef.ErrorHandlerTasks(), 1)
+
+	for _, task := range def.Tasks() {
+		minTask := minDef.GetTask(task.ID())
+		assert.NotNil(t, minTask)
+		assert.Equal(t, task.ActivityConfig().Ref(), minTask.ActivityConfig().Ref())
+
+		uri, _ := task.ActivityConfig().GetInputAttr("uri")
+		minURI, _ := minTask.ActivityConfig().GetInputAttr("uri")
+		if uri != nil {
+			assert.Equal(t, uri.Value(), minURI.Value())
+		}
+	}
+}
+
+// countingVisitor counts the visited tasks and links
+type countingVisitor struct {
+	tasks, links int
+	taskIDs      []string
+}
+
+func (v *countingVisitor) VisitTask(task *Task) bool {
+	v.tasks++
+	v.taskIDs = append(v.taskIDs, task.ID())
+	return true
+}
+
+func (v *countingVisitor) VisitLink(link *Link) bool {
+	v.links++
+	return true
+}
+
+func TestDefinitionWalk(t *testing.T) {
+
+	visitor := &countingVisitor{}
+	newTestDefinition(t, linkExprDefJSON).Walk(visitor)
+
+	assert.Equal(t, 4, visitor.tasks)
+	assert.Equal(t, 4, visitor.links)
+	assert.Equal(t, []string{"Done", "Large", "Small", "Start"}, visitor.taskIDs)
+
+	// the error handler tasks are visited after the flow tasks
+	visitor = &countingVisitor{}
+	def := newTestDefinition(t, restDefJSON)
+	def.Walk(visitor)
+
+	assert.Equal(t, len(def.Tasks())+1, visitor.tasks)
+	assert.Equal(t, "Notify", visitor.taskIDs[len(visitor.taskIDs)-1])
+
+	// the walk stops when the visitor returns false
+	visited := 0
+	def.Walk(VisitorFuncs{Task: func(task *Task) bool {
+		visited++
+		return false
+	}})
+	assert.Equal(t, 1, visited)
+}
+
+func TestDefinitionToJSON(t *testing.T) {
+
+	def := newTestDefinition(t, restDefJSON)
+
+	defJSON, err := def.ToJSON()
+	assert.Nil(t, err)
+
+	rep := &DefinitionRep{}
+	assert.Nil(t, json.Unmarshal(defJSON, rep))
+
+	dumped, err := NewDefinition(rep)
+	assert.Nil(t, err)
+	assert.Equal(t, def.Name(), dumped.Name())
+	assert.Equal(t, def.TaskCount(), dumped.TaskCount())
+	assert.Equal(t, len(def.Tasks())+len(def.ErrorHandlerTasks()), def.TaskCount())
+}
@@ -0,0 +1,76 @@
+package definition
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ToDOT renders the tasks and links of the flow as a Graphviz DOT graph, the
+// conditions of the links are used as the edge labels and the error handler is
+// rendered as a separate cluster
+func (d *Definition) ToDOT() string {
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "digraph %s {\n", dotQuote(d.name))
+	writeDOTGraph(&buf, "  ", d.tasks, d.links)
+
+	if d.errorHandler != nil && len(d.errorHandler.tasks) > 0 {
+		buf.WriteString("  subgraph cluster_error_handler {\n")
+		buf.WriteString("    label=\"error handler\";\n")
+		writeDOTGraph(&buf, "    ", d.errorHandler.tasks, d.errorHandler.links)
+		buf.WriteString("  }\n")
+	}
+
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+// writeDOTGraph writes the tasks and links ordered by id so the output is stable
+func writeDOTGraph(buf *bytes.Buffer, indent string, tasks map[string]*Task, links map[int]*Link) {
+
+	taskIDs := make([]string, 0, len(tasks))
+	for id := range tasks {
+		taskIDs = append(taskIDs, id)
+	}
+	sort.Strings(taskIDs)
+
+	for _, id := range taskIDs {
+		label := tasks[id].name
+		if label == "" {
+			label = id
+		}
+		fmt.Fprintf(buf, "%s%s [label=%s];\n", indent, dotQuote(id), dotQuote(label))
+	}
+
+	linkIDs := make([]int, 0, len(links))
+	for id := range links {
+		linkIDs = append(linkIDs, id)
+	}
+	sort.Ints(linkIDs)
+
+	for _, id := range linkIDs {
+		link := links[id]
+		if link.fromTask == nil || link.toTask == nil {
+			continue
+		}
+
+		fmt.Fprintf(buf, "%s%s -> %s", indent, dotQuote(link.fromTask.id), dotQuote(link.toTask.id))
+
+		switch link.linkType {
+		case LtExpression, LtLabel:
+			fmt.Fprintf(buf, " [label=%s]", dotQuote(link.value))
+		case LtError:
+			buf.WriteString(" [label=\"error\", style=dashed]")
+		}
+
+		buf.WriteString(";\n")
+	}
+}
+
+func dotQuote(s string) string {
+	return "\"" + strings.Replace(strings.Replace(s, "\\", "\\\\", -1), "\"", "\\\"", -1) + "\""
+}
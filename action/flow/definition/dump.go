@@ -0,0 +1,29 @@
+package definition
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToJSON serializes the flow back to JSON, the representation the flow was created from
+// (ex. after the includes were merged) is serialized.  An error is returned for flows in
+// the deprecated format.
+func (d *Definition) ToJSON() ([]byte, error) {
+
+	if d.rep == nil {
+		return nil, fmt.Errorf("flow '%s' is in the deprecated format and can't be serialized", d.name)
+	}
+
+	return json.MarshalIndent(d.rep, "", "  ")
+}
+
+// TaskCount returns the number of tasks of the flow, including the tasks of its error handler
+func (d *Definition) TaskCount() int {
+
+	count := len(d.tasks)
+	if d.errorHandler != nil {
+		count += len(d.errorHandler.tasks)
+	}
+
+	return count
+}
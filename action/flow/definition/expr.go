@@ -1,6 +1,12 @@
 package definition
 
-import "github.com/TIBCOSoftware/flogo-lib/core/data"
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+)
 
 // LinkExprManager interface that defines a Link Expression Manager
 type LinkExprManager interface {
@@ -21,6 +27,12 @@ func (e *LinkExprError) Error() string {
 	return e.msg
 }
 
+// LinkExprCompiler is an optional interface of a LinkExprManager compiling the link
+// expressions of a flow when it is materialized, so that invalid expressions fail the load
+type LinkExprCompiler interface {
+	CompileLinkExprs(def *Definition) error
+}
+
 type LinkExprManagerFactory interface {
 	NewLinkExprManager() LinkExprManager
 }
@@ -35,6 +47,31 @@ func GetLinkExprManagerFactory() LinkExprManagerFactory {
 	return linkExprMangerFactory
 }
 
+var (
+	exprLanguagesMu sync.RWMutex
+	exprLanguages   = make(map[string]LinkExprManagerFactory)
+)
+
+// RegisterExprLanguage registers the factory of the link expression managers of the
+// specified language, flows select the language using their exprLanguage
+func RegisterExprLanguage(language string, factory LinkExprManagerFactory) {
+
+	exprLanguagesMu.Lock()
+	defer exprLanguagesMu.Unlock()
+
+	exprLanguages[language] = factory
+}
+
+// GetExprLanguage gets the factory of the link expression managers of the specified language
+func GetExprLanguage(language string) (LinkExprManagerFactory, bool) {
+
+	exprLanguagesMu.RLock()
+	defer exprLanguagesMu.RUnlock()
+
+	factory, exists := exprLanguages[language]
+	return factory, exists
+}
+
 // GetExpressionLinks gets the links of the definition that are of type LtExpression
 func GetExpressionLinks(def *Definition) []*Link {
 
@@ -58,3 +95,34 @@ func GetExpressionLinks(def *Definition) []*Link {
 
 	return links
 }
+
+// LinkExpressions returns the expressions of the expression links of the definition,
+// including those of the error handler, formatted as '<from task id>-><to task id>: <expression>'
+func (d *Definition) LinkExpressions() []string {
+
+	links := sortedLinks(d.Links())
+
+	if d.GetErrorHandler() != nil {
+		ehLinks := make([]*Link, 0, len(d.GetErrorHandler().links))
+		for _, link := range d.GetErrorHandler().links {
+			ehLinks = append(ehLinks, link)
+		}
+		links = append(links, sortedLinks(ehLinks)...)
+	}
+
+	var exprs []string
+
+	for _, link := range links {
+		if link.Type() == LtExpression {
+			exprs = append(exprs, fmt.Sprintf("%s->%s: %s", link.FromTask().ID(), link.ToTask().ID(), link.Value()))
+		}
+	}
+
+	return exprs
+}
+
+// sortedLinks sorts the links by id
+func sortedLinks(links []*Link) []*Link {
+	sort.Slice(links, func(i, j int) bool { return links[i].ID() < links[j].ID() })
+	return links
+}
@@ -0,0 +1,100 @@
+package definition
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+)
+
+// MapTriggerOutputs reconciles the outputs of a trigger with the inputs of the flow,
+// returning the mapping of the trigger output names to the flow input names.  Outputs
+// are mapped to the flow input with the same name, unless they are renamed by the
+// specified renames (trigger output name -> flow input name).  The errors returned
+// describe the unknown names, the incompatible types and the flow inputs mapped from
+// several outputs, no mapping is returned if there are any.
+func (d *Definition) MapTriggerOutputs(outputs []*data.Attribute, renames map[string]string) (map[string]string, []error) {
+
+	var inputs map[string]*data.Attribute
+	if d.metadata != nil {
+		inputs = d.metadata.Input
+	}
+
+	byName := make(map[string]*data.Attribute, len(outputs))
+	for _, output := range outputs {
+		byName[output.Name()] = output
+	}
+
+	var errs []error
+
+	var unknown []string
+	for outputName := range renames {
+		if _, exists := byName[outputName]; !exists {
+			unknown = append(unknown, outputName)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, outputName := range unknown {
+		errs = append(errs, fmt.Errorf("trigger output '%s' not found", outputName))
+	}
+
+	mapping := make(map[string]string)
+	mappedFrom := make(map[string]string)
+
+	for _, output := range outputs {
+		outputName := output.Name()
+
+		inputName, renamed := renames[outputName]
+		if !renamed {
+			inputName = outputName
+		}
+
+		input, exists := inputs[inputName]
+		if !exists {
+			if renamed {
+				errs = append(errs, fmt.Errorf("trigger output '%s' mapped to unknown flow input '%s'", outputName, inputName))
+			}
+			continue
+		}
+
+		if !compatibleTypes(output.Type(), input.Type()) {
+			errs = append(errs, fmt.Errorf("trigger output '%s' of type %s is incompatible with flow input '%s' of type %s",
+				outputName, output.Type().String(), inputName, input.Type().String()))
+			continue
+		}
+
+		if other, mapped := mappedFrom[inputName]; mapped {
+			errs = append(errs, fmt.Errorf("flow input '%s' mapped from both trigger outputs '%s' and '%s'", inputName, other, outputName))
+			continue
+		}
+
+		mappedFrom[inputName] = outputName
+		mapping[outputName] = inputName
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return mapping, nil
+}
+
+// compatibleTypes determines if a value of the from type can be assigned to the to type
+func compatibleTypes(from, to data.Type) bool {
+
+	if from == to || from == data.TypeAny || to == data.TypeAny {
+		return true
+	}
+
+	switch to {
+	case data.TypeString:
+		return true
+	case data.TypeLong, data.TypeDouble:
+		return from == data.TypeInteger || from == data.TypeLong || from == data.TypeDouble
+	case data.TypeObject:
+		return from == data.TypeComplexObject || from == data.TypeParams
+	}
+
+	return false
+}
@@ -44,10 +44,15 @@ type MapperFactory interface {
 	GetDefaultTaskOutputMapper(task *Task) data.Mapper
 }
 
-var mapperFactory MapperFactory
+var (
+	mapperFactoryMu sync.Mutex // protects mapperFactory, flows are materialized concurrently
+	mapperFactory   MapperFactory
+)
 
 func SetMapperFactory(factory MapperFactory) {
+	mapperFactoryMu.Lock()
 	mapperFactory = factory
+	mapperFactoryMu.Unlock()
 
 	baseFactory, ok := interface{}(factory).(mapper.Factory)
 	if ok {
@@ -57,6 +62,9 @@ func SetMapperFactory(factory MapperFactory) {
 
 func GetMapperFactory() MapperFactory {
 
+	mapperFactoryMu.Lock()
+	defer mapperFactoryMu.Unlock()
+
 	//temp hack until we consolidate mapper definition
 	if mapperFactory == nil {
 		mapperFactory = &BasicMapperFactory{baseFactory: mapper.GetFactory()}
@@ -115,29 +123,30 @@ type DefaultActivityOutputMapper struct {
 
 }
 
+// MetadataScope is a scope restricted to the attributes of the metadata, the output
+// scope of a task instance exposes the output metadata resolved for the instance
+type MetadataScope interface {
+	data.Scope
+
+	// Metadata returns the attributes the scope is restricted to
+	Metadata() map[string]*data.Attribute
+}
+
 func (m *DefaultActivityOutputMapper) Apply(inputScope data.Scope, outputScope data.Scope) error {
 
-	m.mutex.Lock()
-	if m.outputMetadata == nil {
-		act := m.task.activityCfg.Activity
-		if act.Metadata().DynamicIO {
-			//todo validate dynamic on instantiation
-			dynamic, _ := act.(activity.DynamicIO)
-			dynamicIO, _ := dynamic.IOMetadata(&DummyTaskCtx{task: m.task})
-			//todo handler error
-			if dynamicIO != nil {
-				m.outputMetadata = dynamicIO.Output
-			} else {
-				m.outputMetadata =act.Metadata().Output
-			}
-		}
+	outputMetadata := m.defaultOutputMetadata()
 
+	// the outputs of dynamic activities depend on the instance (ex. the flow of a subflow
+	// resolved from an input), so the metadata resolved by the task instance is used
+	if m.task != nil {
+		if mdScope, ok := inputScope.(MetadataScope); ok && mdScope.Metadata() != nil {
+			outputMetadata = mdScope.Metadata()
+		}
 	}
-	m.mutex.Unlock()
 
 	oscope := outputScope.(data.MutableScope)
 
-	for _, attr := range m.outputMetadata {
+	for _, attr := range outputMetadata {
 
 		oAttr, _ := inputScope.GetAttr(attr.Name())
 
@@ -149,6 +158,29 @@ func (m *DefaultActivityOutputMapper) Apply(inputScope data.Scope, outputScope d
 	return nil
 }
 
+func (m *DefaultActivityOutputMapper) defaultOutputMetadata() map[string]*data.Attribute {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.outputMetadata == nil && m.task != nil {
+		act := m.task.activityCfg.Activity
+		if act.Metadata().DynamicIO {
+			//todo validate dynamic on instantiation
+			dynamic, _ := act.(activity.DynamicIO)
+			dynamicIO, _ := dynamic.IOMetadata(&DummyTaskCtx{task: m.task})
+			//todo handler error
+			if dynamicIO != nil {
+				m.outputMetadata = dynamicIO.Output
+			} else {
+				m.outputMetadata = act.Metadata().Output
+			}
+		}
+	}
+
+	return m.outputMetadata
+}
+
 //Deprecated
 func (mf *BasicMapperFactory) NewTaskInputMapper(task *Task, mapperDef *MapperDef) data.Mapper {
 	id := task.definition.name + "." + task.id + ".input"
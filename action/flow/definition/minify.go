@@ -0,0 +1,47 @@
+package definition
+
+import (
+	"encoding/json"
+)
+
+// Minify returns a copy of the representation of the flow stripped of the display
+// metadata (the names of the tasks and links) and of the already merged includes,
+// preserving the execution semantics of the flow (ex. for constrained devices).  Nil
+// is returned for flows in the deprecated format.
+func (d *Definition) Minify() *DefinitionRep {
+
+	if d.rep == nil {
+		return nil
+	}
+
+	// deep copy so the original representation isn't modified
+	repBytes, err := json.Marshal(d.rep)
+	if err != nil {
+		return nil
+	}
+
+	var minified *DefinitionRep
+	if err := json.Unmarshal(repBytes, &minified); err != nil {
+		return nil
+	}
+
+	minified.Includes = nil
+	minifyTasks(minified.Tasks, minified.Links)
+
+	if minified.ErrorHandler != nil {
+		minifyTasks(minified.ErrorHandler.Tasks, minified.ErrorHandler.Links)
+	}
+
+	return minified
+}
+
+func minifyTasks(taskReps []*TaskRep, linkReps []*LinkRep) {
+
+	for _, taskRep := range taskReps {
+		taskRep.Name = ""
+	}
+
+	for _, linkRep := range linkReps {
+		linkRep.Name = ""
+	}
+}
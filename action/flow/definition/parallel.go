@@ -0,0 +1,38 @@
+package definition
+
+import "fmt"
+
+const (
+	// MergeOverwrite merges the outputs of concurrent branches in task order, later tasks
+	// overwriting the attributes set by earlier ones
+	MergeOverwrite = "overwrite"
+
+	// MergeKeep keeps the attributes set by the first of the concurrent branches
+	MergeKeep = "keep"
+
+	// MergeError fails the task when concurrent branches set the same attribute
+	MergeError = "error"
+)
+
+// Parallel determines if the branches of the flow are executed concurrently, in steps
+// (see ParallelRep)
+func (d *Definition) Parallel() bool {
+	return d.parallelMerge != ""
+}
+
+// ParallelMerge returns how the outputs of concurrent branches are merged into the flow
+func (d *Definition) ParallelMerge() string {
+	return d.parallelMerge
+}
+
+func parallelMerge(rep *ParallelRep) (string, error) {
+
+	switch rep.Merge {
+	case "":
+		return MergeOverwrite, nil
+	case MergeOverwrite, MergeKeep, MergeError:
+		return rep.Merge, nil
+	}
+
+	return "", fmt.Errorf("unsupported parallel merge '%s'", rep.Merge)
+}
@@ -1,5 +1,9 @@
 package definition
 
+import (
+	"context"
+)
+
 // ExtensionProvider is the interface that describes an object
 // that can provide flow definitions from a URI
 type Provider interface {
@@ -15,6 +19,24 @@ type Provider interface {
 	//AddFlowURI(id string, uri string) error
 }
 
+// ContextProvider is a Provider that can retrieve flow definitions using a context,
+// allowing it to access request scoped values (ex. tenant, trace)
+type ContextProvider interface {
+	Provider
+
+	// GetFlowWithContext retrieves the flow definition for the specified uri using the context
+	GetFlowWithContext(ctx context.Context, flowURI string) (*DefinitionRep, error)
+}
+
+// VersionedProvider is a Provider that can retrieve specific versions of the flow
+// definitions (ex. to compare a canary with a prior version)
+type VersionedProvider interface {
+	Provider
+
+	// GetFlowVersion retrieves the specified version of the flow definition for the specified uri
+	GetFlowVersion(flowURI string, version string) (*DefinitionRep, error)
+}
+
 //// RemoteFlowProvider is an implementation of FlowProvider service
 //// that can access flowes via URI
 //type RemoteFlowProvider struct {
@@ -0,0 +1,144 @@
+package definition
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	secretRefPrefix = "secret://"
+	settingFlowURI  = "flowURI"
+)
+
+// ExternalEndpoints returns the distinct, sorted URL-like values (ex. the uri of a
+// REST activity) found in the settings and inputs of the activities of the flow,
+// including those of the error handler
+func (d *Definition) ExternalEndpoints() []string {
+
+	found := make(map[string]bool)
+
+	d.scanActivityValues(func(value string) {
+		if isEndpoint(value) && !strings.HasPrefix(value, secretRefPrefix) {
+			found[value] = true
+		}
+	})
+
+	return sortedKeys(found)
+}
+
+// SecretRefs returns the distinct, sorted names of the secrets referenced (ex.
+// secret://db-password) in the settings and inputs of the activities of the flow,
+// including those of the error handler
+func (d *Definition) SecretRefs() []string {
+
+	found := make(map[string]bool)
+
+	d.scanActivityValues(func(value string) {
+		if strings.HasPrefix(value, secretRefPrefix) && len(value) > len(secretRefPrefix) {
+			found[value[len(secretRefPrefix):]] = true
+		}
+	})
+
+	return sortedKeys(found)
+}
+
+// SubflowURIs returns the distinct, sorted uris of the subflows started by the tasks
+// of the flow (the flowURI setting of the subflow activity), including those of the
+// error handler
+func (d *Definition) SubflowURIs() []string {
+
+	found := make(map[string]bool)
+
+	tasks := d.Tasks()
+	if d.errorHandler != nil {
+		tasks = append(tasks, d.errorHandler.Tasks()...)
+	}
+
+	for _, task := range tasks {
+		if uri, ok := task.settings[settingFlowURI].(string); ok && uri != "" {
+			found[uri] = true
+		}
+
+		if task.activityCfg == nil {
+			continue
+		}
+
+		if attr := task.activityCfg.settings[settingFlowURI]; attr != nil {
+			if uri, ok := attr.Value().(string); ok && uri != "" {
+				found[uri] = true
+			}
+		}
+	}
+
+	return sortedKeys(found)
+}
+
+// scanActivityValues calls the function with every string value of the settings and
+// inputs of the tasks of the flow, including the values nested in maps and slices
+func (d *Definition) scanActivityValues(fn func(value string)) {
+
+	d.Walk(VisitorFuncs{Task: func(task *Task) bool {
+		for _, value := range task.settings {
+			scanStrings(value, fn)
+		}
+
+		if task.activityCfg == nil {
+			return true
+		}
+
+		for _, attr := range task.activityCfg.settings {
+			if attr != nil {
+				scanStrings(attr.Value(), fn)
+			}
+		}
+		for _, attr := range task.activityCfg.inputAttrs {
+			if attr != nil {
+				scanStrings(attr.Value(), fn)
+			}
+		}
+		return true
+	}})
+}
+
+func scanStrings(value interface{}, fn func(value string)) {
+
+	switch v := value.(type) {
+	case string:
+		fn(v)
+	case map[string]interface{}:
+		for _, val := range v {
+			scanStrings(val, fn)
+		}
+	case map[string]string:
+		for _, val := range v {
+			fn(val)
+		}
+	case []interface{}:
+		for _, val := range v {
+			scanStrings(val, fn)
+		}
+	}
+}
+
+// isEndpoint determines if the value is an absolute URL with a host
+func isEndpoint(value string) bool {
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme != "" && u.Host != ""
+}
+
+func sortedKeys(set map[string]bool) []string {
+
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
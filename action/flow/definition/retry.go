@@ -0,0 +1,127 @@
+package definition
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// BackoffFixed waits the retry interval between each attempt
+	BackoffFixed = "fixed"
+
+	// BackoffExponential doubles the retry interval after each attempt
+	BackoffExponential = "exponential"
+)
+
+// RetryRep is a serializable representation of the retry policy of a task
+type RetryRep struct {
+	// MaxAttempts is the maximum number of times the activity is evaluated, including the first attempt
+	MaxAttempts int `json:"maxAttempts"`
+
+	// Backoff is either 'fixed' (the default) or 'exponential'
+	Backoff string `json:"backoff,omitempty"`
+
+	// Interval is the delay before the first retry (ex. 500ms, 2s)
+	Interval string `json:"interval,omitempty"`
+
+	// MaxInterval caps the delay between attempts, the delay isn't capped if not set
+	MaxInterval string `json:"maxInterval,omitempty"`
+
+	// RetryOn restricts the retries to errors containing one of the specified strings,
+	// all errors are retried if not set
+	RetryOn []string `json:"retryOn,omitempty"`
+}
+
+// RetryPolicy describes how the evaluation of the activity of a task is retried when it fails
+type RetryPolicy struct {
+	maxAttempts int
+	exponential bool
+	interval    time.Duration
+	maxInterval time.Duration
+	retryOn     []string
+}
+
+// MaxAttempts returns the maximum number of times the activity is evaluated
+func (p *RetryPolicy) MaxAttempts() int {
+	return p.maxAttempts
+}
+
+// Delay returns the delay before the attempt following the specified (1-based) failed attempt
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+
+	delay := p.interval
+
+	if p.exponential {
+		for i := 1; i < attempt; i++ {
+			delay *= 2
+			if p.maxInterval > 0 && delay >= p.maxInterval {
+				break
+			}
+		}
+	}
+
+	if p.maxInterval > 0 && delay > p.maxInterval {
+		delay = p.maxInterval
+	}
+
+	return delay
+}
+
+// Retryable determines if the specified error should be retried
+func (p *RetryPolicy) Retryable(err error) bool {
+
+	if err == nil {
+		return false
+	}
+
+	if len(p.retryOn) == 0 {
+		return true
+	}
+
+	for _, match := range p.retryOn {
+		if strings.Contains(err.Error(), match) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetryPolicy returns the retry policy of the task, nil if the task isn't retried
+func (task *Task) RetryPolicy() *RetryPolicy {
+	return task.retryPolicy
+}
+
+func createRetryPolicy(task *Task, rep *RetryRep) (*RetryPolicy, error) {
+
+	if rep.MaxAttempts < 1 {
+		return nil, fmt.Errorf("task '%s': invalid retry max attempts '%d'", task.id, rep.MaxAttempts)
+	}
+
+	policy := &RetryPolicy{maxAttempts: rep.MaxAttempts, retryOn: rep.RetryOn}
+
+	switch rep.Backoff {
+	case "", BackoffFixed:
+	case BackoffExponential:
+		policy.exponential = true
+	default:
+		return nil, fmt.Errorf("task '%s': unsupported retry backoff '%s'", task.id, rep.Backoff)
+	}
+
+	var err error
+
+	if rep.Interval != "" {
+		if policy.interval, err = time.ParseDuration(rep.Interval); err != nil {
+			return nil, fmt.Errorf("task '%s': invalid retry interval '%s', %s", task.id, rep.Interval, err.Error())
+		}
+	}
+
+	if rep.MaxInterval != "" {
+		if policy.maxInterval, err = time.ParseDuration(rep.MaxInterval); err != nil {
+			return nil, fmt.Errorf("task '%s': invalid retry max interval '%s', %s", task.id, rep.MaxInterval, err.Error())
+		}
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,110 @@
+package definition
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Validate validates the structure of the materialized flow definition, checking
+// that the links connect tasks of the flow and that the flow and its error handler
+// have a starting task (a task without incoming links).  All the problems found are
+// returned as a single error.
+func Validate(def *Definition) error {
+
+	if def == nil {
+		return fmt.Errorf("invalid flow: definition not provided")
+	}
+
+	var problems []string
+
+	problems = append(problems, validateStructure("", def.tasks, def.links)...)
+
+	if def.errorHandler != nil {
+		problems = append(problems, validateStructure("error handler ", def.errorHandler.tasks, def.errorHandler.links)...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid flow '%s': %s", def.name, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func validateStructure(prefix string, tasks map[string]*Task, links map[int]*Link) []string {
+
+	var problems []string
+
+	ids := make([]int, 0, len(links))
+	for id := range links {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		link := links[id]
+		if link.fromTask == nil || tasks[link.fromTask.id] != link.fromTask {
+			problems = append(problems, fmt.Sprintf("%slink[%d]: from task not found", prefix, id))
+		}
+		if link.toTask == nil || tasks[link.toTask.id] != link.toTask {
+			problems = append(problems, fmt.Sprintf("%slink[%d]: to task not found", prefix, id))
+		}
+	}
+
+	if len(tasks) == 0 {
+		return problems
+	}
+
+	for _, task := range tasks {
+		if len(task.fromLinks) == 0 {
+			return problems
+		}
+	}
+
+	return append(problems, prefix+"no starting task, every task has an incoming link")
+}
+
+// LinkWarnings checks the outgoing links of the tasks of the flow and its error handler,
+// returning a warning for each task with several unconditional outgoing links.  Such
+// links are ambiguous as all their branches are always taken, which is likely a bug.
+func LinkWarnings(def *Definition) []string {
+
+	if def == nil {
+		return nil
+	}
+
+	warnings := linkWarnings("", def.tasks)
+
+	if def.errorHandler != nil {
+		warnings = append(warnings, linkWarnings("error handler ", def.errorHandler.tasks)...)
+	}
+
+	return warnings
+}
+
+func linkWarnings(prefix string, tasks map[string]*Task) []string {
+
+	ids := make([]string, 0, len(tasks))
+	for id := range tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var warnings []string
+
+	for _, id := range ids {
+
+		var targets []string
+		for _, link := range tasks[id].toLinks {
+			if link.linkType == LtDependency && link.toTask != nil {
+				targets = append(targets, "'"+link.toTask.id+"'")
+			}
+		}
+
+		if len(targets) > 1 {
+			warnings = append(warnings, fmt.Sprintf("%stask '%s': ambiguous outgoing links, %d unconditional links to %s", prefix, id, len(targets), strings.Join(targets, ", ")))
+		}
+	}
+
+	return warnings
+}
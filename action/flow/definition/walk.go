@@ -0,0 +1,80 @@
+package definition
+
+import (
+	"sort"
+)
+
+// TaskLinkVisitor is visited by Definition.Walk with the tasks and links of a flow
+type TaskLinkVisitor interface {
+
+	// VisitTask is called for each task, the walk stops if false is returned
+	VisitTask(task *Task) bool
+
+	// VisitLink is called for each link, the walk stops if false is returned
+	VisitLink(link *Link) bool
+}
+
+// VisitorFuncs adapts functions to a TaskLinkVisitor, the tasks or links are
+// skipped if the corresponding function isn't set
+type VisitorFuncs struct {
+	Task func(task *Task) bool
+	Link func(link *Link) bool
+}
+
+// VisitTask implements TaskLinkVisitor.VisitTask
+func (v VisitorFuncs) VisitTask(task *Task) bool {
+	if v.Task == nil {
+		return true
+	}
+	return v.Task(task)
+}
+
+// VisitLink implements TaskLinkVisitor.VisitLink
+func (v VisitorFuncs) VisitLink(link *Link) bool {
+	if v.Link == nil {
+		return true
+	}
+	return v.Link(link)
+}
+
+// Walk visits the tasks and then the links of the flow followed by the ones of the
+// error handler, the tasks and links are visited ordered by id
+func (d *Definition) Walk(visitor TaskLinkVisitor) {
+
+	if !walkGraph(visitor, d.tasks, d.links) {
+		return
+	}
+
+	if d.errorHandler != nil {
+		walkGraph(visitor, d.errorHandler.tasks, d.errorHandler.links)
+	}
+}
+
+func walkGraph(visitor TaskLinkVisitor, tasks map[string]*Task, links map[int]*Link) bool {
+
+	taskIDs := make([]string, 0, len(tasks))
+	for id := range tasks {
+		taskIDs = append(taskIDs, id)
+	}
+	sort.Strings(taskIDs)
+
+	for _, id := range taskIDs {
+		if !visitor.VisitTask(tasks[id]) {
+			return false
+		}
+	}
+
+	linkIDs := make([]int, 0, len(links))
+	for id := range links {
+		linkIDs = append(linkIDs, id)
+	}
+	sort.Ints(linkIDs)
+
+	for _, id := range linkIDs {
+		if !visitor.VisitLink(links[id]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,490 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// DebugPoint is the point of the evaluation of a task where a debugged instance pauses
+type DebugPoint string
+
+const (
+	// DebugBefore pauses once the inputs of the task are mapped, before its activity is evaluated
+	DebugBefore DebugPoint = "before"
+	// DebugAfter pauses once the activity is evaluated, before its outputs are mapped
+	DebugAfter DebugPoint = "after"
+)
+
+var (
+	// ErrNotPaused is returned when a paused instance is expected
+	ErrNotPaused = errors.New("flow instance is not paused")
+	// ErrDetached is returned by Wait once the debugger is detached from its instance
+	ErrDetached = errors.New("debugger is detached")
+)
+
+var (
+	debuggersMu sync.Mutex
+	debuggers   = make(map[string]*Debugger)
+)
+
+// GetDebugger returns the debugger attached to the instance with the specified id
+func GetDebugger(instanceID string) (*Debugger, bool) {
+
+	debuggersMu.Lock()
+	defer debuggersMu.Unlock()
+
+	debugger, exists := debuggers[instanceID]
+	return debugger, exists
+}
+
+// Breakpoint pauses a debugged instance at the points of a task, before if none is specified
+type Breakpoint struct {
+	TaskID string       `json:"taskId"`
+	Points []DebugPoint `json:"points,omitempty"`
+}
+
+// Pause is a snapshot of a paused instance, the outputs are only set after the activity
+// of the task is evaluated
+type Pause struct {
+	InstanceID string                 `json:"instanceId"`
+	Flow       string                 `json:"flow"`
+	TaskID     string                 `json:"taskId"`
+	Point      DebugPoint             `json:"point"`
+	Inputs     map[string]interface{} `json:"inputs"`
+	Outputs    map[string]interface{} `json:"outputs,omitempty"`
+	Attrs      map[string]interface{} `json:"attrs"`
+}
+
+// Debugger pauses a flow instance at the breakpoints of its tasks, the working data of
+// the paused task can then be inspected and patched, and the activities of the tasks
+// can be replaced by mock outputs.  The instance is driven from another goroutine (ex.
+// a debugging UI), which uses Wait to wait for the instance to pause and Continue, Step
+// or Abort to resume it.  The timeout of the instance isn't suspended while it's paused.
+type Debugger struct {
+	mu          sync.Mutex
+	instanceID  string
+	breakpoints map[string][]DebugPoint
+	mocks       map[string]map[string]interface{}
+	stepping    bool
+	aborted     bool
+	detached    bool
+	paused      *pausedTask
+
+	// changed is closed when the instance pauses or the debugger is detached
+	changed chan struct{}
+
+	// pauseMu serializes the pauses of the concurrent branches of parallel flows
+	pauseMu sync.Mutex
+}
+
+type pausedTask struct {
+	pause    *Pause
+	commands chan *debugCommand
+	resumed  chan struct{}
+}
+
+// debugCommand is executed by the goroutine of the paused task, a nil apply resumes it
+type debugCommand struct {
+	apply func(ti *TaskInst, point DebugPoint) error
+	reply chan error
+}
+
+// NewDebugger creates a new Debugger, it is attached to an instance using ExecOptions
+func NewDebugger() *Debugger {
+	return &Debugger{
+		breakpoints: make(map[string][]DebugPoint),
+		mocks:       make(map[string]map[string]interface{}),
+		changed:     make(chan struct{}),
+	}
+}
+
+// SetDebugger attaches the debugger to the instance
+func (inst *IndependentInstance) SetDebugger(debugger *Debugger) {
+	inst.debugger = debugger
+
+	debugger.mu.Lock()
+	debugger.instanceID = inst.ID()
+	debugger.mu.Unlock()
+
+	debuggersMu.Lock()
+	debuggers[inst.ID()] = debugger
+	debuggersMu.Unlock()
+}
+
+// Debugger returns the debugger attached to the instance, nil if it isn't debugged
+func (inst *IndependentInstance) Debugger() *Debugger {
+	return inst.debugger
+}
+
+// InstanceID returns the id of the instance the debugger is attached to
+func (d *Debugger) InstanceID() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.instanceID
+}
+
+// SetBreakpoint pauses the instance at the specified points of the task, before its
+// activity is evaluated if no point is specified
+func (d *Debugger) SetBreakpoint(taskID string, points ...DebugPoint) error {
+
+	if len(points) == 0 {
+		points = []DebugPoint{DebugBefore}
+	}
+
+	for _, point := range points {
+		if point != DebugBefore && point != DebugAfter {
+			return fmt.Errorf("unsupported debug point '%s'", point)
+		}
+	}
+
+	d.mu.Lock()
+	d.breakpoints[taskID] = points
+	d.mu.Unlock()
+
+	return nil
+}
+
+// ClearBreakpoint removes the breakpoint of the task
+func (d *Debugger) ClearBreakpoint(taskID string) {
+	d.mu.Lock()
+	delete(d.breakpoints, taskID)
+	d.mu.Unlock()
+}
+
+// Mock replaces the evaluation of the activity of the task by the specified outputs
+func (d *Debugger) Mock(taskID string, outputs map[string]interface{}) {
+	d.mu.Lock()
+	d.mocks[taskID] = outputs
+	d.mu.Unlock()
+}
+
+// Unmock removes the mock of the task
+func (d *Debugger) Unmock(taskID string) {
+	d.mu.Lock()
+	delete(d.mocks, taskID)
+	d.mu.Unlock()
+}
+
+// Paused returns the snapshot of the paused instance, nil if it isn't paused
+func (d *Debugger) Paused() *Pause {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.paused == nil {
+		return nil
+	}
+
+	return d.paused.pause
+}
+
+// Wait waits for the instance to pause, it returns ErrDetached if the debugger is
+// detached (ex. the instance completed) or the error of the context if it's done first
+func (d *Debugger) Wait(ctx context.Context) (*Pause, error) {
+
+	for {
+		d.mu.Lock()
+		if d.paused != nil {
+			pause := d.paused.pause
+			d.mu.Unlock()
+			return pause, nil
+		}
+		if d.detached {
+			d.mu.Unlock()
+			return nil, ErrDetached
+		}
+		changed := d.changed
+		d.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Continue resumes the paused instance until its next breakpoint
+func (d *Debugger) Continue() error {
+	return d.resume(false)
+}
+
+// Step resumes the paused instance until the next debug point of any of its tasks
+func (d *Debugger) Step() error {
+	return d.resume(true)
+}
+
+// Abort aborts the instance, its paused or next evaluated task fails with an
+// InterruptError with the code 'cancelled'
+func (d *Debugger) Abort() {
+
+	d.mu.Lock()
+	d.aborted = true
+	d.mu.Unlock()
+
+	d.resume(false)
+}
+
+// Detach resumes the paused instance and stops debugging it, it is called by the flow
+// action once the instance is done
+func (d *Debugger) Detach() {
+
+	d.mu.Lock()
+	d.detached = true
+	d.notify()
+	instanceID := d.instanceID
+	d.mu.Unlock()
+
+	debuggersMu.Lock()
+	if debuggers[instanceID] == d {
+		delete(debuggers, instanceID)
+	}
+	debuggersMu.Unlock()
+
+	d.resume(false)
+}
+
+// SetInput patches an input of the task paused before its activity is evaluated
+func (d *Debugger) SetInput(name string, value interface{}) (*Pause, error) {
+	return d.exec(func(ti *TaskInst, point DebugPoint) error {
+		if point != DebugBefore {
+			return fmt.Errorf("inputs of task '%s' can only be set before its activity is evaluated", ti.task.ID())
+		}
+		return setScopeValue(ti.InputScope(), ti.task.ID(), "input", name, value)
+	})
+}
+
+// SetOutput patches an output of the task paused after its activity is evaluated
+func (d *Debugger) SetOutput(name string, value interface{}) (*Pause, error) {
+	return d.exec(func(ti *TaskInst, point DebugPoint) error {
+		if point != DebugAfter {
+			return fmt.Errorf("outputs of task '%s' can only be set after its activity is evaluated", ti.task.ID())
+		}
+		return setScopeValue(ti.OutputScope(), ti.task.ID(), "output", name, value)
+	})
+}
+
+// SetAttr patches an attribute of the flow of the paused task
+func (d *Debugger) SetAttr(name string, value interface{}) (*Pause, error) {
+	return d.exec(func(ti *TaskInst, point DebugPoint) error {
+		if ti.branch != nil {
+			return fmt.Errorf("attributes can't be set while the parallel task '%s' is paused", ti.task.ID())
+		}
+		return ti.flowInst.SetAttrValue(name, value)
+	})
+}
+
+func (d *Debugger) resume(stepping bool) error {
+
+	d.mu.Lock()
+	d.stepping = stepping
+	d.mu.Unlock()
+
+	_, err := d.exec(nil)
+	return err
+}
+
+// exec executes the command on the goroutine of the paused task, returns the updated snapshot
+func (d *Debugger) exec(apply func(ti *TaskInst, point DebugPoint) error) (*Pause, error) {
+
+	d.mu.Lock()
+	paused := d.paused
+	d.mu.Unlock()
+
+	if paused == nil {
+		return nil, ErrNotPaused
+	}
+
+	cmd := &debugCommand{apply: apply, reply: make(chan error, 1)}
+
+	select {
+	case paused.commands <- cmd:
+	case <-paused.resumed:
+		return nil, ErrNotPaused
+	}
+
+	if err := <-cmd.reply; err != nil {
+		return nil, err
+	}
+
+	return d.Paused(), nil
+}
+
+// pause pauses the task if it has a breakpoint at the point or the instance is stepping,
+// until it is resumed by the debugger
+func (d *Debugger) pause(ti *TaskInst, point DebugPoint) error {
+
+	d.pauseMu.Lock()
+	defer d.pauseMu.Unlock()
+
+	d.mu.Lock()
+
+	if d.aborted {
+		d.mu.Unlock()
+		return newInterruptError(ti.task.ID(), context.Canceled)
+	}
+
+	if d.detached || !d.shouldPause(ti.task.ID(), point) {
+		d.mu.Unlock()
+		return nil
+	}
+
+	paused := &pausedTask{pause: d.snapshot(ti, point), commands: make(chan *debugCommand), resumed: make(chan struct{})}
+	d.paused = paused
+	d.notify()
+	d.mu.Unlock()
+
+	logger.Infof("Flow instance [%s] paused %s task '%s'", d.instanceID, point, ti.task.ID())
+
+	for {
+		cmd := <-paused.commands
+		if cmd.apply == nil {
+			cmd.reply <- nil
+			break
+		}
+
+		err := cmd.apply(ti, point)
+		if err == nil {
+			d.mu.Lock()
+			paused.pause = d.snapshot(ti, point)
+			d.mu.Unlock()
+		}
+		cmd.reply <- err
+	}
+
+	d.mu.Lock()
+	d.paused = nil
+	close(paused.resumed)
+	aborted := d.aborted
+	d.mu.Unlock()
+
+	logger.Infof("Flow instance [%s] resumed", d.instanceID)
+
+	if aborted {
+		return newInterruptError(ti.task.ID(), context.Canceled)
+	}
+
+	return nil
+}
+
+func (d *Debugger) shouldPause(taskID string, point DebugPoint) bool {
+
+	if d.stepping {
+		return true
+	}
+
+	for _, bpPoint := range d.breakpoints[taskID] {
+		if bpPoint == point {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *Debugger) mock(taskID string) (outputs map[string]interface{}, mocked bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	outputs, mocked = d.mocks[taskID]
+	return outputs, mocked
+}
+
+func (d *Debugger) notify() {
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+func (d *Debugger) snapshot(ti *TaskInst, point DebugPoint) *Pause {
+
+	pause := &Pause{
+		InstanceID: d.instanceID,
+		Flow:       ti.flowInst.Name(),
+		TaskID:     ti.task.ID(),
+		Point:      point,
+		Inputs:     scopeValues(ti.InputScope()),
+		Attrs:      make(map[string]interface{}, len(ti.flowInst.attrs)),
+	}
+
+	if point == DebugAfter {
+		pause.Outputs = scopeValues(ti.OutputScope())
+	}
+
+	for name, attr := range ti.flowInst.attrs {
+		pause.Attrs[name] = attr.Value()
+	}
+
+	return pause
+}
+
+// debugBefore pauses the task before its activity is evaluated, returns true if the
+// activity is replaced by a mock
+func debugBefore(taskInst *TaskInst) (mocked bool, err error) {
+
+	debugger := taskInst.flowInst.master.debugger
+
+	if debugger == nil {
+		return false, nil
+	}
+
+	if err := debugger.pause(taskInst, DebugBefore); err != nil {
+		return false, err
+	}
+
+	outputs, mocked := debugger.mock(taskInst.task.ID())
+	if mocked {
+		logger.Debugf("Mocking activity of task '%s'", taskInst.task.ID())
+
+		for name, value := range outputs {
+			if err := setScopeValue(taskInst.OutputScope(), taskInst.task.ID(), "output", name, value); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return mocked, nil
+}
+
+// debugAfter pauses the task after its activity is evaluated
+func debugAfter(taskInst *TaskInst) error {
+
+	debugger := taskInst.flowInst.master.debugger
+
+	if debugger == nil {
+		return nil
+	}
+
+	return debugger.pause(taskInst, DebugAfter)
+}
+
+// scopeValues returns the values of the attributes of a task scope
+func scopeValues(scope data.Scope) map[string]interface{} {
+
+	values := make(map[string]interface{})
+
+	if taskScope, ok := scope.(*FixedTaskScope); ok {
+		for name := range taskScope.refAttrs {
+			if attr, exists := taskScope.GetAttr(name); exists && attr != nil {
+				values[name] = attr.Value()
+			}
+		}
+	}
+
+	return values
+}
+
+func setScopeValue(scope data.Scope, taskID, kind, name string, value interface{}) error {
+
+	if taskScope, ok := scope.(*FixedTaskScope); ok {
+		if _, declared := taskScope.refAttrs[name]; !declared {
+			return fmt.Errorf("task '%s' has no %s '%s'", taskID, kind, name)
+		}
+	}
+
+	return scope.SetAttrValue(name, value)
+}
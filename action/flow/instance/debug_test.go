@@ -0,0 +1,165 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const debugDefJSON = `
+{
+  "name": "Debug Flow",
+  "model": "test",
+  "attributes": [
+    { "name": "status", "type": "string", "value": "" }
+  ],
+  "tasks": [
+    { "id": "log_1", "activity": { "ref": "test-log", "input": { "message": "log 1" } } },
+    { "id": "log_2", "activity": { "ref": "test-log", "input": { "message": "log 2" } } }
+  ],
+  "links": [
+    { "id": 1, "from": "log_1", "to": "log_2" }
+  ]
+}
+`
+
+// startDebugInstance runs a new instance with the debugger, the returned channel is
+// closed once the instance is done
+func startDebugInstance(t *testing.T, debugger *Debugger) (*IndependentInstance, chan struct{}) {
+
+	defRep := &definition.DefinitionRep{}
+	err := json.Unmarshal([]byte(debugDefJSON), defRep)
+	assert.Nil(t, err)
+
+	def, err := definition.NewDefinition(defRep)
+	assert.Nil(t, err)
+
+	inst := NewIndependentInstance("12345", "uri", def)
+	ApplyExecOptions(inst, &ExecOptions{Debugger: debugger})
+
+	done := make(chan struct{})
+	go func() {
+		runInstance(inst)
+		debugger.Detach()
+		close(done)
+	}()
+
+	return inst, done
+}
+
+func waitPause(t *testing.T, debugger *Debugger) *Pause {
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	pause, err := debugger.Wait(ctx)
+	assert.Nil(t, err)
+	return pause
+}
+
+func TestDebuggerBreakpoints(t *testing.T) {
+
+	debugger := NewDebugger()
+	assert.Nil(t, debugger.SetBreakpoint("log_1", DebugAfter))
+	assert.Nil(t, debugger.SetBreakpoint("log_2"))
+	assert.NotNil(t, debugger.SetBreakpoint("log_2", "inside"))
+
+	inst, done := startDebugInstance(t, debugger)
+
+	pause := waitPause(t, debugger)
+	assert.Equal(t, "12345", pause.InstanceID)
+	assert.Equal(t, "log_1", pause.TaskID)
+	assert.Equal(t, DebugAfter, pause.Point)
+	assert.Equal(t, "log 1", pause.Outputs["message"])
+
+	_, err := debugger.SetInput("message", "patched")
+	assert.NotNil(t, err)
+	pause, err = debugger.SetOutput("message", "patched")
+	assert.Nil(t, err)
+	assert.Equal(t, "patched", pause.Outputs["message"])
+	_, err = debugger.SetOutput("missing", "patched")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, debugger.Continue())
+
+	pause = waitPause(t, debugger)
+	assert.Equal(t, "log_2", pause.TaskID)
+	assert.Equal(t, DebugBefore, pause.Point)
+	assert.Equal(t, "log 2", pause.Inputs["message"])
+	assert.Nil(t, pause.Outputs)
+
+	pause, err = debugger.SetInput("message", "patched")
+	assert.Nil(t, err)
+	assert.Equal(t, "patched", pause.Inputs["message"])
+	pause, err = debugger.SetAttr("status", "debugged")
+	assert.Nil(t, err)
+	assert.Equal(t, "debugged", pause.Attrs["status"])
+
+	assert.Nil(t, debugger.Continue())
+	<-done
+
+	assert.Equal(t, model.FlowStatusCompleted, inst.Status())
+	assert.Equal(t, ErrNotPaused, debugger.Continue())
+
+	_, err = debugger.Wait(context.Background())
+	assert.Equal(t, ErrDetached, err)
+}
+
+func TestDebuggerStepMock(t *testing.T) {
+
+	debugger := NewDebugger()
+	debugger.SetBreakpoint("log_1")
+	debugger.Mock("log_1", map[string]interface{}{"message": "mocked"})
+
+	inst, done := startDebugInstance(t, debugger)
+
+	pause := waitPause(t, debugger)
+	assert.Equal(t, "log_1", pause.TaskID)
+	assert.Equal(t, DebugBefore, pause.Point)
+
+	assert.Nil(t, debugger.Step())
+	pause = waitPause(t, debugger)
+	assert.Equal(t, "log_1", pause.TaskID)
+	assert.Equal(t, DebugAfter, pause.Point)
+	assert.Equal(t, "mocked", pause.Outputs["message"])
+
+	assert.Nil(t, debugger.Step())
+	pause = waitPause(t, debugger)
+	assert.Equal(t, "log_2", pause.TaskID)
+
+	assert.Nil(t, debugger.Continue())
+	<-done
+
+	assert.Equal(t, model.FlowStatusCompleted, inst.Status())
+}
+
+func TestDebuggerAbort(t *testing.T) {
+
+	debugger := NewDebugger()
+	debugger.SetBreakpoint("log_1")
+
+	inst, done := startDebugInstance(t, debugger)
+
+	waitPause(t, debugger)
+
+	registered, exists := GetDebugger("12345")
+	assert.True(t, exists)
+	assert.Equal(t, debugger, registered)
+
+	debugger.Abort()
+	<-done
+
+	assert.Equal(t, model.FlowStatusFailed, inst.Status())
+	err, ok := inst.GetError().(*InterruptError)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCodeCancelled, err.Code())
+	assert.Equal(t, "log_1", err.TaskID())
+
+	_, exists = GetDebugger("12345")
+	assert.False(t, exists)
+}
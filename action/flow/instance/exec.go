@@ -20,10 +20,11 @@ type RunOptions struct {
 	ExecOptions  *ExecOptions
 }
 
-// ExecOptions are optional Patch & Interceptor to be used during instance execution
+// ExecOptions are optional Patch, Interceptor & Debugger to be used during instance execution
 type ExecOptions struct {
 	Patch       *support.Patch
 	Interceptor *support.Interceptor
+	Debugger    *Debugger
 }
 
 // IDGenerator generates IDs for flow instances
@@ -48,6 +49,11 @@ func ApplyExecOptions(instance *IndependentInstance, execOptions *ExecOptions) {
 			instance.interceptor = execOptions.Interceptor
 			instance.interceptor.Init()
 		}
+
+		if execOptions.Debugger != nil {
+			logger.Infof("Instance [%s] has debugger", instance.ID())
+			instance.SetDebugger(execOptions.Debugger)
+		}
 	}
 }
 
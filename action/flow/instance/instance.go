@@ -31,6 +31,9 @@ type Instance struct {
 	taskInsts map[string]*TaskInst
 	linkInsts map[int]*LinkInst
 
+	// joined are the join tasks which fired before all their branches completed
+	joined map[string]bool
+
 	forceCompletion bool
 	returnData      map[string]*data.Attribute
 	returnError     error
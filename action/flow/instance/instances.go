@@ -1,9 +1,11 @@
 package instance
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"sync"
 
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
@@ -29,6 +31,19 @@ type IndependentInstance struct {
 	interceptor *support.Interceptor
 
 	subFlows map[int]*Instance
+
+	// embeddedMu serializes starting the subflows of concurrently evaluated parallel tasks
+	embeddedMu sync.Mutex
+
+	ctx         context.Context
+	interrupted bool
+
+	metrics  support.Metrics
+	tracer   Tracer
+	debugger *Debugger
+
+	// branchWrites maps the attributes merged by the parallel tasks of the current step to the task which set them
+	branchWrites map[string]string
 }
 
 // New creates a new Flow Instance from the specified Flow
@@ -155,24 +170,47 @@ func (inst *IndependentInstance) DoStep() bool {
 
 	if inst.status == model.FlowStatusActive {
 
+		inst.checkInterrupt()
+		inst.observeQueueDepth()
+
 		// get item to be worked on
 		item, ok := inst.workItemQueue.Pop()
 
 		if ok {
 			logger.Debug("Retrieved item from Flow Instance work queue")
 
-			workItem := item.(*WorkItem)
+			workItems := []*WorkItem{item.(*WorkItem)}
 
-			// get the corresponding behavior
-			behavior := inst.flowModel.GetDefaultTaskBehavior()
-			if typeID := workItem.taskInst.task.TypeID(); typeID != "" {
-				behavior = inst.flowModel.GetTaskBehavior(typeID)
+			if inst.flowDef.Parallel() {
+				// execute all the ready tasks in the same step, so their activities can be evaluated concurrently
+				for item, ok = inst.workItemQueue.Pop(); ok; item, ok = inst.workItemQueue.Pop() {
+					workItems = append(workItems, item.(*WorkItem))
+				}
+				inst.evalBranches(workItems)
 			}
 
-			// track the fact that the work item was removed from the queue
-			inst.ChangeTracker.trackWorkItem(&WorkItemQueueChange{ChgType: CtDel, ID: workItem.ID, WorkItem: workItem})
+			for i, workItem := range workItems {
+
+				if inst.status != model.FlowStatusActive {
+					// the flow is no longer active, put the remaining items back
+					for _, remaining := range workItems[i:] {
+						remaining.taskInst.branch = nil
+						inst.workItemQueue.Push(remaining)
+					}
+					break
+				}
 
-			inst.execTask(behavior, workItem.taskInst)
+				// get the corresponding behavior
+				behavior := inst.flowModel.GetDefaultTaskBehavior()
+				if typeID := workItem.taskInst.task.TypeID(); typeID != "" {
+					behavior = inst.flowModel.GetTaskBehavior(typeID)
+				}
+
+				// track the fact that the work item was removed from the queue
+				inst.ChangeTracker.trackWorkItem(&WorkItemQueueChange{ChgType: CtDel, ID: workItem.ID, WorkItem: workItem})
+
+				inst.execTask(behavior, workItem.taskInst)
+			}
 
 			hasNext = true
 		} else {
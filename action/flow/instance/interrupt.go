@@ -0,0 +1,176 @@
+package instance
+
+import (
+	"context"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+const (
+	// ErrorCodeTimeout is the error code (_E.code) of timed out flow instances and tasks
+	ErrorCodeTimeout = "timeout"
+
+	// ErrorCodeCancelled is the error code (_E.code) of cancelled flow instances
+	ErrorCodeCancelled = "cancelled"
+)
+
+// InterruptError is the error of a flow instance or task interrupted by the
+// cancellation or the deadline of its context
+type InterruptError struct {
+	taskID string
+	code   string
+}
+
+func newInterruptError(taskID string, err error) *InterruptError {
+
+	code := ErrorCodeCancelled
+	if err == context.DeadlineExceeded {
+		code = ErrorCodeTimeout
+	}
+
+	return &InterruptError{taskID: taskID, code: code}
+}
+
+// TaskID returns the id of the interrupted task, empty if the whole instance was interrupted
+func (e *InterruptError) TaskID() string {
+	return e.taskID
+}
+
+// Code returns either ErrorCodeTimeout or ErrorCodeCancelled
+func (e *InterruptError) Code() string {
+	return e.code
+}
+
+func (e *InterruptError) Error() string {
+
+	if e.taskID == "" {
+		if e.code == ErrorCodeTimeout {
+			return "flow instance timed out"
+		}
+		return "flow instance cancelled"
+	}
+
+	if e.code == ErrorCodeTimeout {
+		return "task '" + e.taskID + "' timed out"
+	}
+	return "task '" + e.taskID + "' cancelled"
+}
+
+// SetContext sets the context of the instance, the instance is interrupted when the
+// context is cancelled or its deadline expires
+func (inst *IndependentInstance) SetContext(ctx context.Context) {
+	inst.ctx = ctx
+}
+
+// Context returns the context of the instance
+func (inst *IndependentInstance) Context() context.Context {
+
+	if inst.ctx == nil {
+		return context.Background()
+	}
+
+	return inst.ctx
+}
+
+// checkInterrupt routes the instance to its error handler when its context is done
+func (inst *IndependentInstance) checkInterrupt() {
+
+	if inst.ctx == nil || inst.interrupted || inst.isHandlingError {
+		return
+	}
+
+	if inst.ctx.Err() == nil {
+		return
+	}
+
+	inst.interrupted = true
+	err := newInterruptError("", inst.ctx.Err())
+
+	logger.Infof("Flow instance [%s] interrupted: %s", inst.ID(), err.Error())
+
+	// drop the pending work, the instance won't execute it
+	for item, ok := inst.workItemQueue.Pop(); ok; item, ok = inst.workItemQueue.Pop() {
+		workItem := item.(*WorkItem)
+		inst.ChangeTracker.trackWorkItem(&WorkItemQueueChange{ChgType: CtDel, ID: workItem.ID, WorkItem: workItem})
+	}
+
+	inst.appendInterruptData(err)
+	inst.HandleGlobalError(inst.Instance, err)
+}
+
+func (inst *Instance) appendInterruptData(err *InterruptError) {
+	inst.AddAttr("_E.activity", data.TypeString, err.TaskID())
+	inst.AddAttr("_E.message", data.TypeString, err.Error())
+	inst.AddAttr("_E.type", data.TypeString, "interrupt")
+	inst.AddAttr("_E.data", data.TypeObject, nil)
+	inst.AddAttr("_E.code", data.TypeString, err.Code())
+}
+
+// Context returns the context of the evaluation of the activity of the task, bounded by
+// the timeout of the task.  Activities supporting cancellation can get it from their
+// context using interface{ Context() context.Context }.
+func (ti *TaskInst) Context() context.Context {
+
+	ti.ctxMu.Lock()
+	ctx := ti.ctx
+	ti.ctxMu.Unlock()
+
+	if ctx != nil {
+		return ctx
+	}
+
+	if ti.flowInst.isHandlingError {
+		// the error handler isn't interrupted
+		return context.Background()
+	}
+
+	return ti.flowInst.master.Context()
+}
+
+func (ti *TaskInst) setContext(ctx context.Context) {
+	ti.ctxMu.Lock()
+	ti.ctx = ctx
+	ti.ctxMu.Unlock()
+}
+
+// evalWithContext evaluates the activity with a context bounded by the timeout of the
+// task, and carrying the span of the task if the instance has a Tracer.  The activity is
+// evaluated by the goroutine of the instance, so it should stop once its context is
+// done: the task then fails with an InterruptError, whatever the activity returned.
+func (ti *TaskInst) evalWithContext(eval func() (bool, error)) (done bool, err error) {
+
+	start := time.Now()
+	defer ti.observeActivity(start)
+
+	timeout := ti.task.Timeout()
+	tracer := ti.flowInst.master.tracer
+
+	if timeout <= 0 && tracer == nil {
+		done, err = eval()
+	} else {
+		ctx, cancel := ti.Context(), context.CancelFunc(func() {})
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		defer cancel()
+
+		if tracer != nil {
+			var span Span
+			ctx, span = ti.startTaskSpan(ctx)
+			defer func() { span.Finish(err) }()
+		}
+
+		ti.setContext(ctx)
+		defer ti.setContext(nil)
+
+		done, err = eval()
+	}
+
+	if ctxErr := ti.Context().Err(); ctxErr != nil {
+		return false, newInterruptError(ti.task.ID(), ctxErr)
+	}
+
+	return done, err
+}
@@ -0,0 +1,140 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/core/activity"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+const timeoutDefJSON = `
+{
+  "name": "Timeout Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "log_1", "timeout": "%s", "activity": { "ref": "test-log", "input": { "message": "log 1" } } },
+    { "id": "log_2", "activity": { "ref": "test-log", "input": { "message": "log 2" } } }
+  ],
+  "links": [
+    { "id": 1, "from": "log_1", "to": "log_2" }
+  ]
+}
+`
+
+func newTimeoutInstance(t *testing.T, taskTimeout string) *IndependentInstance {
+
+	defRep := &definition.DefinitionRep{}
+	err := json.Unmarshal([]byte(fmt.Sprintf(timeoutDefJSON, taskTimeout)), defRep)
+	assert.Nil(t, err)
+
+	def, err := definition.NewDefinition(defRep)
+	assert.Nil(t, err)
+
+	return NewIndependentInstance("12345", "uri", def)
+}
+
+func TestInstanceCancelled(t *testing.T) {
+
+	inst := newTimeoutInstance(t, "1m")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	inst.SetContext(ctx)
+
+	inst.Start(nil)
+	assert.True(t, inst.DoStep())
+
+	cancel()
+	runInstance(inst)
+
+	assert.Equal(t, model.FlowStatusFailed, inst.Status())
+	err, ok := inst.GetError().(*InterruptError)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCodeCancelled, err.Code())
+	assert.Equal(t, "flow instance cancelled", err.Error())
+
+	attr, _ := inst.GetAttr("_E.code")
+	assert.Equal(t, ErrorCodeCancelled, attr.Value())
+}
+
+func TestTaskTimeout(t *testing.T) {
+
+	inst := newTimeoutInstance(t, "1ns")
+	runInstance(inst)
+
+	assert.Equal(t, model.FlowStatusFailed, inst.Status())
+	err, ok := inst.GetError().(*InterruptError)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCodeTimeout, err.Code())
+	assert.Equal(t, "task 'log_1' timed out", err.Error())
+
+	inst = newTimeoutInstance(t, "1m")
+	runInstance(inst)
+
+	assert.Equal(t, model.FlowStatusCompleted, inst.Status())
+}
+
+// waitingActivity waits for its context to be done, or else for its delay, before
+// setting its output
+type waitingActivity struct {
+	metadata *activity.Metadata
+}
+
+func (a *waitingActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *waitingActivity) Eval(ctx activity.Context) (done bool, err error) {
+
+	delay, _ := time.ParseDuration(ctx.GetInput("delay").(string))
+
+	if delay > 0 {
+		time.Sleep(delay)
+	} else {
+		<-ctx.(interface{ Context() context.Context }).Context().Done()
+	}
+
+	ctx.SetOutput("message", "late")
+	return true, nil
+}
+
+func init() {
+	activity.Register(&waitingActivity{metadata: &activity.Metadata{
+		ID:     "test-wait",
+		Input:  map[string]*data.Attribute{"delay": data.NewZeroAttribute("delay", data.TypeString)},
+		Output: map[string]*data.Attribute{"message": data.NewZeroAttribute("message", data.TypeString)},
+	}})
+}
+
+func TestTaskTimeoutWaitsForActivity(t *testing.T) {
+
+	// the activity sets its output after the timeout of its task, once its context is
+	// done or else once its delay elapsed
+	for _, delay := range []string{"", "50ms"} {
+
+		timeoutJSON := strings.Replace(timeoutDefJSON, `"ref": "test-log", "input": { "message": "log 1" }`, `"ref": "test-wait", "input": { "delay": "`+delay+`" }`, 1)
+
+		defRep := &definition.DefinitionRep{}
+		err := json.Unmarshal([]byte(fmt.Sprintf(timeoutJSON, "20ms")), defRep)
+		assert.Nil(t, err)
+
+		def, err := definition.NewDefinition(defRep)
+		assert.Nil(t, err)
+
+		inst := NewIndependentInstance("12345", "uri", def)
+		runInstance(inst)
+
+		assert.Equal(t, model.FlowStatusFailed, inst.Status())
+		ierr, ok := inst.GetError().(*InterruptError)
+		assert.True(t, ok)
+		assert.Equal(t, ErrorCodeTimeout, ierr.Code())
+		assert.Equal(t, "task 'log_1' timed out", ierr.Error())
+	}
+}
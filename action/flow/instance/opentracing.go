@@ -0,0 +1,64 @@
+package instance
+
+import (
+	"context"
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// HeaderFunc returns the header of the request carried by the context, if any (ex.
+// rest.HeaderFromContext)
+type HeaderFunc func(ctx context.Context) (http.Header, bool)
+
+// OpenTracingTracer is a Tracer reporting the spans using an OpenTracing tracer
+type OpenTracingTracer struct {
+	tracer opentracing.Tracer
+	header HeaderFunc
+}
+
+// NewOpenTracingTracer creates an OpenTracingTracer reporting the spans using the
+// tracer.  A span without a parent in its context continues the trace propagated in
+// the header returned by header, which can be nil.
+func NewOpenTracingTracer(tracer opentracing.Tracer, header HeaderFunc) *OpenTracingTracer {
+	return &OpenTracingTracer{tracer: tracer, header: header}
+}
+
+// StartSpan implements Tracer.StartSpan
+func (t *OpenTracingTracer) StartSpan(ctx context.Context, name string, tags map[string]string) (context.Context, Span) {
+
+	var opts []opentracing.StartSpanOption
+
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	} else if t.header != nil {
+		if header, ok := t.header(ctx); ok {
+			parent, err := t.tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+			if err == nil {
+				opts = append(opts, ext.RPCServerOption(parent))
+			}
+		}
+	}
+
+	for name, value := range tags {
+		opts = append(opts, opentracing.Tag{Key: name, Value: value})
+	}
+
+	span := t.tracer.StartSpan(name, opts...)
+	return opentracing.ContextWithSpan(ctx, span), &openTracingSpan{span}
+}
+
+type openTracingSpan struct {
+	span opentracing.Span
+}
+
+func (s *openTracingSpan) Finish(err error) {
+
+	if err != nil {
+		ext.Error.Set(s.span, true)
+		s.span.SetTag("error.message", err.Error())
+	}
+
+	s.span.Finish()
+}
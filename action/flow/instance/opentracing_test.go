@@ -0,0 +1,55 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+)
+
+type testHeaderKey struct{}
+
+func testHeader(ctx context.Context) (http.Header, bool) {
+	header, ok := ctx.Value(testHeaderKey{}).(http.Header)
+	return header, ok
+}
+
+func TestOpenTracingTracer(t *testing.T) {
+
+	mock := mocktracer.New()
+
+	// the span of the request propagated by the trigger
+	request := mock.StartSpan("request")
+	header := http.Header{}
+	assert.Nil(t, mock.Inject(request.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)))
+
+	tracer := NewOpenTracingTracer(mock, testHeader)
+
+	ctx := context.WithValue(context.Background(), testHeaderKey{}, header)
+	ctx, flowSpan := tracer.StartSpan(ctx, "flow", map[string]string{TagFlow: "flow"})
+	_, taskSpan := tracer.StartSpan(ctx, "task", map[string]string{TagTask: "task"})
+
+	taskSpan.Finish(errors.New("task failed"))
+	flowSpan.Finish(nil)
+
+	spans := mock.FinishedSpans()
+	assert.Len(t, spans, 2)
+
+	task, flow := spans[0], spans[1]
+	assert.Equal(t, "task", task.OperationName)
+	assert.Equal(t, "flow", flow.OperationName)
+
+	requestCtx := request.Context().(mocktracer.MockSpanContext)
+	assert.Equal(t, requestCtx.TraceID, flow.SpanContext.TraceID)
+	assert.Equal(t, requestCtx.SpanID, flow.ParentID)
+	assert.Equal(t, flow.SpanContext.SpanID, task.ParentID)
+
+	assert.Equal(t, "flow", flow.Tag(TagFlow))
+	assert.Nil(t, flow.Tag("error"))
+	assert.Equal(t, true, task.Tag("error"))
+	assert.Equal(t, "task failed", task.Tag("error.message"))
+}
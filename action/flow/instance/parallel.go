@@ -0,0 +1,185 @@
+package instance
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// branchResult is the result of the evaluation of the activity of a task executed
+// concurrently with the other branches of a parallel flow
+type branchResult struct {
+	done  bool
+	err   error
+	scope *branchScope
+}
+
+// branchScope is the scope the outputs of a concurrently executed activity are mapped
+// to, isolating them from the other branches until the task is executed
+type branchScope struct {
+	parent data.Scope
+	attrs  map[string]*branchAttr
+	order  []string
+}
+
+type branchAttr struct {
+	attr  *data.Attribute
+	added bool
+}
+
+func newBranchScope(parent data.Scope) *branchScope {
+	return &branchScope{parent: parent, attrs: make(map[string]*branchAttr)}
+}
+
+// GetAttr implements data.Scope.GetAttr
+func (s *branchScope) GetAttr(attrName string) (attr *data.Attribute, exists bool) {
+
+	if set, ok := s.attrs[attrName]; ok {
+		return set.attr, true
+	}
+
+	return s.parent.GetAttr(attrName)
+}
+
+// SetAttrValue implements data.Scope.SetAttrValue
+func (s *branchScope) SetAttrValue(attrName string, value interface{}) error {
+
+	existingAttr, exists := s.GetAttr(attrName)
+	if !exists {
+		return fmt.Errorf("Attr [%s] does not exists", attrName)
+	}
+
+	attr, err := data.NewAttribute(attrName, existingAttr.Type(), value)
+	if err != nil {
+		return err
+	}
+
+	s.set(attr, false)
+	return nil
+}
+
+// AddAttr implements data.MutableScope.AddAttr
+func (s *branchScope) AddAttr(attrName string, attrType data.Type, value interface{}) *data.Attribute {
+
+	attr, _ := data.NewAttribute(attrName, attrType, value)
+	s.set(attr, true)
+
+	return attr
+}
+
+func (s *branchScope) set(attr *data.Attribute, added bool) {
+
+	if set, ok := s.attrs[attr.Name()]; ok {
+		set.attr = attr
+		set.added = set.added || added
+		return
+	}
+
+	s.attrs[attr.Name()] = &branchAttr{attr: attr, added: added}
+	s.order = append(s.order, attr.Name())
+}
+
+// merge merges the attributes set by the task into the flow instance, writes maps the
+// attributes already merged by the other branches to the task which set them
+func (s *branchScope) merge(taskID string, target *Instance, policy string, writes map[string]string) error {
+
+	if policy == definition.MergeError {
+		for _, name := range s.order {
+			if writer, written := writes[name]; written && writer != taskID {
+				return fmt.Errorf("attribute '%s' is set by parallel tasks '%s' and '%s'", name, writer, taskID)
+			}
+		}
+	}
+
+	for _, name := range s.order {
+
+		if writer, written := writes[name]; written && writer != taskID && policy == definition.MergeKeep {
+			logger.Debugf("Keeping attribute '%s' set by parallel task '%s', ignoring task '%s'", name, writer, taskID)
+			continue
+		}
+		writes[name] = taskID
+
+		set := s.attrs[name]
+		if set.added {
+			target.AddAttr(name, set.attr.Type(), set.attr.Value())
+		} else if err := target.SetAttrValue(name, set.attr.Value()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isBranch determines if the task can be evaluated concurrently with the other ready
+// tasks of the flow, only the activities of basic tasks of parallel flows are
+func isBranch(taskInst *TaskInst) bool {
+
+	return taskInst.flowInst.flowDef.Parallel() && taskInst.status == model.TaskStatusReady &&
+		taskInst.task.TypeID() == "" && taskInst.task.ActivityConfig() != nil
+}
+
+// evalBranches concurrently evaluates the activities of the ready tasks of parallel
+// flows, the results are picked up (and the outputs merged) by EvalActivity when the
+// tasks are executed.  It returns once all the activities completed, so the branches
+// advance in lockstep: the next tasks of a branch are only entered in the next step.
+// Activities of concurrent branches should only read the flow and their own inputs
+// and outputs, starting subflows is synchronized by the master instance.
+func (inst *IndependentInstance) evalBranches(workItems []*WorkItem) {
+
+	var branches []*TaskInst
+	seen := make(map[*TaskInst]bool, len(workItems))
+
+	for _, workItem := range workItems {
+		if !seen[workItem.taskInst] && isBranch(workItem.taskInst) {
+			branches = append(branches, workItem.taskInst)
+		}
+		seen[workItem.taskInst] = true
+	}
+
+	if len(branches) < 2 {
+		return
+	}
+
+	logger.Debugf("Evaluating %d parallel tasks", len(branches))
+
+	inst.branchWrites = make(map[string]string)
+
+	var wg sync.WaitGroup
+	wg.Add(len(branches))
+
+	for _, taskInst := range branches {
+		taskInst.branch = &branchResult{scope: newBranchScope(taskInst.flowInst)}
+
+		go func(taskInst *TaskInst) {
+			defer wg.Done()
+			taskInst.branch.done, taskInst.branch.err = taskInst.evalActivity()
+		}(taskInst)
+	}
+
+	wg.Wait()
+}
+
+// mergeBranch returns the result of the concurrent evaluation of the activity of the
+// task, merging its outputs into the flow according to the merge policy of the flow
+func (ti *TaskInst) mergeBranch(branch *branchResult) (done bool, err error) {
+
+	if branch.err != nil || !branch.done {
+		return branch.done, branch.err
+	}
+
+	writes := ti.flowInst.master.branchWrites
+	if writes == nil {
+		writes = make(map[string]string)
+	}
+
+	err = branch.scope.merge(ti.task.ID(), ti.flowInst, ti.flowInst.flowDef.ParallelMerge(), writes)
+	if err != nil {
+		return true, NewActivityEvalError(ti.task.Name(), "merge", err.Error())
+	}
+
+	return true, nil
+}
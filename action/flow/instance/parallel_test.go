@@ -0,0 +1,174 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/support"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/TIBCOSoftware/flogo-lib/core/activity"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+const parallelDefJSON = `
+{
+  "name": "Parallel Flow",
+  "model": "test",
+  "parallel": {"merge": "%s"},
+  "attributes": [
+    { "name": "status", "type": "string", "value": "" }
+  ],
+  "tasks": [
+    { "id": "split", "activity": { "ref": "test-log", "input": { "message": "split" } } },
+    { "id": "branch_1", "activity": { "ref": "test-log", "input": { "message": "branch 1" } } },
+    { "id": "branch_2", "activity": { "ref": "test-log", "input": { "message": "branch 2" } } },
+    { "id": "join", "type": "join", "settings": { "wait": %s } }
+  ],
+  "links": [
+    { "id": 1, "from": "split", "to": "branch_1" },
+    { "id": 2, "from": "split", "to": "branch_2" },
+    { "id": 3, "from": "branch_1", "to": "join" },
+    { "id": 4, "from": "branch_2", "to": "join" }
+  ]
+}
+`
+
+func newParallelInstance(t *testing.T, merge string, wait string) *IndependentInstance {
+
+	defRep := &definition.DefinitionRep{}
+	err := json.Unmarshal([]byte(fmt.Sprintf(parallelDefJSON, merge, wait)), defRep)
+	assert.Nil(t, err)
+
+	def, err := definition.NewDefinition(defRep)
+	assert.Nil(t, err)
+
+	return NewIndependentInstance("12345", "uri", def)
+}
+
+func runInstance(inst *IndependentInstance) {
+
+	inst.Start(nil)
+	for inst.DoStep() && inst.Status() < model.FlowStatusCompleted {
+	}
+}
+
+func TestParallelJoin(t *testing.T) {
+
+	for _, wait := range []string{`"all"`, `1`} {
+		inst := newParallelInstance(t, "overwrite", wait)
+		runInstance(inst)
+
+		assert.Equal(t, model.FlowStatusCompleted, inst.Status())
+		assert.Len(t, inst.taskInsts, 0)
+	}
+}
+
+func TestBranchScopeMerge(t *testing.T) {
+
+	inst := newParallelInstance(t, "error", `"all"`)
+	writes := make(map[string]string)
+
+	first := newBranchScope(inst.Instance)
+	assert.Nil(t, first.SetAttrValue("status", "first"))
+	assert.NotNil(t, first.SetAttrValue("missing", "first"))
+	first.AddAttr("added", data.TypeString, "value")
+
+	attr, _ := inst.GetAttr("status")
+	assert.Equal(t, "", attr.Value())
+
+	assert.Nil(t, first.merge("branch_1", inst.Instance, definition.MergeError, writes))
+	attr, _ = inst.GetAttr("status")
+	assert.Equal(t, "first", attr.Value())
+	_, exists := inst.GetAttr("added")
+	assert.True(t, exists)
+
+	second := newBranchScope(inst.Instance)
+	assert.Nil(t, second.SetAttrValue("status", "second"))
+
+	err := second.merge("branch_2", inst.Instance, definition.MergeError, writes)
+	assert.NotNil(t, err)
+	assert.Equal(t, "attribute 'status' is set by parallel tasks 'branch_1' and 'branch_2'", err.Error())
+
+	assert.Nil(t, second.merge("branch_2", inst.Instance, definition.MergeKeep, writes))
+	attr, _ = inst.GetAttr("status")
+	assert.Equal(t, "first", attr.Value())
+
+	assert.Nil(t, second.merge("branch_2", inst.Instance, definition.MergeOverwrite, writes))
+	attr, _ = inst.GetAttr("status")
+	assert.Equal(t, "second", attr.Value())
+}
+
+const parallelSubFlowDefJSON = `
+{
+  "name": "Parallel SubFlows",
+  "model": "test",
+  "parallel": {"merge": "overwrite"},
+  "tasks": [
+    { "id": "split", "activity": { "ref": "test-log", "input": { "message": "split" } } },
+    { "id": "sub_1", "activity": { "ref": "test-start-subflow" } },
+    { "id": "sub_2", "activity": { "ref": "test-start-subflow" } },
+    { "id": "join", "type": "join", "settings": { "wait": "all" } }
+  ],
+  "links": [
+    { "id": 1, "from": "split", "to": "sub_1" },
+    { "id": 2, "from": "split", "to": "sub_2" },
+    { "id": 3, "from": "sub_1", "to": "join" },
+    { "id": 4, "from": "sub_2", "to": "join" }
+  ]
+}
+`
+
+const parallelSubFlowJSON = `
+{
+  "name": "SubFlow",
+  "model": "test",
+  "tasks": [
+    { "id": "log", "activity": { "ref": "test-log", "input": { "message": "subflow" } } }
+  ]
+}
+`
+
+func init() {
+	metadata := &activity.Metadata{ID: "test-start-subflow", Output: make(map[string]*data.Attribute)}
+	activity.Register(&startSubFlowActivity{metadata: metadata})
+}
+
+// startSubFlowActivity starts the 'res://flow:parallel_sub' subflow
+type startSubFlowActivity struct {
+	metadata *activity.Metadata
+}
+
+func (a *startSubFlowActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *startSubFlowActivity) Eval(ctx activity.Context) (done bool, err error) {
+	return false, StartSubFlow(ctx, "res://flow:parallel_sub", nil)
+}
+
+// TestParallelSubFlows is meant to be run with -race, the subflows are started by
+// concurrently evaluated tasks
+func TestParallelSubFlows(t *testing.T) {
+
+	fm := support.NewFlowManager(nil)
+	err := fm.LoadResource(&resource.Config{ID: "flow:parallel_sub", Data: []byte(parallelSubFlowJSON)})
+	assert.Nil(t, err)
+
+	defRep := &definition.DefinitionRep{}
+	err = json.Unmarshal([]byte(parallelSubFlowDefJSON), defRep)
+	assert.Nil(t, err)
+
+	def, err := definition.NewDefinition(defRep)
+	assert.Nil(t, err)
+
+	inst := NewIndependentInstance("12345", "uri", def)
+	runInstance(inst)
+
+	assert.Equal(t, model.FlowStatusCompleted, inst.Status())
+	assert.Equal(t, 2, inst.subFlowCtr)
+	assert.Len(t, inst.subFlows, 0)
+}
@@ -0,0 +1,358 @@
+package instance
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultStateKeyPrefix is the prefix of the keys the RedisStateStore keeps the states
+// in if none is specified
+const DefaultStateKeyPrefix = "flow:instance:"
+
+// DefaultRedisDialTimeout is the timeout of the connections to redis if none is specified
+const DefaultRedisDialTimeout = 10 * time.Second
+
+// RedisOptions are the options of a RedisStateStore
+type RedisOptions struct {
+	// Addr is the host:port of the redis server
+	Addr string
+
+	// Password authenticates the connections if set
+	Password string
+
+	// DB is the database the states are kept in
+	DB int
+
+	// KeyPrefix is the prefix of the keys of the states, DefaultStateKeyPrefix is used
+	// if not set.  The state of an instance is kept in <prefix>state:<id> and the ids of
+	// the instances in the <prefix>ids set.
+	KeyPrefix string
+
+	// DialTimeout is the timeout of the connections, DefaultRedisDialTimeout is used
+	// if not set
+	DialTimeout time.Duration
+}
+
+// RedisStateStore is a StateStore keeping the states in redis, it speaks the redis
+// protocol over a single connection that is dialed again if it fails
+type RedisStateStore struct {
+	options RedisOptions
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// redisError is an error reply of the redis server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedisStateStore creates a RedisStateStore, the server is connected to on first use
+func NewRedisStateStore(options RedisOptions) (*RedisStateStore, error) {
+
+	if options.Addr == "" {
+		return nil, errors.New("redis address not specified")
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = DefaultStateKeyPrefix
+	}
+	if options.DialTimeout == 0 {
+		options.DialTimeout = DefaultRedisDialTimeout
+	}
+
+	return &RedisStateStore{options: options}, nil
+}
+
+// Save implements StateStore.Save
+func (s *RedisStateStore) Save(id string, state []byte) error {
+	_, err := s.transaction(
+		[]interface{}{"SET", s.stateKey(id), state},
+		[]interface{}{"SADD", s.idsKey(), id},
+	)
+	return err
+}
+
+// Load implements StateStore.Load
+func (s *RedisStateStore) Load(id string) ([]byte, error) {
+
+	reply, err := s.do([]interface{}{"GET", s.stateKey(id)})
+	if err != nil || reply == nil {
+		return nil, err
+	}
+
+	state, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+
+	return state, nil
+}
+
+// List implements StateStore.List
+func (s *RedisStateStore) List() ([]string, error) {
+
+	reply, err := s.do([]interface{}{"SMEMBERS", s.idsKey()})
+	if err != nil {
+		return nil, err
+	}
+
+	members, _ := reply.([]interface{})
+
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		id, ok := member.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unexpected redis reply %v", reply)
+		}
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// Delete implements StateStore.Delete
+func (s *RedisStateStore) Delete(id string) error {
+	_, err := s.transaction(
+		[]interface{}{"DEL", s.stateKey(id)},
+		[]interface{}{"SREM", s.idsKey(), id},
+	)
+	return err
+}
+
+// Close closes the connection to the server
+func (s *RedisStateStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn, s.rd = nil, nil
+	return err
+}
+
+func (s *RedisStateStore) stateKey(id string) string {
+	return s.options.KeyPrefix + "state:" + id
+}
+
+func (s *RedisStateStore) idsKey() string {
+	return s.options.KeyPrefix + "ids"
+}
+
+// do sends the command and returns its reply
+func (s *RedisStateStore) do(cmd []interface{}) (interface{}, error) {
+
+	replies, err := s.pipeline(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if err, ok := replies[0].(redisError); ok {
+		return nil, err
+	}
+
+	return replies[0], nil
+}
+
+// transaction sends the commands in a MULTI/EXEC transaction, so they are applied
+// together, and returns their replies
+func (s *RedisStateStore) transaction(cmds ...[]interface{}) ([]interface{}, error) {
+
+	pipeline := append([][]interface{}{{"MULTI"}}, cmds...)
+	pipeline = append(pipeline, []interface{}{"EXEC"})
+
+	replies, err := s.pipeline(pipeline...)
+	if err != nil {
+		return nil, err
+	}
+
+	// a command rejected when queued aborts the transaction
+	for _, reply := range replies[:len(replies)-1] {
+		if err, ok := reply.(redisError); ok {
+			return nil, err
+		}
+	}
+
+	results, ok := replies[len(replies)-1].([]interface{})
+	if !ok {
+		return nil, errors.New("redis: transaction aborted")
+	}
+	for _, result := range results {
+		if err, ok := result.(redisError); ok {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// pipeline sends the commands and reads their replies, the error replies are returned
+// as redisError replies.  The connection is closed on a network error, so the next
+// commands are sent on a new connection.
+func (s *RedisStateStore) pipeline(cmds ...[]interface{}) ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	replies, err := s.roundTrip(cmds...)
+	if err != nil {
+		s.conn.Close()
+		s.conn, s.rd = nil, nil
+		return nil, err
+	}
+
+	return replies, nil
+}
+
+// connect dials the server, authenticates and selects the database of the states
+func (s *RedisStateStore) connect() error {
+
+	conn, err := net.DialTimeout("tcp", s.options.Addr, s.options.DialTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn, s.rd = conn, bufio.NewReader(conn)
+
+	var setup [][]interface{}
+	if s.options.Password != "" {
+		setup = append(setup, []interface{}{"AUTH", s.options.Password})
+	}
+	if s.options.DB != 0 {
+		setup = append(setup, []interface{}{"SELECT", s.options.DB})
+	}
+
+	if len(setup) > 0 {
+		replies, err := s.roundTrip(setup...)
+		if err == nil {
+			for _, reply := range replies {
+				if replyErr, ok := reply.(redisError); ok {
+					err = replyErr
+					break
+				}
+			}
+		}
+		if err != nil {
+			conn.Close()
+			s.conn, s.rd = nil, nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *RedisStateStore) roundTrip(cmds ...[]interface{}) ([]interface{}, error) {
+
+	var buf []byte
+	for _, cmd := range cmds {
+		buf = appendCommand(buf, cmd)
+	}
+
+	if _, err := s.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(cmds))
+	for i := range cmds {
+		reply, err := readReply(s.rd)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+
+	return replies, nil
+}
+
+// appendCommand appends the command encoded as an array of bulk strings
+func appendCommand(buf []byte, cmd []interface{}) []byte {
+
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(cmd)), 10)
+	buf = append(buf, '\r', '\n')
+
+	for _, arg := range cmd {
+		var value []byte
+		switch arg := arg.(type) {
+		case []byte:
+			value = arg
+		case string:
+			value = []byte(arg)
+		case int:
+			value = strconv.AppendInt(nil, int64(arg), 10)
+		}
+
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(value)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, value...)
+		buf = append(buf, '\r', '\n')
+	}
+
+	return buf
+}
+
+// readReply reads a reply, a status is returned as a string, an integer as an int64, a
+// bulk string as a []byte, an array as a []interface{} and an error as a redisError
+func readReply(rd *bufio.Reader) (interface{}, error) {
+
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply '%s'", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, value); err != nil {
+			return nil, err
+		}
+		return value[:size], nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, size)
+		for i := range values {
+			if values[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("redis: invalid reply '%s'", line)
+}
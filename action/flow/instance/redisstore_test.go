@@ -0,0 +1,213 @@
+package instance
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// redisServer is a redis server emulating the commands of the RedisStateStore
+type redisServer struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	strings  map[string][]byte
+	sets     map[string]map[string]bool
+	commands []string
+	conns    []net.Conn
+}
+
+func startRedisServer(t *testing.T, password string) *redisServer {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	server := &redisServer{listener: listener, password: password, strings: make(map[string][]byte), sets: make(map[string]map[string]bool)}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+// dropConnections closes the connections of the clients
+func (s *redisServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *redisServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	var queued [][]string
+
+	for {
+		reply, err := readReply(rd)
+		if err != nil {
+			return
+		}
+
+		var cmd []string
+		for _, arg := range reply.([]interface{}) {
+			cmd = append(cmd, string(arg.([]byte)))
+		}
+
+		name := strings.ToUpper(cmd[0])
+		s.mu.Lock()
+		s.commands = append(s.commands, name)
+		s.mu.Unlock()
+
+		var out []byte
+		switch {
+		case name == "AUTH":
+			authenticated = cmd[1] == s.password
+			if authenticated {
+				out = []byte("+OK\r\n")
+			} else {
+				out = []byte("-ERR invalid password\r\n")
+			}
+		case !authenticated:
+			out = []byte("-NOAUTH Authentication required.\r\n")
+		case name == "SELECT":
+			out = []byte("+OK\r\n")
+		case name == "MULTI":
+			queued = [][]string{}
+			out = []byte("+OK\r\n")
+		case name == "EXEC":
+			out = []byte("*" + strconv.Itoa(len(queued)) + "\r\n")
+			for _, queuedCmd := range queued {
+				out = append(out, s.exec(queuedCmd)...)
+			}
+			queued = nil
+		case queued != nil:
+			queued = append(queued, cmd)
+			out = []byte("+QUEUED\r\n")
+		default:
+			out = s.exec(cmd)
+		}
+
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+func (s *redisServer) exec(cmd []string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(cmd[0]) {
+	case "SET":
+		s.strings[cmd[1]] = []byte(cmd[2])
+		return []byte("+OK\r\n")
+	case "GET":
+		value, exists := s.strings[cmd[1]]
+		if !exists {
+			return []byte("$-1\r\n")
+		}
+		return appendCommand(nil, []interface{}{value})[4:]
+	case "DEL":
+		delete(s.strings, cmd[1])
+		return []byte(":1\r\n")
+	case "SADD":
+		if s.sets[cmd[1]] == nil {
+			s.sets[cmd[1]] = make(map[string]bool)
+		}
+		s.sets[cmd[1]][cmd[2]] = true
+		return []byte(":1\r\n")
+	case "SREM":
+		delete(s.sets[cmd[1]], cmd[2])
+		return []byte(":1\r\n")
+	case "SMEMBERS":
+		var members []interface{}
+		for member := range s.sets[cmd[1]] {
+			members = append(members, member)
+		}
+		return appendCommand(nil, members)
+	}
+
+	return []byte("-ERR unknown command '" + cmd[0] + "'\r\n")
+}
+
+func TestRedisStateStore(t *testing.T) {
+
+	server := startRedisServer(t, "secret")
+	defer server.listener.Close()
+
+	_, err := NewRedisStateStore(RedisOptions{})
+	assert.NotNil(t, err)
+
+	store, err := NewRedisStateStore(RedisOptions{Addr: server.listener.Addr().String(), Password: "secret", DB: 2})
+	assert.Nil(t, err)
+	defer store.Close()
+
+	assert.Nil(t, store.Save("2", []byte(`{"id":"2"}`)))
+	assert.Nil(t, store.Save("1", []byte(`{"id":"1"}`)))
+	assert.Nil(t, store.Save("1", []byte(`{"id":"1","status":100}`)))
+
+	state, err := store.Load("1")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"1","status":100}`, string(state))
+
+	ids, err := store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids)
+
+	assert.Nil(t, store.Delete("1"))
+
+	state, err = store.Load("1")
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+
+	ids, err = store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, ids)
+
+	assert.Contains(t, server.strings, "flow:instance:state:2")
+	assert.Equal(t, []string{"AUTH", "SELECT", "MULTI", "SET", "SADD", "EXEC"}, server.commands[:6])
+
+	// the store connects again once the connection is lost
+	server.dropConnections()
+	_, err = store.Load("2")
+	assert.NotNil(t, err)
+
+	state, err = store.Load("2")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"2"}`, string(state))
+}
+
+func TestRedisStateStoreAuthError(t *testing.T) {
+
+	server := startRedisServer(t, "secret")
+	defer server.listener.Close()
+
+	store, err := NewRedisStateStore(RedisOptions{Addr: server.listener.Addr().String(), Password: "wrong"})
+	assert.Nil(t, err)
+	defer store.Close()
+
+	err = store.Save("1", []byte(`{}`))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid password")
+}
@@ -0,0 +1,29 @@
+package instance
+
+import (
+	"context"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+)
+
+type replyHandlerKey struct{}
+
+// NewReplyContext returns a child context whose flow instances send their replies to the
+// handler as they are produced, the results of the instances still go to the result
+// handler of the action
+func NewReplyContext(ctx context.Context, handler action.ResultHandler) context.Context {
+	return context.WithValue(ctx, replyHandlerKey{}, handler)
+}
+
+// ReplyResultHandler returns the handler the replies of an instance run with the context
+// are sent to, the result handler of the action if the context has no reply handler
+func ReplyResultHandler(ctx context.Context, handler action.ResultHandler) action.ResultHandler {
+
+	if ctx != nil {
+		if replyHandler, ok := ctx.Value(replyHandlerKey{}).(action.ResultHandler); ok {
+			return replyHandler
+		}
+	}
+
+	return handler
+}
@@ -0,0 +1,38 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+type testResultHandler struct {
+	results []map[string]*data.Attribute
+}
+
+func (rh *testResultHandler) HandleResult(resultData map[string]*data.Attribute, err error) {
+	rh.results = append(rh.results, resultData)
+}
+
+func (rh *testResultHandler) Done() {
+}
+
+func TestReplyResultHandler(t *testing.T) {
+
+	handler := &testResultHandler{}
+	replies := &testResultHandler{}
+
+	assert.Equal(t, handler, ReplyResultHandler(context.Background(), handler))
+	assert.Equal(t, replies, ReplyResultHandler(NewReplyContext(context.Background(), replies), handler))
+
+	// the replies of the instance go to the reply handler of the context
+	inst := &Instance{}
+	inst.SetResultHandler(ReplyResultHandler(NewReplyContext(context.Background(), replies), handler))
+	inst.ReplyHandler().Reply(200, "reply", nil)
+
+	assert.Empty(t, handler.results)
+	assert.Len(t, replies.results, 1)
+	assert.Equal(t, "reply", replies.results[0]["data"].Value())
+}
@@ -102,6 +102,11 @@ func (s *FixedTaskScope) GetAttr(attrName string) (attr *data.Attribute, exists
 	return nil, false
 }
 
+// Metadata implements definition.MetadataScope.Metadata
+func (s *FixedTaskScope) Metadata() map[string]*data.Attribute {
+	return s.refAttrs
+}
+
 // SetAttrValue implements Scope.SetAttrValue
 func (s *FixedTaskScope) SetAttrValue(attrName string, value interface{}) error {
 
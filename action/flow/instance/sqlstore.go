@@ -0,0 +1,90 @@
+package instance
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+// DefaultStateTable is the table the SQLStateStore keeps the states in if none is specified
+const DefaultStateTable = "flow_instance_state"
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// SQLStateStore is a StateStore keeping the states in a PostgreSQL table, the database
+// is opened by the application with the driver of its choice (ex. github.com/lib/pq)
+type SQLStateStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStateStore creates a SQLStateStore keeping the states in the specified table of
+// the database, DefaultStateTable is used if the table is empty
+func NewSQLStateStore(db *sql.DB, table string) (*SQLStateStore, error) {
+
+	if table == "" {
+		table = DefaultStateTable
+	}
+
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid state table name '%s'", table)
+	}
+
+	return &SQLStateStore{db: db, table: table}, nil
+}
+
+// CreateTable creates the table of the states if it doesn't exist
+func (s *SQLStateStore) CreateTable() error {
+
+	_, err := s.db.Exec("CREATE TABLE IF NOT EXISTS " + s.table + " (id VARCHAR(255) PRIMARY KEY, state BYTEA NOT NULL)")
+	if err != nil {
+		return fmt.Errorf("error creating state table '%s', %s", s.table, err.Error())
+	}
+
+	return nil
+}
+
+// Save implements StateStore.Save
+func (s *SQLStateStore) Save(id string, state []byte) error {
+	_, err := s.db.Exec("INSERT INTO "+s.table+" (id, state) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET state = EXCLUDED.state", id, state)
+	return err
+}
+
+// Load implements StateStore.Load
+func (s *SQLStateStore) Load(id string) ([]byte, error) {
+
+	var state []byte
+	err := s.db.QueryRow("SELECT state FROM "+s.table+" WHERE id = $1", id).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return state, err
+}
+
+// List implements StateStore.List
+func (s *SQLStateStore) List() ([]string, error) {
+
+	rows, err := s.db.Query("SELECT id FROM " + s.table + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Delete implements StateStore.Delete
+func (s *SQLStateStore) Delete(id string) error {
+	_, err := s.db.Exec("DELETE FROM "+s.table+" WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,131 @@
+package instance
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stateDriver is a database/sql driver emulating the statements of the SQLStateStore
+type stateDriver struct {
+	mu      sync.Mutex
+	states  map[string][]byte
+	queries []string
+}
+
+var testStateDriver = &stateDriver{states: make(map[string][]byte)}
+
+func init() {
+	sql.Register("flowstatetest", testStateDriver)
+}
+
+func (d *stateDriver) Open(name string) (driver.Conn, error) { return &stateConn{d}, nil }
+
+type stateConn struct{ d *stateDriver }
+
+func (c *stateConn) Prepare(query string) (driver.Stmt, error) { return &stateStmt{c.d, query}, nil }
+func (c *stateConn) Close() error                              { return nil }
+func (c *stateConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type stateStmt struct {
+	d     *stateDriver
+	query string
+}
+
+func (s *stateStmt) Close() error  { return nil }
+func (s *stateStmt) NumInput() int { return -1 }
+
+func (s *stateStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.queries = append(s.d.queries, s.query)
+
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+	case strings.HasPrefix(s.query, "INSERT INTO"):
+		s.d.states[args[0].(string)] = args[1].([]byte)
+	case strings.HasPrefix(s.query, "DELETE FROM"):
+		delete(s.d.states, args[0].(string))
+	default:
+		return nil, errors.New("unexpected statement: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *stateStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.queries = append(s.d.queries, s.query)
+
+	rows := &stateRows{}
+	switch {
+	case strings.HasPrefix(s.query, "SELECT state"):
+		if state, exists := s.d.states[args[0].(string)]; exists {
+			rows.values = append(rows.values, state)
+		}
+	case strings.HasPrefix(s.query, "SELECT id"):
+		for id := range s.d.states {
+			rows.values = append(rows.values, id)
+		}
+		sort.Slice(rows.values, func(i, j int) bool { return rows.values[i].(string) < rows.values[j].(string) })
+	default:
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return rows, nil
+}
+
+type stateRows struct {
+	values []driver.Value
+}
+
+func (r *stateRows) Columns() []string { return []string{"value"} }
+func (r *stateRows) Close() error      { return nil }
+
+func (r *stateRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func TestSQLStateStore(t *testing.T) {
+
+	db, err := sql.Open("flowstatetest", "")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = NewSQLStateStore(db, "states; DROP TABLE users")
+	assert.NotNil(t, err)
+
+	store, err := NewSQLStateStore(db, "")
+	assert.Nil(t, err)
+	assert.Nil(t, store.CreateTable())
+
+	assert.Nil(t, store.Save("2", []byte(`{"id":"2"}`)))
+	assert.Nil(t, store.Save("1", []byte(`{"id":"1"}`)))
+	assert.Nil(t, store.Save("1", []byte(`{"id":"1","status":100}`)))
+
+	state, err := store.Load("1")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"1","status":100}`, string(state))
+
+	ids, err := store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids)
+
+	assert.Nil(t, store.Delete("1"))
+
+	state, err = store.Load("1")
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+
+	assert.Contains(t, testStateDriver.queries, "INSERT INTO flow_instance_state (id, state) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET state = EXCLUDED.state")
+}
@@ -0,0 +1,110 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StateStore is the interface that describes a durable store of the state of flow
+// instances, so that instances interrupted by a crash can be resumed.  The state of an
+// instance is the JSON serialization of the IndependentInstance.
+type StateStore interface {
+	// Save saves the state of the instance with the specified id, replacing any prior state
+	Save(id string, state []byte) error
+
+	// Load loads the state of the instance with the specified id, nil is returned if
+	// there is no state for the instance
+	Load(id string) ([]byte, error)
+
+	// List lists the ids of the instances with a saved state
+	List() ([]string, error)
+
+	// Delete deletes the state of the instance with the specified id
+	Delete(id string) error
+}
+
+// SaveInstance checkpoints the state of the instance to the store
+func SaveInstance(store StateStore, inst *IndependentInstance) error {
+
+	state, err := json.Marshal(inst)
+	if err != nil {
+		return fmt.Errorf("error serializing flow instance '%s', %s", inst.ID(), err.Error())
+	}
+
+	if err := store.Save(inst.ID(), state); err != nil {
+		return fmt.Errorf("error saving flow instance '%s', %s", inst.ID(), err.Error())
+	}
+
+	return nil
+}
+
+// LoadInstance loads the instance with the specified id from the store, the flow
+// definitions of the loaded instance are resolved when it is restarted
+func LoadInstance(store StateStore, id string) (*IndependentInstance, error) {
+
+	state, err := store.Load(id)
+	if err != nil {
+		return nil, fmt.Errorf("error loading flow instance '%s', %s", id, err.Error())
+	}
+	if state == nil {
+		return nil, fmt.Errorf("flow instance '%s' not found", id)
+	}
+
+	inst := &IndependentInstance{}
+	if err := json.Unmarshal(state, inst); err != nil {
+		return nil, fmt.Errorf("error deserializing flow instance '%s', %s", id, err.Error())
+	}
+	inst.master = inst
+
+	return inst, nil
+}
+
+// MemoryStateStore is a StateStore keeping the states in memory, it doesn't survive
+// restarts and is meant for tests and development
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+// NewMemoryStateStore creates an empty MemoryStateStore
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{states: make(map[string][]byte)}
+}
+
+// Save implements StateStore.Save
+func (s *MemoryStateStore) Save(id string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[id] = state
+	return nil
+}
+
+// Load implements StateStore.Load
+func (s *MemoryStateStore) Load(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[id], nil
+}
+
+// List implements StateStore.List
+func (s *MemoryStateStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.states))
+	for id := range s.states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Delete implements StateStore.Delete
+func (s *MemoryStateStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, id)
+	return nil
+}
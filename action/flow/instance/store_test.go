@@ -0,0 +1,43 @@
+package instance
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveLoadInstance(t *testing.T) {
+
+	defRep := &definition.DefinitionRep{}
+	err := json.Unmarshal([]byte(defJSON), defRep)
+	assert.Nil(t, err)
+
+	def, _ := definition.NewDefinition(defRep)
+	assert.NotNil(t, def)
+
+	inst := NewIndependentInstance("12345", "uri", def)
+	inst.Start(nil)
+	inst.DoStep()
+
+	store := NewMemoryStateStore()
+	assert.Nil(t, SaveInstance(store, inst))
+
+	ids, err := store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"12345"}, ids)
+
+	loaded, err := LoadInstance(store, "12345")
+	assert.Nil(t, err)
+	assert.Equal(t, "12345", loaded.ID())
+	assert.Equal(t, "uri", loaded.FlowURI())
+	assert.True(t, loaded.Status() < model.FlowStatusCompleted)
+
+	assert.Nil(t, store.Delete("12345"))
+
+	_, err = LoadInstance(store, "12345")
+	assert.NotNil(t, err)
+	assert.Equal(t, "flow instance '12345' not found", err.Error())
+}
@@ -1,9 +1,11 @@
 package instance
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"sync"
 
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
@@ -37,6 +39,17 @@ type TaskInst struct {
 
 	returnError error
 
+	// attempt is the current attempt at evaluating the activity, see definition.RetryPolicy
+	attempt int
+
+	// branch is the result of the concurrent evaluation of the activity, see evalBranches
+	branch *branchResult
+
+	// ctx is the context of the evaluation of the activity, see evalWithContext.  It is
+	// protected by ctxMu as activities may read it from their own goroutines.
+	ctxMu sync.Mutex
+	ctx   context.Context
+
 	taskID string //needed for serialization
 }
 
@@ -199,6 +212,39 @@ func (ti *TaskInst) SetStatus(status model.TaskStatus) {
 	postTaskEvent(ti)
 }
 
+// Attempt implements model.RetryContext.Attempt, activities can get the attempt
+// from their context using interface{ Attempt() int }
+func (ti *TaskInst) Attempt() int {
+	if ti.attempt < 1 {
+		return 1
+	}
+	return ti.attempt
+}
+
+// SetAttempt implements model.RetryContext.SetAttempt
+func (ti *TaskInst) SetAttempt(attempt int) {
+	ti.attempt = attempt
+}
+
+// Joined implements model.JoinContext.Joined
+func (ti *TaskInst) Joined() bool {
+	return ti.flowInst.joined[ti.task.ID()]
+}
+
+// SetJoined implements model.JoinContext.SetJoined
+func (ti *TaskInst) SetJoined(joined bool) {
+
+	if !joined {
+		delete(ti.flowInst.joined, ti.task.ID())
+		return
+	}
+
+	if ti.flowInst.joined == nil {
+		ti.flowInst.joined = make(map[string]bool)
+	}
+	ti.flowInst.joined[ti.task.ID()] = true
+}
+
 func (ti *TaskInst) HasWorkingData() bool {
 	return ti.workingData != nil
 }
@@ -324,6 +370,17 @@ func (ti *TaskInst) HasActivity() bool {
 // EvalActivity implements activity.ActivityContext.EvalActivity method
 func (ti *TaskInst) EvalActivity() (done bool, evalErr error) {
 
+	if branch := ti.branch; branch != nil {
+		// the activity was already evaluated concurrently with the other branches
+		ti.branch = nil
+		return ti.mergeBranch(branch)
+	}
+
+	return ti.evalActivity()
+}
+
+func (ti *TaskInst) evalActivity() (done bool, evalErr error) {
+
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Warnf("Unhandled Error executing activity '%s'[%s] : %v\n", ti.task.Name(), ti.task.ActivityConfig().Ref(), r)
@@ -357,10 +414,15 @@ func (ti *TaskInst) EvalActivity() (done bool, evalErr error) {
 	//if taskData.HasAttrs() {
 	eval = applyInputInterceptor(ti)
 
-	if eval {
+	mocked, err := debugBefore(ti)
+	if err != nil {
+		return false, err
+	}
+
+	if eval && !mocked {
 
 		act := activity.Get(ti.task.ActivityConfig().Ref())
-		done, evalErr = act.Eval(ti)
+		done, evalErr = ti.evalWithContext(func() (bool, error) { return act.Eval(ti) })
 
 		if evalErr != nil {
 			e, ok := evalErr.(*activity.Error)
@@ -379,6 +441,10 @@ func (ti *TaskInst) EvalActivity() (done bool, evalErr error) {
 		//if taskData.HasAttrs() {
 		applyOutputInterceptor(ti)
 
+		if err := debugAfter(ti); err != nil {
+			return false, err
+		}
+
 		if ti.task.ActivityConfig().OutputMapper() != nil {
 
 			appliedMapper, err := applyOutputMapper(ti)
@@ -438,6 +504,10 @@ func (ti *TaskInst) PostEvalActivity() (done bool, evalErr error) {
 
 	if done {
 
+		if err := debugAfter(ti); err != nil {
+			return false, err
+		}
+
 		if ti.task.ActivityConfig().OutputMapper() != nil {
 			applyOutputInterceptor(ti)
 
@@ -493,6 +563,8 @@ func (taskInst *TaskInst) appendErrorData(err error) {
 		} else {
 			taskInst.flowInst.AddAttr("_E.activity", data.TypeString, taskInst.taskID)
 		}
+	case *InterruptError:
+		taskInst.flowInst.appendInterruptData(e)
 	case *ActivityEvalError:
 		taskInst.flowInst.AddAttr("_E.activity", data.TypeString, e.TaskName())
 		taskInst.flowInst.AddAttr("_E.message", data.TypeString, err.Error())
@@ -0,0 +1,79 @@
+package instance
+
+import (
+	"context"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/support"
+)
+
+// Tags of the spans created for flow instances and tasks
+const (
+	TagFlow     = "flow"
+	TagInstance = "flow.instance"
+	TagTask     = "flow.task"
+	TagActivity = "flow.activity"
+)
+
+// Tracer is the interface used to create the spans of flow instances and tasks, it
+// can be implemented to report them using a tracing system (ex. OpenTracing or
+// OpenTelemetry).  StartSpan returns a context carrying the new span, the spans of the
+// tasks of an instance are started from the context carrying the span of the instance.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string, tags map[string]string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// Finish finishes the span, err is the error the flow instance or task failed with
+	Finish(err error)
+}
+
+// SetMetrics sets the Metrics used to record the execution metrics of the instance
+func (inst *IndependentInstance) SetMetrics(metrics support.Metrics) {
+	inst.metrics = metrics
+}
+
+// SetTracer sets the Tracer used to create the spans of the tasks of the instance
+func (inst *IndependentInstance) SetTracer(tracer Tracer) {
+	inst.tracer = tracer
+}
+
+// observeQueueDepth records the number of work items queued at the start of a step
+func (inst *IndependentInstance) observeQueueDepth() {
+
+	if inst.metrics == nil {
+		return
+	}
+
+	labels := map[string]string{support.LabelFlow: inst.flowDef.Name()}
+	inst.metrics.Observe(support.MetricWorkQueueDepth, labels, float64(inst.workItemQueue.List.Len()))
+}
+
+// startTaskSpan starts the span of the evaluation of the activity of the task
+func (ti *TaskInst) startTaskSpan(ctx context.Context) (context.Context, Span) {
+
+	tags := map[string]string{
+		TagFlow:     ti.flowInst.flowDef.Name(),
+		TagInstance: ti.flowInst.master.ID(),
+		TagTask:     ti.task.ID(),
+		TagActivity: ti.task.ActivityConfig().Ref(),
+	}
+
+	return ti.flowInst.master.tracer.StartSpan(ctx, ti.task.ID(), tags)
+}
+
+// observeActivity records the duration of the evaluation of the activity of the task
+func (ti *TaskInst) observeActivity(start time.Time) {
+
+	metrics := ti.flowInst.master.metrics
+	if metrics == nil {
+		return
+	}
+
+	labels := map[string]string{
+		support.LabelFlow:     ti.flowInst.flowDef.Name(),
+		support.LabelActivity: ti.task.ActivityConfig().Ref(),
+	}
+	metrics.Observe(support.MetricActivityDuration, labels, time.Since(start).Seconds())
+}
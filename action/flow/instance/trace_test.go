@@ -0,0 +1,83 @@
+package instance
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/support"
+	"github.com/stretchr/testify/assert"
+)
+
+type spanKey struct{}
+
+type testSpan struct {
+	name     string
+	parent   *testSpan
+	tags     map[string]string
+	finished bool
+}
+
+func (s *testSpan) Finish(err error) {
+	s.finished = true
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, name string, tags map[string]string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parent, _ := ctx.Value(spanKey{}).(*testSpan)
+	span := &testSpan{name: name, parent: parent, tags: tags}
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type testMetrics struct {
+	mu       sync.Mutex
+	observed map[string]int
+}
+
+func (m *testMetrics) Observe(name string, labels map[string]string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observed[name]++
+}
+
+func (m *testMetrics) Inc(name string, labels map[string]string) {
+}
+
+func TestInstanceTracing(t *testing.T) {
+
+	inst := newTimeoutInstance(t, "")
+
+	flowSpan := &testSpan{name: "flow"}
+	inst.SetContext(context.WithValue(context.Background(), spanKey{}, flowSpan))
+
+	tracer := &testTracer{}
+	inst.SetTracer(tracer)
+	metrics := &testMetrics{observed: make(map[string]int)}
+	inst.SetMetrics(metrics)
+
+	runInstance(inst)
+	assert.Equal(t, model.FlowStatusCompleted, inst.Status())
+
+	assert.Equal(t, 2, len(tracer.spans))
+	for i, id := range []string{"log_1", "log_2"} {
+		span := tracer.spans[i]
+		assert.Equal(t, id, span.name)
+		assert.Equal(t, id, span.tags[TagTask])
+		assert.Equal(t, "test-log", span.tags[TagActivity])
+		assert.True(t, span.parent == flowSpan)
+		assert.True(t, span.finished)
+	}
+
+	assert.Equal(t, 2, metrics.observed[support.MetricActivityDuration])
+	assert.True(t, metrics.observed[support.MetricWorkQueueDepth] > 0)
+}
@@ -3,6 +3,7 @@ package instance
 import (
 	"errors"
 
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/support"
 	"github.com/TIBCOSoftware/flogo-lib/core/activity"
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
@@ -114,7 +115,15 @@ func applyOutputMapper(taskInst *TaskInst) (bool, error) {
 
 	if outputMapper != nil {
 		logger.Debug("Applying OutputMapper")
-		err := outputMapper.Apply(taskInst.OutputScope(), taskInst.flowInst)
+
+		var outputScope data.Scope
+		outputScope = taskInst.flowInst
+
+		if taskInst.branch != nil {
+			outputScope = taskInst.branch.scope
+		}
+
+		err := outputMapper.Apply(taskInst.OutputScope(), outputScope)
 
 		return true, err
 	}
@@ -122,6 +131,65 @@ func applyOutputMapper(taskInst *TaskInst) (bool, error) {
 	return false, nil
 }
 
+// ResolveInput resolves the value of an input of the task before its input scope exists,
+// using the input mapping of the input or else its configured value.  It allows an
+// activity with a dynamic IO to determine its metadata from its input (ex. the uri of a
+// subflow).
+func ResolveInput(ctx activity.Context, name string) (value interface{}, exists bool, err error) {
+
+	taskInst, ok := ctx.(*TaskInst)
+	if !ok {
+		return nil, false, errors.New("unable to resolve input using this context")
+	}
+
+	activityCfg := taskInst.task.ActivityConfig()
+
+	for _, mappingDef := range activityCfg.InputMappings() {
+		if mappingDef.MapTo != name {
+			continue
+		}
+
+		var inputScope data.Scope
+		inputScope = taskInst.flowInst
+
+		if taskInst.workingData != nil {
+			inputScope = NewWorkingDataScope(taskInst.flowInst, taskInst.workingData)
+		}
+
+		outputScope := data.NewSimpleScope([]*data.Attribute{data.NewZeroAttribute(name, data.TypeAny)}, nil)
+
+		mapper := definition.GetMapperFactory().NewMapper(&definition.MapperDef{Mappings: []*data.MappingDef{mappingDef}})
+		if err := mapper.Apply(inputScope, outputScope); err != nil {
+			return nil, false, err
+		}
+
+		attr, _ := outputScope.GetAttr(name)
+		return attr.Value(), true, nil
+	}
+
+	if attr, found := activityCfg.GetInputAttr(name); found {
+		return attr.Value(), true, nil
+	}
+
+	return nil, false, nil
+}
+
+// MappedInputs returns the names of the inputs of the task set by its input mappings
+func MappedInputs(ctx activity.Context) ([]string, error) {
+
+	taskInst, ok := ctx.(*TaskInst)
+	if !ok {
+		return nil, errors.New("unable to get inputs using this context")
+	}
+
+	var names []string
+	for _, mappingDef := range taskInst.task.ActivityConfig().InputMappings() {
+		names = append(names, mappingDef.MapTo)
+	}
+
+	return names, nil
+}
+
 func GetFlowIOMetadata(flowURI string) (*data.IOMetadata, error) {
 	manager := support.GetFlowManager()
 	def, err := manager.GetFlow(flowURI)
@@ -156,16 +224,18 @@ func StartSubFlow(ctx activity.Context, flowURI string, inputs map[string]*data.
 		return errors.New("unable to resolve subflow: " + flowURI)
 	}
 
-	//todo make sure that there is only one subFlow per taskinst
-	flowInst := taskInst.flowInst.master.newEmbeddedInstance(taskInst, flowURI, def)
+	master := taskInst.flowInst.master
 
-	if err != nil {
-		return err
-	}
+	// the tasks of parallel flows can start their subflows concurrently
+	master.embeddedMu.Lock()
+	defer master.embeddedMu.Unlock()
+
+	//todo make sure that there is only one subFlow per taskinst
+	flowInst := master.newEmbeddedInstance(taskInst, flowURI, def)
 
 	logger.Debugf("starting embedded subflow `%s`", flowInst.Name())
 
-	err = taskInst.flowInst.master.startEmbedded(flowInst, inputs)
+	err = master.startEmbedded(flowInst, inputs)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,93 @@
+package flow
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/instance"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/support"
+)
+
+// the Metrics and Tracer are wrapped as an atomic.Value can't hold nil and needs a
+// consistent type
+type metricsValue struct{ metrics support.Metrics }
+type tracerValue struct{ tracer instance.Tracer }
+
+var configuredMetrics, configuredTracer atomic.Value
+
+// SetMetrics sets the Metrics used to record the execution metrics of the flow
+// instances (ex. a support.PrometheusMetrics)
+func SetMetrics(m support.Metrics) {
+	configuredMetrics.Store(metricsValue{m})
+}
+
+// SetTracer sets the Tracer used to create the spans of the flow instances and their
+// tasks.  The span of an instance is started from the context passed to Run by the
+// trigger, so traces propagated by the trigger are continued (ex. an
+// instance.OpenTracingTracer).
+func SetTracer(t instance.Tracer) {
+	configuredTracer.Store(tracerValue{t})
+}
+
+func currentMetrics() support.Metrics {
+	v, _ := configuredMetrics.Load().(metricsValue)
+	return v.metrics
+}
+
+func currentTracer() instance.Tracer {
+	v, _ := configuredTracer.Load().(tracerValue)
+	return v.tracer
+}
+
+// instrument instruments the instance with the configured Metrics and Tracer, the
+// returned func records the outcome of the instance once it's no longer executing
+func instrument(inst *instance.IndependentInstance) func() {
+
+	metrics, tracer := currentMetrics(), currentTracer()
+	if metrics == nil && tracer == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	labels := map[string]string{support.LabelFlow: inst.FlowDefinition().Name()}
+
+	if metrics != nil {
+		inst.SetMetrics(metrics)
+		metrics.Inc(support.MetricInstancesStarted, labels)
+	}
+
+	var span instance.Span
+	if tracer != nil {
+		tags := map[string]string{
+			instance.TagFlow:     inst.FlowDefinition().Name(),
+			instance.TagInstance: inst.ID(),
+		}
+
+		var ctx context.Context
+		ctx, span = tracer.StartSpan(inst.Context(), inst.FlowDefinition().Name(), tags)
+		inst.SetContext(ctx)
+		inst.SetTracer(tracer)
+	}
+
+	return func() {
+
+		if span != nil {
+			span.Finish(inst.GetError())
+		}
+
+		if metrics == nil {
+			return
+		}
+
+		switch inst.Status() {
+		case model.FlowStatusCompleted:
+			metrics.Inc(support.MetricInstancesCompleted, labels)
+		case model.FlowStatusFailed:
+			metrics.Inc(support.MetricInstancesFailed, labels)
+		}
+
+		metrics.Observe(support.MetricInstanceDuration, labels, time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,531 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+)
+
+// evalContext is the context an expression is evaluated in
+type evalContext struct {
+	scope    data.Scope
+	resolver data.Resolver
+}
+
+// Eval evaluates the expression against the specified scope, the references are resolved
+// using the flow data resolver
+func (e *Expression) Eval(scope data.Scope) (interface{}, error) {
+
+	value, err := e.root.eval(&evalContext{scope: scope, resolver: definition.GetDataResolver()})
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating expression '%s', %s", e.source, err.Error())
+	}
+
+	return value, nil
+}
+
+func (n *literalNode) eval(ctx *evalContext) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *arrayNode) eval(ctx *evalContext) (interface{}, error) {
+
+	items := make([]interface{}, len(n.items))
+
+	for i, item := range n.items {
+		value, err := item.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = value
+	}
+
+	return items, nil
+}
+
+func (n *refNode) eval(ctx *evalContext) (interface{}, error) {
+
+	toResolve := n.root
+	if n.attr != "" {
+		toResolve += "." + n.attr
+	}
+
+	value, err := ctx.resolver.Resolve(toResolve, ctx.scope)
+	if err != nil {
+		if n.safe {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to resolve '%s', %s", toResolve, err.Error())
+	}
+
+	return value, nil
+}
+
+func (n *memberNode) eval(ctx *evalContext) (interface{}, error) {
+
+	target, err := n.target.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := interface{}(n.name)
+	if n.index != nil {
+		if key, err = n.index.eval(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if target == nil {
+		if n.safe {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot access '%v' of null", key)
+	}
+
+	return member(target, key)
+}
+
+// member gets the member of the map or the item of the array, a missing member is null
+func member(target interface{}, key interface{}) (interface{}, error) {
+
+	if m, ok := target.(map[string]interface{}); ok {
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key '%v' of object", key)
+		}
+		return m[name], nil
+	}
+
+	v := reflect.ValueOf(target)
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot access '%v' of %T", key, target)
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key '%v' of object", key)
+		}
+		item := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !item.IsValid() {
+			return nil, nil
+		}
+		return item.Interface(), nil
+
+	case reflect.Slice, reflect.Array, reflect.String:
+		index, ok := toInt(key)
+		if !ok {
+			return nil, fmt.Errorf("invalid index '%v' of array", key)
+		}
+		if s, isString := target.(string); isString {
+			runes := []rune(s)
+			if index < 0 || index >= int64(len(runes)) {
+				return nil, nil
+			}
+			return string(runes[index]), nil
+		}
+		if index < 0 || index >= int64(v.Len()) {
+			return nil, nil
+		}
+		return v.Index(int(index)).Interface(), nil
+	}
+
+	return nil, fmt.Errorf("cannot access '%v' of %T", key, target)
+}
+
+func (n *callNode) eval(ctx *evalContext) (interface{}, error) {
+
+	args := make([]interface{}, len(n.args))
+
+	for i, arg := range n.args {
+		value, err := arg.eval(ctx)
+		if err != nil {
+			if !lenientFunctions[n.name] {
+				return nil, err
+			}
+			// the arguments of the lenient functions which fail to resolve are null
+			value = nil
+		}
+		args[i] = value
+	}
+
+	value, err := n.fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("error calling '%s', %s", n.name, err.Error())
+	}
+
+	return value, nil
+}
+
+func (n *unaryNode) eval(ctx *evalContext) (interface{}, error) {
+
+	operand, err := n.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "!" {
+		return !truthy(operand), nil
+	}
+
+	if i, ok := asInt(operand); ok {
+		return -i, nil
+	}
+	if f, ok := toFloat(operand); ok {
+		return -f, nil
+	}
+
+	return nil, fmt.Errorf("cannot negate %s", describe(operand))
+}
+
+func (n *ternaryNode) eval(ctx *evalContext) (interface{}, error) {
+
+	cond, err := n.cond.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if truthy(cond) {
+		return n.then.eval(ctx)
+	}
+
+	return n.otherwise.eval(ctx)
+}
+
+func (n *binaryNode) eval(ctx *evalContext) (interface{}, error) {
+
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the short-circuiting operators
+	switch n.op {
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(ctx)
+		return truthy(right), err
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(ctx)
+		return truthy(right), err
+	case "??":
+		if left != nil {
+			return left, nil
+		}
+		return n.right.eval(ctx)
+	}
+
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		cmp, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+		return cmp >= 0, nil
+	case "+":
+		if isString(left) || isString(right) {
+			return toString(left) + toString(right), nil
+		}
+	}
+
+	return arithmetic(n.op, left, right)
+}
+
+// arithmetic applies the arithmetic operator, integers yield integers except for
+// divisions with a remainder
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+
+	li, lInt := asInt(left)
+	ri, rInt := asInt(right)
+
+	if lInt && rInt {
+		switch op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if op == "%" {
+				return li % ri, nil
+			}
+			if li%ri == 0 {
+				return li / ri, nil
+			}
+		}
+	}
+
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("invalid operands of '%s', %s and %s", op, describe(left), describe(right))
+	}
+
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf / rf, nil
+	case "%":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator '%s'", op)
+}
+
+// equal compares the values, numbers are compared by value regardless of their type
+func equal(left, right interface{}) bool {
+
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	if lf, ok := toFloat(left); ok {
+		rf, ok := toFloat(right)
+		return ok && lf == rf
+	}
+
+	if lt, ok := left.(time.Time); ok {
+		rt, err := toTime(right)
+		return err == nil && lt.Equal(rt)
+	}
+
+	return reflect.DeepEqual(normalize(left), normalize(right))
+}
+
+// compare orders the numbers, strings or dates
+func compare(left, right interface{}) (int, error) {
+
+	if lf, ok := toFloat(left); ok {
+		if rf, ok := toFloat(right); ok {
+			switch {
+			case lf < rf:
+				return -1, nil
+			case lf > rf:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	_, lTime := left.(time.Time)
+	_, rTime := right.(time.Time)
+	if lTime || rTime {
+		lt, lerr := toTime(left)
+		rt, rerr := toTime(right)
+		if lerr == nil && rerr == nil {
+			switch {
+			case lt.Before(rt):
+				return -1, nil
+			case lt.After(rt):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	if ls, ok := left.(string); ok {
+		if rs, ok := right.(string); ok {
+			return strings.Compare(ls, rs), nil
+		}
+	}
+
+	return 0, fmt.Errorf("cannot compare %s and %s", describe(left), describe(right))
+}
+
+// truthy determines if the value is true, null, false, zero and empty values are false
+func truthy(value interface{}) bool {
+
+	switch t := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	}
+
+	if f, ok := toFloat(value); ok {
+		return f != 0
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() > 0
+	}
+
+	return true
+}
+
+// toFloat converts the numeric value to a float64
+func toFloat(value interface{}) (float64, bool) {
+
+	switch t := value.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int16:
+		return float64(t), true
+	case int8:
+		return float64(t), true
+	case uint:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case uint32:
+		return float64(t), true
+	case uint16:
+		return float64(t), true
+	case uint8:
+		return float64(t), true
+	case interface {
+		Float64() (float64, error)
+	}:
+		// ex. json.Number
+		f, err := t.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// asInt converts the value of an integer type to an int64
+func asInt(value interface{}) (int64, bool) {
+
+	switch t := value.(type) {
+	case int64:
+		return t, true
+	case int:
+		return int64(t), true
+	case int32:
+		return int64(t), true
+	case int16:
+		return int64(t), true
+	case int8:
+		return int64(t), true
+	case uint32:
+		return int64(t), true
+	case uint16:
+		return int64(t), true
+	case uint8:
+		return int64(t), true
+	}
+
+	return 0, false
+}
+
+// toInt converts the integral numeric value to an int64
+func toInt(value interface{}) (int64, bool) {
+
+	if i, ok := asInt(value); ok {
+		return i, true
+	}
+
+	f, ok := toFloat(value)
+	if !ok || f != math.Trunc(f) {
+		return 0, false
+	}
+
+	return int64(f), true
+}
+
+// normalize converts the integers to int64 so that they are compared by value
+func normalize(value interface{}) interface{} {
+
+	switch t := value.(type) {
+	case []interface{}:
+		normalized := make([]interface{}, len(t))
+		for i, item := range t {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			normalized[k] = normalize(item)
+		}
+		return normalized
+	}
+
+	if f, ok := toFloat(value); ok {
+		return f
+	}
+
+	return value
+}
+
+func isString(value interface{}) bool {
+	_, ok := value.(string)
+	return ok
+}
+
+func toString(value interface{}) string {
+
+	switch t := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case time.Time:
+		return t.Format(time.RFC3339)
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+func describe(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,187 @@
+package expr
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/core/activity"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func newScope() data.Scope {
+
+	order := map[string]interface{}{
+		"id":       "ord-42",
+		"total":    120.5,
+		"items":    []interface{}{"book", "pen"},
+		"customer": nil,
+		"placed":   "2018-03-01T10:00:00Z",
+	}
+
+	attr := func(name string, dataType data.Type, value interface{}) *data.Attribute {
+		a, _ := data.NewAttribute(name, dataType, value)
+		return a
+	}
+
+	return data.NewSimpleScope([]*data.Attribute{
+		attr("order", data.TypeObject, order),
+		attr("petMax", data.TypeInteger, 4),
+		attr("_T.method", data.TypeString, "POST"),
+	}, nil)
+}
+
+func eval(t *testing.T, source string) interface{} {
+
+	expr, err := Compile(source)
+	if !assert.Nil(t, err) {
+		return nil
+	}
+
+	value, err := expr.Eval(newScope())
+	assert.Nil(t, err, source)
+	return value
+}
+
+func TestEvalOperators(t *testing.T) {
+
+	assert.Equal(t, int64(7), eval(t, "1 + 2 * 3"))
+	assert.Equal(t, int64(9), eval(t, "(1 + 2) * 3"))
+	assert.Equal(t, 2.5, eval(t, "5 / 2"))
+	assert.Equal(t, int64(2), eval(t, "4 / 2"))
+	assert.Equal(t, int64(1), eval(t, "7 % 3"))
+	assert.Equal(t, int64(-3), eval(t, "-3"))
+	assert.Equal(t, true, eval(t, "$flow.petMax > 2 && !($flow.petMax == 5)"))
+	assert.Equal(t, true, eval(t, "$flow.petMax == 4.0"))
+	assert.Equal(t, "ord-42:POST", eval(t, "$flow.order.id + ':' + $trigger.method"))
+	assert.Equal(t, false, eval(t, "null || false"))
+}
+
+func TestEvalTernaryAndCoalesce(t *testing.T) {
+
+	assert.Equal(t, "big", eval(t, "$flow.order.total > 100 ? 'big' : 'small'"))
+	assert.Equal(t, 0.5, eval(t, "false ? 1 :.5"))
+	assert.Equal(t, "small", eval(t, "$flow.order.total > 200 ? 'big' : $flow.order.total > 1000 ? 'huge' : 'small'"))
+	assert.Equal(t, "anonymous", eval(t, "$flow.order.customer?.name ?? 'anonymous'"))
+}
+
+func TestEvalSafeAccess(t *testing.T) {
+
+	assert.Nil(t, eval(t, "$flow.order.customer?.name"))
+	assert.Nil(t, eval(t, "$flow.order.customer?.address?.city"))
+	assert.Nil(t, eval(t, "$flow?.missing?.name"))
+	assert.Nil(t, eval(t, "$flow.order.missing"))
+	assert.Equal(t, "pen", eval(t, "$flow.order.items[1]"))
+	assert.Nil(t, eval(t, "$flow.order.items[5]"))
+	assert.Equal(t, "ord-42", eval(t, "$flow.order['id']"))
+
+	expr, err := Compile("$flow.order.customer.name")
+	assert.Nil(t, err)
+	_, err = expr.Eval(newScope())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "cannot access 'name' of null")
+
+	expr, err = Compile("$flow.missing")
+	assert.Nil(t, err)
+	_, err = expr.Eval(newScope())
+	assert.NotNil(t, err)
+}
+
+func TestEvalFunctions(t *testing.T) {
+
+	assert.Equal(t, "ORD-42", eval(t, "string.upper($flow.order.id)"))
+	assert.Equal(t, true, eval(t, "string.startsWith($flow.order.id, 'ord')"))
+	assert.Equal(t, "42", eval(t, "string.substring($flow.order.id, 4)"))
+	assert.Equal(t, int64(2), eval(t, "len($flow.order.items)"))
+	assert.Equal(t, true, eval(t, "array.contains($flow.order.items, 'pen')"))
+	assert.Equal(t, "book,pen", eval(t, "array.join($flow.order.items, ',')"))
+	assert.Equal(t, int64(6), eval(t, "array.sum([1, 2, 3])"))
+	assert.Equal(t, int64(121), eval(t, "number.round($flow.order.total)"))
+	assert.Equal(t, int64(4), eval(t, "number.max(1, $flow.petMax, 3)"))
+	assert.Equal(t, int64(42), eval(t, "toNumber('42')"))
+	assert.Equal(t, false, eval(t, "isDefined($flow.missing)"))
+	assert.Equal(t, true, eval(t, "isDefined($flow.order)"))
+	assert.Equal(t, "POST", eval(t, "coalesce($flow.missing, $trigger.method)"))
+}
+
+func TestEvalDates(t *testing.T) {
+
+	assert.Equal(t, "2018-03-02", eval(t, "date.format(date.addDays($flow.order.placed, 1), '2006-01-02')"))
+	assert.Equal(t, int64(2018), eval(t, "date.year($flow.order.placed)"))
+	assert.Equal(t, true, eval(t, "date.parse($flow.order.placed) < date.now()"))
+	assert.Equal(t, 7200.0, eval(t, "date.diff(date.add($flow.order.placed, '2h'), $flow.order.placed)"))
+
+	placed, _ := time.Parse(time.RFC3339, "2018-03-01T10:00:00Z")
+	assert.Equal(t, placed, eval(t, "date.parse('01/03/2018 10:00', '02/01/2006 15:04')"))
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	for _, source := range []string{"1 +", "(1", "$flow.a ? 1", "unknown(1)", "foo", "'open", "1 # 2"} {
+		_, err := Compile(source)
+		assert.NotNil(t, err, source)
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+
+	RegisterFunction("test.double", func(args []interface{}) (interface{}, error) {
+		return arithmetic("*", args[0], int64(2))
+	})
+
+	assert.Equal(t, int64(8), eval(t, "test.double($flow.petMax)"))
+}
+
+type testActivity struct {
+}
+
+func (a *testActivity) Metadata() *activity.Metadata {
+	return &activity.Metadata{ID: "test-noop"}
+}
+
+func (a *testActivity) Eval(context activity.Context) (done bool, err error) {
+	return true, nil
+}
+
+const defJSON = `
+{
+  "name": "Expr Flow",
+  "exprLanguage": "expr",
+  "tasks": [
+    { "id": "a", "activity": { "ref": "test-noop" } },
+    { "id": "b", "activity": { "ref": "test-noop" } }
+  ],
+  "links": [
+    { "type": "expression", "from": "a", "to": "b", "value": "$flow.order.customer?.vip ?? $flow.order.total > 100" },
+    { "type": "expression", "from": "a", "to": "b", "value": "string.length($flow.order.id) > 10" }
+  ]
+}
+`
+
+func TestLinkExprManager(t *testing.T) {
+
+	activity.Register(&testActivity{})
+
+	defRep := &definition.DefinitionRep{}
+	assert.Nil(t, json.Unmarshal([]byte(defJSON), defRep))
+
+	def, err := definition.NewDefinition(defRep)
+	assert.Nil(t, err)
+	assert.Equal(t, Language, def.ExprLanguage())
+
+	factory, exists := definition.GetExprLanguage(Language)
+	assert.True(t, exists)
+
+	mgr := factory.NewLinkExprManager()
+	assert.Nil(t, mgr.(definition.LinkExprCompiler).CompileLinkExprs(def))
+
+	result, err := mgr.EvalLinkExpr(def.GetLink(0), newScope())
+	assert.Nil(t, err)
+	assert.True(t, result)
+
+	result, err = mgr.EvalLinkExpr(def.GetLink(1), newScope())
+	assert.Nil(t, err)
+	assert.False(t, result)
+}
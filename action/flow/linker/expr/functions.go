@@ -0,0 +1,462 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Function is a function that can be called by the expressions, the arguments are the
+// evaluated values of the call
+type Function func(args []interface{}) (interface{}, error)
+
+var (
+	functionsMu sync.RWMutex
+	functions   = make(map[string]Function)
+)
+
+// lenientFunctions are the functions the arguments of which are null when they fail to
+// resolve (ex. isDefined($flow.missing))
+var lenientFunctions = map[string]bool{"isDefined": true, "coalesce": true}
+
+// RegisterFunction registers the function with the specified name (ex. string.reverse),
+// the functions are resolved when the expressions are compiled
+func RegisterFunction(name string, fn Function) {
+
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+
+	functions[name] = fn
+}
+
+// GetFunction gets the function with the specified name
+func GetFunction(name string) (Function, bool) {
+
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+
+	fn, exists := functions[name]
+	return fn, exists
+}
+
+func init() {
+
+	RegisterFunction("isDefined", func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return args[0] != nil, nil
+	})
+	RegisterFunction("coalesce", func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	})
+	RegisterFunction("len", unary(func(v interface{}) (interface{}, error) { return length(v) }))
+	RegisterFunction("toString", unary(func(v interface{}) (interface{}, error) { return toString(v), nil }))
+	RegisterFunction("toNumber", unary(toNumber))
+	RegisterFunction("toBoolean", unary(func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return strconv.ParseBool(s)
+		}
+		return truthy(v), nil
+	}))
+
+	registerStringFunctions()
+	registerNumberFunctions()
+	registerDateFunctions()
+	registerArrayFunctions()
+}
+
+func registerStringFunctions() {
+
+	RegisterFunction("string.length", stringFn(1, func(s []string, args []interface{}) (interface{}, error) {
+		return int64(len([]rune(s[0]))), nil
+	}))
+	RegisterFunction("string.concat", func(args []interface{}) (interface{}, error) {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			parts[i] = toString(arg)
+		}
+		return strings.Join(parts, ""), nil
+	})
+	RegisterFunction("string.upper", stringFn(1, func(s []string, args []interface{}) (interface{}, error) { return strings.ToUpper(s[0]), nil }))
+	RegisterFunction("string.lower", stringFn(1, func(s []string, args []interface{}) (interface{}, error) { return strings.ToLower(s[0]), nil }))
+	RegisterFunction("string.trim", stringFn(1, func(s []string, args []interface{}) (interface{}, error) { return strings.TrimSpace(s[0]), nil }))
+	RegisterFunction("string.contains", stringFn(2, func(s []string, args []interface{}) (interface{}, error) { return strings.Contains(s[0], s[1]), nil }))
+	RegisterFunction("string.startsWith", stringFn(2, func(s []string, args []interface{}) (interface{}, error) { return strings.HasPrefix(s[0], s[1]), nil }))
+	RegisterFunction("string.endsWith", stringFn(2, func(s []string, args []interface{}) (interface{}, error) { return strings.HasSuffix(s[0], s[1]), nil }))
+	RegisterFunction("string.indexOf", stringFn(2, func(s []string, args []interface{}) (interface{}, error) {
+		index := strings.Index(s[0], s[1])
+		if index < 0 {
+			return int64(-1), nil
+		}
+		return int64(len([]rune(s[0][:index]))), nil
+	}))
+	RegisterFunction("string.replace", stringFn(3, func(s []string, args []interface{}) (interface{}, error) {
+		return strings.Replace(s[0], s[1], s[2], -1), nil
+	}))
+	RegisterFunction("string.split", stringFn(2, func(s []string, args []interface{}) (interface{}, error) {
+		parts := strings.Split(s[0], s[1])
+		items := make([]interface{}, len(parts))
+		for i, part := range parts {
+			items[i] = part
+		}
+		return items, nil
+	}))
+	RegisterFunction("string.matches", stringFn(2, func(s []string, args []interface{}) (interface{}, error) {
+		return regexp.MatchString(s[1], s[0])
+	}))
+	RegisterFunction("string.substring", func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 2, 3); err != nil {
+			return nil, err
+		}
+		runes := []rune(toString(args[0]))
+		start, ok := toInt(args[1])
+		end := int64(len(runes))
+		if len(args) == 3 {
+			var endOk bool
+			end, endOk = toInt(args[2])
+			ok = ok && endOk
+		}
+		if !ok {
+			return nil, errors.New("start and end must be integers")
+		}
+		start, end = clamp(start, int64(len(runes))), clamp(end, int64(len(runes)))
+		if start >= end {
+			return "", nil
+		}
+		return string(runes[start:end]), nil
+	})
+	RegisterFunction("string.format", func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, -1); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf(toString(args[0]), args[1:]...), nil
+	})
+}
+
+func registerNumberFunctions() {
+
+	RegisterFunction("number.abs", numberFn(func(f float64) float64 { return math.Abs(f) }))
+	RegisterFunction("number.floor", numberFn(math.Floor))
+	RegisterFunction("number.ceil", numberFn(math.Ceil))
+	RegisterFunction("number.round", func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 2); err != nil {
+			return nil, err
+		}
+		f, ok := toFloat(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%s is not a number", describe(args[0]))
+		}
+		if len(args) == 1 {
+			return int64(math.Floor(f + 0.5)), nil
+		}
+		places, ok := toInt(args[1])
+		if !ok {
+			return nil, errors.New("places must be an integer")
+		}
+		scale := math.Pow(10, float64(places))
+		return math.Floor(f*scale+0.5) / scale, nil
+	})
+	RegisterFunction("number.min", extremum(func(a, b float64) bool { return a < b }))
+	RegisterFunction("number.max", extremum(func(a, b float64) bool { return a > b }))
+	RegisterFunction("number.parse", unary(toNumber))
+}
+
+func registerDateFunctions() {
+
+	RegisterFunction("date.now", func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 0, 0); err != nil {
+			return nil, err
+		}
+		return time.Now(), nil
+	})
+	RegisterFunction("date.parse", func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 2); err != nil {
+			return nil, err
+		}
+		if len(args) == 2 {
+			return time.Parse(toString(args[1]), toString(args[0]))
+		}
+		return toTime(args[0])
+	})
+	RegisterFunction("date.format", dateFn(1, func(t time.Time, args []interface{}) (interface{}, error) {
+		return t.Format(toString(args[0])), nil
+	}))
+	RegisterFunction("date.add", dateFn(1, func(t time.Time, args []interface{}) (interface{}, error) {
+		d, err := time.ParseDuration(toString(args[0]))
+		if err != nil {
+			return nil, err
+		}
+		return t.Add(d), nil
+	}))
+	RegisterFunction("date.addDays", dateFn(1, func(t time.Time, args []interface{}) (interface{}, error) {
+		days, ok := toInt(args[0])
+		if !ok {
+			return nil, errors.New("days must be an integer")
+		}
+		return t.AddDate(0, 0, int(days)), nil
+	}))
+	RegisterFunction("date.diff", dateFn(1, func(t time.Time, args []interface{}) (interface{}, error) {
+		other, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return t.Sub(other).Seconds(), nil
+	}))
+	RegisterFunction("date.year", dateFn(0, func(t time.Time, args []interface{}) (interface{}, error) { return int64(t.Year()), nil }))
+	RegisterFunction("date.month", dateFn(0, func(t time.Time, args []interface{}) (interface{}, error) { return int64(t.Month()), nil }))
+	RegisterFunction("date.day", dateFn(0, func(t time.Time, args []interface{}) (interface{}, error) { return int64(t.Day()), nil }))
+}
+
+func registerArrayFunctions() {
+
+	RegisterFunction("array.length", arrayFn(0, func(items []interface{}, args []interface{}) (interface{}, error) {
+		return int64(len(items)), nil
+	}))
+	RegisterFunction("array.contains", arrayFn(1, func(items []interface{}, args []interface{}) (interface{}, error) {
+		for _, item := range items {
+			if equal(item, args[0]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}))
+	RegisterFunction("array.indexOf", arrayFn(1, func(items []interface{}, args []interface{}) (interface{}, error) {
+		for i, item := range items {
+			if equal(item, args[0]) {
+				return int64(i), nil
+			}
+		}
+		return int64(-1), nil
+	}))
+	RegisterFunction("array.first", arrayFn(0, func(items []interface{}, args []interface{}) (interface{}, error) {
+		if len(items) == 0 {
+			return nil, nil
+		}
+		return items[0], nil
+	}))
+	RegisterFunction("array.last", arrayFn(0, func(items []interface{}, args []interface{}) (interface{}, error) {
+		if len(items) == 0 {
+			return nil, nil
+		}
+		return items[len(items)-1], nil
+	}))
+	RegisterFunction("array.sum", arrayFn(0, func(items []interface{}, args []interface{}) (interface{}, error) {
+		var sum interface{} = int64(0)
+		for _, item := range items {
+			var err error
+			if sum, err = arithmetic("+", sum, item); err != nil {
+				return nil, err
+			}
+		}
+		return sum, nil
+	}))
+	RegisterFunction("array.join", arrayFn(1, func(items []interface{}, args []interface{}) (interface{}, error) {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, toString(args[0])), nil
+	}))
+}
+
+// checkArgs checks the number of arguments, a negative max doesn't limit them
+func checkArgs(args []interface{}, min, max int) error {
+
+	if len(args) < min || max >= 0 && len(args) > max {
+		if min == max {
+			return fmt.Errorf("expected %d arguments, got %d", min, len(args))
+		}
+		return fmt.Errorf("expected %d to %d arguments, got %d", min, max, len(args))
+	}
+
+	return nil
+}
+
+func unary(fn func(v interface{}) (interface{}, error)) Function {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return fn(args[0])
+	}
+}
+
+// stringFn creates a function of n arguments converted to strings
+func stringFn(n int, fn func(s []string, args []interface{}) (interface{}, error)) Function {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, n, n); err != nil {
+			return nil, err
+		}
+		s := make([]string, n)
+		for i, arg := range args {
+			s[i] = toString(arg)
+		}
+		return fn(s, args)
+	}
+}
+
+func numberFn(fn func(f float64) float64) Function {
+	return unary(func(v interface{}) (interface{}, error) {
+		if i, ok := asInt(v); ok {
+			return int64(fn(float64(i))), nil
+		}
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a number", describe(v))
+		}
+		return fn(f), nil
+	})
+}
+
+// dateFn creates a function of a date followed by n arguments
+func dateFn(n int, fn func(t time.Time, args []interface{}) (interface{}, error)) Function {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, n+1, n+1); err != nil {
+			return nil, err
+		}
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return fn(t, args[1:])
+	}
+}
+
+// arrayFn creates a function of an array followed by n arguments
+func arrayFn(n int, fn func(items []interface{}, args []interface{}) (interface{}, error)) Function {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, n+1, n+1); err != nil {
+			return nil, err
+		}
+		items, err := toArray(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return fn(items, args[1:])
+	}
+}
+
+func extremum(better func(a, b float64) bool) Function {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, -1); err != nil {
+			return nil, err
+		}
+		var result interface{}
+		var best float64
+		for _, arg := range args {
+			f, ok := toFloat(arg)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a number", describe(arg))
+			}
+			if result == nil || better(f, best) {
+				result, best = arg, f
+			}
+		}
+		return toNumber(result)
+	}
+}
+
+func length(value interface{}) (interface{}, error) {
+
+	switch t := value.(type) {
+	case nil:
+		return int64(0), nil
+	case string:
+		return int64(len([]rune(t))), nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(v.Len()), nil
+	}
+
+	return nil, fmt.Errorf("%s has no length", describe(value))
+}
+
+func toNumber(value interface{}) (interface{}, error) {
+
+	if s, ok := value.(string); ok {
+		s = strings.TrimSpace(s)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", s)
+		}
+		return f, nil
+	}
+
+	if i, ok := toInt(value); ok {
+		return i, nil
+	}
+	if f, ok := toFloat(value); ok {
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("%s is not a number", describe(value))
+}
+
+// toTime converts the date or the RFC 3339 (or yyyy-mm-dd) formatted string to a date
+func toTime(value interface{}) (time.Time, error) {
+
+	switch t := value.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			return parsed, nil
+		}
+		if parsed, err := time.Parse("2006-01-02", t); err == nil {
+			return parsed, nil
+		}
+		return time.Time{}, fmt.Errorf("'%s' is not a date", t)
+	}
+
+	return time.Time{}, fmt.Errorf("%s is not a date", describe(value))
+}
+
+func toArray(value interface{}) ([]interface{}, error) {
+
+	if items, ok := value.([]interface{}); ok {
+		return items, nil
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%s is not an array", describe(value))
+	}
+
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+
+	return items, nil
+}
+
+func clamp(i, max int64) int64 {
+	if i < 0 {
+		return 0
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
@@ -0,0 +1,198 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokRef
+	tokOp
+)
+
+// token is a lexical token of an expression, pos is its byte offset in the expression
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators are the operators of the language, longest first so they are matched greedily
+var operators = []string{
+	"?.[", "?.", "??", "==", "!=", "<=", ">=", "&&", "||",
+	"?", ":", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ",", ".",
+}
+
+// lex splits the expression into tokens
+func lex(expr string) ([]token, error) {
+
+	var tokens []token
+
+	for pos := 0; pos < len(expr); {
+
+		r, size := utf8.DecodeRuneInString(expr[pos:])
+
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+
+		case r >= '0' && r <= '9' || r == '.' && startsFraction(expr, pos, tokens):
+			end := scanNumber(expr, pos)
+			tokens = append(tokens, token{kind: tokNumber, text: expr[pos:end], pos: pos})
+			pos = end
+
+		case r == '"' || r == '\'':
+			text, end, err := scanString(expr, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: pos})
+			pos = end
+
+		case r == '$':
+			end := scanIdent(expr, pos+1)
+			if end == pos+1 {
+				return nil, fmt.Errorf("invalid reference at position %d", pos)
+			}
+			if expr[pos+1:end] == "activity" && end < len(expr) && expr[end] == '[' {
+				// the id of the activity, ex. $activity[log_1]
+				closing := strings.IndexByte(expr[end:], ']')
+				if closing < 0 {
+					return nil, fmt.Errorf("unterminated activity reference at position %d", pos)
+				}
+				end += closing + 1
+			}
+			tokens = append(tokens, token{kind: tokRef, text: expr[pos:end], pos: pos})
+			pos = end
+
+		case isIdentRune(r, true):
+			end := scanIdent(expr, pos)
+			tokens = append(tokens, token{kind: tokIdent, text: expr[pos:end], pos: pos})
+			pos = end
+
+		default:
+			op := matchOperator(expr[pos:])
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character '%c' at position %d", r, pos)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: pos})
+			pos += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(expr)}), nil
+}
+
+func matchOperator(s string) string {
+
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			// a ternary followed by a fraction, ex. a ?.5 : 1
+			if op == "?." && len(s) > 2 && s[2] >= '0' && s[2] <= '9' {
+				continue
+			}
+			return op
+		}
+	}
+
+	return ""
+}
+
+// startsFraction determines if the '.' at pos starts a number (ex. .5), which is the case
+// when it is followed by a digit and doesn't follow an operand
+func startsFraction(expr string, pos int, tokens []token) bool {
+
+	if pos+1 >= len(expr) || expr[pos+1] < '0' || expr[pos+1] > '9' {
+		return false
+	}
+
+	if len(tokens) == 0 {
+		return true
+	}
+
+	prev := tokens[len(tokens)-1]
+	return prev.kind == tokOp && prev.text != ")" && prev.text != "]"
+}
+
+func isIdentRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || !first && unicode.IsDigit(r)
+}
+
+func scanIdent(expr string, pos int) int {
+
+	for pos < len(expr) {
+		r, size := utf8.DecodeRuneInString(expr[pos:])
+		if !isIdentRune(r, false) {
+			break
+		}
+		pos += size
+	}
+
+	return pos
+}
+
+func scanNumber(expr string, pos int) int {
+
+	seenDot, seenExp := expr[pos] == '.', false
+	if seenDot {
+		pos++
+	}
+
+	for pos < len(expr) {
+		c := expr[pos]
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' && !seenDot && !seenExp && pos+1 < len(expr) && expr[pos+1] >= '0' && expr[pos+1] <= '9':
+			seenDot = true
+		case (c == 'e' || c == 'E') && !seenExp:
+			seenExp = true
+			if pos+1 < len(expr) && (expr[pos+1] == '+' || expr[pos+1] == '-') {
+				pos++
+			}
+		default:
+			return pos
+		}
+		pos++
+	}
+
+	return pos
+}
+
+// scanString scans the quoted string starting at pos, returning its unescaped text
+func scanString(expr string, pos int) (string, int, error) {
+
+	quote := expr[pos]
+	var text []byte
+
+	for i := pos + 1; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == quote:
+			return string(text), i + 1, nil
+		case c == '\\' && i+1 < len(expr):
+			i++
+			switch expr[i] {
+			case 'n':
+				text = append(text, '\n')
+			case 't':
+				text = append(text, '\t')
+			case 'r':
+				text = append(text, '\r')
+			default:
+				text = append(text, expr[i])
+			}
+		default:
+			text = append(text, c)
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string at position %d", pos)
+}
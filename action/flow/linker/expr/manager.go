@@ -0,0 +1,104 @@
+// Package expr is the "expr" link expression language, a flow selects it by setting
+// its exprLanguage.  Besides the comparison, logical and arithmetic operators it supports
+// ternaries (a ? b : c), null coalescing (a ?? b), null-safe member access
+// ($flow.order?.customer?.name) and the string, number, date and array functions.
+package expr
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+)
+
+// Language is the name flows select the language with
+const Language = "expr"
+
+func init() {
+	definition.RegisterExprLanguage(Language, NewLinkExprManagerFactory())
+}
+
+type linkExprManagerFactory struct {
+}
+
+// NewLinkExprManagerFactory creates the factory of the link expression managers of the language
+func NewLinkExprManagerFactory() definition.LinkExprManagerFactory {
+	return &linkExprManagerFactory{}
+}
+
+func (f *linkExprManagerFactory) NewLinkExprManager() definition.LinkExprManager {
+	return &linkExprManager{exprs: make(map[*definition.Link]*Expression)}
+}
+
+// linkExprManager evaluates the link expressions of a flow, compiling each of them once
+type linkExprManager struct {
+	mu    sync.RWMutex
+	exprs map[*definition.Link]*Expression
+}
+
+// CompileLinkExprs compiles the link expressions of the flow, so that invalid expressions
+// are reported when the flow is materialized
+func (m *linkExprManager) CompileLinkExprs(def *definition.Definition) error {
+
+	for _, link := range definition.GetExpressionLinks(def) {
+		if _, err := m.compile(link); err != nil {
+			return fmt.Errorf("invalid expression of link '%d', %s", link.ID(), err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (m *linkExprManager) EvalLinkExpr(link *definition.Link, scope data.Scope) (bool, error) {
+
+	if link.Value() == "" {
+		return true, nil
+	}
+
+	expr, err := m.compile(link)
+	if err != nil {
+		return false, err
+	}
+
+	value, err := expr.Eval(scope)
+	if err != nil {
+		return false, err
+	}
+
+	switch t := value.(type) {
+	case bool:
+		return t, nil
+	case nil:
+		return false, nil
+	}
+
+	result, err := data.CoerceToBoolean(value)
+	if err != nil {
+		return false, fmt.Errorf("expression '%s' isn't a boolean, %s", link.Value(), err.Error())
+	}
+
+	return result, nil
+}
+
+func (m *linkExprManager) compile(link *definition.Link) (*Expression, error) {
+
+	m.mu.RLock()
+	expr := m.exprs[link]
+	m.mu.RUnlock()
+
+	if expr != nil {
+		return expr, nil
+	}
+
+	expr, err := Compile(link.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.exprs[link] = expr
+	m.mu.Unlock()
+
+	return expr, nil
+}
@@ -0,0 +1,370 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// node is a node of the syntax tree of an expression
+type node interface {
+	eval(ctx *evalContext) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+type arrayNode struct {
+	items []node
+}
+
+// refNode is a reference to an attribute of the scope, ex. $flow.order or $activity[log].message
+type refNode struct {
+	root string
+	attr string
+	safe bool
+}
+
+// memberNode accesses a member of a value by name or index, a safe access of a null
+// value is null
+type memberNode struct {
+	target node
+	name   string
+	index  node
+	safe   bool
+}
+
+type callNode struct {
+	name string
+	fn   Function
+	args []node
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+type ternaryNode struct {
+	cond, then, otherwise node
+}
+
+// Expression is a compiled expression
+type Expression struct {
+	source string
+	root   node
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Compile compiles the expression, the functions are resolved when it is compiled
+func Compile(source string) (*Expression, error) {
+
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression '%s', %s", source, err.Error())
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.parseExpr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression '%s', %s", source, err.Error())
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the specified operators
+func (p *parser) accept(ops ...string) (string, bool) {
+
+	t := p.peek()
+	if t.kind != tokOp {
+		return "", false
+	}
+
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) unexpected() error {
+
+	t := p.peek()
+	if t.kind == tokEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+
+	return fmt.Errorf("unexpected '%s' at position %d", t.text, t.pos)
+}
+
+// parseExpr parses a ternary, the lowest precedence expression
+func (p *parser) parseExpr() (node, error) {
+
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+
+	otherwise, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ternaryNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// binaryPrecedence are the binary operators by increasing precedence
+var binaryPrecedence = [][]string{
+	{"??"},
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+
+	if level == len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(binaryPrecedence[level]...)
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+
+	target, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(".", "?.", "[", "?.[")
+		if !ok {
+			return target, nil
+		}
+
+		member := &memberNode{target: target, safe: strings.HasPrefix(op, "?")}
+
+		if strings.HasSuffix(op, "[") {
+			if member.index, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		} else {
+			name := p.next()
+			if name.kind != tokIdent {
+				p.pos--
+				return nil, p.unexpected()
+			}
+			member.name = name.text
+		}
+
+		if ref, isRef := target.(*refNode); isRef && ref.attr == "" && member.index == nil {
+			// the attribute of the reference, ex. the order of $flow.order
+			ref.attr, ref.safe = member.name, member.safe
+			continue
+		}
+
+		target = member
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+
+	t := p.next()
+
+	switch t.kind {
+	case tokNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literalNode{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at position %d", t.text, t.pos)
+		}
+		return &literalNode{value: f}, nil
+
+	case tokString:
+		return &literalNode{value: t.text}, nil
+
+	case tokRef:
+		return &refNode{root: t.text}, nil
+
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{}, nil
+		}
+		return p.parseCall(t)
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			inner, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &arrayNode{items: items}, nil
+		}
+	}
+
+	p.pos--
+	return nil, p.unexpected()
+}
+
+// parseCall parses a call of a function, the name of which can be qualified (ex. string.length)
+func (p *parser) parseCall(first token) (node, error) {
+
+	name := first.text
+
+	for p.peek().kind == tokOp && p.peek().text == "." {
+		p.next()
+		part := p.next()
+		if part.kind != tokIdent {
+			p.pos--
+			return nil, p.unexpected()
+		}
+		name += "." + part.text
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, fmt.Errorf("unknown identifier '%s' at position %d", name, first.pos)
+	}
+
+	fn, exists := GetFunction(name)
+	if !exists {
+		return nil, fmt.Errorf("unknown function '%s' at position %d", name, first.pos)
+	}
+
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+
+	return &callNode{name: name, fn: fn, args: args}, nil
+}
+
+// parseList parses the comma separated expressions up to the closing operator
+func (p *parser) parseList(closing string) ([]node, error) {
+
+	var items []node
+
+	if _, ok := p.accept(closing); ok {
+		return items, nil
+	}
+
+	for {
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if _, ok := p.accept(closing); ok {
+			return items, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
 	"github.com/TIBCOSoftware/flogo-lib/core/mapper/exprmapper"
+
+	// the expr link expression language
+	_ "github.com/TIBCOSoftware/flogo-contrib/action/flow/linker/expr"
 )
 
 var log = logger.GetLogger("linker")
@@ -57,6 +57,28 @@ type TaskContext interface {
 	GetWorkingData(key string) (*data.Attribute, bool)
 }
 
+// RetryContext is optionally implemented by a TaskContext tracking the attempts at
+// evaluating the Activity of its Task, see definition.RetryPolicy
+type RetryContext interface {
+
+	// Attempt returns the current (1-based) attempt at evaluating the Activity
+	Attempt() int
+
+	// SetAttempt sets the current attempt at evaluating the Activity
+	SetAttempt(attempt int)
+}
+
+// JoinContext is optionally implemented by a TaskContext tracking if its join Task
+// already fired, see simple.JoinTaskBehavior
+type JoinContext interface {
+
+	// Joined determines if the join Task already fired in the flow instance
+	Joined() bool
+
+	// SetJoined marks the join Task as fired in the flow instance
+	SetJoined(joined bool)
+}
+
 // LinkInstance is the instance of a link
 type LinkInstance interface {
 
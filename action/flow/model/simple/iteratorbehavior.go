@@ -96,7 +96,7 @@ func (tb *IteratorTaskBehavior) Eval(ctx model.TaskContext) (evalResult model.Ev
 		iteration["key"] = itx.Key()
 		iteration["value"] = itx.Value()
 
-		done, err := ctx.EvalActivity()
+		done, err := evalActivity(ctx)
 
 		if err != nil {
 			log.Errorf("Error evaluating activity '%s'[%s] - %s", ctx.Task().Name(), ctx.Task().ActivityConfig().Ref(), err.Error())
@@ -0,0 +1,128 @@
+package simple
+
+import (
+	"strconv"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+)
+
+// JoinTaskBehavior implements model.TaskBehavior for tasks joining the branches of a
+// parallel split.  By default the task waits for all its branches, the 'wait' setting
+// specifies the number of branches to wait for instead (N-of-M join).  The branches
+// completing after the join fired are ignored, an N-of-M join requires a TaskContext
+// implementing model.JoinContext to track it fired.
+type JoinTaskBehavior struct {
+	TaskBehavior
+}
+
+// Enter implements model.TaskBehavior.Enter
+func (tb *JoinTaskBehavior) Enter(ctx model.TaskContext) (enterResult model.EnterResult) {
+
+	task := ctx.Task()
+	joinCtx, _ := ctx.(model.JoinContext)
+
+	if joinCtx != nil && joinCtx.Joined() {
+		log.Debugf("Join Task '%s' already fired, ignoring branch", task.ID())
+		ctx.SetStatus(model.TaskStatusSkipped)
+		return model.ENTER_SKIP
+	}
+
+	ctx.SetStatus(model.TaskStatusEntered)
+
+	linkInsts := ctx.GetFromLinkInstances()
+
+	wait, err := getJoinWait(ctx, len(linkInsts))
+	if err != nil {
+		log.Errorf("Join Task '%s' has an invalid wait setting, waiting for all branches - %s", task.ID(), err.Error())
+		wait = len(linkInsts)
+	}
+	if joinCtx == nil && wait < len(linkInsts) {
+		log.Warnf("Join Task '%s' can't track the late branches in this context, waiting for all branches", task.ID())
+		wait = len(linkInsts)
+	}
+
+	completed, pending := 0, 0
+	for _, linkInst := range linkInsts {
+		if linkInst.Status() < model.LinkStatusFalse {
+			pending++
+		} else if linkInst.Status() == model.LinkStatusTrue {
+			completed++
+		}
+	}
+
+	log.Debugf("Join Task '%s': %d of %d branches completed, %d pending, waiting for %d", task.ID(), completed, len(linkInsts), pending, wait)
+
+	if completed > 0 && (completed >= wait || pending == 0) {
+		if pending > 0 {
+			// only an N-of-M join, so one having a JoinContext, fires with pending branches
+			joinCtx.SetJoined(true)
+		}
+		ctx.SetStatus(model.TaskStatusReady)
+		return model.ENTER_EVAL
+	}
+
+	if pending == 0 {
+		ctx.SetStatus(model.TaskStatusSkipped)
+		return model.ENTER_SKIP
+	}
+
+	return model.ENTER_NOTREADY
+}
+
+// Eval implements model.TaskBehavior.Eval
+func (tb *JoinTaskBehavior) Eval(ctx model.TaskContext) (evalResult model.EvalResult, err error) {
+
+	if ctx.Status() == model.TaskStatusSkipped {
+		return model.EVAL_SKIP, nil
+	}
+
+	if ctx.Task().ActivityConfig() == nil {
+		// a plain gateway
+		return model.EVAL_DONE, nil
+	}
+
+	return tb.TaskBehavior.Eval(ctx)
+}
+
+// Skip implements model.TaskBehavior.Skip
+func (tb *JoinTaskBehavior) Skip(ctx model.TaskContext) (notifyFlow bool, taskEntries []*model.TaskEntry) {
+
+	if joinCtx, ok := ctx.(model.JoinContext); ok && joinCtx.Joined() {
+		// a late branch, the successors of the join were already entered
+		ctx.SetStatus(model.TaskStatusSkipped)
+		return true, nil
+	}
+
+	return tb.TaskBehavior.Skip(ctx)
+}
+
+// getJoinWait returns the number of branches the join task waits for
+func getJoinWait(ctx model.TaskContext, branches int) (int, error) {
+
+	value, set := ctx.Task().GetSetting("wait")
+	if !set || value == "all" {
+		return branches, nil
+	}
+
+	var wait int
+
+	switch v := value.(type) {
+	case int:
+		wait = v
+	case float64:
+		wait = int(v)
+	case string:
+		var err error
+		if wait, err = strconv.Atoi(v); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, strconv.ErrSyntax
+	}
+
+	if wait < 1 || wait > branches {
+		return 0, strconv.ErrRange
+	}
+
+	return wait, nil
+}
@@ -21,6 +21,7 @@ func New() *model.FlowModel {
 	m.RegisterFlowBehavior(&FlowBehavior{})
 	m.RegisterDefaultTaskBehavior("basic", &TaskBehavior{})
 	m.RegisterTaskBehavior("iterator", &IteratorTaskBehavior{})
+	m.RegisterTaskBehavior("join", &JoinTaskBehavior{})
 
 	return m
 }
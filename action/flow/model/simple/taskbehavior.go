@@ -1,6 +1,9 @@
 package simple
 
 import (
+	"context"
+	"time"
+
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
 )
@@ -72,7 +75,7 @@ func (tb *TaskBehavior) Eval(ctx model.TaskContext) (evalResult model.EvalResult
 	task := ctx.Task()
 	log.Debugf("Eval Task '%s'", task.ID())
 
-	done, err := ctx.EvalActivity()
+	done, err := evalActivity(ctx)
 
 	if err != nil {
 		log.Errorf("Error evaluating activity '%s'[%s] - %s", ctx.Task().ID(), ctx.Task().ActivityConfig().Ref(), err.Error())
@@ -89,6 +92,54 @@ func (tb *TaskBehavior) Eval(ctx model.TaskContext) (evalResult model.EvalResult
 	return evalResult, nil
 }
 
+// evalActivity evaluates the activity of the task, retrying it according to the
+// retry policy of the task while it fails with a retryable error
+func evalActivity(ctx model.TaskContext) (done bool, err error) {
+
+	policy := ctx.Task().RetryPolicy()
+	retryCtx, _ := ctx.(model.RetryContext)
+
+	for attempt := 1; ; attempt++ {
+		if retryCtx != nil {
+			retryCtx.SetAttempt(attempt)
+		}
+
+		done, err = ctx.EvalActivity()
+
+		if policy == nil || attempt >= policy.MaxAttempts() || !policy.Retryable(err) {
+			return done, err
+		}
+
+		delay := policy.Delay(attempt)
+		log.Warnf("Attempt %d of %d evaluating activity '%s' failed, retrying in %s - %s", attempt, policy.MaxAttempts(), ctx.Task().ID(), delay, err.Error())
+
+		if !waitRetry(ctx, delay) {
+			log.Warnf("Retries of activity '%s' stopped, its context is done", ctx.Task().ID())
+			return done, err
+		}
+	}
+}
+
+// waitRetry waits the delay before retrying the activity of the task, false is returned
+// if the context of the task is done first (ex. the instance was cancelled)
+func waitRetry(ctx model.TaskContext, delay time.Duration) bool {
+
+	var done <-chan struct{}
+	if taskCtx, ok := ctx.(interface{ Context() context.Context }); ok {
+		done = taskCtx.Context().Done()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
 // PostEval implements model.TaskBehavior.PostEval
 func (tb *TaskBehavior) PostEval(ctx model.TaskContext) (evalResult model.EvalResult, err error) {
 
@@ -0,0 +1,95 @@
+package simple
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// retryTaskContext evaluates an activity failing a number of times, recording the attempts
+type retryTaskContext struct {
+	model.TaskContext
+
+	ctx      context.Context
+	task     *definition.Task
+	failures int
+	err      error
+
+	attempts []int
+	times    []time.Time
+}
+
+func (c *retryTaskContext) Task() *definition.Task   { return c.task }
+func (c *retryTaskContext) Attempt() int             { return len(c.attempts) }
+func (c *retryTaskContext) SetAttempt(attempt int)   { c.attempts = append(c.attempts, attempt) }
+func (c *retryTaskContext) Context() context.Context { return c.ctx }
+
+func (c *retryTaskContext) EvalActivity() (bool, error) {
+	c.times = append(c.times, time.Now())
+	if len(c.times) <= c.failures {
+		return false, c.err
+	}
+	return true, nil
+}
+
+func newRetryTask(t *testing.T, retry string) *definition.Task {
+
+	defRep := &definition.DefinitionRep{}
+	err := json.Unmarshal([]byte(fmt.Sprintf(`{"name": "Retry Flow", "model": "simple", "tasks": [{"id": "fetch", "retry": %s}]}`, retry)), defRep)
+	assert.Nil(t, err)
+
+	def, err := definition.NewDefinition(defRep)
+	assert.Nil(t, err)
+
+	return def.GetTask("fetch")
+}
+
+func TestEvalActivityRetries(t *testing.T) {
+
+	task := newRetryTask(t, `{"maxAttempts": 4, "backoff": "exponential", "interval": "20ms", "retryOn": ["503"]}`)
+
+	// the activity is retried with an exponential backoff until it succeeds
+	ctx := &retryTaskContext{ctx: context.Background(), task: task, failures: 2, err: errors.New("status 503")}
+	done, err := evalActivity(ctx)
+	assert.True(t, done)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3}, ctx.attempts)
+	assert.True(t, ctx.times[1].Sub(ctx.times[0]) >= 20*time.Millisecond)
+	assert.True(t, ctx.times[2].Sub(ctx.times[1]) >= 40*time.Millisecond)
+
+	// until the max attempts
+	ctx = &retryTaskContext{ctx: context.Background(), task: task, failures: 10, err: errors.New("status 503")}
+	_, err = evalActivity(ctx)
+	assert.Equal(t, "status 503", err.Error())
+	assert.Equal(t, []int{1, 2, 3, 4}, ctx.attempts)
+
+	// only the errors matching retryOn are retried
+	ctx = &retryTaskContext{ctx: context.Background(), task: task, failures: 10, err: errors.New("status 400")}
+	_, err = evalActivity(ctx)
+	assert.Equal(t, "status 400", err.Error())
+	assert.Equal(t, []int{1}, ctx.attempts)
+}
+
+func TestEvalActivityRetryCancelled(t *testing.T) {
+
+	task := newRetryTask(t, `{"maxAttempts": 3, "interval": "1m"}`)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	ctx := &retryTaskContext{ctx: cancelled, task: task, failures: 10, err: errors.New("failed")}
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	// the retries stop without waiting the backoff once the context is done
+	start := time.Now()
+	done, err := evalActivity(ctx)
+	assert.False(t, done)
+	assert.Equal(t, "failed", err.Error())
+	assert.Equal(t, []int{1}, ctx.attempts)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
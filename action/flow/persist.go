@@ -0,0 +1,154 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/instance"
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// ErrCancelled is the result of an instance cancelled using CancelInstance before it
+// completed or failed
+var ErrCancelled = errors.New("flow instance cancelled")
+
+// instanceStates tracks the persisted state of the instances of a flow action
+type instanceStates struct {
+	mu        sync.RWMutex
+	store     instance.StateStore
+	active    map[string]bool
+	cancelled map[string]bool
+}
+
+// SetStateStore sets the store the state of the instances of the action is checkpointed
+// to after each step, so they can be resumed using ResumeInstance after a crash (ex. a
+// SQLStateStore).  The state of an instance is deleted once it completes or fails, nil
+// disables the checkpoints.
+func (fa *FlowAction) SetStateStore(store instance.StateStore) {
+	fa.states.mu.Lock()
+	defer fa.states.mu.Unlock()
+	fa.states.store = store
+}
+
+func (fa *FlowAction) stateStore() instance.StateStore {
+	fa.states.mu.RLock()
+	defer fa.states.mu.RUnlock()
+	return fa.states.store
+}
+
+// PersistedInstances lists the ids of the instances of the action with a persisted state
+func (fa *FlowAction) PersistedInstances() ([]string, error) {
+
+	store := fa.stateStore()
+	if store == nil {
+		return nil, errors.New("state store not set")
+	}
+
+	return store.List()
+}
+
+// ResumeInstance resumes the persisted flow instance with the specified id from its
+// last checkpoint, the results of the instance are passed to the handler
+func (fa *FlowAction) ResumeInstance(ctx context.Context, id string, handler action.ResultHandler) error {
+
+	store := fa.stateStore()
+	if store == nil {
+		return errors.New("state store not set")
+	}
+
+	inst, err := instance.LoadInstance(store, id)
+	if err != nil {
+		return err
+	}
+
+	if err := inst.Restart(id, manager); err != nil {
+		return err
+	}
+
+	logger.Infof("Resuming persisted flow instance [%s]", id)
+
+	ro := &instance.RunOptions{Op: instance.OpResume, InitialState: inst, FlowURI: inst.FlowURI()}
+	attr, _ := data.NewAttribute("_run_options", data.TypeAny, ro)
+
+	return fa.Run(ctx, map[string]*data.Attribute{attr.Name(): attr}, handler)
+}
+
+// CancelInstance cancels the flow instance with the specified id, deleting its persisted
+// state.  A running instance is routed to its error handler at its next step.
+func (fa *FlowAction) CancelInstance(id string) error {
+
+	fa.states.mu.Lock()
+	if fa.states.active[id] {
+		// the instance is no longer checkpointed, it is forgotten once it finishes
+		if fa.states.cancelled == nil {
+			fa.states.cancelled = make(map[string]bool)
+		}
+		fa.states.cancelled[id] = true
+	}
+	store := fa.states.store
+	fa.states.mu.Unlock()
+
+	cancelRunning(id)
+
+	if store == nil {
+		return nil
+	}
+
+	return store.Delete(id)
+}
+
+// startInstance registers the instance as run by the action until finishInstance is called
+func (fa *FlowAction) startInstance(id string) {
+	fa.states.mu.Lock()
+	defer fa.states.mu.Unlock()
+
+	if fa.states.active == nil {
+		fa.states.active = make(map[string]bool)
+	}
+	fa.states.active[id] = true
+}
+
+// finishInstance forgets the instance once the action is done running it
+func (fa *FlowAction) finishInstance(id string) {
+	fa.states.mu.Lock()
+	defer fa.states.mu.Unlock()
+
+	delete(fa.states.active, id)
+	delete(fa.states.cancelled, id)
+}
+
+// isCancelled determines if the instance was cancelled
+func (fa *FlowAction) isCancelled(id string) bool {
+	fa.states.mu.RLock()
+	defer fa.states.mu.RUnlock()
+	return fa.states.cancelled[id]
+}
+
+// checkpoint saves the state of the running instance if a state store is set
+func (fa *FlowAction) checkpoint(inst *instance.IndependentInstance) {
+
+	store := fa.stateStore()
+	if store == nil || fa.isCancelled(inst.ID()) {
+		return
+	}
+
+	if err := instance.SaveInstance(store, inst); err != nil {
+		logger.Warnf("Unable to checkpoint flow instance [%s]: %s", inst.ID(), err.Error())
+	}
+}
+
+// releaseInstance deletes the persisted state of the finished instance
+func (fa *FlowAction) releaseInstance(inst *instance.IndependentInstance) {
+
+	store := fa.stateStore()
+	if store == nil {
+		return
+	}
+
+	if err := store.Delete(inst.ID()); err != nil {
+		logger.Warnf("Unable to delete the state of flow instance [%s]: %s", inst.ID(), err.Error())
+	}
+}
@@ -0,0 +1,79 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/instance"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+// resultRecorder records the results passed to a ResultHandler
+type resultRecorder struct {
+	errs []error
+}
+
+func (r *resultRecorder) HandleResult(resultData map[string]*data.Attribute, err error) {
+	r.errs = append(r.errs, err)
+}
+
+func (r *resultRecorder) Done() {}
+
+func TestCancelInstanceIsForgotten(t *testing.T) {
+
+	store := instance.NewMemoryStateStore()
+	store.Save("running", []byte("{}"))
+	store.Save("persisted", []byte("{}"))
+
+	fa := &FlowAction{}
+	fa.SetStateStore(store)
+
+	// the state of a running instance is deleted and no longer checkpointed
+	fa.startInstance("running")
+	assert.Nil(t, fa.CancelInstance("running"))
+	assert.True(t, fa.isCancelled("running"))
+
+	// the cancelled instance is forgotten once it finishes
+	fa.finishInstance("running")
+	assert.False(t, fa.isCancelled("running"))
+
+	// an instance which isn't running is only deleted
+	assert.Nil(t, fa.CancelInstance("persisted"))
+	assert.False(t, fa.isCancelled("persisted"))
+	assert.Empty(t, fa.states.cancelled)
+
+	ids, err := fa.PersistedInstances()
+	assert.Nil(t, err)
+	assert.Empty(t, ids)
+
+	// the actions have their own store
+	_, err = (&FlowAction{}).PersistedInstances()
+	assert.NotNil(t, err)
+}
+
+func TestHandleResultCancelled(t *testing.T) {
+
+	def, err := definition.NewDefinition(&definition.DefinitionRep{Name: "test"})
+	assert.Nil(t, err)
+
+	fa := &FlowAction{}
+	recorder := &resultRecorder{}
+
+	// an instance which was neither completed nor failed has no result
+	inst := instance.NewIndependentInstance("1", "uri", def)
+	fa.handleResult(inst, recorder)
+	assert.Empty(t, recorder.errs)
+
+	// unless it was cancelled
+	fa.startInstance("1")
+	assert.Nil(t, fa.CancelInstance("1"))
+	fa.handleResult(inst, recorder)
+	assert.Equal(t, []error{ErrCancelled}, recorder.errs)
+
+	inst.SetStatus(model.FlowStatusCancelled)
+	fa.finishInstance("1")
+	fa.handleResult(inst, recorder)
+	assert.Equal(t, []error{ErrCancelled, ErrCancelled}, recorder.errs)
+}
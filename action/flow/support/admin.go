@@ -0,0 +1,276 @@
+package support
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// FlowInfo describes a flow loaded by a FlowManager
+type FlowInfo struct {
+	// ID is the id of the resource of an embedded flow, the uri of a remote flow
+	ID  string `json:"id"`
+	URI string `json:"uri"`
+
+	Name      string `json:"name"`
+	Version   string `json:"version,omitempty"`
+	TaskCount int    `json:"taskCount"`
+
+	// Embedded indicates if the flow is an embedded flow (res://) rather than a cached remote flow
+	Embedded bool `json:"embedded"`
+
+	// Loaded is the time the flow was loaded (or last reloaded)
+	Loaded time.Time `json:"loaded"`
+
+	// Expires is the time a cached remote flow expires, zero if it never expires
+	Expires time.Time `json:"expires"`
+
+	// Pinned indicates if the cached remote flow is pinned
+	Pinned bool `json:"pinned,omitempty"`
+
+	// Pending indicates if the embedded flow was loaded lazily and isn't materialized
+	// yet, only its id, uri and load time are known
+	Pending bool `json:"pending,omitempty"`
+}
+
+func newFlowInfo(id string, uri string, flow *definition.Definition, loaded time.Time) *FlowInfo {
+	return &FlowInfo{
+		ID:        id,
+		URI:       uri,
+		Name:      flow.Name(),
+		Version:   flow.Version(),
+		TaskCount: flow.TaskCount(),
+		Loaded:    loaded,
+	}
+}
+
+func newPendingInfo(id string, lazy *lazyResource) *FlowInfo {
+	return &FlowInfo{ID: id, URI: uriSchemeRes + id, Embedded: true, Loaded: lazy.loaded, Pending: true}
+}
+
+// ListFlows lists the embedded flows and the cached remote flows, sorted by uri
+func (fm *FlowManager) ListFlows() []*FlowInfo {
+
+	var infos []*FlowInfo
+
+	fm.resMu.RLock()
+	for id, flow := range fm.resFlows {
+		info := newFlowInfo(id, uriSchemeRes+id, flow, fm.resLoaded[id])
+		info.Embedded = true
+		infos = append(infos, info)
+	}
+	for id, lazy := range fm.lazyFlows {
+		infos = append(infos, newPendingInfo(id, lazy))
+	}
+	fm.resMu.RUnlock()
+
+	fm.rfMu.Lock()
+	for uri, entry := range fm.remoteFlows {
+		info := newFlowInfo(uri, uri, entry.flow, entry.loaded)
+		info.Expires = entry.expires
+		info.Pinned = fm.pinned[uri]
+		infos = append(infos, info)
+	}
+	fm.rfMu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].URI < infos[j].URI })
+
+	return infos
+}
+
+// GetFlowInfo describes the loaded flow with the specified uri, false is returned if
+// the flow isn't loaded.  Unlike GetFlow, the flow isn't loaded if it isn't already.
+func (fm *FlowManager) GetFlowInfo(uri string) (*FlowInfo, bool) {
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		id := uri[len(uriSchemeRes):]
+
+		fm.resMu.RLock()
+		defer fm.resMu.RUnlock()
+
+		flow, exists := fm.resFlows[id]
+		if !exists {
+			if lazy, pending := fm.lazyFlows[id]; pending {
+				return newPendingInfo(id, lazy), true
+			}
+			return nil, false
+		}
+
+		info := newFlowInfo(id, uri, flow, fm.resLoaded[id])
+		info.Embedded = true
+		return info, true
+	}
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	entry, exists := fm.remoteFlows[uri]
+	if !exists {
+		return nil, false
+	}
+
+	info := newFlowInfo(uri, uri, entry.flow, entry.loaded)
+	info.Expires = entry.expires
+	info.Pinned = fm.pinned[uri]
+	return info, true
+}
+
+// UnloadFlow unloads the flow with the specified uri.  An unloaded embedded flow is
+// gone until its resource is loaded again, an unloaded remote flow is fetched from the
+// provider the next time it is requested.  Use DeleteFlow to keep the flow restorable.
+func (fm *FlowManager) UnloadFlow(uri string) error {
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		if flow, _ := fm.removeResFlow(uri[len(uriSchemeRes):]); flow == nil {
+			return fmt.Errorf("flow not found for uri '%s'", uri)
+		}
+		return nil
+	}
+
+	fm.rfMu.Lock()
+	_, exists := fm.remoteFlows[uri]
+	delete(fm.remoteFlows, uri)
+	delete(fm.pinned, uri)
+	fm.rfMu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("flow not found for uri '%s'", uri)
+	}
+
+	fm.dropVersions(uri)
+	return nil
+}
+
+// DumpFlow serializes the loaded flow with the specified uri back to JSON, as it was
+// materialized (ex. after the post-processing pipeline)
+func (fm *FlowManager) DumpFlow(uri string) ([]byte, error) {
+
+	var flow *definition.Definition
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		flow = fm.getResFlow(uri[len(uriSchemeRes):])
+	} else {
+		fm.rfMu.Lock()
+		if entry, exists := fm.remoteFlows[uri]; exists {
+			flow = entry.flow
+		}
+		fm.rfMu.Unlock()
+	}
+
+	if flow == nil {
+		return nil, fmt.Errorf("flow not found for uri '%s'", uri)
+	}
+
+	return flow.ToJSON()
+}
+
+// AdminHandler is an http.Handler exposing the management operations of a FlowManager,
+// the flow is specified using the 'uri' query parameter:
+//
+//	GET  /flows                 lists the loaded flows
+//	GET  /flows/info?uri=       describes a loaded flow
+//	GET  /flows/definition?uri= dumps the definition of a loaded flow
+//	POST /flows/reload?uri=     reloads a flow
+//	POST /flows/unload?uri=     unloads a flow
+//
+// The paths are relative to the path the handler is mounted on using http.StripPrefix.
+type AdminHandler struct {
+	manager *FlowManager
+}
+
+// NewAdminHandler creates an AdminHandler for the specified FlowManager
+func NewAdminHandler(manager *FlowManager) *AdminHandler {
+	return &AdminHandler{manager: manager}
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	uri := r.URL.Query().Get("uri")
+
+	switch r.URL.Path {
+	case "/flows":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, h.manager.ListFlows())
+
+	case "/flows/info":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		info, exists := h.manager.GetFlowInfo(uri)
+		if !exists {
+			http.Error(w, fmt.Sprintf("flow not found for uri '%s'", uri), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+
+	case "/flows/definition":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		flowJSON, err := h.manager.DumpFlow(uri)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(flowJSON)
+
+	case "/flows/reload":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		if _, err := h.manager.ReloadFlow(uri); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		info, _ := h.manager.GetFlowInfo(uri)
+		writeJSON(w, http.StatusOK, info)
+
+	case "/flows/unload":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		if err := h.manager.UnloadFlow(uri); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		logger.Errorf("Unable to serialize response: %s", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
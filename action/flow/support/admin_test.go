@@ -0,0 +1,134 @@
+package support
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAdminTestManager(t *testing.T) (*FlowManager, *fakeClock) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/orders": strings.Replace(testFlowJSON, `"name": "Test Flow",`, `"name": "Orders", "version": "2",`, 1),
+	})
+
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Cache: CacheConfig{TTL: time.Hour}, Clock: clock})
+
+	err := fm.LoadResource(&resource.Config{ID: "flow:payments", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+
+	clock.Advance(time.Minute)
+
+	_, err = fm.GetFlow("http://flows/orders")
+	assert.Nil(t, err)
+
+	return fm, clock
+}
+
+func TestListFlows(t *testing.T) {
+
+	fm, clock := newAdminTestManager(t)
+	fm.PinFlow("http://flows/orders")
+
+	infos := fm.ListFlows()
+	assert.Len(t, infos, 2)
+
+	remote := infos[0]
+	assert.Equal(t, "http://flows/orders", remote.URI)
+	assert.Equal(t, "Orders", remote.Name)
+	assert.Equal(t, "2", remote.Version)
+	assert.Equal(t, 2, remote.TaskCount)
+	assert.False(t, remote.Embedded)
+	assert.True(t, remote.Pinned)
+	assert.Equal(t, clock.Now(), remote.Loaded)
+	assert.Equal(t, clock.Now().Add(time.Hour), remote.Expires)
+
+	embedded := infos[1]
+	assert.Equal(t, "flow:payments", embedded.ID)
+	assert.Equal(t, "res://flow:payments", embedded.URI)
+	assert.True(t, embedded.Embedded)
+	assert.Equal(t, clock.Now().Add(-time.Minute), embedded.Loaded)
+
+	_, exists := fm.GetFlowInfo("http://flows/unknown")
+	assert.False(t, exists)
+}
+
+func TestUnloadFlow(t *testing.T) {
+
+	fm, _ := newAdminTestManager(t)
+
+	assert.Nil(t, fm.UnloadFlow("res://flow:payments"))
+	assert.Nil(t, fm.UnloadFlow("http://flows/orders"))
+	assert.Len(t, fm.ListFlows(), 0)
+
+	assert.NotNil(t, fm.UnloadFlow("res://flow:payments"))
+
+	// unloaded remote flows are fetched again
+	_, err := fm.GetFlow("http://flows/orders")
+	assert.Nil(t, err)
+	_, exists := fm.GetFlowInfo("http://flows/orders")
+	assert.True(t, exists)
+}
+
+func TestDumpFlow(t *testing.T) {
+
+	fm, _ := newAdminTestManager(t)
+
+	flowJSON, err := fm.DumpFlow("res://flow:payments")
+	assert.Nil(t, err)
+
+	var rep *definition.DefinitionRep
+	assert.Nil(t, json.Unmarshal(flowJSON, &rep))
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Len(t, rep.Tasks, 2)
+
+	_, err = fm.DumpFlow("res://flow:unknown")
+	assert.NotNil(t, err)
+}
+
+func TestAdminHandler(t *testing.T) {
+
+	fm, _ := newAdminTestManager(t)
+	handler := NewAdminHandler(fm)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/flows", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var infos []*FlowInfo
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &infos))
+	assert.Len(t, infos, 2)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/flows/definition?uri=res://flow:payments", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, strings.Contains(recorder.Body.String(), `"Test Flow"`))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/flows/unload?uri=http://flows/orders", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/flows/unload?uri=http://flows/orders", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/flows/info?uri=http://flows/orders", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/flows/reload?uri=http://flows/orders", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var info *FlowInfo
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &info))
+	assert.Equal(t, "Orders", info.Name)
+}
@@ -0,0 +1,110 @@
+package support
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+const uriSchemeFlow = "flow://"
+
+// WeightedURI is a flow uri and its relative weight in a weighted alias
+type WeightedURI struct {
+	URI    string
+	Weight int
+}
+
+// weightedAlias is an alias resolved to one of its targets chosen by weight
+type weightedAlias struct {
+	targets     []WeightedURI
+	totalWeight int
+}
+
+// SetFlowAlias sets a weighted alias, a flow requested as "flow://<alias>" resolves to
+// one of the target uris, chosen randomly according to the relative weights of the
+// targets (ex. 90% v1 and 10% v2 for a progressive rollout)
+func (fm *FlowManager) SetFlowAlias(alias string, targets []WeightedURI) error {
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets specified for flow alias '%s'", alias)
+	}
+
+	totalWeight := 0
+	for _, target := range targets {
+		if target.Weight < 0 {
+			return fmt.Errorf("invalid weight %d for target '%s' of flow alias '%s'", target.Weight, target.URI, alias)
+		}
+		totalWeight += target.Weight
+	}
+
+	if totalWeight == 0 {
+		return fmt.Errorf("flow alias '%s' doesn't have a target with a positive weight", alias)
+	}
+
+	fm.aliasMu.Lock()
+	defer fm.aliasMu.Unlock()
+
+	if fm.aliases == nil {
+		fm.aliases = make(map[string]*weightedAlias)
+	}
+	fm.aliases[alias] = &weightedAlias{targets: targets, totalWeight: totalWeight}
+
+	return nil
+}
+
+// RemoveFlowAlias removes the specified weighted alias
+func (fm *FlowManager) RemoveFlowAlias(alias string) {
+	fm.aliasMu.Lock()
+	defer fm.aliasMu.Unlock()
+
+	delete(fm.aliases, alias)
+}
+
+// resolveAlias selects the target uri of the alias uri
+func (fm *FlowManager) resolveAlias(uri string) (string, error) {
+
+	alias := strings.TrimPrefix(uri, uriSchemeFlow)
+
+	fm.aliasMu.Lock()
+	defer fm.aliasMu.Unlock()
+
+	wa, exists := fm.aliases[alias]
+	if !exists {
+		return "", fmt.Errorf("flow alias not found for uri '%s'", uri)
+	}
+
+	if fm.rand == nil {
+		fm.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	n := fm.rand.Intn(wa.totalWeight)
+	for _, target := range wa.targets {
+		if n < target.Weight {
+			return target.URI, nil
+		}
+		n -= target.Weight
+	}
+
+	// unreachable, the weights add up to the total weight
+	return "", errors.New("unable to select target of flow alias '" + alias + "'")
+}
+
+// getAliasFlow gets the flow of a target of the weighted alias uri
+func (fm *FlowManager) getAliasFlow(ctx context.Context, uri string) (*definition.Definition, error) {
+
+	target, err := fm.resolveAlias(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(target, uriSchemeFlow) {
+		return nil, fmt.Errorf("target '%s' of flow alias uri '%s' cannot be an alias", target, uri)
+	}
+
+	return fm.GetFlowWithContext(ctx, target)
+}
@@ -0,0 +1,66 @@
+package support
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFlowWeightedAlias(t *testing.T) {
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{RandSource: rand.NewSource(1)})
+
+	err := fm.LoadResource(&resource.Config{ID: "orders_v1", Data: []byte(strings.Replace(testFlowJSON, "Test Flow", "v1", 1))})
+	assert.Nil(t, err)
+	err = fm.LoadResource(&resource.Config{ID: "orders_v2", Data: []byte(strings.Replace(testFlowJSON, "Test Flow", "v2", 1))})
+	assert.Nil(t, err)
+
+	err = fm.SetFlowAlias("orders", []WeightedURI{{URI: "res://orders_v1", Weight: 90}, {URI: "res://orders_v2", Weight: 10}})
+	assert.Nil(t, err)
+
+	calls := 10000
+	counts := make(map[string]int)
+
+	for i := 0; i < calls; i++ {
+		flow, err := fm.GetFlow("flow://orders")
+		assert.Nil(t, err)
+		counts[flow.Name()]++
+	}
+
+	assert.Equal(t, calls, counts["v1"]+counts["v2"])
+	assert.InDelta(t, 0.9, float64(counts["v1"])/float64(calls), 0.02)
+	assert.InDelta(t, 0.1, float64(counts["v2"])/float64(calls), 0.02)
+}
+
+func TestGetFlowWeightedAliasDeterministic(t *testing.T) {
+
+	selections := func() []string {
+		fm := NewFlowManagerWithOptions(nil, &ManagerOptions{RandSource: rand.NewSource(42)})
+		fm.SetFlowAlias("orders", []WeightedURI{{URI: "res://orders_v1", Weight: 50}, {URI: "res://orders_v2", Weight: 50}})
+
+		var uris []string
+		for i := 0; i < 20; i++ {
+			uri, err := fm.resolveAlias("flow://orders")
+			assert.Nil(t, err)
+			uris = append(uris, uri)
+		}
+		return uris
+	}
+
+	assert.Equal(t, selections(), selections())
+}
+
+func TestSetFlowAliasInvalid(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	assert.NotNil(t, fm.SetFlowAlias("orders", nil))
+	assert.NotNil(t, fm.SetFlowAlias("orders", []WeightedURI{{URI: "res://orders_v1", Weight: 0}}))
+	assert.NotNil(t, fm.SetFlowAlias("orders", []WeightedURI{{URI: "res://orders_v1", Weight: -1}}))
+
+	_, err := fm.GetFlow("flow://unknown")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,247 @@
+package support
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+const archiveEntryExt = ".json"
+
+// ExportAll exports the embedded and cached remote flows of the manager as a tar.gz
+// archive, containing an entry per flow named after the escaped flow uri.  The
+// archive can be loaded using LoadArchive.
+func (fm *FlowManager) ExportAll() ([]byte, error) {
+
+	fm.materializeAll()
+
+	reps := make(map[string]*definition.DefinitionRep)
+
+	fm.resMu.RLock()
+	for id, rep := range fm.resReps {
+		reps[uriSchemeRes+id] = rep
+	}
+	fm.resMu.RUnlock()
+
+	fm.rfMu.Lock()
+	for uri, entry := range fm.remoteFlows {
+		if entry.rep != nil {
+			reps[uri] = entry.rep
+		}
+	}
+	fm.rfMu.Unlock()
+
+	uris := make([]string, 0, len(reps))
+	for uri := range reps {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for _, uri := range uris {
+		repBytes, err := exportRep(reps[uri])
+		if err != nil {
+			return nil, fmt.Errorf("error exporting flow with uri '%s', %s", uri, err.Error())
+		}
+
+		hdr := &tar.Header{
+			Name:    url.QueryEscape(uri) + archiveEntryExt,
+			Mode:    0644,
+			Size:    int64(len(repBytes)),
+			ModTime: fm.now(),
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(repBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ExportFlow exports the embedded or cached remote flow with the specified uri as
+// indented JSON.  Tasks and links are sorted (by id and by from/to task ids) so
+// exporting the same flow always produces the same output.
+func (fm *FlowManager) ExportFlow(uri string) ([]byte, error) {
+
+	var rep *definition.DefinitionRep
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		rep = fm.getResRep(uri[len(uriSchemeRes):])
+	} else {
+		fm.rfMu.Lock()
+		if entry, exists := fm.remoteFlows[uri]; exists {
+			rep = entry.rep
+		}
+		fm.rfMu.Unlock()
+	}
+
+	if rep == nil {
+		return nil, fmt.Errorf("flow not found for uri '%s'", uri)
+	}
+
+	repBytes, err := exportRep(rep)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting flow with uri '%s', %s", uri, err.Error())
+	}
+
+	return repBytes, nil
+}
+
+// exportRep serializes the flow with its tasks, links and metadata attributes sorted,
+// the rep itself isn't modified
+func exportRep(rep *definition.DefinitionRep) ([]byte, error) {
+
+	repBytes, err := json.Marshal(rep)
+	if err != nil {
+		return nil, err
+	}
+
+	var flow map[string]interface{}
+	if err := json.Unmarshal(repBytes, &flow); err != nil {
+		return nil, err
+	}
+
+	sortFlowObject(flow)
+
+	if errorHandler, ok := flow["errorHandler"].(map[string]interface{}); ok {
+		sortFlowObject(errorHandler)
+	}
+
+	if metadata, ok := flow["metadata"].(map[string]interface{}); ok {
+		sortObjects(metadata["input"], "name")
+		sortObjects(metadata["output"], "name")
+	}
+
+	// object keys are sorted by the encoder
+	return json.MarshalIndent(flow, "", "  ")
+}
+
+func sortFlowObject(obj map[string]interface{}) {
+	sortObjects(obj["tasks"], "id")
+	sortObjects(obj["links"], "from", "to", "type", "value")
+}
+
+// sortObjects sorts a JSON array of objects by the values of the specified keys
+func sortObjects(value interface{}, keys ...string) {
+
+	objs, ok := value.([]interface{})
+	if !ok {
+		return
+	}
+
+	sortKey := func(i int) []string {
+		obj, _ := objs[i].(map[string]interface{})
+		values := make([]string, len(keys))
+		for k, key := range keys {
+			values[k] = fmt.Sprint(obj[key])
+		}
+		return values
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		a, b := sortKey(i), sortKey(j)
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+}
+
+// LoadArchive loads the flows of an archive created using ExportAll, embedded flows
+// are loaded as resources and remote flows are added to the cache
+func (fm *FlowManager) LoadArchive(archive []byte) error {
+
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("error reading flow archive, %s", err.Error())
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading flow archive, %s", err.Error())
+		}
+
+		if !strings.HasSuffix(hdr.Name, archiveEntryExt) {
+			continue
+		}
+
+		uri, err := url.QueryUnescape(strings.TrimSuffix(hdr.Name, archiveEntryExt))
+		if err != nil {
+			return fmt.Errorf("invalid flow archive entry '%s', %s", hdr.Name, err.Error())
+		}
+
+		repBytes, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("error reading flow with uri '%s' from archive, %s", uri, err.Error())
+		}
+
+		if err := fm.loadArchivedFlow(uri, repBytes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fm *FlowManager) loadArchivedFlow(uri string, repBytes []byte) error {
+
+	var rep *definition.DefinitionRep
+	if err := jsonCodec.Unmarshal(repBytes, &rep); err != nil {
+		return fmt.Errorf("error marshalling flow with uri '%s' from archive, %s", uri, err.Error())
+	}
+
+	flow, err := fm.materializeFlow(rep)
+	if err != nil {
+		return fmt.Errorf("error loading flow with uri '%s' from archive, %s", uri, err.Error())
+	}
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		id := uri[len(uriSchemeRes):]
+		fm.setResFlow(id, flow, rep)
+		return nil
+	}
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	if fm.remoteFlows == nil {
+		fm.remoteFlows = make(map[string]*cacheEntry)
+	}
+
+	_, ttl := fm.cachePolicy(uri, rep)
+	fm.cacheRemoteFlow(uri, fm.newCacheEntry(flow, rep, ttl))
+
+	return nil
+}
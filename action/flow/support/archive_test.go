@@ -0,0 +1,166 @@
+package support
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportAllRoundTrip(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/orders?version=2": strings.Replace(testFlowJSON, "Test Flow", "Remote Orders", 1),
+	})
+	fm := NewFlowManager(provider)
+
+	err := fm.LoadResource(&resource.Config{ID: "payments", Data: []byte(strings.Replace(testFlowJSON, "Test Flow", "Payments", 1))})
+	assert.Nil(t, err)
+	err = fm.LoadResource(CompressResource(&resource.Config{ID: "refunds", Data: []byte(strings.Replace(testFlowJSON, "Test Flow", "Refunds", 1))}))
+	assert.Nil(t, err)
+	_, err = fm.GetFlow("http://flows/orders?version=2")
+	assert.Nil(t, err)
+
+	archive, err := fm.ExportAll()
+	assert.Nil(t, err)
+
+	otherProvider := newTestFlowProvider(map[string]string{})
+	imported := NewFlowManager(otherProvider)
+	assert.Nil(t, imported.LoadArchive(archive))
+
+	expected := map[string]string{
+		"res://payments":                "Payments",
+		"res://refunds":                 "Refunds",
+		"http://flows/orders?version=2": "Remote Orders",
+	}
+
+	for uri, name := range expected {
+		flow, err := imported.GetFlow(uri)
+		assert.Nil(t, err)
+		assert.Equal(t, name, flow.Name())
+	}
+
+	// the remote flow was loaded from the archive, not fetched
+	assert.Equal(t, 0, otherProvider.callCount("http://flows/orders?version=2"))
+}
+
+func TestLoadArchiveInvalid(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+	assert.NotNil(t, fm.LoadArchive([]byte("not an archive")))
+}
+
+const unorderedFlowJSON = `{
+  "name": "Unordered Flow",
+  "model": "test",
+  "metadata": {
+    "input": [ { "name": "b", "type": "string" }, { "name": "a", "type": "integer" } ]
+  },
+  "tasks": [
+    { "id": "log_3", "activity": { "ref": "test-log", "input": { "message": "three" } } },
+    { "id": "log_1", "activity": { "ref": "test-log", "input": { "message": "one" } } },
+    { "id": "log_2", "activity": { "ref": "test-log", "input": { "message": "two" } } }
+  ],
+  "links": [
+    { "from": "log_2", "to": "log_3" },
+    { "from": "log_1", "to": "log_2" }
+  ]
+}`
+
+const exportedFlowGolden = `{
+  "errorHandler": null,
+  "errorHandlerTask": null,
+  "explicitReply": false,
+  "links": [
+    {
+      "from": "log_1",
+      "name": "",
+      "to": "log_2",
+      "type": "",
+      "value": ""
+    },
+    {
+      "from": "log_2",
+      "name": "",
+      "to": "log_3",
+      "type": "",
+      "value": ""
+    }
+  ],
+  "metadata": {
+    "input": [
+      {
+        "name": "a",
+        "type": "integer",
+        "value": 0
+      },
+      {
+        "name": "b",
+        "type": "string",
+        "value": ""
+      }
+    ],
+    "output": null
+  },
+  "model": "test",
+  "name": "Unordered Flow",
+  "rootTask": null,
+  "tasks": [
+    {
+      "activity": {
+        "input": {
+          "message": "one"
+        },
+        "ref": "test-log",
+        "settings": null
+      },
+      "id": "log_1",
+      "name": "",
+      "settings": null,
+      "type": ""
+    },
+    {
+      "activity": {
+        "input": {
+          "message": "two"
+        },
+        "ref": "test-log",
+        "settings": null
+      },
+      "id": "log_2",
+      "name": "",
+      "settings": null,
+      "type": ""
+    },
+    {
+      "activity": {
+        "input": {
+          "message": "three"
+        },
+        "ref": "test-log",
+        "settings": null
+      },
+      "id": "log_3",
+      "name": "",
+      "settings": null,
+      "type": ""
+    }
+  ]
+}`
+
+func TestExportFlowGolden(t *testing.T) {
+
+	for i := 0; i < 10; i++ {
+		fm := NewFlowManager(nil)
+		err := fm.LoadResource(&resource.Config{ID: "unordered", Data: []byte(unorderedFlowJSON)})
+		assert.Nil(t, err)
+
+		exported, err := fm.ExportFlow("res://unordered")
+		assert.Nil(t, err)
+		assert.Equal(t, exportedFlowGolden, string(exported))
+	}
+
+	_, err := NewFlowManager(nil).ExportFlow("res://missing")
+	assert.NotNil(t, err)
+}
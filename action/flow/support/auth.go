@@ -0,0 +1,130 @@
+package support
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Authenticator adds the credentials to the requests for the remote flows
+type Authenticator interface {
+
+	// Authenticate adds the credentials to the request
+	Authenticate(req *http.Request) error
+}
+
+// TokenRefresher is an Authenticator with a token which can be invalidated, a flow
+// request rejected with a 401 status code is retried once with a new token
+type TokenRefresher interface {
+	Authenticator
+
+	// Invalidate discards the current token, a new token is used by the next request
+	Invalidate()
+}
+
+// BearerToken authenticates the requests using a static bearer token
+type BearerToken string
+
+// Authenticate implements Authenticator.Authenticate
+func (t BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+string(t))
+	return nil
+}
+
+// BasicAuth authenticates the requests using HTTP basic authentication
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements Authenticator.Authenticate
+func (a *BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// TokenFunc gets a new bearer token and the time it expires at, a zero expiry
+// indicates the token doesn't expire
+type TokenFunc func() (token string, expiry time.Time, err error)
+
+// RefreshingToken authenticates the requests using a bearer token obtained from a
+// callback (ex. an OAuth2 client credentials grant), the token is reused until it
+// expires or is rejected
+type RefreshingToken struct {
+	// Refresh gets a new token
+	Refresh TokenFunc
+
+	// Leeway is how long before its expiry a token is refreshed
+	Leeway time.Duration
+
+	// Clock is the source of the current time, RealClock is used if not set
+	Clock Clock
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewRefreshingToken creates a RefreshingToken getting its tokens using the specified function
+func NewRefreshingToken(refresh TokenFunc) *RefreshingToken {
+	return &RefreshingToken{Refresh: refresh}
+}
+
+// Authenticate implements Authenticator.Authenticate
+func (t *RefreshingToken) Authenticate(req *http.Request) error {
+
+	token, err := t.currentToken()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate implements TokenRefresher.Invalidate
+func (t *RefreshingToken) Invalidate() {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.token = ""
+}
+
+// currentToken returns the current token, refreshing it if there is none or it expired
+func (t *RefreshingToken) currentToken() (string, error) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := clockOrReal(t.Clock).Now()
+
+	if t.token != "" && (t.expiry.IsZero() || now.Add(t.Leeway).Before(t.expiry)) {
+		return t.token, nil
+	}
+
+	if t.Refresh == nil {
+		return "", errors.New("token refresh function not set")
+	}
+
+	token, expiry, err := t.Refresh()
+	if err != nil {
+		return "", err
+	}
+
+	t.token = token
+	t.expiry = expiry
+
+	return token, nil
+}
+
+// authenticate adds the credentials of the provider to the request
+func (p *BasicRemoteFlowProvider) authenticate(req *http.Request) error {
+
+	if p.Auth == nil {
+		return nil
+	}
+
+	return p.Auth.Authenticate(req)
+}
@@ -0,0 +1,111 @@
+package support
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFlowAuth(t *testing.T) {
+
+	var authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{Auth: BearerToken("secret")}
+	_, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer secret", authorization)
+
+	provider = &BasicRemoteFlowProvider{Auth: &BasicAuth{Username: "flogo", Password: "secret"}}
+	_, err = provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Basic ZmxvZ286c2VjcmV0", authorization)
+
+	provider = &BasicRemoteFlowProvider{Auth: NewRefreshingToken(func() (string, time.Time, error) {
+		return "", time.Time{}, fmt.Errorf("token endpoint unavailable")
+	})}
+	_, err = provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error authenticating request for flow with uri")
+}
+
+func TestGetFlowRefreshingToken(t *testing.T) {
+
+	valid := "token-1"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	refreshes := 0
+	token := NewRefreshingToken(func() (string, time.Time, error) {
+		refreshes++
+		return fmt.Sprintf("token-%d", refreshes), clock.Now().Add(time.Hour), nil
+	})
+	token.Clock = clock
+	token.Leeway = time.Minute
+
+	provider := &BasicRemoteFlowProvider{Auth: token}
+
+	_, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	_, err = provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, refreshes)
+
+	// the token is refreshed before it expires
+	clock.Advance(59 * time.Minute)
+	valid = "token-2"
+	_, err = provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, refreshes)
+
+	// a rejected token is refreshed and the request retried
+	valid = "token-3"
+	_, err = provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, refreshes)
+}
+
+func TestGetFlowTLSConfig(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	// the server certificate isn't trusted by default
+	_, err := (&BasicRemoteFlowProvider{}).GetFlow(server.URL)
+	assert.NotNil(t, err)
+
+	cert, err := x509.ParseCertificate(server.TLS.Certificates[0].Certificate[0])
+	assert.Nil(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	provider := &BasicRemoteFlowProvider{TLSConfig: &tls.Config{RootCAs: roots}, Timeout: 5 * time.Second}
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, 5*time.Second, provider.httpClient().Timeout)
+}
@@ -0,0 +1,161 @@
+package support
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// LoadPolicy determines how a batch of flow resources is loaded when one of the flows
+// fails to load
+type LoadPolicy int
+
+const (
+	// FailFast stops loading at the first flow that fails to load
+	FailFast LoadPolicy = iota
+
+	// SkipInvalid logs and skips the flows that fail to load, loading the remaining flows
+	SkipInvalid
+)
+
+// LoadResources loads the specified flow resources, a flow that fails to load
+// doesn't prevent the remaining flows from loading.  The errors of the flows that
+// failed to load are returned keyed by resource id.
+func (fm *FlowManager) LoadResources(configs []*resource.Config) map[string]error {
+	errs, _ := fm.LoadResourcesWithPolicy(configs, SkipInvalid)
+	return errs
+}
+
+// LoadResourcesWithPolicy loads the specified flow resources in order using the specified
+// policy.  With FailFast the error of the first flow that failed to load is returned, the
+// flows loaded before it remain loaded.  With SkipInvalid the errors of the skipped flows
+// are returned keyed by resource id.
+func (fm *FlowManager) LoadResourcesWithPolicy(configs []*resource.Config, policy LoadPolicy) (map[string]error, error) {
+
+	errs := make(map[string]error)
+
+	for _, config := range configs {
+
+		err := fm.loadResource(config)
+		if err == nil {
+			continue
+		}
+
+		if policy == FailFast {
+			return nil, fmt.Errorf("error loading flow resource '%s', %s", resourceID(config), err.Error())
+		}
+
+		logger.Warnf("Skipping flow resource '%s' which failed to load: %s", resourceID(config), err.Error())
+		errs[resourceID(config)] = err
+	}
+
+	return errs, nil
+}
+
+// loadResource loads the flow resource, recovering from a panic while materializing the flow
+func (fm *FlowManager) loadResource(config *resource.Config) error {
+
+	if fm.loadsLazily(config) {
+		fm.storeLazyResource(config)
+		return nil
+	}
+
+	defRep, err := fm.decodeResource(config)
+	if err != nil {
+		return err
+	}
+
+	flow, err := fm.safeMaterializeFlow(defRep)
+	if err != nil {
+		return err
+	}
+
+	fm.storeResource(config.ID, flow, defRep)
+	return nil
+}
+
+// PreloadFlows fetches and caches the specified remote flows, a flow that fails to
+// load doesn't prevent the remaining flows from loading.  The errors of the flows
+// that failed to load are returned keyed by uri.
+func (fm *FlowManager) PreloadFlows(uris []string) map[string]error {
+
+	errs := make(map[string]error)
+
+	for _, uri := range uris {
+		_, err := fm.getRemoteFlow(context.Background(), uri, fm.safeMaterializeFlow)
+		if err != nil {
+			errs[uri] = err
+		}
+	}
+
+	return errs
+}
+
+// ReloadAll reloads all the cached remote flows, a flow that fails to reload doesn't
+// prevent the remaining flows from reloading.  The errors of the flows that failed to
+// reload are returned keyed by uri.
+func (fm *FlowManager) ReloadAll() map[string]error {
+	errs, _ := fm.ReloadAllContext(context.Background(), nil)
+	return errs
+}
+
+// ReloadAllContext reloads all the cached remote flows, reporting the progress after
+// each flow is reloaded.  Reloading stops as soon as the context is cancelled, in which
+// case the context's error is returned along with the errors of the flows reloaded so far.
+func (fm *FlowManager) ReloadAllContext(ctx context.Context, progress func(done, total int)) (map[string]error, error) {
+
+	fm.rfMu.Lock()
+	uris := make([]string, 0, len(fm.remoteFlows))
+	for uri := range fm.remoteFlows {
+		uris = append(uris, uri)
+	}
+	fm.rfMu.Unlock()
+
+	sort.Strings(uris)
+
+	errs := make(map[string]error)
+
+	for i, uri := range uris {
+		if err := ctx.Err(); err != nil {
+			return errs, err
+		}
+
+		_, err := fm.ReloadFlow(uri)
+		if err != nil {
+			errs[uri] = err
+		}
+
+		if progress != nil {
+			progress(i+1, len(uris))
+		}
+	}
+
+	return errs, nil
+}
+
+// safeMaterializeFlow materializes the flow, converting a panic into an error so that
+// a bad flow doesn't abort a batch operation.  It waits for a free slot when the number
+// of concurrent materializations is limited.
+func (fm *FlowManager) safeMaterializeFlow(flowRep *definition.DefinitionRep) (def *definition.Definition, err error) {
+
+	if fm.materializeSem != nil {
+		fm.materializeSem <- struct{}{}
+		defer func() { <-fm.materializeSem }()
+	}
+
+	defer util.HandlePanic("materializeFlow", &err)
+
+	return fm.materializeFlow(flowRep)
+}
+
+func resourceID(config *resource.Config) string {
+	if config == nil {
+		return ""
+	}
+	return config.ID
+}
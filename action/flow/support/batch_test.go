@@ -0,0 +1,256 @@
+package support
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// panicLinkExprManagerFactory panics when creating the link expression manager
+// of the n-th flow that is materialized
+type panicLinkExprManagerFactory struct {
+	calls   int
+	panicOn int
+}
+
+func (f *panicLinkExprManagerFactory) NewLinkExprManager() definition.LinkExprManager {
+	f.calls++
+	if f.calls == f.panicOn {
+		panic("bad flow")
+	}
+	return nil
+}
+
+func TestLoadResourcesRecoversPanic(t *testing.T) {
+
+	definition.SetLinkExprManagerFactory(&panicLinkExprManagerFactory{panicOn: 2})
+	defer definition.SetLinkExprManagerFactory(nil)
+
+	configs := []*resource.Config{
+		{ID: "flow1", Data: []byte(testFlowJSON)},
+		{ID: "flow2", Data: []byte(testFlowJSON)},
+		{ID: "flow3", Data: []byte(testFlowJSON)},
+	}
+
+	fm := NewFlowManager(nil)
+
+	var errs map[string]error
+	assert.NotPanics(t, func() {
+		errs = fm.LoadResources(configs)
+	})
+
+	assert.Len(t, errs, 1)
+	assert.NotNil(t, errs["flow2"])
+
+	assert.NotNil(t, fm.GetResource("flow1"))
+	assert.Nil(t, fm.GetResource("flow2"))
+	assert.NotNil(t, fm.GetResource("flow3"))
+}
+
+func TestPreloadFlowsRecoversPanic(t *testing.T) {
+
+	definition.SetLinkExprManagerFactory(&panicLinkExprManagerFactory{panicOn: 1})
+	defer definition.SetLinkExprManagerFactory(nil)
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/flow1": testFlowJSON,
+		"http://flows/flow2": testFlowJSON,
+	})
+
+	fm := NewFlowManager(provider)
+
+	var errs map[string]error
+	assert.NotPanics(t, func() {
+		errs = fm.PreloadFlows([]string{"http://flows/flow1", "http://flows/flow2"})
+	})
+
+	assert.Len(t, errs, 1)
+	assert.NotNil(t, errs["http://flows/flow1"])
+
+	_, cached := fm.remoteFlows["http://flows/flow2"]
+	assert.True(t, cached)
+}
+
+func TestReloadAllContextCancel(t *testing.T) {
+
+	flows := make(map[string]string)
+	var uris []string
+	for i := 0; i < 5; i++ {
+		uri := fmt.Sprintf("http://flows/flow%d", i)
+		flows[uri] = testFlowJSON
+		uris = append(uris, uri)
+	}
+
+	provider := newTestFlowProvider(flows)
+	fm := NewFlowManager(provider)
+
+	errs := fm.PreloadFlows(uris)
+	assert.Len(t, errs, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var reported []int
+	errs, err := fm.ReloadAllContext(ctx, func(done, total int) {
+		assert.Equal(t, 5, total)
+		reported = append(reported, done)
+		if done == 2 {
+			cancel()
+		}
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, errs, 0)
+	assert.Equal(t, []int{1, 2}, reported)
+
+	for i, uri := range uris {
+		if i < 2 {
+			assert.Equal(t, 2, provider.callCount(uri))
+		} else {
+			assert.Equal(t, 1, provider.callCount(uri))
+		}
+	}
+}
+
+func TestReloadAll(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+	fm := NewFlowManager(provider)
+
+	_, err := fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+
+	provider.removeFlow("http://flows/flow")
+
+	errs := fm.ReloadAll()
+	assert.Len(t, errs, 1)
+	assert.NotNil(t, errs["http://flows/flow"])
+}
+
+func TestLoadResourcesWithPolicy(t *testing.T) {
+
+	configs := []*resource.Config{
+		{ID: "flow1", Data: []byte(testFlowJSON)},
+		{ID: "invalid", Data: []byte(invalidFlowJSON)},
+		{ID: "flow2", Data: []byte(testFlowJSON)},
+	}
+
+	fm := NewFlowManager(nil)
+
+	errs, err := fm.LoadResourcesWithPolicy(configs, SkipInvalid)
+	assert.Nil(t, err)
+	assert.Len(t, errs, 1)
+	assert.NotNil(t, errs["invalid"])
+
+	assert.NotNil(t, fm.GetResource("flow1"))
+	assert.Nil(t, fm.GetResource("invalid"))
+	assert.NotNil(t, fm.GetResource("flow2"))
+
+	fm = NewFlowManager(nil)
+
+	_, err = fm.LoadResourcesWithPolicy(configs, FailFast)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error loading flow resource 'invalid'")
+
+	assert.NotNil(t, fm.GetResource("flow1"))
+	assert.Nil(t, fm.GetResource("flow2"))
+}
+
+func TestLoadResourcesMaxMaterializations(t *testing.T) {
+
+	const maxMaterializations = 2
+
+	var mu sync.Mutex
+	var active, peak int
+
+	tracker := StageFunc(func(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return rep, nil
+	})
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{
+		Pipeline:            NewPipeline(tracker),
+		MaxMaterializations: maxMaterializations,
+	})
+
+	// the default mapper factory is created lazily on first use
+	definition.GetMapperFactory()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			configs := []*resource.Config{
+				{ID: fmt.Sprintf("flow%d-1", i), Data: []byte(testFlowJSON)},
+				{ID: fmt.Sprintf("flow%d-2", i), Data: []byte(testFlowJSON)},
+			}
+			errs := fm.LoadResources(configs)
+			assert.Len(t, errs, 0)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.True(t, peak > 0)
+	assert.True(t, peak <= maxMaterializations)
+	assert.NotNil(t, fm.GetResource("flow5-2"))
+}
+
+func TestLoadResourcesConcurrentGetFlow(t *testing.T) {
+
+	configs := []*resource.Config{
+		{ID: "flow1", Data: []byte(testFlowJSON)},
+		{ID: "malformed", Data: []byte(`{"name": "Malformed Flow",`)},
+		{ID: "flow2", Data: []byte(testFlowJSON)},
+		nil,
+	}
+
+	fm := NewFlowManager(nil)
+
+	fm.LoadResource(configs[0])
+	assert.Nil(t, fm.DeleteFlow("res://flow1"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			fm.GetFlow("res://flow2")
+			fm.GetResource("flow1")
+		}
+	}()
+
+	errs := fm.LoadResources(configs)
+	wg.Wait()
+
+	assert.Len(t, errs, 2)
+	assert.NotNil(t, errs["malformed"])
+	assert.NotNil(t, errs[""])
+
+	for _, id := range []string{"flow1", "flow2"} {
+		flow, err := fm.GetFlow("res://" + id)
+		assert.Nil(t, err)
+		assert.NotNil(t, flow)
+	}
+
+	// loading the flow again clears its tombstone
+	_, deleted := fm.Tombstone("res://flow1")
+	assert.False(t, deleted)
+}
@@ -0,0 +1,84 @@
+package support
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+const uriSchemeBolt = "bolt://"
+
+// KVStore is an embedded key/value database (ex. a bbolt or badger DB) holding flows
+type KVStore interface {
+	// Get gets the value of the key in the bucket, nil is returned if the key doesn't exist
+	Get(bucket, key string) ([]byte, error)
+}
+
+// KVFlowProvider is a definition.Provider that gets flows with 'bolt://<bucket>/<key>'
+// uris from an embedded key/value database, gzipped flows are uncompressed
+type KVFlowProvider struct {
+	// Store is the database the flows are read from
+	Store KVStore
+}
+
+// NewKVFlowProvider creates a KVFlowProvider reading the flows from the specified store
+func NewKVFlowProvider(store KVStore) *KVFlowProvider {
+	return &KVFlowProvider{Store: store}
+}
+
+// GetFlow implements definition.Provider.GetFlow
+func (p *KVFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	bucket, key, err := parseKVURI(flowURI)
+	if err != nil {
+		return nil, err
+	}
+
+	flowDefBytes, err := p.Store.Get(bucket, key)
+	if err != nil {
+		readErr := fmt.Errorf("error reading flow with uri '%s', %s", flowURI, err.Error())
+		logger.Errorf(readErr.Error())
+		return nil, readErr
+	}
+
+	if flowDefBytes == nil {
+		return nil, fmt.Errorf("flow not found for uri '%s'", flowURI)
+	}
+
+	if isGzipped(flowDefBytes) {
+		flowDefBytes, err = unzip(flowDefBytes, false)
+		if err != nil {
+			decompressErr := uncompressError(flowURI, err)
+			logger.Errorf(decompressErr.Error())
+			return nil, decompressErr
+		}
+	}
+
+	var flow *definition.DefinitionRep
+	err = jsonCodec.Unmarshal(flowDefBytes, &flow)
+	if err != nil {
+		logger.Errorf(err.Error())
+		return nil, fmt.Errorf("error marshalling flow with uri '%s', %s", flowURI, err.Error())
+	}
+
+	return flow, nil
+}
+
+// parseKVURI splits the uri into the bucket and the key, the key can contain slashes
+func parseKVURI(flowURI string) (bucket, key string, err error) {
+
+	if !strings.HasPrefix(flowURI, uriSchemeBolt) {
+		return "", "", fmt.Errorf("unsupported flow uri '%s'", flowURI)
+	}
+
+	path := flowURI[len(uriSchemeBolt):]
+
+	idx := strings.Index(path, "/")
+	if idx <= 0 || idx == len(path)-1 {
+		return "", "", fmt.Errorf("invalid flow uri '%s', expected 'bolt://<bucket>/<key>'", flowURI)
+	}
+
+	return path[:idx], path[idx+1:], nil
+}
@@ -0,0 +1,49 @@
+package support
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memKVStore is an in-memory KVStore
+type memKVStore map[string]map[string][]byte
+
+func (s memKVStore) Get(bucket, key string) ([]byte, error) {
+	return s[bucket][key], nil
+}
+
+func TestKVFlowProvider(t *testing.T) {
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(testFlowJSON))
+	w.Close()
+
+	provider := NewKVFlowProvider(memKVStore{
+		"flows": {
+			"orders":    []byte(testFlowJSON),
+			"orders/v2": buf.Bytes(),
+			"malformed": []byte(`{"name":`),
+		},
+	})
+
+	for _, uri := range []string{"bolt://flows/orders", "bolt://flows/orders/v2"} {
+		rep, err := provider.GetFlow(uri)
+		assert.Nil(t, err)
+		assert.Equal(t, "Test Flow", rep.Name)
+	}
+
+	_, err := provider.GetFlow("bolt://flows/missing")
+	assert.NotNil(t, err)
+	assert.Equal(t, "flow not found for uri 'bolt://flows/missing'", err.Error())
+
+	_, err = provider.GetFlow("bolt://flows/malformed")
+	assert.NotNil(t, err)
+
+	_, err = provider.GetFlow("bolt://flows")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "expected 'bolt://<bucket>/<key>'")
+}
@@ -0,0 +1,232 @@
+package support
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// CacheConfig is the configuration of the remote flow cache
+type CacheConfig struct {
+	// TTL is the default duration a remote flow is cached for, 0 caches flows forever
+	TTL time.Duration
+
+	// StaleGrace is the duration past its expiration an expired flow is still served
+	// when it can't be refreshed (ex. provider outage), 0 disables serving stale flows
+	StaleGrace time.Duration
+
+	// MaxEntries is the maximum number of cached remote flows, the least recently used
+	// flow is evicted when it is exceeded.  Pinned flows aren't counted, 0 is unlimited.
+	MaxEntries int
+}
+
+// cacheEntry is a cached remote flow
+type cacheEntry struct {
+	flow *definition.Definition
+	rep  *definition.DefinitionRep
+	// raw is the JSON of the rep as fetched, before the pipeline processed it
+	raw      []byte
+	expires  time.Time
+	loaded   time.Time
+	lastUsed uint64
+}
+
+// expired determines if the entry has expired, an entry without an expiration never expires
+func (e *cacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// withinGrace determines if the expired entry can still be served during the specified grace period
+func (e *cacheEntry) withinGrace(now time.Time, grace time.Duration) bool {
+	return grace > 0 && !e.expires.IsZero() && now.Before(e.expires.Add(grace))
+}
+
+// cachePolicy determines if and for how long the flow should be cached, the flow's
+// cache annotation overrides the default TTL of the manager
+func (fm *FlowManager) cachePolicy(uri string, flowRep *definition.DefinitionRep) (cacheable bool, ttl time.Duration) {
+
+	ttl = fm.cacheConfig.TTL
+
+	if flowRep.Cache == nil {
+		return true, ttl
+	}
+
+	if flowRep.Cache.Disabled {
+		return false, 0
+	}
+
+	if flowRep.Cache.TTL != "" {
+		flowTTL, err := time.ParseDuration(flowRep.Cache.TTL)
+		if err != nil {
+			logger.Warnf("Invalid cache ttl '%s' for flow with uri '%s', using default", flowRep.Cache.TTL, uri)
+		} else {
+			ttl = flowTTL
+		}
+	}
+
+	return true, ttl
+}
+
+// newCacheEntry creates a cache entry for the flow which expires after the specified ttl
+func (fm *FlowManager) newCacheEntry(flow *definition.Definition, rep *definition.DefinitionRep, ttl time.Duration) *cacheEntry {
+
+	entry := &cacheEntry{flow: flow, rep: rep, loaded: fm.now()}
+	if ttl > 0 {
+		entry.expires = fm.now().Add(ttl)
+	}
+
+	return entry
+}
+
+// cacheRemoteFlow caches the remote flow, evicting the least recently used flows if
+// the cache is full.  rfMu must be held by the caller.
+func (fm *FlowManager) cacheRemoteFlow(uri string, entry *cacheEntry) {
+
+	fm.touchCacheEntry(entry)
+	fm.remoteFlows[uri] = entry
+
+	if fm.cacheConfig.MaxEntries <= 0 {
+		return
+	}
+
+	for {
+		var lruURI string
+		var lru *cacheEntry
+		count := 0
+
+		for cachedURI, cached := range fm.remoteFlows {
+			if fm.pinned[cachedURI] {
+				continue
+			}
+			count++
+			if lru == nil || cached.lastUsed < lru.lastUsed {
+				lruURI, lru = cachedURI, cached
+			}
+		}
+
+		if count <= fm.cacheConfig.MaxEntries {
+			return
+		}
+
+		logger.Debugf("Evicting least recently used flow with uri '%s'", lruURI)
+		delete(fm.remoteFlows, lruURI)
+		fm.dropVersions(lruURI)
+	}
+}
+
+// touchCacheEntry marks the entry as the most recently used, rfMu must be held by the caller
+func (fm *FlowManager) touchCacheEntry(entry *cacheEntry) {
+	fm.cacheTick++
+	entry.lastUsed = fm.cacheTick
+}
+
+// PinFlow pins the remote flow with the specified uri, a pinned flow is never evicted
+// from the cache but can still be refreshed using ReloadFlow.  The cached flow is kept
+// when a refresh fails.
+func (fm *FlowManager) PinFlow(uri string) {
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	if fm.pinned == nil {
+		fm.pinned = make(map[string]bool)
+	}
+	fm.pinned[uri] = true
+}
+
+// UnpinFlow unpins the remote flow with the specified uri, making it subject to eviction again
+func (fm *FlowManager) UnpinFlow(uri string) {
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	delete(fm.pinned, uri)
+}
+
+// ReloadFlow refreshes the remote flow with the specified uri from the provider,
+// regardless of whether it is cached, expired or pinned.  An embedded flow (res://)
+// is materialized again from its definition.  The flow is replaced atomically, so
+// concurrent gets see either the old or the new flow.
+func (fm *FlowManager) ReloadFlow(uri string) (*definition.Definition, error) {
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		return fm.reloadResFlow(uri[len(uriSchemeRes):])
+	}
+
+	return fm.fetchRemoteFlow(context.Background(), uri, fm.materializeFlow)
+}
+
+// reloadResFlow materializes the embedded flow with the specified id again
+func (fm *FlowManager) reloadResFlow(id string) (*definition.Definition, error) {
+
+	rep := fm.getResRep(id)
+	if rep == nil {
+		return nil, fmt.Errorf("flow not found for uri '%s'", uriSchemeRes+id)
+	}
+
+	flow, err := fm.materializeFlow(rep)
+	if err != nil {
+		return nil, err
+	}
+
+	fm.setResFlow(id, flow, rep)
+	return flow, nil
+}
+
+// Invalidate drops the cached remote flow with the specified uri, so it is fetched
+// from the provider the next time it is requested, its versions are dropped too.
+// Embedded flows aren't cached, use ReloadFlow to refresh them.
+func (fm *FlowManager) Invalidate(uri string) {
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	delete(fm.remoteFlows, uri)
+	fm.dropVersions(uri)
+}
+
+// InvalidateFlow is the same as Invalidate
+func (fm *FlowManager) InvalidateFlow(uri string) {
+	fm.Invalidate(uri)
+}
+
+// InvalidateAll drops all the cached remote flows, including the pinned ones, so they
+// are fetched from the provider the next time they are requested (ex. after a flow
+// server redeployment).  The number of dropped flows is returned.
+func (fm *FlowManager) InvalidateAll() int {
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	invalidated := len(fm.remoteFlows)
+	fm.remoteFlows = make(map[string]*cacheEntry)
+	fm.dropRemoteVersions()
+
+	return invalidated
+}
+
+// EvictExpired evicts the expired remote flows which are no longer within the stale
+// grace period, pinned flows are never evicted.  The number of evicted flows is returned.
+func (fm *FlowManager) EvictExpired() int {
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	now := fm.now()
+	evicted := 0
+
+	for uri, entry := range fm.remoteFlows {
+		if fm.pinned[uri] || !entry.expired(now) || entry.withinGrace(now, fm.cacheConfig.StaleGrace) {
+			continue
+		}
+		delete(fm.remoteFlows, uri)
+		fm.dropVersions(uri)
+		evicted++
+	}
+
+	return evicted
+}
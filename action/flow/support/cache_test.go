@@ -0,0 +1,330 @@
+package support
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFlowCacheAnnotation(t *testing.T) {
+
+	annotatedFlowJSON := strings.Replace(testFlowJSON, `"name": "Test Flow",`, `"name": "Test Flow", "cache": {"ttl": "1m"},`, 1)
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/default":   testFlowJSON,
+		"http://flows/annotated": annotatedFlowJSON,
+	})
+
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Cache: CacheConfig{TTL: time.Hour}})
+
+	now := time.Now()
+	fm.now = func() time.Time { return now }
+
+	for _, uri := range []string{"http://flows/default", "http://flows/annotated"} {
+		flow, err := fm.GetFlow(uri)
+		assert.Nil(t, err)
+		assert.NotNil(t, flow)
+	}
+
+	// past the flow's ttl, but within the manager default ttl
+	now = now.Add(2 * time.Minute)
+
+	for _, uri := range []string{"http://flows/default", "http://flows/annotated"} {
+		_, err := fm.GetFlow(uri)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, 1, provider.callCount("http://flows/default"))
+	assert.Equal(t, 2, provider.callCount("http://flows/annotated"))
+}
+
+func TestGetFlowCacheDisabled(t *testing.T) {
+
+	uncachedFlowJSON := strings.Replace(testFlowJSON, `"name": "Test Flow",`, `"name": "Test Flow", "cache": {"disabled": true},`, 1)
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/uncached": uncachedFlowJSON})
+	fm := NewFlowManager(provider)
+
+	fm.GetFlow("http://flows/uncached")
+	fm.GetFlow("http://flows/uncached")
+
+	assert.Equal(t, 2, provider.callCount("http://flows/uncached"))
+}
+
+func TestGetFlowStaleGrace(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+	metrics := &testMetrics{}
+
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{
+		Cache:   CacheConfig{TTL: time.Minute, StaleGrace: 10 * time.Minute},
+		Metrics: metrics,
+	})
+
+	now := time.Now()
+	fm.now = func() time.Time { return now }
+
+	flow, err := fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+
+	// simulate a provider outage
+	provider.removeFlow("http://flows/flow")
+
+	// expired, but within the grace period
+	now = now.Add(5 * time.Minute)
+
+	staleFlow, err := fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+	assert.True(t, flow == staleFlow)
+	assert.Equal(t, 2, provider.callCount("http://flows/flow"))
+	assert.Equal(t, []string{"http"}, metrics.schemes(metrics.counters, MetricStaleServed))
+
+	// past the grace period
+	now = now.Add(10 * time.Minute)
+
+	staleFlow, err = fm.GetFlow("http://flows/flow")
+	assert.NotNil(t, err)
+	assert.Nil(t, staleFlow)
+}
+
+func TestGetFlowNoStaleGrace(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Cache: CacheConfig{TTL: time.Minute}})
+
+	now := time.Now()
+	fm.now = func() time.Time { return now }
+
+	_, err := fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+
+	provider.removeFlow("http://flows/flow")
+	now = now.Add(2 * time.Minute)
+
+	_, err = fm.GetFlow("http://flows/flow")
+	assert.NotNil(t, err)
+}
+
+func TestPinnedFlowSurvivesEviction(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/pinned":   testFlowJSON,
+		"http://flows/unpinned": testFlowJSON,
+	})
+
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Cache: CacheConfig{TTL: time.Minute}})
+
+	now := time.Now()
+	fm.now = func() time.Time { return now }
+
+	fm.PinFlow("http://flows/pinned")
+
+	for _, uri := range []string{"http://flows/pinned", "http://flows/unpinned"} {
+		_, err := fm.GetFlow(uri)
+		assert.Nil(t, err)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	assert.Equal(t, 1, fm.EvictExpired())
+
+	_, err := fm.GetFlow("http://flows/pinned")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, provider.callCount("http://flows/pinned"))
+
+	// pinned flows are still refreshed on explicit reload
+	_, err = fm.ReloadFlow("http://flows/pinned")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, provider.callCount("http://flows/pinned"))
+
+	// a pinned flow failing to refresh is still served
+	provider.removeFlow("http://flows/pinned")
+	_, err = fm.ReloadFlow("http://flows/pinned")
+	assert.NotNil(t, err)
+
+	flow, err := fm.GetFlow("http://flows/pinned")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", flow.Name())
+	assert.Equal(t, 3, provider.callCount("http://flows/pinned"))
+
+	fm.UnpinFlow("http://flows/pinned")
+	now = now.Add(2 * time.Minute)
+
+	assert.Equal(t, 1, fm.EvictExpired())
+}
+
+func TestGetFlowCacheTTL(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Cache: CacheConfig{TTL: time.Minute}})
+
+	now := time.Now()
+	fm.now = func() time.Time { return now }
+
+	fm.GetFlow("http://flows/flow")
+	now = now.Add(30 * time.Second)
+	fm.GetFlow("http://flows/flow")
+	assert.Equal(t, 1, provider.callCount("http://flows/flow"))
+
+	// past the ttl the flow is fetched again
+	now = now.Add(time.Minute)
+	_, err := fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, provider.callCount("http://flows/flow"))
+}
+
+func TestGetFlowCacheMaxEntries(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/a": testFlowJSON,
+		"http://flows/b": testFlowJSON,
+		"http://flows/c": testFlowJSON,
+		"http://flows/d": testFlowJSON,
+	})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Cache: CacheConfig{MaxEntries: 2}})
+	fm.PinFlow("http://flows/d")
+
+	fm.GetFlow("http://flows/d")
+	fm.GetFlow("http://flows/a")
+	fm.GetFlow("http://flows/b")
+	fm.GetFlow("http://flows/a")
+
+	// b is the least recently used flow
+	fm.GetFlow("http://flows/c")
+	assert.Len(t, fm.remoteFlows, 3)
+
+	fm.GetFlow("http://flows/a")
+	fm.GetFlow("http://flows/b")
+	fm.GetFlow("http://flows/d")
+
+	assert.Equal(t, 1, provider.callCount("http://flows/a"))
+	assert.Equal(t, 2, provider.callCount("http://flows/b"))
+	assert.Equal(t, 1, provider.callCount("http://flows/c"))
+	assert.Equal(t, 1, provider.callCount("http://flows/d"))
+}
+
+func TestGetFlowCacheConcurrent(t *testing.T) {
+
+	flows := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		flows["http://flows/"+strconv.Itoa(i)] = testFlowJSON
+	}
+
+	fm := NewFlowManagerWithOptions(newTestFlowProvider(flows), &ManagerOptions{Cache: CacheConfig{MaxEntries: 3}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := fm.GetFlow("http://flows/" + strconv.Itoa(i%10))
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.True(t, len(fm.remoteFlows) <= 3)
+}
+
+func TestInvalidate(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"file://flows/flow.json": testFlowJSON})
+	fm := NewFlowManager(provider)
+
+	fm.GetFlow("file://flows/flow.json")
+	fm.GetFlow("file://flows/flow.json")
+	assert.Equal(t, 1, provider.callCount("file://flows/flow.json"))
+
+	fm.Invalidate("file://flows/flow.json")
+
+	fm.GetFlow("file://flows/flow.json")
+	assert.Equal(t, 2, provider.callCount("file://flows/flow.json"))
+
+	fm.InvalidateFlow("file://flows/flow.json")
+
+	fm.GetFlow("file://flows/flow.json")
+	assert.Equal(t, 3, provider.callCount("file://flows/flow.json"))
+}
+
+func TestInvalidateAll(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"file://flows/flow1.json": testFlowJSON,
+		"file://flows/flow2.json": testFlowJSON,
+	})
+	fm := NewFlowManager(provider)
+
+	assert.Equal(t, 0, fm.InvalidateAll())
+
+	fm.GetFlow("file://flows/flow1.json")
+	fm.GetFlow("file://flows/flow2.json")
+
+	assert.Equal(t, 2, fm.InvalidateAll())
+
+	fm.GetFlow("file://flows/flow1.json")
+	fm.GetFlow("file://flows/flow2.json")
+	assert.Equal(t, 2, provider.callCount("file://flows/flow1.json"))
+	assert.Equal(t, 2, provider.callCount("file://flows/flow2.json"))
+}
+
+func TestReloadFlowConcurrentGetFlow(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"file://flows/flow.json": testFlowJSON})
+	fm := NewFlowManager(provider)
+
+	old, err := fm.GetFlow("file://flows/flow.json")
+	assert.Nil(t, err)
+
+	provider.mu.Lock()
+	provider.flows["file://flows/flow.json"] = strings.Replace(testFlowJSON, "Test Flow", "Edited Flow", 1)
+	provider.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			flow, err := fm.GetFlow("file://flows/flow.json")
+			assert.Nil(t, err)
+			assert.True(t, flow.Name() == "Test Flow" || flow.Name() == "Edited Flow")
+		}()
+	}
+
+	reloaded, err := fm.ReloadFlow("file://flows/flow.json")
+	wg.Wait()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Edited Flow", reloaded.Name())
+	assert.True(t, old != reloaded)
+
+	flow, _ := fm.GetFlow("file://flows/flow.json")
+	assert.True(t, reloaded == flow)
+}
+
+func TestReloadFlowResource(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	err := fm.LoadResource(&resource.Config{ID: "flow1", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+
+	old, _ := fm.GetFlow("res://flow1")
+
+	reloaded, err := fm.ReloadFlow("res://flow1")
+	assert.Nil(t, err)
+	assert.NotNil(t, reloaded)
+	assert.True(t, old != reloaded)
+
+	flow, _ := fm.GetFlow("res://flow1")
+	assert.True(t, reloaded == flow)
+
+	_, err = fm.ReloadFlow("res://missing")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "flow not found for uri 'res://missing'")
+}
@@ -0,0 +1,117 @@
+package support
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+// CachingProvider is a definition.Provider decorator caching the flows found by the
+// wrapped provider and the failures to get them for independent durations, so flows
+// can be cached aggressively while missing flows are retried shortly after
+type CachingProvider struct {
+	provider definition.Provider
+
+	// PositiveTTL is the duration a flow is cached for, 0 caches flows forever
+	PositiveTTL time.Duration
+
+	// NegativeTTL is the duration a failure is cached for, 0 disables caching failures
+	NegativeTTL time.Duration
+
+	// Clock is the source of the current time used to expire the entries, RealClock
+	// is used if not set
+	Clock Clock
+
+	mu      sync.Mutex
+	entries map[string]*providerCacheEntry
+}
+
+type providerCacheEntry struct {
+	flow    []byte
+	err     error
+	expires time.Time
+}
+
+func (e *providerCacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// NewCachingProvider creates a CachingProvider wrapping the specified provider
+func NewCachingProvider(provider definition.Provider, positiveTTL, negativeTTL time.Duration) *CachingProvider {
+	return &CachingProvider{
+		provider:    provider,
+		PositiveTTL: positiveTTL,
+		NegativeTTL: negativeTTL,
+		entries:     make(map[string]*providerCacheEntry),
+	}
+}
+
+// GetFlow implements definition.Provider.GetFlow, a copy of the cached flow is returned
+// so it can be modified by the caller
+func (p *CachingProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	p.mu.Lock()
+	entry, exists := p.entries[flowURI]
+	p.mu.Unlock()
+
+	if exists && !entry.expired(clockOrReal(p.Clock).Now()) {
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		return decodeCachedFlow(entry.flow)
+	}
+
+	rep, err := p.provider.GetFlow(flowURI)
+
+	entry = &providerCacheEntry{}
+
+	switch {
+	case err != nil:
+		if p.NegativeTTL <= 0 {
+			p.evict(flowURI)
+			return nil, err
+		}
+		entry.err = err
+		entry.expires = clockOrReal(p.Clock).Now().Add(p.NegativeTTL)
+	case rep != nil:
+		entry.flow, err = json.Marshal(rep)
+		if err != nil {
+			// not cacheable, but still usable
+			p.evict(flowURI)
+			return rep, nil
+		}
+		if p.PositiveTTL > 0 {
+			entry.expires = clockOrReal(p.Clock).Now().Add(p.PositiveTTL)
+		}
+	default:
+		p.evict(flowURI)
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	p.entries[flowURI] = entry
+	p.mu.Unlock()
+
+	return rep, entry.err
+}
+
+// Invalidate removes the cached result for the flow with the specified uri
+func (p *CachingProvider) Invalidate(flowURI string) {
+	p.evict(flowURI)
+}
+
+func (p *CachingProvider) evict(flowURI string) {
+	p.mu.Lock()
+	delete(p.entries, flowURI)
+	p.mu.Unlock()
+}
+
+func decodeCachedFlow(flow []byte) (*definition.DefinitionRep, error) {
+	var rep *definition.DefinitionRep
+	if err := jsonCodec.Unmarshal(flow, &rep); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
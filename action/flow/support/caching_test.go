@@ -0,0 +1,68 @@
+package support
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachingProvider(t *testing.T) {
+
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/orders": testFlowJSON})
+
+	cp := NewCachingProvider(provider, time.Hour, time.Minute)
+	cp.Clock = ClockFunc(func() time.Time { return now })
+
+	rep, err := cp.GetFlow("http://flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+
+	_, err = cp.GetFlow("http://flows/missing")
+	assert.NotNil(t, err)
+
+	// both results are cached
+	rep.Name = "Modified"
+	rep, err = cp.GetFlow("http://flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	_, err = cp.GetFlow("http://flows/missing")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, provider.callCount("http://flows/orders"))
+	assert.Equal(t, 1, provider.callCount("http://flows/missing"))
+
+	// the negative entry expires before the positive entry
+	now = now.Add(2 * time.Minute)
+
+	_, err = cp.GetFlow("http://flows/orders")
+	assert.Nil(t, err)
+	_, err = cp.GetFlow("http://flows/missing")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, provider.callCount("http://flows/orders"))
+	assert.Equal(t, 2, provider.callCount("http://flows/missing"))
+
+	now = now.Add(time.Hour)
+
+	_, err = cp.GetFlow("http://flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, provider.callCount("http://flows/orders"))
+
+	cp.Invalidate("http://flows/orders")
+	_, err = cp.GetFlow("http://flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, provider.callCount("http://flows/orders"))
+}
+
+func TestCachingProviderNoNegativeCaching(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{})
+	cp := NewCachingProvider(provider, time.Hour, 0)
+
+	for i := 0; i < 3; i++ {
+		_, err := cp.GetFlow("http://flows/missing")
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, 3, provider.callCount("http://flows/missing"))
+}
@@ -0,0 +1,37 @@
+package support
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time used by the time based features (ex. the
+// cache TTL, stale grace, tombstone retention and negative caching), it allows the
+// time to be controlled in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to allow the use of a function as a Clock
+type ClockFunc func() time.Time
+
+// Now implements Clock.Now
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// RealClock is the Clock returning the system time
+var RealClock Clock = realClock{}
+
+// clockOrReal returns the clock, RealClock if it isn't set
+func clockOrReal(clock Clock) Clock {
+	if clock == nil {
+		return RealClock
+	}
+	return clock
+}
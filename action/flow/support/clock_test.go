@@ -0,0 +1,45 @@
+package support
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a Clock whose time only changes when advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestClockCacheTTL(t *testing.T) {
+
+	clock := &fakeClock{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Cache: CacheConfig{TTL: time.Minute}, Clock: clock})
+
+	fm.GetFlow("http://flows/flow")
+
+	clock.Advance(59 * time.Second)
+	fm.GetFlow("http://flows/flow")
+	assert.Equal(t, 1, provider.callCount("http://flows/flow"))
+
+	clock.Advance(time.Second)
+	fm.GetFlow("http://flows/flow")
+	assert.Equal(t, 2, provider.callCount("http://flows/flow"))
+}
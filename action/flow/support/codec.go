@@ -0,0 +1,45 @@
+package support
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONCodec is the codec used to decode flows, it allows a faster JSON
+// implementation (ex. jsoniter) to be used in place of encoding/json
+type JSONCodec interface {
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONStreamCodec is a JSONCodec which decodes the flows as they are read, the http
+// flows are decoded as they are received when the codec supports it
+type JSONStreamCodec interface {
+	JSONCodec
+	Decode(r io.Reader, v interface{}) error
+}
+
+// stdJSONCodec is the encoding/json codec
+type stdJSONCodec struct{}
+
+func (stdJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (stdJSONCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+var jsonCodec JSONCodec = stdJSONCodec{}
+
+// SetJSONCodec sets the codec used to decode flows, nil restores encoding/json
+func SetJSONCodec(codec JSONCodec) {
+	if codec == nil {
+		codec = stdJSONCodec{}
+	}
+	jsonCodec = codec
+}
+
+// GetJSONCodec gets the codec used to decode flows
+func GetJSONCodec() JSONCodec {
+	return jsonCodec
+}
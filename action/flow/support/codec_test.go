@@ -0,0 +1,48 @@
+package support
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingJSONCodec is a JSONCodec counting the number of values it decoded
+type countingJSONCodec struct {
+	calls int
+}
+
+func (c *countingJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	c.calls++
+	return json.Unmarshal(data, v)
+}
+
+func TestSetJSONCodec(t *testing.T) {
+
+	codec := &countingJSONCodec{}
+	SetJSONCodec(codec)
+	defer SetJSONCodec(nil)
+
+	fm := NewFlowManager(newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON}))
+
+	err := fm.LoadResource(&resource.Config{ID: "flow", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, codec.calls)
+
+	SetJSONCodec(nil)
+	assert.Equal(t, stdJSONCodec{}, GetJSONCodec())
+}
+
+func BenchmarkLoadResource(b *testing.B) {
+
+	fm := NewFlowManager(nil)
+	config := &resource.Config{ID: "flow", Data: []byte(testFlowJSON)}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := fm.LoadResource(config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package support
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// DefaultMaxCommitFlows is the number of flows cached by commit by the git providers if
+// their MaxCachedFlows isn't set
+const DefaultMaxCommitFlows = 100
+
+// commitCache caches the flows fetched at a commit, the least recently used flow is
+// evicted when it holds more than max flows
+type commitCache struct {
+	mu    sync.Mutex
+	tick  uint64
+	flows map[string]*commitFlow
+}
+
+type commitFlow struct {
+	flow     []byte
+	lastUsed uint64
+}
+
+// get returns a copy of the flow cached with the key, nil if it isn't cached
+func (c *commitCache) get(key string) (*definition.DefinitionRep, error) {
+
+	c.mu.Lock()
+	cached, exists := c.flows[key]
+	if exists {
+		c.tick++
+		cached.lastUsed = c.tick
+	}
+	c.mu.Unlock()
+
+	if !exists {
+		return nil, nil
+	}
+
+	return decodeCachedFlow(cached.flow)
+}
+
+// put caches the flow with the key, evicting the least recently used flows if the cache
+// holds more than max flows (DefaultMaxCommitFlows if max is 0, unlimited if negative)
+func (c *commitCache) put(key string, rep *definition.DefinitionRep, max int) {
+
+	encoded, err := json.Marshal(rep)
+	if err != nil {
+		return
+	}
+
+	if max == 0 {
+		max = DefaultMaxCommitFlows
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.flows == nil {
+		c.flows = make(map[string]*commitFlow)
+	}
+
+	c.tick++
+	c.flows[key] = &commitFlow{flow: encoded, lastUsed: c.tick}
+
+	for max > 0 && len(c.flows) > max {
+		var lruKey string
+		var lru *commitFlow
+
+		for cachedKey, cached := range c.flows {
+			if lru == nil || cached.lastUsed < lru.lastUsed {
+				lruKey, lru = cachedKey, cached
+			}
+		}
+
+		logger.Debugf("Evicting least recently used flow '%s' cached by commit", lruKey)
+		delete(c.flows, lruKey)
+	}
+}
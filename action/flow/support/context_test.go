@@ -0,0 +1,49 @@
+package support
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantKey struct{}
+
+// tenantFlowProvider is a definition.ContextProvider serving the flow of the
+// tenant found in the context
+type tenantFlowProvider struct {
+	*testFlowProvider
+	tenants []string
+}
+
+func (p *tenantFlowProvider) GetFlowWithContext(ctx context.Context, flowURI string) (*definition.DefinitionRep, error) {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	p.tenants = append(p.tenants, tenant)
+	return p.GetFlow(flowURI + "/" + tenant)
+}
+
+func TestGetFlowWithContext(t *testing.T) {
+
+	provider := &tenantFlowProvider{testFlowProvider: newTestFlowProvider(map[string]string{
+		"http://flows/flow/acme":   testFlowJSON,
+		"http://flows/orders/acme": testFlowJSON,
+	})}
+	fm := NewFlowManager(provider)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	flow, err := fm.GetFlowWithContext(ctx, "http://flows/flow")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+	assert.Equal(t, []string{"acme"}, provider.tenants)
+
+	// the context is also passed when resolving aliases
+	err = fm.SetFlowAlias("orders", []WeightedURI{{URI: "http://flows/orders", Weight: 1}})
+	assert.Nil(t, err)
+
+	flow, err = fm.GetFlowWithContext(ctx, "flow://orders")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+	assert.Equal(t, []string{"acme", "acme"}, provider.tenants)
+}
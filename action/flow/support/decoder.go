@@ -0,0 +1,175 @@
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeYAML = "application/yaml"
+	ContentTypeGzip = "application/gzip"
+)
+
+// FlowDecoder decodes a flow of a specific content type
+type FlowDecoder func(data []byte) (*definition.DefinitionRep, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]FlowDecoder{
+		ContentTypeJSON:      decodeJSONFlow,
+		ContentTypeYAML:      decodeYAMLFlow,
+		"application/x-yaml": decodeYAMLFlow,
+		"text/yaml":          decodeYAMLFlow,
+	}
+)
+
+// RegisterFlowDecoder registers the decoder for the specified content type,
+// replacing any decoder previously registered for it
+func RegisterFlowDecoder(contentType string, decoder FlowDecoder) {
+
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+
+	decoders[strings.ToLower(contentType)] = decoder
+}
+
+// getFlowDecoder gets the decoder for the specified content type, JSON based
+// content types (ex. application/vnd.flow+json) use the JSON decoder
+func getFlowDecoder(contentType string) (FlowDecoder, bool) {
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+
+	decoder, exists := decoders[mediaType]
+	if !exists && strings.HasSuffix(mediaType, "+json") {
+		decoder, exists = decoders[ContentTypeJSON]
+	}
+
+	return decoder, exists
+}
+
+// LoadFromReader loads the flow read from the reader as the resource with the
+// specified id.  The flow is decoded using the decoder registered for the content
+// type, gzipped flows are uncompressed and flows with a gzip or unspecified content
+// type are sniffed as JSON or YAML.
+func (fm *FlowManager) LoadFromReader(id string, r io.Reader, contentType string) error {
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		readErr := fmt.Errorf("error reading flow '%s', %s", id, err.Error())
+		logger.Errorf(readErr.Error())
+		return readErr
+	}
+
+	if isGzipped(data) {
+		data, err = unzip(data, fm.strictGzip)
+		if err != nil {
+			decompressErr := fmt.Errorf("error uncompressing flow '%s', %s", id, err.Error())
+			logger.Errorf(decompressErr.Error())
+			return decompressErr
+		}
+	}
+
+	if contentType == "" || isGzipMediaType(contentType) {
+		contentType = sniffContentType(data)
+	}
+
+	decoder, exists := getFlowDecoder(contentType)
+	if !exists {
+		decoderErr := fmt.Errorf("error decoding flow '%s', unsupported content type '%s'", id, contentType)
+		logger.Errorf(decoderErr.Error())
+		return decoderErr
+	}
+
+	flowRep, err := decoder(data)
+	if err != nil {
+		decodeErr := fmt.Errorf("error decoding flow '%s', %s", id, err.Error())
+		logger.Errorf(decodeErr.Error())
+		return decodeErr
+	}
+
+	flow, err := fm.materializeFlow(flowRep)
+	if err != nil {
+		return err
+	}
+
+	fm.setResFlow(id, flow, flowRep)
+
+	return nil
+}
+
+func decodeJSONFlow(data []byte) (*definition.DefinitionRep, error) {
+
+	var flowRep *definition.DefinitionRep
+	err := jsonCodec.Unmarshal(data, &flowRep)
+	return flowRep, err
+}
+
+func decodeYAMLFlow(data []byte) (*definition.DefinitionRep, error) {
+
+	var value interface{}
+	err := yaml.Unmarshal(data, &value)
+	if err != nil {
+		return nil, err
+	}
+
+	// go through JSON so the flow's json tags are honored
+	jsonBytes, err := json.Marshal(toJSONValue(value))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeJSONFlow(jsonBytes)
+}
+
+// toJSONValue converts the YAML maps (keyed by interface{}) to JSON compatible maps
+func toJSONValue(value interface{}) interface{} {
+
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = toJSONValue(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = toJSONValue(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isGzipMediaType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == ContentTypeGzip || mediaType == "application/x-gzip"
+}
+
+// sniffContentType determines if the flow is JSON or YAML
+func sniffContentType(data []byte) string {
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return ContentTypeJSON
+	}
+
+	return ContentTypeYAML
+}
@@ -0,0 +1,82 @@
+package support
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFlowYAML = `
+name: Test Flow
+model: test
+tasks:
+  - id: log_1
+    name: Log Start
+    activity:
+      ref: test-log
+      input:
+        message: flow started
+  - id: log_2
+    name: Log End
+    activity:
+      ref: test-log
+      input:
+        message: flow done
+links:
+  - id: 1
+    from: log_1
+    to: log_2
+`
+
+func TestLoadFromReaderJSON(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	err := fm.LoadFromReader("json", strings.NewReader(testFlowJSON), "application/json; charset=utf-8")
+	assert.Nil(t, err)
+
+	flow, err := fm.GetFlow("res://json")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", flow.Name())
+}
+
+func TestLoadFromReaderYAML(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	err := fm.LoadFromReader("yaml", strings.NewReader(testFlowYAML), ContentTypeYAML)
+	assert.Nil(t, err)
+
+	flow, err := fm.GetFlow("res://yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", flow.Name())
+	assert.Len(t, flow.Tasks(), 2)
+	assert.Len(t, flow.Links(), 1)
+}
+
+func TestLoadFromReaderGzip(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	err := fm.LoadFromReader("gzip-json", bytes.NewReader(gzipBytes(t, []byte(testFlowJSON))), ContentTypeGzip)
+	assert.Nil(t, err)
+
+	err = fm.LoadFromReader("gzip-yaml", bytes.NewReader(gzipBytes(t, []byte(testFlowYAML))), "")
+	assert.Nil(t, err)
+
+	for _, uri := range []string{"res://gzip-json", "res://gzip-yaml"} {
+		flow, err := fm.GetFlow(uri)
+		assert.Nil(t, err)
+		assert.Equal(t, "Test Flow", flow.Name())
+	}
+}
+
+func TestLoadFromReaderUnsupportedContentType(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	err := fm.LoadFromReader("xml", strings.NewReader("<flow/>"), "application/xml")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,60 @@
+package support
+
+import (
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+var (
+	deprecatedMu   sync.RWMutex
+	deprecatedRefs = make(map[string]string)
+)
+
+// RegisterDeprecatedRef registers the replacement of a deprecated (ex. renamed) activity ref
+func RegisterDeprecatedRef(ref string, replacement string) {
+
+	deprecatedMu.Lock()
+	defer deprecatedMu.Unlock()
+
+	deprecatedRefs[ref] = replacement
+}
+
+// ReplacementRef gets the replacement of the activity ref, if it is deprecated
+func ReplacementRef(ref string) (string, bool) {
+
+	deprecatedMu.RLock()
+	defer deprecatedMu.RUnlock()
+
+	replacement, deprecated := deprecatedRefs[ref]
+	return replacement, deprecated
+}
+
+// RewriteDeprecatedRefs rewrites the deprecated activity refs of the flow to their
+// replacements, it can be added to the pipeline of a FlowManager using StageFunc
+func RewriteDeprecatedRefs(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+
+	rewriteDeprecatedTaskRefs(rep.Tasks)
+
+	if rep.ErrorHandler != nil {
+		rewriteDeprecatedTaskRefs(rep.ErrorHandler.Tasks)
+	}
+
+	return rep, nil
+}
+
+func rewriteDeprecatedTaskRefs(taskReps []*definition.TaskRep) {
+
+	for _, taskRep := range taskReps {
+		if taskRep.ActivityCfgRep == nil {
+			continue
+		}
+
+		ref := taskRep.ActivityCfgRep.Ref
+		if replacement, deprecated := ReplacementRef(ref); deprecated {
+			logger.Warnf("Task '%s' uses deprecated activity '%s', rewriting to '%s'", taskRep.ID, ref, replacement)
+			taskRep.ActivityCfgRep.Ref = replacement
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package support
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteDeprecatedRefs(t *testing.T) {
+
+	RegisterDeprecatedRef("test-log-old", "test-log")
+
+	deprecatedFlowJSON := strings.Replace(testFlowJSON, `"ref": "test-log"`, `"ref": "test-log-old"`, 1)
+
+	// without rewriting, loading points to the replacement
+	errs := ValidateRep(unmarshalRep(t, deprecatedFlowJSON))
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "replaced by 'test-log'")
+
+	rep, err := RewriteDeprecatedRefs(unmarshalRep(t, deprecatedFlowJSON))
+	assert.Nil(t, err)
+	assert.Equal(t, "test-log", rep.Tasks[0].ActivityCfgRep.Ref)
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/deprecated": deprecatedFlowJSON})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Pipeline: NewPipeline(StageFunc(RewriteDeprecatedRefs))})
+
+	flow, err := fm.GetFlow("http://flows/deprecated")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+}
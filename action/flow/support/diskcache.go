@@ -0,0 +1,99 @@
+package support
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DiskCache is a cache of fetched flows persisted on disk, so that the flows
+// survive a process restart (ex. to speed up cold starts on edge devices)
+type DiskCache struct {
+	// Dir is the directory the flows are cached in
+	Dir string
+
+	// MaxAge is the duration a cached flow is used without revalidating it with the
+	// server (using its ETag), 0 means cached flows are always revalidated
+	MaxAge time.Duration
+
+	// Clock is the source of the current time used to age the cached flows, RealClock
+	// is used if not set
+	Clock Clock
+}
+
+// diskCacheEntry is the metadata of a flow cached on disk, the flow is cached as
+// fetched and its detached signature is kept so it is verified on every hit
+type diskCacheEntry struct {
+	URI       string    `json:"uri"`
+	ETag      string    `json:"etag,omitempty"`
+	Checksum  string    `json:"checksum"`
+	Signature string    `json:"signature,omitempty"`
+	Fetched   time.Time `json:"fetched"`
+
+	data []byte
+}
+
+// NewDiskCache creates a DiskCache storing flows in the specified directory
+func NewDiskCache(dir string, maxAge time.Duration) *DiskCache {
+	return &DiskCache{Dir: dir, MaxAge: maxAge}
+}
+
+// load loads the cached flow with the specified uri, nil is returned if the flow
+// isn't cached or the cached flow doesn't match its checksum
+func (c *DiskCache) load(uri string) *diskCacheEntry {
+
+	metaBytes, err := ioutil.ReadFile(c.path(uri, ".meta"))
+	if err != nil {
+		return nil
+	}
+
+	entry := &diskCacheEntry{}
+	if err := json.Unmarshal(metaBytes, entry); err != nil || entry.URI != uri {
+		return nil
+	}
+
+	entry.data, err = ioutil.ReadFile(c.path(uri, ".json"))
+	if err != nil || checksum(entry.data) != entry.Checksum {
+		return nil
+	}
+
+	return entry
+}
+
+// fresh determines if the cached flow can be used without revalidating it
+func (c *DiskCache) fresh(entry *diskCacheEntry) bool {
+	return c.MaxAge > 0 && clockOrReal(c.Clock).Now().Sub(entry.Fetched) < c.MaxAge
+}
+
+// store stores the flow with the specified uri and its detached signature in the cache
+func (c *DiskCache) store(uri string, data []byte, etag string, signature []byte) error {
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	entry := &diskCacheEntry{URI: uri, ETag: etag, Checksum: checksum(data), Signature: string(signature), Fetched: clockOrReal(c.Clock).Now()}
+	metaBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(c.path(uri, ".json"), data, 0644); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.path(uri, ".meta"), metaBytes, 0644)
+}
+
+func (c *DiskCache) path(uri string, ext string) string {
+	return filepath.Join(c.Dir, checksum([]byte(uri))+ext)
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
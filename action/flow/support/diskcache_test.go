@@ -0,0 +1,143 @@
+package support
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newETagFlowServer(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testFlowJSON))
+	}))
+}
+
+func newTestDiskCache(t *testing.T, maxAge time.Duration) *DiskCache {
+	dir, err := ioutil.TempDir("", "flowcache")
+	assert.Nil(t, err)
+	return NewDiskCache(dir, maxAge)
+}
+
+func TestDiskCacheWarmAvoidsFetch(t *testing.T) {
+
+	var hits int32
+	server := newETagFlowServer(&hits)
+	defer server.Close()
+
+	cache := newTestDiskCache(t, time.Hour)
+	defer os.RemoveAll(cache.Dir)
+
+	provider := &BasicRemoteFlowProvider{DiskCache: cache}
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	// a new provider simulates a process restart
+	provider = &BasicRemoteFlowProvider{DiskCache: NewDiskCache(cache.Dir, time.Hour)}
+	rep, err = provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestDiskCacheRevalidatesWithETag(t *testing.T) {
+
+	var hits int32
+	server := newETagFlowServer(&hits)
+	defer server.Close()
+
+	cache := newTestDiskCache(t, 0)
+	defer os.RemoveAll(cache.Dir)
+
+	provider := &BasicRemoteFlowProvider{DiskCache: cache}
+	_, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestDiskCacheChecksumMismatch(t *testing.T) {
+
+	var hits int32
+	server := newETagFlowServer(&hits)
+	defer server.Close()
+
+	cache := newTestDiskCache(t, time.Hour)
+	defer os.RemoveAll(cache.Dir)
+
+	assert.Nil(t, cache.store(server.URL, []byte(testFlowJSON), `"v1"`, nil))
+	assert.NotNil(t, cache.load(server.URL))
+
+	// corrupt the cached flow
+	err := ioutil.WriteFile(filepath.Join(cache.Dir, checksum([]byte(server.URL))+".json"), []byte("{}"), 0644)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.load(server.URL))
+
+	provider := &BasicRemoteFlowProvider{DiskCache: cache}
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestDiskCacheVerifiesHits(t *testing.T) {
+
+	verifier, key := newTestVerifier(t, true)
+	header, _, signature := signES256(t, key, "flows", []byte(testFlowJSON))
+
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("flow-signature", header+".."+signature)
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	cache := newTestDiskCache(t, time.Hour)
+	defer os.RemoveAll(cache.Dir)
+
+	provider := &BasicRemoteFlowProvider{DiskCache: cache, Verifier: verifier}
+	_, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+
+	// the signed flow is verified when it is used from the cache
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	// a tampered flow (with a valid checksum) is refused and fetched again
+	tampered := []byte(strings.Replace(testFlowJSON, "Test Flow", "Tampered Flow", 1))
+	assert.Nil(t, cache.store(server.URL, tampered, "", []byte(header+".."+signature)))
+
+	rep, err = provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	// as is an unsigned flow
+	assert.Nil(t, cache.store(server.URL, tampered, "", nil))
+
+	rep, err = provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
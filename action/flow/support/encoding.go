@@ -0,0 +1,257 @@
+package support
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// The content encodings of compressed flows
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingZstd    = "zstd"
+)
+
+// Decompressor decompresses a flow compressed using a content encoding, r is read as
+// the returned reader is read so the flow is never buffered compressed
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = map[string]Decompressor{
+		EncodingDeflate: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil },
+	}
+)
+
+// RegisterDecompressor registers the Decompressor of the specified content encoding
+// (ex. zstd, see the support/zstd package), a nil Decompressor unregisters the encoding.
+// Gzip is built in and can't be replaced.
+func RegisterDecompressor(encoding string, decompressor Decompressor) {
+
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+
+	if decompressor == nil {
+		delete(decompressors, strings.ToLower(encoding))
+		return
+	}
+
+	decompressors[strings.ToLower(encoding)] = decompressor
+}
+
+func getDecompressor(encoding string) Decompressor {
+
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+
+	return decompressors[encoding]
+}
+
+// flowEncoding returns the content encoding specified by the value of a flow-compressed
+// header, 'true' is the gzip encoding.  Empty is returned for uncompressed flows, which
+// includes the values that aren't a known content encoding (ex. 'false' or '0').
+func flowEncoding(value string) string {
+
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	switch value {
+	case "true", EncodingGzip:
+		return EncodingGzip
+	case EncodingDeflate, EncodingZstd:
+		return value
+	}
+
+	if value != "" && getDecompressor(value) != nil {
+		return value
+	}
+
+	return ""
+}
+
+// uncompress uncompresses the flow read from r using the specified content encoding, data
+// trailing a gzip stream is rejected if strict
+func uncompress(encoding string, r io.Reader, strict bool) ([]byte, error) {
+
+	ur, err := newUncompressReader(encoding, r, strict)
+	if err != nil {
+		return nil, err
+	}
+	defer ur.Close()
+
+	return ioutil.ReadAll(ur)
+}
+
+// newUncompressReader returns the reader uncompressing the flow read from r using the
+// specified content encoding, r is only read as the flow is read
+func newUncompressReader(encoding string, r io.Reader, strict bool) (io.ReadCloser, error) {
+
+	if encoding == EncodingGzip {
+		return newGzipReader(r, strict)
+	}
+
+	decompressor := getDecompressor(encoding)
+	if decompressor == nil {
+		return nil, fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+
+	return decompressor(r)
+}
+
+// decodeAndUncompress decodes the base64 encoded flow while uncompressing it using the
+// specified content encoding
+func decodeAndUncompress(encoding string, encoded string, strict bool) ([]byte, error) {
+
+	decoded, err := uncompress(encoding, base64.NewDecoder(base64.StdEncoding, strings.NewReader(encoded)), strict)
+	if corruptErr, ok := err.(base64.CorruptInputError); ok {
+		return nil, fmt.Errorf("invalid base64 encoding, %s", corruptErr.Error())
+	}
+
+	return decoded, err
+}
+
+// gzipReader uncompresses a gzip stream, concatenated gzip members are all uncompressed.
+// Data trailing the stream is rejected if strict, ignored otherwise.
+type gzipReader struct {
+	br     *bufio.Reader
+	zr     *gzip.Reader
+	strict bool
+	done   bool
+}
+
+func newGzipReader(r io.Reader, strict bool) (*gzipReader, error) {
+
+	// gzip doesn't read past the end of a member from a bufio.Reader
+	br := bufio.NewReader(r)
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	zr.Multistream(false)
+
+	return &gzipReader{br: br, zr: zr, strict: strict}, nil
+}
+
+func (r *gzipReader) Read(p []byte) (int, error) {
+
+	for !r.done {
+		n, err := r.zr.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+
+		if err := r.nextMember(); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	return 0, io.EOF
+}
+
+// nextMember moves to the next member of the gzip stream, the reader is done once the
+// stream is fully read
+func (r *gzipReader) nextMember() error {
+
+	next, err := r.br.Peek(2)
+	if len(next) == 0 {
+		if err == io.EOF {
+			r.done = true
+			return nil
+		}
+		return err
+	}
+
+	if !isGzipped(next) {
+		trailing, err := io.Copy(ioutil.Discard, r.br)
+		if err != nil {
+			return err
+		}
+		if r.strict {
+			return fmt.Errorf("unexpected %d bytes of data after the gzip stream", trailing)
+		}
+		logger.Debugf("Ignoring %d bytes of data after the gzip stream", trailing)
+		r.done = true
+		return nil
+	}
+
+	if err := r.zr.Reset(r.br); err != nil {
+		return err
+	}
+	r.zr.Multistream(false)
+
+	return nil
+}
+
+func (r *gzipReader) Close() error {
+	return r.zr.Close()
+}
+
+// errFlowTooLarge is the error reading a flow larger than the maximum size
+var errFlowTooLarge = errors.New("flow exceeds the maximum size")
+
+// sizeLimitReader fails with errFlowTooLarge once more than the maximum size is read
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// limitSize limits the size of the flow read from r, 0 means no limit
+func limitSize(r io.Reader, maxSize int64) io.Reader {
+	if maxSize <= 0 {
+		return r
+	}
+	return &sizeLimitReader{r: r, remaining: maxSize}
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+
+	n, err := l.r.Read(p)
+
+	// the bytes past the maximum size are dropped so they can't complete the flow
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = 0
+		return n, errFlowTooLarge
+	}
+	l.remaining -= int64(n)
+
+	return n, err
+}
+
+// encodedResource is the data of a compressed resource specifying its content encoding
+// (ex. {"encoding": "zstd", "data": "KLUv/..."})
+type encodedResource struct {
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"`
+}
+
+// resourceEncoding gets the content encoding and the encoded flow of a compressed
+// resource, resources which don't specify their encoding are gzipped
+func resourceEncoding(data json.RawMessage) (encoding string, encoded string) {
+
+	trimmed := bytes.TrimSpace(data)
+
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var resource encodedResource
+		if err := json.Unmarshal(trimmed, &resource); err == nil && resource.Encoding != "" {
+			return strings.ToLower(resource.Encoding), resource.Data
+		}
+	}
+
+	return EncodingGzip, compressedData(data)
+}
@@ -0,0 +1,240 @@
+package support
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func deflateFlow(t *testing.T, flowJSON string) string {
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	assert.Nil(t, err)
+	w.Write([]byte(flowJSON))
+	w.Close()
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestDecodeAndUncompress(t *testing.T) {
+
+	decoded, err := decodeAndUncompress(EncodingDeflate, deflateFlow(t, testFlowJSON), false)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(decoded))
+
+	encoded, _ := EncodeAndZip([]byte(testFlowJSON))
+	decoded, err = decodeAndUncompress(EncodingGzip, encoded, false)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(decoded))
+
+	_, err = decodeAndUncompress(EncodingGzip, encoded[:8]+"!"+encoded[9:], false)
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "invalid base64 encoding, illegal base64 data"))
+
+	_, err = decodeAndUncompress("brotli", encoded, false)
+	assert.NotNil(t, err)
+	assert.Equal(t, "unsupported content encoding 'brotli'", err.Error())
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+
+	RegisterDecompressor("Identity", func(r io.Reader) (io.ReadCloser, error) { return ioutil.NopCloser(r), nil })
+	defer RegisterDecompressor("identity", nil)
+
+	decoded, err := decodeAndUncompress("identity", base64.StdEncoding.EncodeToString([]byte(testFlowJSON)), false)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(decoded))
+}
+
+func TestLoadResourceEncoding(t *testing.T) {
+
+	data, _ := json.Marshal(&encodedResource{Encoding: "deflate", Data: deflateFlow(t, testFlowJSON)})
+
+	fm := NewFlowManager(nil)
+	err := fm.LoadResource(&resource.Config{ID: "flow:deflated", Compressed: true, Data: data})
+	assert.Nil(t, err)
+	assert.NotNil(t, fm.GetResource("flow:deflated"))
+
+	decompressed, err := DecompressResource(&resource.Config{ID: "flow:deflated", Compressed: true, Data: data})
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(decompressed.Data))
+
+	stats, err := ResourceCompressionStats(&resource.Config{ID: "flow:deflated", Compressed: true, Data: data})
+	assert.Nil(t, err)
+	assert.Equal(t, len(testFlowJSON), stats.Original)
+}
+
+func TestGetFlowEncodingHeader(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("flow-compressed", "deflate")
+		w.Write([]byte(deflateFlow(t, testFlowJSON)))
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{}
+
+	rep, err := provider.GetFlow(server.URL + "/flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+}
+
+// streamCodec records how the flows are decoded
+type streamCodec struct {
+	stdJSONCodec
+	streamed int
+}
+
+func (c *streamCodec) Decode(r io.Reader, v interface{}) error {
+	c.streamed++
+	return c.stdJSONCodec.Decode(r, v)
+}
+
+func TestGetFlowStreamed(t *testing.T) {
+
+	codec := &streamCodec{}
+	SetJSONCodec(codec)
+	defer SetJSONCodec(nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flows/gzipped" {
+			encoded, _ := EncodeAndZip([]byte(testFlowJSON))
+			w.Header().Set("flow-compressed", "true")
+			w.Write([]byte(encoded))
+			return
+		}
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{}
+
+	rep, err := provider.GetFlow(server.URL + "/flows/gzipped")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+
+	rep, err = provider.GetFlow(server.URL + "/flows/plain")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, 2, codec.streamed)
+
+	// the flows which are processed before they are decoded are read first
+	provider = &BasicRemoteFlowProvider{FlowPath: "flow"}
+	_, err = provider.GetFlow(server.URL + "/flows/plain")
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, codec.streamed)
+
+	server.Close()
+	_, err = (&BasicRemoteFlowProvider{}).GetFlow(server.URL + "/flows/plain")
+	assert.NotNil(t, err)
+}
+
+func TestGetFlowStreamedInvalid(t *testing.T) {
+
+	encoded, _ := EncodeAndZip([]byte(testFlowJSON))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flows/truncated":
+			w.Header().Set("flow-compressed", "gzip")
+			w.Write([]byte(encoded[:len(encoded)/2]))
+		case "/flows/corrupt":
+			w.Header().Set("flow-compressed", "gzip")
+			w.Write([]byte(encoded[:8] + "!" + encoded[9:]))
+		default:
+			w.Write([]byte(testFlowJSON[:len(testFlowJSON)/2]))
+		}
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{}
+
+	_, err := provider.getFlow(server.URL + "/flows/truncated")
+	assert.IsType(t, &TruncatedFlowError{}, err)
+
+	_, err = provider.getFlow(server.URL + "/flows/corrupt")
+	assert.IsType(t, &decodeError{}, err)
+	assert.Contains(t, err.Error(), "invalid base64 encoding")
+
+	_, err = provider.getFlow(server.URL + "/flows/partial")
+	assert.IsType(t, &decodeError{}, err)
+	assert.Contains(t, err.Error(), "error marshalling flow")
+}
+
+func TestGetFlowMaxSize(t *testing.T) {
+
+	// a small compressed flow which is large once uncompressed
+	padded := strings.Replace(testFlowJSON, `"name"`, `"description": "`+strings.Repeat(" ", 64*1024)+`", "name"`, 1)
+	encoded, _ := EncodeAndZip([]byte(padded))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flows/padded" {
+			w.Header().Set("flow-compressed", "true")
+			w.Write([]byte(encoded))
+			return
+		}
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	assert.True(t, len(encoded) < 16*1024)
+
+	// the flow is limited whether it is decoded as it is received or read first
+	identity := func(flowURI string, source []byte) ([]byte, error) { return source, nil }
+
+	for _, provider := range []*BasicRemoteFlowProvider{{MaxFlowSize: 16 * 1024}, {MaxFlowSize: 16 * 1024, Compiler: identity}} {
+
+		_, err := provider.GetFlow(server.URL + "/flows/padded")
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "exceeds the maximum size of 16384 bytes")
+
+		provider.MaxFlowSize = int64(len(testFlowJSON) - 1)
+		_, err = provider.GetFlow(server.URL + "/flows/plain")
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "exceeds the maximum size")
+	}
+
+	rep, err := (&BasicRemoteFlowProvider{MaxFlowSize: 128 * 1024}).GetFlow(server.URL + "/flows/padded")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+}
+
+func TestFlowEncoding(t *testing.T) {
+
+	assert.Equal(t, EncodingGzip, flowEncoding("true"))
+	assert.Equal(t, EncodingGzip, flowEncoding(" GZIP "))
+	assert.Equal(t, EncodingDeflate, flowEncoding("deflate"))
+	assert.Equal(t, EncodingZstd, flowEncoding("zstd"))
+
+	for _, value := range []string{"", "false", "0", "1", "no", "brotli"} {
+		assert.Equal(t, "", flowEncoding(value), value)
+	}
+
+	RegisterDecompressor("identity", func(r io.Reader) (io.ReadCloser, error) { return ioutil.NopCloser(r), nil })
+	defer RegisterDecompressor("identity", nil)
+	assert.Equal(t, "identity", flowEncoding("Identity"))
+}
+
+func TestGetFlowUnknownEncodingHeader(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("flow-compressed", "0")
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	rep, err := (&BasicRemoteFlowProvider{}).GetFlow(server.URL + "/flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+}
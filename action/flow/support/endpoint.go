@@ -0,0 +1,78 @@
+package support
+
+import (
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// EndpointRule rewrites the endpoints starting with From, replacing the prefix with
+// To (ex. From: "https://api.example.com", To: "https://api.staging.example.com")
+type EndpointRule struct {
+	From string
+	To   string
+}
+
+// EndpointRewriter is a pipeline Stage rewriting the URL settings and inputs of
+// the activities of the flow (ex. to target the hosts of the environment), the
+// first rule matching an endpoint is applied
+type EndpointRewriter struct {
+	Rules []EndpointRule
+}
+
+// NewEndpointRewriter creates an EndpointRewriter applying the specified rules
+func NewEndpointRewriter(rules ...EndpointRule) *EndpointRewriter {
+	return &EndpointRewriter{Rules: rules}
+}
+
+// Process implements Stage.Process
+func (r *EndpointRewriter) Process(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+
+	r.rewriteTasks(rep.Tasks)
+
+	if rep.ErrorHandler != nil {
+		r.rewriteTasks(rep.ErrorHandler.Tasks)
+	}
+
+	return rep, nil
+}
+
+func (r *EndpointRewriter) rewriteTasks(taskReps []*definition.TaskRep) {
+
+	for _, taskRep := range taskReps {
+		if taskRep.ActivityCfgRep == nil {
+			continue
+		}
+
+		r.rewriteValues(taskRep.ID, taskRep.ActivityCfgRep.Settings)
+		r.rewriteValues(taskRep.ID, taskRep.ActivityCfgRep.InputAttrs)
+	}
+}
+
+func (r *EndpointRewriter) rewriteValues(taskID string, values map[string]interface{}) {
+
+	for name, value := range values {
+		endpoint, ok := value.(string)
+		if !ok || !strings.Contains(endpoint, "://") {
+			continue
+		}
+
+		if rewritten, matched := r.rewrite(endpoint); matched {
+			logger.Debugf("Rewriting endpoint '%s' of task '%s' to '%s'", name, taskID, rewritten)
+			values[name] = rewritten
+		}
+	}
+}
+
+// rewrite applies the first rule matching the endpoint
+func (r *EndpointRewriter) rewrite(endpoint string) (string, bool) {
+
+	for _, rule := range r.Rules {
+		if rule.From != "" && strings.HasPrefix(endpoint, rule.From) {
+			return rule.To + endpoint[len(rule.From):], true
+		}
+	}
+
+	return endpoint, false
+}
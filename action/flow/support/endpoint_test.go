@@ -0,0 +1,81 @@
+package support
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/TIBCOSoftware/flogo-lib/core/activity"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	activity.Register(newTestRestActivity())
+}
+
+type testRestActivity struct {
+	metadata *activity.Metadata
+}
+
+func newTestRestActivity() activity.Activity {
+	metadata := &activity.Metadata{ID: "test-rest"}
+	metadata.Settings = map[string]*data.Attribute{
+		"proxy": data.NewZeroAttribute("proxy", data.TypeString),
+	}
+	metadata.Input = map[string]*data.Attribute{
+		"method": data.NewZeroAttribute("method", data.TypeString),
+		"uri":    data.NewZeroAttribute("uri", data.TypeString),
+	}
+	return &testRestActivity{metadata: metadata}
+}
+
+func (a *testRestActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *testRestActivity) Eval(context activity.Context) (done bool, err error) {
+	return true, nil
+}
+
+const restFlowJSON = `{
+  "name": "Rest Flow",
+  "model": "test",
+  "tasks": [
+    {
+      "id": "get_order",
+      "activity": {
+        "ref": "test-rest",
+        "settings": { "proxy": "http://proxy.example.com:3128" },
+        "input": { "method": "GET", "uri": "https://api.example.com/orders/1" }
+      }
+    }
+  ]
+}`
+
+func TestEndpointRewriter(t *testing.T) {
+
+	rewriter := NewEndpointRewriter(
+		EndpointRule{From: "https://api.example.com", To: "https://api.staging.example.com"},
+		EndpointRule{From: "https://", To: "http://"},
+	)
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{Pipeline: NewPipeline(rewriter)})
+	err := fm.LoadResource(&resource.Config{ID: "rest", Data: []byte(restFlowJSON)})
+	assert.Nil(t, err)
+
+	flow, err := fm.GetFlow("res://rest")
+	assert.Nil(t, err)
+
+	activityCfg := flow.GetTask("get_order").ActivityConfig()
+
+	uri, exists := activityCfg.GetInputAttr("uri")
+	assert.True(t, exists)
+	assert.Equal(t, "https://api.staging.example.com/orders/1", uri.Value())
+
+	method, _ := activityCfg.GetInputAttr("method")
+	assert.Equal(t, "GET", method.Value())
+
+	// endpoints not matching any rule are kept
+	proxy, _ := activityCfg.GetSetting("proxy")
+	assert.Equal(t, "http://proxy.example.com:3128", proxy.Value())
+}
@@ -0,0 +1,43 @@
+package support
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// extractFlowJSON extracts the flow found at the dot separated path (ex. data.flow or
+// data.flows.0) of the JSON wrapper, numeric segments index arrays
+func extractFlowJSON(wrapper []byte, path string) ([]byte, error) {
+
+	var value interface{}
+	if err := json.Unmarshal(wrapper, &value); err != nil {
+		return nil, err
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			val, exists := v[segment]
+			if !exists {
+				return nil, fmt.Errorf("'%s' not found", segment)
+			}
+			value = val
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("invalid index '%s'", segment)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("'%s' not found", segment)
+		}
+	}
+
+	if _, isObject := value.(map[string]interface{}); !isObject {
+		return nil, fmt.Errorf("value at path '%s' is not an object", path)
+	}
+
+	return json.Marshal(value)
+}
@@ -0,0 +1,36 @@
+package support
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFlowFlowPath(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok", "data": {"flows": [{"flow": ` + testFlowJSON + `}]}}`))
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{FlowPath: "data.flows.0.flow"}
+
+	rep, err := provider.GetFlow(server.URL + "/flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Len(t, rep.Tasks, 2)
+
+	provider = &BasicRemoteFlowProvider{FlowPath: "data.flow"}
+
+	_, err = provider.GetFlow(server.URL + "/flows/orders")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "at path 'data.flow', 'flow' not found")
+
+	provider = &BasicRemoteFlowProvider{FlowPath: "status"}
+
+	_, err = provider.GetFlow(server.URL + "/flows/orders")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "value at path 'status' is not an object")
+}
@@ -0,0 +1,52 @@
+package support
+
+import (
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+// FlagEvaluator determines if the feature flag is enabled
+type FlagEvaluator func(flag string) bool
+
+// PruneDisabledFeatures creates a pipeline Stage that removes the tasks gated by a
+// disabled feature flag, along with their links, producing a flow tailored to the
+// enabled features
+func PruneDisabledFeatures(enabled FlagEvaluator) Stage {
+	return StageFunc(func(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+
+		rep.Tasks, rep.Links = pruneTasks(rep.Tasks, rep.Links, enabled)
+
+		if rep.ErrorHandler != nil {
+			rep.ErrorHandler.Tasks, rep.ErrorHandler.Links = pruneTasks(rep.ErrorHandler.Tasks, rep.ErrorHandler.Links, enabled)
+		}
+
+		return rep, nil
+	})
+}
+
+func pruneTasks(taskReps []*definition.TaskRep, linkReps []*definition.LinkRep, enabled FlagEvaluator) ([]*definition.TaskRep, []*definition.LinkRep) {
+
+	pruned := make(map[string]bool)
+	tasks := make([]*definition.TaskRep, 0, len(taskReps))
+
+	for _, taskRep := range taskReps {
+		if taskRep.FeatureFlag != "" && !enabled(taskRep.FeatureFlag) {
+			pruned[taskRep.ID] = true
+			continue
+		}
+		tasks = append(tasks, taskRep)
+	}
+
+	if len(pruned) == 0 {
+		return taskReps, linkReps
+	}
+
+	links := make([]*definition.LinkRep, 0, len(linkReps))
+
+	for _, linkRep := range linkReps {
+		if !pruned[linkRep.FromID] && !pruned[linkRep.ToID] {
+			links = append(links, linkRep)
+		}
+	}
+
+	return tasks, links
+}
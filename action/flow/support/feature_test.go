@@ -0,0 +1,43 @@
+package support
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const featureFlowJSON = `{
+  "name": "Feature Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "start", "activity": { "ref": "test-log" } },
+    { "id": "legacy_checkout", "featureFlag": "legacy-checkout", "activity": { "ref": "test-log" } },
+    { "id": "new_checkout", "featureFlag": "new-checkout", "activity": { "ref": "test-log" } },
+    { "id": "done", "activity": { "ref": "test-log" } }
+  ],
+  "links": [
+    { "from": "start", "to": "legacy_checkout" },
+    { "from": "start", "to": "new_checkout" },
+    { "from": "legacy_checkout", "to": "done" },
+    { "from": "new_checkout", "to": "done" }
+  ]
+}`
+
+func TestPruneDisabledFeatures(t *testing.T) {
+
+	flags := map[string]bool{"new-checkout": true}
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/feature": featureFlowJSON})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{
+		Pipeline: NewPipeline(PruneDisabledFeatures(func(flag string) bool { return flags[flag] })),
+	})
+
+	flow, err := fm.GetFlow("http://flows/feature")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+
+	assert.Nil(t, flow.GetTask("legacy_checkout"))
+	assert.NotNil(t, flow.GetTask("new_checkout"))
+	assert.Len(t, flow.Tasks(), 3)
+	assert.Len(t, flow.Links(), 2)
+}
@@ -0,0 +1,98 @@
+package support
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// FlowHandler is an http.Handler serving flows to a BasicRemoteFlowProvider, flows
+// are gzipped and base64 encoded using the 'flow-compressed' convention if Compress
+// is set.  Responses carry an ETag so unchanged flows aren't transferred again.
+type FlowHandler struct {
+	// Compress indicates if the flows are served compressed
+	Compress bool
+
+	mu    sync.RWMutex
+	flows map[string]*servedFlow
+}
+
+type servedFlow struct {
+	body []byte
+	etag string
+}
+
+// NewFlowHandler creates a FlowHandler
+func NewFlowHandler(compress bool) *FlowHandler {
+	return &FlowHandler{Compress: compress, flows: make(map[string]*servedFlow)}
+}
+
+// SetFlow serves the flow at the specified path (ex. /flows/orders)
+func (h *FlowHandler) SetFlow(path string, flowJSON []byte) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.flows == nil {
+		h.flows = make(map[string]*servedFlow)
+	}
+	h.flows[path] = &servedFlow{body: flowJSON, etag: `"` + checksum(flowJSON) + `"`}
+}
+
+// RemoveFlow stops serving the flow at the specified path
+func (h *FlowHandler) RemoveFlow(path string) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.flows, path)
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP
+func (h *FlowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.RLock()
+	flow := h.flows[r.URL.Path]
+	h.mu.RUnlock()
+
+	if flow == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", flow.etag)
+
+	if r.Header.Get("If-None-Match") == flow.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := flow.body
+
+	if h.Compress {
+		encoded, err := EncodeAndZip(flow.body)
+		if err != nil {
+			logger.Errorf("Unable to compress flow '%s': %s", r.URL.Path, err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		body = []byte(encoded)
+		w.Header().Set("flow-compressed", "true")
+		w.Header().Set("Content-Type", "text/plain")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
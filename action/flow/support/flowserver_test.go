@@ -0,0 +1,63 @@
+package support
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowHandlerCompressedRoundTrip(t *testing.T) {
+
+	handler := NewFlowHandler(true)
+	handler.SetFlow("/flows/test", []byte(testFlowJSON))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/flows/test")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "true", resp.Header.Get("flow-compressed"))
+
+	provider := &BasicRemoteFlowProvider{}
+
+	rep, err := provider.GetFlow(server.URL + "/flows/test")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Len(t, rep.Tasks, 2)
+}
+
+func TestFlowHandler(t *testing.T) {
+
+	handler := NewFlowHandler(false)
+	handler.SetFlow("/flows/test", []byte(testFlowJSON))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/flows/test")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "", resp.Header.Get("flow-compressed"))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/flows/test", nil)
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	handler.RemoveFlow("/flows/test")
+	resp, err = http.Get(server.URL + "/flows/test")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post(server.URL+"/flows/test", "application/json", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
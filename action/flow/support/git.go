@@ -0,0 +1,321 @@
+package support
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+const uriSchemeGit = "git://"
+
+// Protocols a GitFlowProvider can clone the repositories with
+const (
+	GitProtocolHTTPS = "https"
+	GitProtocolSSH   = "ssh"
+	GitProtocolGit   = "git"
+	GitProtocolFile  = "file"
+)
+
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// GitFlowProvider is a definition.Provider getting the flows from git repositories using
+// the git command, so any git server can be the source of truth of the flows.  A flow
+// uri is git://<host>/<repository>//<path>@<ref> (ex.
+// git://github.com/acme/flows.git//orders.json@v1.2), the default branch is used if the
+// ref isn't set.  The ref is resolved to its commit every time the flow is requested and
+// the flows are cached by commit.  A flow is fetched using a shallow clone with a sparse
+// checkout of its file, so only the commit and the flow are transferred.  The provider
+// can be registered for the git scheme using RegisterFlowProvider.
+type GitFlowProvider struct {
+	// Protocol is the protocol the repositories are cloned with, GitProtocolHTTPS is
+	// used if not set.  The repository of a flow is <protocol>://<host>/<repository>,
+	// the user of the ssh protocol is git.
+	Protocol string
+
+	// SSHKeyFile is the private key authenticating the ssh clones of private repositories
+	SSHKeyFile string
+
+	// Auth authenticates the https clones of private repositories (ex. a BasicAuth with
+	// a personal access token as password)
+	Auth Authenticator
+
+	// GitPath is the path of the git command, git is looked up in the PATH if not set
+	GitPath string
+
+	// WorkDir is the directory the repositories are cloned in, the default directory for
+	// temporary files is used if not set.  The clones are removed once the flow is read.
+	WorkDir string
+
+	// MaxCachedFlows is the maximum number of flows cached by commit, the least recently
+	// used flow is evicted when it is exceeded.  DefaultMaxCommitFlows is used if not set,
+	// a negative value is unlimited.
+	MaxCachedFlows int
+
+	flows commitCache
+}
+
+// NewGitFlowProvider creates a GitFlowProvider cloning the repositories with the
+// specified protocol
+func NewGitFlowProvider(protocol string) *GitFlowProvider {
+	return &GitFlowProvider{Protocol: protocol}
+}
+
+// GetFlow implements definition.Provider.GetFlow
+func (p *GitFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	repo, path, ref, err := parseGitURI(flowURI)
+	if err != nil {
+		return nil, err
+	}
+
+	repoURL, err := p.repositoryURL(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := p.environment(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sha, err := p.resolveCommit(repoURL, ref, env)
+	if err != nil {
+		resolveErr := fmt.Errorf("error resolving ref '%s' of flow with uri '%s', %s", ref, flowURI, err.Error())
+		logger.Errorf(resolveErr.Error())
+		return nil, resolveErr
+	}
+
+	key := repoURL + "//" + path + "@" + sha
+
+	if cached, err := p.flows.get(key); cached != nil || err != nil {
+		logger.Debugf("Using flow with uri '%s' cached at commit '%s'", flowURI, sha)
+		return cached, err
+	}
+
+	flow, err := p.fetchFile(repoURL, sha, ref, path, env)
+	if err != nil {
+		fetchErr := fmt.Errorf("error fetching flow with uri '%s' at commit '%s', %s", flowURI, sha, err.Error())
+		logger.Errorf(fetchErr.Error())
+		return nil, fetchErr
+	}
+
+	if isGzipped(flow) {
+		flow, err = unzip(flow, false)
+		if err != nil {
+			decompressErr := uncompressError(flowURI, err)
+			logger.Errorf(decompressErr.Error())
+			return nil, decompressErr
+		}
+	}
+
+	var rep *definition.DefinitionRep
+	if err := jsonCodec.Unmarshal(flow, &rep); err != nil {
+		decodeErr := fmt.Errorf("error marshalling flow with uri '%s', %s", flowURI, err.Error())
+		logger.Errorf(decodeErr.Error())
+		return nil, decodeErr
+	}
+
+	p.flows.put(key, rep, p.MaxCachedFlows)
+
+	return rep, nil
+}
+
+// resolveCommit resolves the ref of the repository to its commit sha, a peeled tag is
+// preferred to the tag object of an annotated tag
+func (p *GitFlowProvider) resolveCommit(repoURL, ref string, env []string) (string, error) {
+
+	if commitSHAPattern.MatchString(ref) {
+		return ref, nil
+	}
+
+	// the peeled ref is only listed if requested
+	out, err := p.git("", env, "ls-remote", repoURL, ref, ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+
+	var sha string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if strings.HasSuffix(fields[1], "^{}") {
+			return fields[0], nil
+		}
+		if sha == "" {
+			sha = fields[0]
+		}
+	}
+
+	if sha == "" {
+		return "", fmt.Errorf("ref not found")
+	}
+
+	return sha, nil
+}
+
+// fetchFile fetches the file of the repository at the commit using a shallow clone with
+// a sparse checkout of the file, the blobs are filtered out of the clone when the server
+// supports it so only the blob of the file is transferred.  The ref is fetched if the
+// server doesn't allow fetching the commit.
+func (p *GitFlowProvider) fetchFile(repoURL, sha, ref, path string, env []string) ([]byte, error) {
+
+	dir, err := ioutil.TempDir(p.WorkDir, "flow-git-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := p.git(dir, env, "init", "-q"); err != nil {
+		return nil, err
+	}
+	if _, err := p.git(dir, env, "remote", "add", "origin", repoURL); err != nil {
+		return nil, err
+	}
+	if _, err := p.git(dir, env, "config", "core.sparseCheckout", "true"); err != nil {
+		return nil, err
+	}
+
+	sparse := filepath.Join(dir, ".git", "info", "sparse-checkout")
+	if err := os.MkdirAll(filepath.Dir(sparse), 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(sparse, []byte("/"+path+"\n"), 0644); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.git(dir, env, "fetch", "-q", "--depth", "1", "--filter=blob:none", "origin", sha); err != nil {
+		if ref == sha {
+			return nil, err
+		}
+		logger.Debugf("Unable to fetch commit '%s' of '%s', fetching ref '%s'", sha, repoURL, ref)
+		if _, err := p.git(dir, env, "fetch", "-q", "--depth", "1", "--filter=blob:none", "origin", ref); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.git(dir, env, "checkout", "-q", "FETCH_HEAD"); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+}
+
+// git runs the git command in the directory and returns its output, its error output is
+// added to the returned error
+func (p *GitFlowProvider) git(dir string, env []string, args ...string) (string, error) {
+
+	gitPath := p.GitPath
+	if gitPath == "" {
+		gitPath = "git"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(gitPath, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s failed, %s: %s", args[0], err.Error(), msg)
+		}
+		return "", fmt.Errorf("git %s failed, %s", args[0], err.Error())
+	}
+
+	return stdout.String(), nil
+}
+
+// environment returns the environment of the git commands, the credentials are passed
+// in the environment so they aren't visible in the arguments of the commands
+func (p *GitFlowProvider) environment(repoURL string) ([]string, error) {
+
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	switch p.protocol() {
+	case GitProtocolSSH:
+		sshCommand := "ssh -o BatchMode=yes"
+		if p.SSHKeyFile != "" {
+			sshCommand += " -o IdentitiesOnly=yes -i '" + strings.Replace(p.SSHKeyFile, "'", `'\''`, -1) + "'"
+		}
+		env = append(env, "GIT_SSH_COMMAND="+sshCommand)
+	case GitProtocolHTTPS:
+		if p.Auth == nil {
+			break
+		}
+
+		req, err := http.NewRequest(http.MethodGet, repoURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+
+		if authorization := req.Header.Get("Authorization"); authorization != "" {
+			env = append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: "+authorization)
+		}
+	}
+
+	return env, nil
+}
+
+// repositoryURL returns the url the repository is cloned from
+func (p *GitFlowProvider) repositoryURL(repo string) (string, error) {
+
+	switch protocol := p.protocol(); protocol {
+	case GitProtocolHTTPS, GitProtocolGit, GitProtocolFile:
+		return protocol + "://" + repo, nil
+	case GitProtocolSSH:
+		return "ssh://git@" + repo, nil
+	default:
+		return "", fmt.Errorf("unsupported git protocol '%s'", protocol)
+	}
+}
+
+func (p *GitFlowProvider) protocol() string {
+	if p.Protocol == "" {
+		return GitProtocolHTTPS
+	}
+	return p.Protocol
+}
+
+// parseGitURI splits the uri into the repository (<host>/<repository>), the path of the
+// flow in the repository and the ref, the ref is HEAD if not set
+func parseGitURI(flowURI string) (repo, path, ref string, err error) {
+
+	if !strings.HasPrefix(flowURI, uriSchemeGit) {
+		return "", "", "", fmt.Errorf("unsupported flow uri '%s'", flowURI)
+	}
+
+	location := flowURI[len(uriSchemeGit):]
+	ref = "HEAD"
+
+	if idx := strings.LastIndex(location, "@"); idx >= 0 {
+		location, ref = location[:idx], location[idx+1:]
+	}
+
+	idx := strings.Index(location, "//")
+	if idx <= 0 || ref == "" || strings.HasPrefix(ref, "-") {
+		return "", "", "", fmt.Errorf("invalid flow uri '%s', expected git://<host>/<repository>//<path>@<ref>", flowURI)
+	}
+
+	repo, path = location[:idx], location[idx+2:]
+
+	cleaned := filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+	if path == "" || cleaned != path || strings.HasPrefix(cleaned, "../") || strings.HasPrefix(cleaned, "/") {
+		return "", "", "", fmt.Errorf("invalid path '%s' of flow with uri '%s'", path, flowURI)
+	}
+
+	return repo, path, ref, nil
+}
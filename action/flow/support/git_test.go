@@ -0,0 +1,137 @@
+package support
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// commitTestFlow commits the flow named name to the repository in dir
+func commitTestFlow(t *testing.T, dir string, name string) {
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "flows"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "flows", "orders.json"), []byte(strings.Replace(testFlowJSON, "Test Flow", name, 1)), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte(name), 0644))
+
+	runTestGit(t, dir, "add", "-A")
+	runTestGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", name)
+}
+
+func runTestGit(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(out))
+}
+
+func TestGitFlowProvider(t *testing.T) {
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir, err := ioutil.TempDir("", "flow-repo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	repo := filepath.Join(dir, "flows")
+	assert.Nil(t, os.Mkdir(repo, 0755))
+	runTestGit(t, repo, "init", "-q")
+	commitTestFlow(t, repo, "Flow v1")
+	runTestGit(t, repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "tag", "-a", "-m", "v1", "v1")
+
+	workDir := filepath.Join(dir, "work")
+	assert.Nil(t, os.Mkdir(workDir, 0755))
+
+	provider := NewGitFlowProvider(GitProtocolFile)
+	provider.WorkDir = workDir
+	provider.MaxCachedFlows = 1
+
+	uri := "git://" + filepath.ToSlash(repo) + "//flows/orders.json"
+
+	rep, err := provider.GetFlow(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, "Flow v1", rep.Name)
+
+	// the flow is cached by commit, so it isn't fetched again
+	assert.Nil(t, os.Remove(workDir))
+	rep, err = provider.GetFlow(uri + "@v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Flow v1", rep.Name)
+	assert.Nil(t, os.Mkdir(workDir, 0755))
+
+	commitTestFlow(t, repo, "Flow v2")
+
+	rep, err = provider.GetFlow(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, "Flow v2", rep.Name)
+
+	// the flow at the tag was evicted
+	rep, err = provider.GetFlow(uri + "@v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Flow v1", rep.Name)
+
+	// only the flow was checked out
+	files, err := ioutil.ReadDir(workDir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 0)
+
+	_, err = provider.GetFlow(uri + "@missing")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error resolving ref 'missing'")
+
+	_, err = provider.GetFlow("git://" + filepath.ToSlash(repo) + "//flows/missing.json")
+	assert.NotNil(t, err)
+}
+
+func TestGitFlowProviderEnvironment(t *testing.T) {
+
+	provider := NewGitFlowProvider(GitProtocolSSH)
+	provider.SSHKeyFile = "/keys/flow's key"
+
+	env, err := provider.environment("ssh://git@github.com/acme/flows.git")
+	assert.Nil(t, err)
+	assert.Contains(t, env, `GIT_SSH_COMMAND=ssh -o BatchMode=yes -o IdentitiesOnly=yes -i '/keys/flow'\''s key'`)
+
+	provider = NewGitFlowProvider("")
+	provider.Auth = BearerToken("secret")
+
+	env, err = provider.environment("https://github.com/acme/flows.git")
+	assert.Nil(t, err)
+	assert.Contains(t, env, "GIT_CONFIG_VALUE_0=Authorization: Bearer secret")
+
+	url, err := provider.repositoryURL("github.com/acme/flows.git")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://github.com/acme/flows.git", url)
+
+	provider.Protocol = "svn"
+	_, err = provider.repositoryURL("github.com/acme/flows.git")
+	assert.NotNil(t, err)
+}
+
+func TestParseGitURI(t *testing.T) {
+
+	repo, path, ref, err := parseGitURI("git://github.com/acme/flows.git//a/b/orders.json@v1.2")
+	assert.Nil(t, err)
+	assert.Equal(t, "github.com/acme/flows.git", repo)
+	assert.Equal(t, "a/b/orders.json", path)
+	assert.Equal(t, "v1.2", ref)
+
+	_, _, ref, err = parseGitURI("git://github.com/acme/flows.git//orders.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "HEAD", ref)
+
+	_, _, _, err = parseGitURI("git://github.com/acme/flows.git/orders.json")
+	assert.NotNil(t, err)
+
+	_, _, _, err = parseGitURI("git://github.com/acme/flows.git//../orders.json")
+	assert.NotNil(t, err)
+
+	_, _, _, err = parseGitURI("git://github.com/acme/flows.git//orders.json@--upload-pack=evil")
+	assert.NotNil(t, err)
+}
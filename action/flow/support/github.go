@@ -0,0 +1,177 @@
+package support
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+const (
+	uriSchemeGitHub = "github://"
+
+	// DefaultGitHubAPIURL is the url of the GitHub API used to resolve the refs of the flows
+	DefaultGitHubAPIURL = "https://api.github.com"
+
+	// DefaultGitHubRawURL is the url the content of the GitHub flows is fetched from
+	DefaultGitHubRawURL = "https://raw.githubusercontent.com"
+)
+
+// GitHubFlowProvider is a definition.Provider getting the flows from GitHub repositories,
+// so a repository can be the source of truth of the flows.  A flow uri is
+// github://<owner>/<repo>/<path>@<ref> (ex. github://acme/flows/orders.json@v1.2), the
+// default branch is used if the ref isn't set.  The ref is resolved to its commit every
+// time the flow is requested and the flows are cached by commit, so a flow is only
+// fetched again once its ref moves.
+type GitHubFlowProvider struct {
+	// Fetcher fetches the commits and the flows, its Auth authenticates the requests
+	// (ex. a BearerToken with a personal access token for private repositories)
+	Fetcher *BasicRemoteFlowProvider
+
+	// APIURL is the url of the GitHub API, DefaultGitHubAPIURL is used if not set
+	APIURL string
+
+	// RawURL is the url the content of the flows is fetched from, DefaultGitHubRawURL
+	// is used if not set
+	RawURL string
+
+	// MaxCachedFlows is the maximum number of flows cached by commit, the least recently
+	// used flow is evicted when it is exceeded.  DefaultMaxCommitFlows is used if not set,
+	// a negative value is unlimited.
+	MaxCachedFlows int
+
+	flows commitCache
+}
+
+// NewGitHubFlowProvider creates a GitHubFlowProvider fetching the flows using the specified
+// fetcher, a BasicRemoteFlowProvider with the default settings is used if it is nil
+func NewGitHubFlowProvider(fetcher *BasicRemoteFlowProvider) *GitHubFlowProvider {
+
+	if fetcher == nil {
+		fetcher = &BasicRemoteFlowProvider{}
+	}
+
+	return &GitHubFlowProvider{Fetcher: fetcher}
+}
+
+// GetFlow implements definition.Provider.GetFlow
+func (p *GitHubFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	repo, path, ref, err := parseGitHubURI(flowURI)
+	if err != nil {
+		return nil, err
+	}
+
+	sha, err := p.resolveRef(repo, ref)
+	if err != nil {
+		resolveErr := fmt.Errorf("error resolving ref '%s' of flow with uri '%s', %s", ref, flowURI, err.Error())
+		logger.Errorf(resolveErr.Error())
+		return nil, resolveErr
+	}
+
+	rawURI := fmt.Sprintf("%s/%s/%s/%s", strings.TrimSuffix(p.rawURL(), "/"), repo, sha, path)
+
+	if cached, err := p.flows.get(rawURI); cached != nil || err != nil {
+		logger.Debugf("Using flow with uri '%s' cached at commit '%s'", flowURI, sha)
+		return cached, err
+	}
+
+	rep, err := p.fetcher().GetFlow(rawURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if rep != nil {
+		p.flows.put(rawURI, rep, p.MaxCachedFlows)
+	}
+
+	return rep, nil
+}
+
+// resolveRef resolves the ref of the repository to its commit sha
+func (p *GitHubFlowProvider) resolveRef(repo string, ref string) (string, error) {
+
+	fetcher := p.fetcher()
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/repos/%s/commits/%s", strings.TrimSuffix(p.apiURL(), "/"), repo, ref), nil)
+	if err != nil {
+		return "", err
+	}
+
+	for name, value := range fetcher.Headers {
+		req.Header.Set(name, value)
+	}
+	if err := fetcher.authenticate(req); err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+
+	resp, err := fetcher.hostClient(req.URL.Host).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	sha := strings.TrimSpace(string(body))
+	if sha == "" {
+		return "", fmt.Errorf("commit not found")
+	}
+
+	return sha, nil
+}
+
+func (p *GitHubFlowProvider) fetcher() *BasicRemoteFlowProvider {
+	if p.Fetcher == nil {
+		return &BasicRemoteFlowProvider{}
+	}
+	return p.Fetcher
+}
+
+func (p *GitHubFlowProvider) apiURL() string {
+	if p.APIURL == "" {
+		return DefaultGitHubAPIURL
+	}
+	return p.APIURL
+}
+
+func (p *GitHubFlowProvider) rawURL() string {
+	if p.RawURL == "" {
+		return DefaultGitHubRawURL
+	}
+	return p.RawURL
+}
+
+// parseGitHubURI splits the uri into the repository (owner/repo), the path of the flow
+// and the ref, the ref is HEAD if not set
+func parseGitHubURI(flowURI string) (repo, path, ref string, err error) {
+
+	if !strings.HasPrefix(flowURI, uriSchemeGitHub) {
+		return "", "", "", fmt.Errorf("unsupported flow uri '%s'", flowURI)
+	}
+
+	location := flowURI[len(uriSchemeGitHub):]
+	ref = "HEAD"
+
+	if idx := strings.LastIndex(location, "@"); idx >= 0 {
+		location, ref = location[:idx], location[idx+1:]
+	}
+
+	parts := strings.SplitN(location, "/", 3)
+	if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" || ref == "" {
+		return "", "", "", fmt.Errorf("invalid flow uri '%s', expected github://<owner>/<repo>/<path>@<ref>", flowURI)
+	}
+
+	return parts[0] + "/" + parts[1], parts[2], ref, nil
+}
@@ -0,0 +1,80 @@
+package support
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitHubFlowProvider(t *testing.T) {
+
+	sha := "1111111"
+	var contentCalls int
+	var authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+
+		switch {
+		case r.URL.Path == "/api/repos/acme/flows/commits/main":
+			assert.Equal(t, "application/vnd.github.sha", r.Header.Get("Accept"))
+			w.Write([]byte(sha))
+		case r.URL.Path == "/raw/acme/flows/"+sha+"/flows/orders.json":
+			contentCalls++
+			w.Write([]byte(strings.Replace(testFlowJSON, "Test Flow", "Flow "+sha, 1)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewGitHubFlowProvider(&BasicRemoteFlowProvider{Auth: BearerToken("ghp_secret")})
+	provider.APIURL = server.URL + "/api"
+	provider.RawURL = server.URL + "/raw"
+	provider.MaxCachedFlows = 1
+
+	rep, err := provider.GetFlow("github://acme/flows/flows/orders.json@main")
+	assert.Nil(t, err)
+	assert.Equal(t, "Flow 1111111", rep.Name)
+	assert.Equal(t, "Bearer ghp_secret", authorization)
+
+	// the flow is cached by commit
+	_, err = provider.GetFlow("github://acme/flows/flows/orders.json@main")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, contentCalls)
+
+	sha = "2222222"
+	rep, err = provider.GetFlow("github://acme/flows/flows/orders.json@main")
+	assert.Nil(t, err)
+	assert.Equal(t, "Flow 2222222", rep.Name)
+	assert.Equal(t, 2, contentCalls)
+
+	// the flow at the first commit was evicted
+	sha = "1111111"
+	_, err = provider.GetFlow("github://acme/flows/flows/orders.json@main")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, contentCalls)
+
+	_, err = provider.GetFlow("github://acme/flows/flows/orders.json@missing")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error resolving ref 'missing'")
+}
+
+func TestParseGitHubURI(t *testing.T) {
+
+	repo, path, ref, err := parseGitHubURI("github://acme/flows/a/b/orders.json@v1.2")
+	assert.Nil(t, err)
+	assert.Equal(t, "acme/flows", repo)
+	assert.Equal(t, "a/b/orders.json", path)
+	assert.Equal(t, "v1.2", ref)
+
+	_, _, ref, err = parseGitHubURI("github://acme/flows/orders.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "HEAD", ref)
+
+	_, _, _, err = parseGitHubURI("github://acme/flows")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,164 @@
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+const uriSchemeGraphQL = "gql://"
+
+// HTTPDoer sends HTTP requests, it is satisfied by *http.Client
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// GraphQLFlowProvider is a definition.Provider that gets flows with 'gql://<flowId>'
+// uris by querying a GraphQL flow registry
+type GraphQLFlowProvider struct {
+	// Endpoint is the url of the GraphQL endpoint
+	Endpoint string
+
+	// Query is the GraphQL query document, the flow id is passed as the $id variable
+	// (ex. query($id: ID!) { flow(id: $id) { definition } })
+	Query string
+
+	// DataPath is the dot separated path of the flow in the data of the response
+	// (ex. flow.definition), the flow can either be a JSON object or a JSON string
+	DataPath string
+
+	// Transport is used to send the queries, http.DefaultClient is used if not set
+	Transport HTTPDoer
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// GetFlow implements definition.Provider.GetFlow
+func (p *GraphQLFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	if !strings.HasPrefix(flowURI, uriSchemeGraphQL) {
+		return nil, fmt.Errorf("unsupported flow uri '%s'", flowURI)
+	}
+
+	flowID := flowURI[len(uriSchemeGraphQL):]
+
+	flowBytes, err := p.query(flowID)
+	if err != nil {
+		queryErr := fmt.Errorf("error querying flow with uri '%s', %s", flowURI, err.Error())
+		logger.Errorf(queryErr.Error())
+		return nil, queryErr
+	}
+
+	var flow *definition.DefinitionRep
+	err = jsonCodec.Unmarshal(flowBytes, &flow)
+	if err != nil {
+		logger.Errorf(err.Error())
+		return nil, fmt.Errorf("error marshalling flow with uri '%s', %s", flowURI, err.Error())
+	}
+
+	return flow, nil
+}
+
+// query queries the flow with the specified id, returning the flow found at the data path
+func (p *GraphQLFlowProvider) query(flowID string) ([]byte, error) {
+
+	reqBody, err := json.Marshal(&graphQLRequest{Query: p.Query, Variables: map[string]interface{}{"id": flowID}})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.Endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	transport := p.Transport
+	if transport == nil {
+		transport = http.DefaultClient
+	}
+
+	resp, err := transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var gqlResp graphQLResponse
+	if err := json.Unmarshal(body, &gqlResp); err != nil {
+		return nil, err
+	}
+
+	if len(gqlResp.Errors) > 0 {
+		msgs := make([]string, 0, len(gqlResp.Errors))
+		for _, gqlErr := range gqlResp.Errors {
+			msgs = append(msgs, gqlErr.Message)
+		}
+		return nil, errors.New(strings.Join(msgs, "; "))
+	}
+
+	return extractDataPath(gqlResp.Data, p.DataPath)
+}
+
+// extractDataPath extracts the value at the dot separated path of the data, a JSON
+// string value is returned unquoted
+func extractDataPath(data json.RawMessage, path string) ([]byte, error) {
+
+	value := data
+
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(value, &obj); err != nil {
+				return nil, fmt.Errorf("data path '%s' not found", path)
+			}
+
+			var exists bool
+			value, exists = obj[key]
+			if !exists {
+				return nil, fmt.Errorf("data path '%s' not found", path)
+			}
+		}
+	}
+
+	if len(value) == 0 || string(value) == "null" {
+		return nil, errors.New("flow not found")
+	}
+
+	if value[0] == '"' {
+		var flowJSON string
+		if err := json.Unmarshal(value, &flowJSON); err != nil {
+			return nil, err
+		}
+		return []byte(flowJSON), nil
+	}
+
+	return value, nil
+}
@@ -0,0 +1,76 @@
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockGraphQLTransport is an HTTPDoer answering GraphQL queries with a canned response
+type mockGraphQLTransport struct {
+	request  *graphQLRequest
+	response string
+}
+
+func (m *mockGraphQLTransport) Do(req *http.Request) (*http.Response, error) {
+
+	m.request = &graphQLRequest{}
+	if err := json.NewDecoder(req.Body).Decode(m.request); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString(m.response)),
+	}, nil
+}
+
+func TestGraphQLFlowProvider(t *testing.T) {
+
+	transport := &mockGraphQLTransport{response: `{"data": {"flow": {"definition": ` + testFlowJSON + `}}}`}
+
+	provider := &GraphQLFlowProvider{
+		Endpoint:  "http://registry/graphql",
+		Query:     "query($id: ID!) { flow(id: $id) { definition } }",
+		DataPath:  "flow.definition",
+		Transport: transport,
+	}
+
+	rep, err := provider.GetFlow("gql://orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, provider.Query, transport.request.Query)
+	assert.Equal(t, "orders", transport.request.Variables["id"])
+}
+
+func TestGraphQLFlowProviderStringFlow(t *testing.T) {
+
+	flowString, _ := json.Marshal(testFlowJSON)
+	transport := &mockGraphQLTransport{response: `{"data": {"flow": ` + string(flowString) + `}}`}
+
+	provider := &GraphQLFlowProvider{Endpoint: "http://registry/graphql", DataPath: "flow", Transport: transport}
+
+	rep, err := provider.GetFlow("gql://orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+}
+
+func TestGraphQLFlowProviderErrors(t *testing.T) {
+
+	transport := &mockGraphQLTransport{response: `{"data": null, "errors": [{"message": "flow 'orders' not found"}]}`}
+	provider := &GraphQLFlowProvider{Endpoint: "http://registry/graphql", DataPath: "flow", Transport: transport}
+
+	_, err := provider.GetFlow("gql://orders")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "flow 'orders' not found")
+
+	transport.response = `{"data": {"other": {}}}`
+	_, err = provider.GetFlow("gql://orders")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "data path 'flow' not found")
+}
@@ -0,0 +1,103 @@
+package support
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// HostTransportPolicy returns the transport used to fetch the flows of the host (host or
+// host:port of the flow uri), the transport is only created once per host.  Nil is returned
+// for the hosts sharing the transport of the provider's client, IsolatedTransport for the
+// hosts using a copy of it with their own connection pool.
+type HostTransportPolicy func(host string) http.RoundTripper
+
+// IsolatedTransport is returned by a HostTransportPolicy to fetch the flows of a host with
+// a copy of the transport of the provider's client, so the host has its own connection pool
+// but the same proxy and TLS configuration as the other hosts
+var IsolatedTransport http.RoundTripper = isolatedTransport{}
+
+// isolatedTransport is replaced by a copy of the provider's transport, it is never used
+type isolatedTransport struct{}
+
+func (isolatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("isolated transport used outside of a flow provider")
+}
+
+// IsolateAllHosts is a HostTransportPolicy giving each host its own connection pool
+func IsolateAllHosts(host string) http.RoundTripper {
+	return IsolatedTransport
+}
+
+// hostClient returns the client used to fetch the flows of the host, the client has
+// the transport returned by the HostTransport policy if there is one for the host.  The
+// client checks the hosts of the redirects if the hosts are restricted.
+func (p *BasicRemoteFlowProvider) hostClient(host string) *http.Client {
+
+	base := p.checkRedirects(p.httpClient())
+	if p.HostTransport == nil {
+		return base
+	}
+
+	host = strings.ToLower(host)
+
+	p.hostMu.Lock()
+	defer p.hostMu.Unlock()
+
+	if client, exists := p.hostClients[host]; exists {
+		return client
+	}
+
+	client := base
+	if transport := p.HostTransport(host); transport != nil {
+		if transport == IsolatedTransport {
+			transport = isolate(base.Transport)
+		}
+		isolated := *base
+		isolated.Transport = transport
+		client = &isolated
+	}
+
+	if p.hostClients == nil {
+		p.hostClients = make(map[string]*http.Client)
+	}
+	p.hostClients[host] = client
+
+	return client
+}
+
+// isolate returns a copy of the transport without its connections, a transport which
+// isn't a *http.Transport can't be copied so it is shared
+func isolate(rt http.RoundTripper) http.RoundTripper {
+
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		logger.Warnf("Unable to isolate the connection pool of a %T transport, sharing it", rt)
+		return rt
+	}
+
+	return &http.Transport{
+		Proxy:                  t.Proxy,
+		DialContext:            t.DialContext,
+		Dial:                   t.Dial,
+		DialTLS:                t.DialTLS,
+		TLSClientConfig:        t.TLSClientConfig,
+		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
+		DisableKeepAlives:      t.DisableKeepAlives,
+		DisableCompression:     t.DisableCompression,
+		MaxIdleConns:           t.MaxIdleConns,
+		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
+		IdleConnTimeout:        t.IdleConnTimeout,
+		ResponseHeaderTimeout:  t.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  t.ExpectContinueTimeout,
+		TLSNextProto:           t.TLSNextProto,
+		ProxyConnectHeader:     t.ProxyConnectHeader,
+		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
+	}
+}
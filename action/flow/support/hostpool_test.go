@@ -0,0 +1,179 @@
+package support
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// singleConnTransport allows a single request in flight at a time, like a
+// connection pool limited to one connection
+type singleConnTransport struct {
+	sem chan struct{}
+}
+
+func newSingleConnTransport() *singleConnTransport {
+	return &singleConnTransport{sem: make(chan struct{}, 1)}
+}
+
+func (t *singleConnTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.sem <- struct{}{}
+	defer func() { <-t.sem }()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHostTransportSlowHost(t *testing.T) {
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer fast.Close()
+
+	provider := NewBasicRemoteFlowProvider(&http.Client{Transport: newSingleConnTransport()}, nil)
+	provider.HostTransport = func(host string) http.RoundTripper {
+		return newSingleConnTransport()
+	}
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := provider.GetFlow(slow.URL + "/flow")
+		slowDone <- err
+	}()
+	<-entered
+
+	fastDone := make(chan error, 1)
+	go func() {
+		_, err := provider.GetFlow(fast.URL + "/flow")
+		fastDone <- err
+	}()
+
+	select {
+	case err := <-fastDone:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetch from the fast host was blocked by the slow host")
+	}
+
+	close(release)
+	assert.Nil(t, <-slowDone)
+
+	// the client is reused for the host
+	assert.True(t, provider.hostClient(fast.Listener.Addr().String()) == provider.hostClient(fast.Listener.Addr().String()))
+}
+
+func TestHostTransportSharedByDefault(t *testing.T) {
+
+	client := &http.Client{Timeout: time.Second}
+	provider := NewBasicRemoteFlowProvider(client, nil)
+	assert.True(t, provider.hostClient("flows.example.com") == client)
+
+	provider.HostTransport = func(host string) http.RoundTripper {
+		if host == "tenant.example.com" {
+			return IsolateAllHosts(host)
+		}
+		return nil
+	}
+
+	assert.True(t, provider.hostClient("flows.example.com") == client)
+
+	isolated := provider.hostClient("Tenant.example.com")
+	assert.True(t, isolated != client)
+	assert.Equal(t, time.Second, isolated.Timeout)
+}
+
+func TestIsolateAllHostsKeepsTransportConfig(t *testing.T) {
+
+	server := newTestFlowServer()
+	defer server.Close()
+
+	var connects int32
+	proxy := newTestSOCKS5Proxy(t, "", "", &connects)
+	defer proxy.Close()
+
+	tlsConfig := &tls.Config{ServerName: "flows.example.com"}
+	provider := &BasicRemoteFlowProvider{
+		Proxy:         &SOCKS5Proxy{Address: proxy.Addr().String()},
+		TLSConfig:     tlsConfig,
+		HostTransport: IsolateAllHosts,
+	}
+
+	// the flows of the isolated host are still fetched through the proxy
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connects))
+
+	isolated := provider.hostClient(server.Listener.Addr().String())
+	transport, ok := isolated.Transport.(*http.Transport)
+	assert.True(t, ok)
+	assert.True(t, transport != provider.httpClient().Transport)
+	assert.True(t, transport.TLSClientConfig == tlsConfig)
+	assert.Equal(t, DefaultFetchTimeout, isolated.Timeout)
+}
+
+func TestGetFlowSlowHostDoesntBlockManager(t *testing.T) {
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer slow.Close()
+
+	fast := newTestFlowServer()
+	defer fast.Close()
+
+	provider := NewBasicRemoteFlowProvider(nil, nil)
+	provider.HostTransport = IsolateAllHosts
+	fm := NewFlowManager(provider)
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := fm.GetFlow(slow.URL + "/flow")
+		slowDone <- err
+	}()
+	<-entered
+
+	// the manager isn't locked while the slow host is fetched
+	fastDone := make(chan error, 1)
+	go func() {
+		_, err := fm.GetFlow(fast.URL + "/flow")
+		fastDone <- err
+	}()
+
+	select {
+	case err := <-fastDone:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("get of the flow of the fast host was blocked by the slow host")
+	}
+
+	// a concurrent get of the slow flow shares its fetch
+	sharedDone := make(chan error, 1)
+	go func() {
+		_, err := fm.GetFlow(slow.URL + "/flow")
+		sharedDone <- err
+	}()
+
+	close(release)
+	assert.Nil(t, <-slowDone)
+	assert.Nil(t, <-sharedDone)
+}
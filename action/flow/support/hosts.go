@@ -0,0 +1,94 @@
+package support
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxRedirects is the number of redirects followed by the clients checking the redirects,
+// the limit of the default policy of http.Client
+const maxRedirects = 10
+
+// checkHost checks that the host of the flow uri is allowed by the provider
+func (p *BasicRemoteFlowProvider) checkHost(flowURI string) error {
+
+	if len(p.AllowedHosts) == 0 && len(p.DeniedHosts) == 0 {
+		return nil
+	}
+
+	u, err := url.Parse(flowURI)
+	if err != nil {
+		return err
+	}
+
+	return p.checkURLHost(u)
+}
+
+// checkURLHost checks that the host of the url is allowed by the provider
+func (p *BasicRemoteFlowProvider) checkURLHost(u *url.URL) error {
+
+	host := strings.ToLower(u.Hostname())
+
+	if matchHost(host, p.DeniedHosts) {
+		return fmt.Errorf("host '%s' is denied", host)
+	}
+
+	if len(p.AllowedHosts) > 0 && !matchHost(host, p.AllowedHosts) {
+		return fmt.Errorf("host '%s' is not allowed", host)
+	}
+
+	return nil
+}
+
+// checkRedirects returns a copy of the client checking the hosts the requests are
+// redirected to, so an allowed host can't redirect the fetch of a flow to a host which
+// isn't allowed.  The client is returned as is if the hosts aren't restricted.
+func (p *BasicRemoteFlowProvider) checkRedirects(client *http.Client) *http.Client {
+
+	if len(p.AllowedHosts) == 0 && len(p.DeniedHosts) == 0 {
+		return client
+	}
+
+	checkRedirect := client.CheckRedirect
+	checked := *client
+	checked.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+
+		if err := p.checkURLHost(req.URL); err != nil {
+			return fmt.Errorf("redirect refused, %s", err.Error())
+		}
+
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+
+		if len(via) >= maxRedirects {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		return nil
+	}
+
+	return &checked
+}
+
+// matchHost determines if the host matches any of the patterns, a pattern
+// prefixed with '*.' matches all the subdomains of the domain
+func matchHost(host string, patterns []string) bool {
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
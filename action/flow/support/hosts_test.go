@@ -0,0 +1,82 @@
+package support
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFlowAllowedHost(t *testing.T) {
+
+	server := newTestFlowServer()
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{AllowedHosts: []string{"127.0.0.1", "*.flows.example.com"}}
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+}
+
+func TestGetFlowDeniedHost(t *testing.T) {
+
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{DeniedHosts: []string{"127.0.0.1"}}
+	_, err := provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "host '127.0.0.1' is denied")
+
+	provider = &BasicRemoteFlowProvider{AllowedHosts: []string{"*.flows.example.com"}}
+	_, err = provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "host '127.0.0.1' is not allowed")
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&hits))
+}
+
+func TestGetFlowRedirectToDeniedHost(t *testing.T) {
+
+	var hits int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer target.Close()
+
+	// the allowed host redirects to the same server through a host which isn't allowed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{AllowedHosts: []string{"127.0.0.1"}}
+	_, err := provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "redirect refused, host 'localhost' is not allowed")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&hits))
+
+	// redirects to allowed hosts are followed
+	provider = &BasicRemoteFlowProvider{AllowedHosts: []string{"127.0.0.1", "localhost"}}
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+}
+
+func TestMatchHost(t *testing.T) {
+
+	patterns := []string{"flows.example.com", "*.cdn.example.com"}
+
+	assert.True(t, matchHost("flows.example.com", patterns))
+	assert.True(t, matchHost("edge1.cdn.example.com", patterns))
+	assert.False(t, matchHost("cdn.example.com", patterns))
+	assert.False(t, matchHost("evilflows.example.com", patterns))
+}
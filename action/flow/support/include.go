@@ -0,0 +1,118 @@
+package support
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+// IncludeResolver is a pipeline Stage that merges the flow fragments listed in the
+// $include of a flow into the flow.  The fragments are fetched concurrently, but are
+// always merged in the order they are listed.
+type IncludeResolver struct {
+	// Provider is used to fetch the fragments
+	Provider definition.Provider
+
+	// Parallelism is the maximum number of fragments fetched concurrently, 0 or 1
+	// fetches the fragments serially
+	Parallelism int
+}
+
+// Process implements Stage.Process
+func (r *IncludeResolver) Process(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+	return rep, r.resolve(rep, nil)
+}
+
+// resolve merges the fragments included by the flow, chain contains the uris of
+// the fragments being resolved and is used to detect include cycles
+func (r *IncludeResolver) resolve(rep *definition.DefinitionRep, chain []string) error {
+
+	if len(rep.Includes) == 0 {
+		return nil
+	}
+
+	for _, uri := range rep.Includes {
+		for _, included := range chain {
+			if uri == included {
+				return fmt.Errorf("include cycle detected for fragment '%s'", uri)
+			}
+		}
+	}
+
+	fragments, err := r.fetch(rep.Includes)
+	if err != nil {
+		return err
+	}
+
+	for i, fragment := range fragments {
+		if err := r.resolve(fragment, append(chain, rep.Includes[i])); err != nil {
+			return err
+		}
+		mergeFragment(rep, fragment)
+	}
+
+	rep.Includes = nil
+
+	return nil
+}
+
+// fetch fetches the fragments with bounded parallelism, the fragments are returned
+// in the order of the uris
+func (r *IncludeResolver) fetch(uris []string) ([]*definition.DefinitionRep, error) {
+
+	parallelism := r.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	fragments := make([]*definition.DefinitionRep, len(uris))
+	errs := make([]error, len(uris))
+
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+
+	for i, uri := range uris {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, uri string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			fragment, err := r.Provider.GetFlow(uri)
+			if err == nil && fragment == nil {
+				err = fmt.Errorf("fragment not found for uri '%s'", uri)
+			}
+			fragments[i], errs[i] = fragment, err
+		}(i, uri)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("error including fragment '%s', %s", uris[i], err.Error())
+		}
+	}
+
+	return fragments, nil
+}
+
+// mergeFragment appends the attributes, tasks, links and error handler of the fragment to the flow
+func mergeFragment(rep *definition.DefinitionRep, fragment *definition.DefinitionRep) {
+
+	rep.Attributes = append(rep.Attributes, fragment.Attributes...)
+	rep.Tasks = append(rep.Tasks, fragment.Tasks...)
+	rep.Links = append(rep.Links, fragment.Links...)
+
+	if fragment.ErrorHandler != nil {
+		if rep.ErrorHandler == nil {
+			rep.ErrorHandler = &definition.ErrorHandlerRep{}
+		}
+		rep.ErrorHandler.Tasks = append(rep.ErrorHandler.Tasks, fragment.ErrorHandler.Tasks...)
+		rep.ErrorHandler.Links = append(rep.ErrorHandler.Links, fragment.ErrorHandler.Links...)
+	}
+}
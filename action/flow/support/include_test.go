@@ -0,0 +1,96 @@
+package support
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowFlowProvider delays each fetch by a random duration, tracking the maximum
+// number of concurrent fetches
+type slowFlowProvider struct {
+	*testFlowProvider
+	active    int32
+	maxActive int32
+}
+
+func (p *slowFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	active := atomic.AddInt32(&p.active, 1)
+	defer atomic.AddInt32(&p.active, -1)
+
+	for {
+		max := atomic.LoadInt32(&p.maxActive)
+		if active <= max || atomic.CompareAndSwapInt32(&p.maxActive, max, active) {
+			break
+		}
+	}
+
+	time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond)
+
+	return p.testFlowProvider.GetFlow(flowURI)
+}
+
+func fragmentJSON(i int) string {
+	return fmt.Sprintf(`{
+  "tasks": [ { "id": "log_%d", "activity": { "ref": "test-log" } } ],
+  "links": [ { "from": "log_%d", "to": "log_%d" } ]
+}`, i, i-1, i)
+}
+
+func TestIncludeResolver(t *testing.T) {
+
+	flows := make(map[string]string)
+	var includes []string
+	for i := 3; i <= 8; i++ {
+		uri := fmt.Sprintf("http://fragments/%d", i)
+		flows[uri] = fragmentJSON(i)
+		includes = append(includes, uri)
+	}
+
+	provider := &slowFlowProvider{testFlowProvider: newTestFlowProvider(flows)}
+	resolver := &IncludeResolver{Provider: provider, Parallelism: 3}
+
+	for n := 0; n < 5; n++ {
+		rep := unmarshalRep(t, testFlowJSON)
+		rep.Includes = includes
+
+		rep, err := resolver.Process(rep)
+		assert.Nil(t, err)
+		assert.Len(t, rep.Includes, 0)
+
+		var taskIDs []string
+		for _, task := range rep.Tasks {
+			taskIDs = append(taskIDs, task.ID)
+		}
+		assert.Equal(t, []string{"log_1", "log_2", "log_3", "log_4", "log_5", "log_6", "log_7", "log_8"}, taskIDs)
+		assert.Len(t, rep.Links, 7)
+		assert.Len(t, ValidateRep(rep), 0)
+	}
+
+	assert.True(t, atomic.LoadInt32(&provider.maxActive) <= 3)
+}
+
+func TestIncludeResolverErrors(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://fragments/cycle": `{ "$include": ["http://fragments/cycle"] }`,
+	})
+	resolver := &IncludeResolver{Provider: provider, Parallelism: 2}
+
+	rep := unmarshalRep(t, testFlowJSON)
+	rep.Includes = []string{"http://fragments/missing"}
+	_, err := resolver.Process(rep)
+	assert.NotNil(t, err)
+
+	rep = unmarshalRep(t, testFlowJSON)
+	rep.Includes = []string{"http://fragments/cycle"}
+	_, err = resolver.Process(rep)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "include cycle")
+}
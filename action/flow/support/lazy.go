@@ -0,0 +1,127 @@
+package support
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// lazyResource is an embedded flow loaded lazily, it is materialized the first time it
+// is requested
+type lazyResource struct {
+	config *resource.Config
+	loaded time.Time
+
+	once sync.Once
+	flow *definition.Definition
+	rep  *definition.DefinitionRep
+	err  error
+}
+
+// loadsLazily determines if the flow resource is loaded lazily, flows to pre-warm are
+// materialized when they are loaded
+func (fm *FlowManager) loadsLazily(config *resource.Config) bool {
+	return fm.lazy && config != nil && !fm.prewarm[config.ID]
+}
+
+// storeLazyResource stores the flow resource without decoding or materializing it
+func (fm *FlowManager) storeLazyResource(config *resource.Config) {
+
+	if _, deleted := fm.Tombstone(uriSchemeRes + config.ID); deleted {
+		logger.Infof("Loading flow resource '%s' which was previously deleted", config.ID)
+		fm.clearTombstone(uriSchemeRes + config.ID)
+	}
+
+	fm.resMu.Lock()
+	defer fm.resMu.Unlock()
+
+	delete(fm.resFlows, config.ID)
+	delete(fm.resReps, config.ID)
+	delete(fm.resLoaded, config.ID)
+	fm.lazyFlows[config.ID] = &lazyResource{config: config, loaded: fm.now()}
+}
+
+// resolveResFlow gets the embedded flow with the specified id, materializing it if it was
+// loaded lazily.  A nil flow is returned if the flow isn't loaded.
+func (fm *FlowManager) resolveResFlow(id string) (*definition.Definition, error) {
+
+	fm.resMu.RLock()
+	flow, lazy := fm.resFlows[id], fm.lazyFlows[id]
+	fm.resMu.RUnlock()
+
+	if flow != nil || lazy == nil {
+		return flow, nil
+	}
+
+	lazy.once.Do(func() {
+		lazy.rep, lazy.err = fm.decodeResource(lazy.config)
+		if lazy.err == nil {
+			lazy.flow, lazy.err = fm.safeMaterializeFlow(lazy.rep)
+		}
+	})
+
+	if lazy.err != nil {
+		return nil, fmt.Errorf("error materializing flow resource '%s', %s", id, lazy.err.Error())
+	}
+
+	fm.resMu.Lock()
+	if fm.lazyFlows[id] == lazy {
+		// the flow wasn't reloaded or removed in the meantime
+		delete(fm.lazyFlows, id)
+		fm.resFlows[id], fm.resReps[id] = lazy.flow, lazy.rep
+		fm.resLoaded[id] = lazy.loaded
+		fm.storeVersion(uriSchemeRes+id, lazy.flow)
+	}
+	fm.resMu.Unlock()
+
+	return lazy.flow, nil
+}
+
+// lazyIDs returns the sorted ids of the lazily loaded flows which aren't materialized yet
+func (fm *FlowManager) lazyIDs() []string {
+
+	fm.resMu.RLock()
+	defer fm.resMu.RUnlock()
+
+	ids := make([]string, 0, len(fm.lazyFlows))
+	for id := range fm.lazyFlows {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// Prewarm materializes the specified lazily loaded flows (ex. in the background after
+// startup), the flows which were already materialized are skipped.  The errors of the
+// flows that failed to materialize are returned keyed by resource id.
+func (fm *FlowManager) Prewarm(ids []string) map[string]error {
+
+	errs := make(map[string]error)
+
+	for _, id := range ids {
+		flow, err := fm.resolveResFlow(id)
+		if err == nil && flow == nil {
+			err = fmt.Errorf("flow not found for uri '%s'", uriSchemeRes+id)
+		}
+		if err != nil {
+			errs[id] = err
+		}
+	}
+
+	return errs
+}
+
+// materializeAll materializes all the lazily loaded flows, used by the operations on
+// all the embedded flows
+func (fm *FlowManager) materializeAll() {
+
+	for id, err := range fm.Prewarm(fm.lazyIDs()) {
+		logger.Warnf("Flow resource '%s' failed to materialize: %s", id, err.Error())
+	}
+}
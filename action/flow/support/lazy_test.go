@@ -0,0 +1,105 @@
+package support
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingPipeline counts the flows materialized by the manager
+func countingPipeline(mu *sync.Mutex, counts map[string]int) *Pipeline {
+	return NewPipeline(StageFunc(func(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[rep.Name]++
+		return rep, nil
+	}))
+}
+
+func TestLazyResources(t *testing.T) {
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{
+		LazyResources:    true,
+		PrewarmResources: []string{"flow:warm"},
+		Pipeline:         countingPipeline(&mu, counts),
+	})
+
+	err := fm.LoadResource(&resource.Config{ID: "flow:orders", Data: []byte(strings.Replace(testFlowJSON, "Test Flow", "Orders", 1))})
+	assert.Nil(t, err)
+	err = fm.LoadResource(&resource.Config{ID: "flow:warm", Data: []byte(strings.Replace(testFlowJSON, "Test Flow", "Warm", 1))})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 0, counts["Orders"])
+	assert.Equal(t, 1, counts["Warm"])
+
+	info, exists := fm.GetFlowInfo("res://flow:orders")
+	assert.True(t, exists)
+	assert.True(t, info.Pending)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			flow, err := fm.GetFlow("res://flow:orders")
+			assert.Nil(t, err)
+			assert.Equal(t, "Orders", flow.Name())
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, counts["Orders"])
+
+	info, _ = fm.GetFlowInfo("res://flow:orders")
+	assert.False(t, info.Pending)
+	assert.Equal(t, "Orders", info.Name)
+}
+
+func TestLazyResourceInvalid(t *testing.T) {
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{LazyResources: true})
+
+	// the invalid flow only fails once it is requested
+	err := fm.LoadResource(&resource.Config{ID: "flow:invalid", Data: []byte(`{"name": "Invalid", "tasks": [`)})
+	assert.Nil(t, err)
+
+	_, err = fm.GetFlow("res://flow:invalid")
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "error materializing flow resource 'flow:invalid'"))
+
+	assert.Nil(t, fm.getResFlow("flow:invalid"))
+
+	errs := fm.Prewarm([]string{"flow:invalid", "flow:unknown"})
+	assert.Len(t, errs, 2)
+}
+
+func TestPrewarm(t *testing.T) {
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{LazyResources: true, Pipeline: countingPipeline(&mu, counts)})
+
+	errs := fm.LoadResources([]*resource.Config{
+		{ID: "flow:orders", Data: []byte(strings.Replace(testFlowJSON, "Test Flow", "Orders", 1))},
+		{ID: "flow:payments", Data: []byte(strings.Replace(testFlowJSON, "Test Flow", "Payments", 1))},
+	})
+	assert.Len(t, errs, 0)
+	assert.Len(t, counts, 0)
+
+	assert.Len(t, fm.Prewarm([]string{"flow:orders"}), 0)
+	assert.Equal(t, 1, counts["Orders"])
+	assert.Equal(t, []string{"flow:payments"}, fm.lazyIDs())
+
+	// operations on all the embedded flows materialize them
+	assert.Equal(t, []string{"test-log"}, fm.AllActivityRefs())
+	assert.Equal(t, 1, counts["Payments"])
+	assert.Equal(t, []string{"flow:orders", "flow:payments"}, fm.UnusedResources())
+}
@@ -1,15 +1,22 @@
 package support
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/linker"
 
@@ -33,22 +40,216 @@ func GetFlowManager() *FlowManager {
 }
 
 type FlowManager struct {
-	resFlows map[string]*definition.Definition
+	resMu     sync.RWMutex // protects resFlows, resReps, resLoaded and lazyFlows
+	resFlows  map[string]*definition.Definition
+	resReps   map[string]*definition.DefinitionRep
+	resLoaded map[string]time.Time
+	lazyFlows map[string]*lazyResource
 
-	//todo switch to cache
-	rfMu         sync.Mutex // protects the flow maps
-	remoteFlows  map[string]*definition.Definition
+	lazy    bool
+	prewarm map[string]bool
+
+	verifier *FlowVerifier
+
+	accessMu    sync.Mutex // protects resAccessed
+	resAccessed map[string]bool
+
+	rfMu         sync.Mutex // protects the flow maps and cacheTick
+	remoteFlows  map[string]*cacheEntry
+	fetches      map[string]*flowFetch
+	pinned       map[string]bool
+	cacheTick    uint64
 	flowProvider definition.Provider
+
+	cacheConfig CacheConfig
+	metrics     Metrics
+	pipeline    *Pipeline
+	now         func() time.Time
+
+	validateSettings    bool
+	skipValidation      bool
+	strictSchemaVersion bool
+	strictGzip          bool
+
+	// materializeSem limits the concurrent materializations of the batch loads
+	materializeSem chan struct{}
+
+	// fetchSem limits the concurrent fetches from the provider
+	fetchSem chan struct{}
+
+	overrideDir string
+
+	versionsMu sync.Mutex // protects the versions
+	versions   map[string]map[string]*definition.Definition
+
+	prefetch        bool
+	prefetchMu      sync.Mutex // protects the prefetch queue and state
+	prefetchQueue   []string
+	prefetchPending map[string]bool
+	prefetched      map[string]*definition.Definition
+	prefetching     bool
+
+	statsMu sync.Mutex // protects the stats
+	stats   map[string]*flowStats
+
+	aliasMu sync.Mutex // protects the aliases and rand
+	aliases map[string]*weightedAlias
+	rand    *rand.Rand
+
+	tombMu             sync.Mutex // protects the tombstones
+	tombstones         map[string]*tombstone
+	tombstoneRetention time.Duration
+
+	defaultScheme string
+
+	namesMu       sync.Mutex // protects the names and resolvedNames
+	names         map[string]string
+	resolvedNames map[string]string
+	nameResolver  NameResolver
+}
+
+// ManagerOptions are the options used to configure a FlowManager
+type ManagerOptions struct {
+	// Cache is the configuration of the remote flow cache
+	Cache CacheConfig
+
+	// Metrics is used to record the metrics of the manager, metrics aren't recorded if not set
+	Metrics Metrics
+
+	// Pipeline is the post-processing pipeline run against each flow before it is materialized
+	Pipeline *Pipeline
+
+	// RandSource is the source of randomness used to select the targets of
+	// weighted aliases, a time seeded source is used if not set
+	RandSource rand.Source
+
+	// TombstoneRetention is the duration a deleted flow can be restored for, 0 keeps
+	// deleted flows restorable until they are loaded again
+	TombstoneRetention time.Duration
+
+	// DefaultScheme is the scheme applied to flow uris without a scheme (ex. res://),
+	// uris without a scheme are fetched using the provider if not set
+	DefaultScheme string
+
+	// NameResolver resolves the flow names which aren't registered using
+	// RegisterFlowName, unregistered names can't be resolved if not set
+	NameResolver NameResolver
+
+	// ValidateSettings indicates if the settings and inputs of the activities are
+	// validated against the activity metadata when a flow is materialized
+	ValidateSettings bool
+
+	// SkipValidation disables the structural validation of the flows when they are
+	// materialized (ex. to intentionally load partial flows)
+	SkipValidation bool
+
+	// MaxMaterializations is the maximum number of flows materialized concurrently by
+	// the batch loads (ex. LoadResources, PreloadFlows), 0 doesn't limit them
+	MaxMaterializations int
+
+	// Clock is the source of the current time of the time based features (ex. cache
+	// TTL, stale grace, tombstone retention), RealClock is used if not set
+	Clock Clock
+
+	// StrictSchemaVersion indicates if flows declaring a schema version newer than
+	// CurrentSchemaVersion fail to load, such flows are only logged as a warning if not set
+	StrictSchemaVersion bool
+
+	// StrictGzip indicates if data trailing the gzip stream of an embedded, overridden or
+	// raw flow (ex. padding) is rejected, it is ignored if not set.  It is also used by the
+	// default provider when no provider is specified.
+	StrictGzip bool
+
+	// MaxConcurrentFetches is the maximum number of flows fetched concurrently from the
+	// provider (ex. to protect a shared flow server), 0 doesn't limit them
+	MaxConcurrentFetches int
+
+	// PrefetchSubflows indicates if the subflows of a flow are fetched in the background
+	// when the flow is first accessed, so they are cached by the time they are started
+	PrefetchSubflows bool
+
+	// LazyResources indicates if the flow resources are materialized the first time they
+	// are requested rather than when they are loaded (ex. to speed up cold starts)
+	LazyResources bool
+
+	// PrewarmResources are the ids of the flow resources materialized when they are
+	// loaded even though LazyResources is set
+	PrewarmResources []string
+
+	// Verifier verifies the signatures of the flow resources and of the flows fetched
+	// from the provider before they are materialized, signatures aren't verified if not set.
+	// It is also used by the default provider when no provider is specified.
+	Verifier *FlowVerifier
+
+	// OverrideDir is a local directory with flows used instead of the provider's flows
+	// during development, a flow is overridden by the <name>.json or <name> file where
+	// name is the last segment of its uri path (ex. orderFlow for https://flows/orderFlow).
+	// The overrides are verified by the Verifier if set, so a Strict verifier refuses
+	// unsigned overrides.
+	OverrideDir string
 }
 
 func NewFlowManager(flowProvider definition.Provider) *FlowManager {
+	return NewFlowManagerWithOptions(flowProvider, nil)
+}
+
+// NewFlowManagerWithOptions creates a FlowManager configured using the specified options
+func NewFlowManagerWithOptions(flowProvider definition.Provider, options *ManagerOptions) *FlowManager {
 	manager := &FlowManager{}
 	manager.resFlows = make(map[string]*definition.Definition)
+	manager.resReps = make(map[string]*definition.DefinitionRep)
+	manager.resLoaded = make(map[string]time.Time)
+	manager.lazyFlows = make(map[string]*lazyResource)
+	manager.now = time.Now
+	manager.metrics = noopMetrics{}
+
+	if options != nil {
+		manager.cacheConfig = options.Cache
+		manager.pipeline = options.Pipeline
+		manager.tombstoneRetention = options.TombstoneRetention
+		manager.defaultScheme = options.DefaultScheme
+		manager.nameResolver = options.NameResolver
+		manager.validateSettings = options.ValidateSettings
+		manager.skipValidation = options.SkipValidation
+		manager.prefetch = options.PrefetchSubflows
+		manager.strictSchemaVersion = options.StrictSchemaVersion
+		manager.strictGzip = options.StrictGzip
+		manager.overrideDir = options.OverrideDir
+		manager.lazy = options.LazyResources
+		manager.verifier = options.Verifier
+
+		if len(options.PrewarmResources) > 0 {
+			manager.prewarm = make(map[string]bool, len(options.PrewarmResources))
+			for _, id := range options.PrewarmResources {
+				manager.prewarm[id] = true
+			}
+		}
+
+		if options.Clock != nil {
+			manager.now = options.Clock.Now
+		}
+
+		if options.MaxConcurrentFetches > 0 {
+			manager.fetchSem = make(chan struct{}, options.MaxConcurrentFetches)
+		}
+
+		if options.MaxMaterializations > 0 {
+			manager.materializeSem = make(chan struct{}, options.MaxMaterializations)
+		}
+
+		if options.RandSource != nil {
+			manager.rand = rand.New(options.RandSource)
+		}
+
+		if options.Metrics != nil {
+			manager.metrics = options.Metrics
+		}
+	}
 
 	if flowProvider != nil {
 		manager.flowProvider = flowProvider
 	} else {
-		manager.flowProvider = &BasicRemoteFlowProvider{}
+		manager.flowProvider = &BasicRemoteFlowProvider{Verifier: manager.verifier, StrictGzip: manager.strictGzip}
 	}
 
 	//temp hack
@@ -59,12 +260,51 @@ func NewFlowManager(flowProvider definition.Provider) *FlowManager {
 
 func (fm *FlowManager) LoadResource(config *resource.Config) error {
 
+	if fm.loadsLazily(config) {
+		fm.storeLazyResource(config)
+		return nil
+	}
+
+	defRep, err := fm.decodeResource(config)
+	if err != nil {
+		return err
+	}
+
+	flow, err := fm.materializeFlow(defRep)
+	if err != nil {
+		return err
+	}
+
+	fm.storeResource(config.ID, flow, defRep)
+	return nil
+}
+
+// storeResource stores the loaded embedded flow, clearing the tombstone of a
+// previously deleted flow with the same id
+func (fm *FlowManager) storeResource(id string, flow *definition.Definition, rep *definition.DefinitionRep) {
+
+	if _, deleted := fm.Tombstone(uriSchemeRes + id); deleted {
+		logger.Infof("Loading flow resource '%s' which was previously deleted", id)
+		fm.clearTombstone(uriSchemeRes + id)
+	}
+
+	fm.setResFlow(id, flow, rep)
+}
+
+// decodeResource decodes the flow definition of the specified resource
+func (fm *FlowManager) decodeResource(config *resource.Config) (*definition.DefinitionRep, error) {
+
+	if config == nil {
+		return nil, errors.New("unable to load flow resource, resource config not provided")
+	}
+
 	var flowDefBytes []byte
 
 	if config.Compressed {
-		decodedBytes, err := decodeAndUnzip(string(config.Data))
+		encoding, encoded := resourceEncoding(config.Data)
+		decodedBytes, err := decodeAndUncompress(encoding, encoded, fm.strictGzip)
 		if err != nil {
-			return fmt.Errorf("error decoding compressed resource with id '%s', %s", config.ID, err.Error())
+			return nil, fmt.Errorf("error decoding compressed resource with id '%s', %s", config.ID, err.Error())
 		}
 
 		flowDefBytes = decodedBytes
@@ -72,180 +312,1243 @@ func (fm *FlowManager) LoadResource(config *resource.Config) error {
 		flowDefBytes = config.Data
 	}
 
+	if fm.verifier != nil {
+		var err error
+		flowDefBytes, err = fm.verifier.Open(uriSchemeRes+config.ID, flowDefBytes, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var defRep *definition.DefinitionRep
-	err := json.Unmarshal(flowDefBytes, &defRep)
+	err := jsonCodec.Unmarshal(flowDefBytes, &defRep)
 	if err != nil {
-		return fmt.Errorf("error marshalling flow resource with id '%s', %s", config.ID, err.Error())
+		return nil, fmt.Errorf("error marshalling flow resource with id '%s', %s", config.ID, err.Error())
 	}
 
-	flow, err := fm.materializeFlow(defRep)
+	return defRep, nil
+}
+
+func (fm *FlowManager) GetResource(id string) interface{} {
+	fm.markAccessed(id)
+	return fm.getResFlow(id)
+}
+
+// getResFlow gets the embedded flow with the specified id, a lazily loaded flow which
+// fails to materialize is logged and not returned
+func (fm *FlowManager) getResFlow(id string) *definition.Definition {
+	flow, err := fm.resolveResFlow(id)
 	if err != nil {
-		return err
+		logger.Error(err.Error())
 	}
+	return flow
+}
 
-	fm.resFlows[config.ID] = flow
-	return nil
+// getResRep gets the definition of the embedded flow with the specified id
+func (fm *FlowManager) getResRep(id string) *definition.DefinitionRep {
+	fm.getResFlow(id)
+	fm.resMu.RLock()
+	defer fm.resMu.RUnlock()
+	return fm.resReps[id]
 }
 
-func (fm *FlowManager) GetResource(id string) interface{} {
-	return fm.resFlows[id]
+// setResFlow sets the embedded flow with the specified id
+func (fm *FlowManager) setResFlow(id string, flow *definition.Definition, rep *definition.DefinitionRep) {
+	fm.resMu.Lock()
+	defer fm.resMu.Unlock()
+	fm.resFlows[id], fm.resReps[id] = flow, rep
+	fm.resLoaded[id] = fm.now()
+	delete(fm.lazyFlows, id)
+	fm.storeVersion(uriSchemeRes+id, flow)
 }
 
-func (fm *FlowManager) GetFlow(uri string) (*definition.Definition, error) {
+// removeResFlow removes the embedded flow with the specified id, returning the removed flow
+func (fm *FlowManager) removeResFlow(id string) (*definition.Definition, *definition.DefinitionRep) {
+	// a lazily loaded flow is materialized, so it can be restored once deleted
+	fm.getResFlow(id)
+	fm.resMu.Lock()
+	defer fm.resMu.Unlock()
+	flow, rep := fm.resFlows[id], fm.resReps[id]
+	delete(fm.resFlows, id)
+	delete(fm.resReps, id)
+	delete(fm.resLoaded, id)
+	delete(fm.lazyFlows, id)
+	fm.dropVersions(uriSchemeRes + id)
+	return flow, rep
+}
 
-	if strings.HasPrefix(uri, uriSchemeRes) {
-		return fm.resFlows[uri[6:]], nil
-	}
+func (fm *FlowManager) GetFlow(uri string) (*definition.Definition, error) {
+	return fm.GetFlowWithContext(context.Background(), uri)
+}
 
-	fm.rfMu.Lock()
-	defer fm.rfMu.Unlock()
+// GetFlowWithContext gets the flow with the specified uri, the context is passed to
+// the provider if it is a definition.ContextProvider (ex. to access request scoped
+// values such as the tenant)
+func (fm *FlowManager) GetFlowWithContext(ctx context.Context, uri string) (*definition.Definition, error) {
 
-	if fm.remoteFlows == nil {
-		fm.remoteFlows = make(map[string]*definition.Definition)
+	if fm.defaultScheme != "" && uriScheme(uri) == "" {
+		uri = fm.defaultScheme + uri
 	}
 
-	flow, exists := fm.remoteFlows[uri]
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		if base, version := splitResVersion(uri); version != "" {
+			return fm.GetFlowVersion(base, version)
+		}
 
-	if !exists {
+		id := strings.TrimPrefix(uri, uriSchemeRes)
 
-		defRep, err := fm.flowProvider.GetFlow(uri)
+		flow, err := fm.resolveResFlow(id)
 		if err != nil {
 			return nil, err
 		}
+		if flow == nil {
+			return nil, fmt.Errorf("flow not found for uri '%s'", uri)
+		}
 
-		flow, err = fm.materializeFlow(defRep)
-		if err != nil {
-			return nil, err
+		fm.markAccessed(id)
+		fm.recordAccess(uri)
+
+		if fm.prefetch {
+			fm.prefetchSubflows(uri, flow)
 		}
+		return flow, nil
+	}
+
+	if strings.HasPrefix(uri, uriSchemeFlow) {
+		return fm.getAliasFlow(ctx, uri)
+	}
+
+	if _, deleted := fm.Tombstone(uri); deleted {
+		return nil, fmt.Errorf("flow with uri '%s' has been deleted", uri)
+	}
+
+	flow, err := fm.getRemoteFlow(ctx, uri, fm.materializeFlow)
+	if err != nil {
+		return nil, err
+	}
+
+	fm.recordAccess(uri)
 
-		fm.remoteFlows[uri] = flow
+	if fm.prefetch {
+		fm.prefetchSubflows(uri, flow)
 	}
 
 	return flow, nil
 }
 
-func (fm *FlowManager) materializeFlow(flowRep *definition.DefinitionRep) (*definition.Definition, error) {
+// GetFlowMultiScheme gets the flow with the specified id trying the schemes in order,
+// the uri is the scheme followed by the id so a scheme can include a base path (ex.
+// []string{"res://", "https://flows.example.com/"}).  The first flow found is returned.
+func (fm *FlowManager) GetFlowMultiScheme(id string, schemes []string) (*definition.Definition, error) {
 
-	def, err := definition.NewDefinition(flowRep)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling flow: %s", err.Error())
+	var errs []error
+
+	for _, scheme := range schemes {
+		uri := scheme + id
+
+		flow, err := fm.GetFlow(uri)
+		if err == nil {
+			return flow, nil
+		}
+
+		errs = append(errs, err)
 	}
 
-	//todo validate flow
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("flow '%s' not found, no schemes specified", id)
+	}
 
-	//todo fix this up
-	factory := definition.GetLinkExprManagerFactory()
+	return nil, joinErrors(fmt.Sprintf("flow '%s' not found", id), errs)
+}
 
-	if factory == nil {
-		factory = linker.NewDefaultLinkerFactory()
+// getRemoteFlow gets the remote flow from the cache, fetching the flow from the
+// provider and materializing it using the specified function if it isn't cached
+func (fm *FlowManager) getRemoteFlow(ctx context.Context, uri string, materialize materializeFunc) (*definition.Definition, error) {
+
+	fm.rfMu.Lock()
+
+	now := fm.now()
+	entry, exists := fm.remoteFlows[uri]
+
+	if exists && (fm.pinned[uri] || !entry.expired(now)) {
+		fm.touchCacheEntry(entry)
+		fm.rfMu.Unlock()
+		return entry.flow, nil
 	}
 
-	def.SetLinkExprManager(factory.NewLinkExprManager())
-	//todo init activities
+	fm.rfMu.Unlock()
 
-	return def, nil
+	return fm.fetchRemoteFlow(ctx, uri, materialize)
+}
 
+// flowFetch is a fetch of a remote flow in flight, shared by the concurrent gets of the flow
+type flowFetch struct {
+	done chan struct{}
+	flow *definition.Definition
+	err  error
 }
 
-type BasicRemoteFlowProvider struct {
+// fetchRemoteFlow fetches the remote flow from the provider and updates the cache.
+// rfMu is only held to read and update the cache, so a slow fetch doesn't block the
+// gets of the other flows, and the concurrent fetches of a flow share a single fetch.
+func (fm *FlowManager) fetchRemoteFlow(ctx context.Context, uri string, materialize materializeFunc) (*definition.Definition, error) {
+
+	fm.rfMu.Lock()
+
+	if fetch, fetching := fm.fetches[uri]; fetching {
+		fm.rfMu.Unlock()
+
+		select {
+		case <-fetch.done:
+			return fetch.flow, fetch.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	fetch := &flowFetch{done: make(chan struct{})}
+	if fm.fetches == nil {
+		fm.fetches = make(map[string]*flowFetch)
+	}
+	fm.fetches[uri] = fetch
+
+	entry, exists := fm.remoteFlows[uri]
+
+	fm.rfMu.Unlock()
+
+	fetch.flow, fetch.err = fm.fetchAndCacheFlow(ctx, uri, entry, exists, materialize)
+
+	fm.rfMu.Lock()
+	delete(fm.fetches, uri)
+	fm.rfMu.Unlock()
+
+	close(fetch.done)
+
+	return fetch.flow, fetch.err
 }
 
-func (*BasicRemoteFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+// fetchAndCacheFlow fetches the remote flow from the provider and replaces the cached
+// entry if there is one, rfMu must not be held by the caller
+func (fm *FlowManager) fetchAndCacheFlow(ctx context.Context, uri string, entry *cacheEntry, exists bool, materialize materializeFunc) (*definition.Definition, error) {
 
-	var flowDefBytes []byte
+	now := fm.now()
 
-	if strings.HasPrefix(flowURI, uriSchemeFile) {
-		// File URI
-		logger.Infof("Loading Local Flow: %s\n", flowURI)
-		flowFilePath, _ := util.URLStringToFilePath(flowURI)
+	start := fm.now()
+	defRep, err := fm.getFlowRep(ctx, uri)
+	fm.recordFetch(uri, start, err)
 
-		readBytes, err := ioutil.ReadFile(flowFilePath)
-		if err != nil {
-			readErr := fmt.Errorf("error reading flow with uri '%s', %s", flowURI, err.Error())
-			logger.Errorf(readErr.Error())
-			return nil, readErr
+	var raw []byte
+	var flow *definition.Definition
+	if err == nil {
+		// the pipeline can modify the rep, so it is kept as fetched to detect its changes
+		raw, _ = json.Marshal(defRep)
+		flow, err = materialize(defRep)
+	}
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	if err != nil {
+		if exists && entry.withinGrace(now, fm.cacheConfig.StaleGrace) {
+			logger.Warnf("Unable to refresh flow with uri '%s', serving stale flow: %s", uri, err.Error())
+			fm.metrics.Inc(MetricStaleServed, map[string]string{LabelScheme: uriScheme(uri)})
+			return entry.flow, nil
 		}
-		if readBytes[0] == 0x1f && readBytes[2] == 0x8b {
-			flowDefBytes, err = unzip(readBytes)
-			if err != nil {
-				decompressErr := fmt.Errorf("error uncompressing flow with uri '%s', %s", flowURI, err.Error())
-				logger.Errorf(decompressErr.Error())
-				return nil, decompressErr
-			}
-		} else {
-			flowDefBytes = readBytes
 
+		// a pinned flow keeps being served until it is refreshed successfully
+		if exists && fm.pinned[uri] {
+			logger.Warnf("Unable to refresh pinned flow with uri '%s', keeping the cached flow: %s", uri, err.Error())
+			return nil, err
 		}
 
+		delete(fm.remoteFlows, uri)
+		fm.dropVersions(uri)
+		return nil, err
+	}
+
+	if exists && isOlderFlow(flow, entry.flow) {
+		logger.Warnf("Fetched version '%s' of flow with uri '%s' is older than the cached version '%s', keeping the cached flow", flow.Version(), uri, entry.flow.Version())
+		return entry.flow, nil
+	}
+
+	if fm.remoteFlows == nil {
+		fm.remoteFlows = make(map[string]*cacheEntry)
+	}
+
+	if cacheable, ttl := fm.cachePolicy(uri, defRep); cacheable || fm.pinned[uri] {
+		cached := fm.newCacheEntry(flow, defRep, ttl)
+		cached.raw = raw
+		fm.cacheRemoteFlow(uri, cached)
 	} else {
-		// URI
-		req, err := http.NewRequest("GET", flowURI, nil)
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			getErr := fmt.Errorf("error getting flow with uri '%s', %s", flowURI, err.Error())
-			logger.Errorf(getErr.Error())
-			return nil, getErr
-		}
-		defer resp.Body.Close()
+		delete(fm.remoteFlows, uri)
+	}
 
-		logger.Infof("response Status:", resp.Status)
+	return flow, nil
+}
 
-		if resp.StatusCode >= 300 {
-			//not found
-			getErr := fmt.Errorf("error getting flow with uri '%s', status code %d", flowURI, resp.StatusCode)
-			logger.Errorf(getErr.Error())
-			return nil, getErr
+// getFlowRep gets the flow from the provider, passing the context if the provider
+// supports it.  A provider returning no flow is treated as the flow not being found.
+func (fm *FlowManager) getFlowRep(ctx context.Context, uri string) (defRep *definition.DefinitionRep, err error) {
+
+	if fm.overrideDir != "" {
+		if defRep, overridden, err := fm.getOverrideRep(uri); overridden {
+			return defRep, err
 		}
+	}
+
+	release, err := fm.acquireFetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error waiting to fetch flow with uri '%s', %s", uri, err.Error())
+	}
+	defer release()
+
+	flowProvider := fm.provider(uri)
+
+	if provider, ok := flowProvider.(RawFlowProvider); ok {
+		defRep, err = getRawFlowRep(provider, uri, fm.verifier, fm.strictGzip)
+	} else if err = fm.checkVerifiable(flowProvider, uri); err != nil {
+		return nil, err
+	} else if provider, ok := flowProvider.(definition.ContextProvider); ok {
+		defRep, err = provider.GetFlowWithContext(ctx, uri)
+	} else {
+		defRep, err = flowProvider.GetFlow(uri)
+	}
+
+	if err == nil && defRep == nil {
+		err = fmt.Errorf("flow not found for uri '%s'", uri)
+	}
+
+	return defRep, err
+}
 
-		body, err := ioutil.ReadAll(resp.Body)
+// acquireFetch waits for a free fetch slot when the concurrent fetches are limited,
+// returning the function releasing the slot.  The context's error is returned if it is
+// done before a slot is free.
+func (fm *FlowManager) acquireFetch(ctx context.Context) (func(), error) {
+
+	if fm.fetchSem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case fm.fetchSem <- struct{}{}:
+		return func() { <-fm.fetchSem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// materializeFunc is a function that materializes a flow definition
+type materializeFunc func(flowRep *definition.DefinitionRep) (*definition.Definition, error)
+
+func (fm *FlowManager) materializeFlow(flowRep *definition.DefinitionRep) (*definition.Definition, error) {
+
+	if flowRep == nil {
+		return nil, fm.materializeFailure(FailureParse, errors.New("unable to materialize flow, flow definition not provided"))
+	}
+
+	if fm.pipeline != nil {
+		var err error
+		flowRep, err = fm.pipeline.Run(flowRep)
 		if err != nil {
-			readErr := fmt.Errorf("error reading flow response body with uri '%s', %s", flowURI, err.Error())
-			logger.Errorf(readErr.Error())
-			return nil, readErr
+			return nil, fm.materializeFailure(FailurePipeline, err)
 		}
+	}
 
-		val := resp.Header.Get("flow-compressed")
-		if strings.ToLower(val) == "true" {
-			decodedBytes, err := decodeAndUnzip(string(body))
-			if err != nil {
-				decodeErr := fmt.Errorf("error decoding compressed flow with uri '%s', %s", flowURI, err.Error())
-				logger.Errorf(decodeErr.Error())
-				return nil, decodeErr
-			}
-			flowDefBytes = decodedBytes
-		} else {
-			flowDefBytes = body
+	if err := fm.checkSchemaVersion(flowRep); err != nil {
+		return nil, fm.materializeFailure(FailureValidate, err)
+	}
+
+	if !fm.skipValidation {
+		if errs := ValidateRep(flowRep); len(errs) > 0 {
+			return nil, fm.materializeFailure(validationCategory(errs), joinErrors("invalid flow", errs))
 		}
 	}
 
-	var flow *definition.DefinitionRep
-	err := json.Unmarshal(flowDefBytes, &flow)
+	if fm.validateSettings {
+		if errs := ValidateActivitySettings(flowRep); len(errs) > 0 {
+			return nil, fm.materializeFailure(FailureValidate, joinErrors("invalid activity settings", errs))
+		}
+	}
+
+	def, err := definition.NewDefinition(flowRep)
 	if err != nil {
-		logger.Errorf(err.Error())
-		return nil, fmt.Errorf("error marshalling flow with uri '%s', %s", flowURI, err.Error())
+		return nil, fm.materializeFailure(FailureParse, fmt.Errorf("error unmarshalling flow: %s", err.Error()))
 	}
 
-	return flow, nil
-}
+	if !fm.skipValidation {
+		if err := definition.Validate(def); err != nil {
+			return nil, fm.materializeFailure(FailureValidate, err)
+		}
 
-func decodeAndUnzip(encoded string) ([]byte, error) {
+		for _, warning := range definition.LinkWarnings(def) {
+			logger.Warnf("Flow '%s' %s", def.Name(), warning)
+		}
+	}
+
+	//todo fix this up
+	if err := setLinkExprManager(def); err != nil {
+		return nil, fm.materializeFailure(FailureLinkExpr, fmt.Errorf("error creating link expression manager: %s", err.Error()))
+	}
+
+	//todo init activities
+
+	return def, nil
 
-	decoded, _ := base64.StdEncoding.DecodeString(encoded)
-	return unzip(decoded)
 }
 
-func unzip(compressed []byte) ([]byte, error) {
+// setLinkExprManager sets the link expression manager of the definition, a panic
+// creating the manager is returned as an error
+func setLinkExprManager(def *definition.Definition) (err error) {
 
-	buf := bytes.NewBuffer(compressed)
-	r, err := gzip.NewReader(buf)
-	if err != nil {
-		return nil, err
+	defer util.HandlePanic("setLinkExprManager", &err)
+
+	factory := definition.GetLinkExprManagerFactory()
+
+	if language := def.ExprLanguage(); language != "" {
+		var exists bool
+		if factory, exists = definition.GetExprLanguage(language); !exists {
+			return fmt.Errorf("unsupported link expression language '%s'", language)
+		}
 	}
-	jsonAsBytes, err := ioutil.ReadAll(r)
-	if err != nil {
-		return nil, err
+
+	if factory == nil {
+		factory = linker.NewDefaultLinkerFactory()
 	}
 
-	return jsonAsBytes, nil
+	mgr := factory.NewLinkExprManager()
+
+	if compiler, ok := mgr.(definition.LinkExprCompiler); ok {
+		if err := compiler.CompileLinkExprs(def); err != nil {
+			return err
+		}
+	}
+
+	def.SetLinkExprManager(mgr)
+
+	return nil
+}
+
+// SourceCompiler compiles the source of the flow with the specified uri to the flow JSON
+type SourceCompiler func(flowURI string, source []byte) ([]byte, error)
+
+// DefaultFetchTimeout is the timeout of the requests of the default http client used
+// to fetch remote flows
+const DefaultFetchTimeout = 30 * time.Second
+
+type BasicRemoteFlowProvider struct {
+	// Headers are the headers added to the requests for the flows (ex. Authorization)
+	Headers map[string]string
+
+	// SignatureVerifier is used to verify the signature part of a multipart
+	// flow response, the signature is nil if the response didn't contain one
+	SignatureVerifier func(flow []byte, signature []byte) error
+
+	// Verifier verifies the signatures of the flows before they are decoded, signatures
+	// aren't verified if not set.  The detached signature of a http flow is taken from the
+	// flow-signature header or the signature part of a multipart response.
+	Verifier *FlowVerifier
+
+	// Query builds the JSON query to POST to get a flow from the flow uri, the flow is
+	// retrieved using a GET if not set.  The query is marshalled, so the values taken
+	// from the uri are always escaped.
+	Query QueryBuilder
+
+	// RetryOnDecodeError indicates if a remote flow should be fetched again once
+	// when the fetched flow couldn't be decoded (ex. truncated response)
+	RetryOnDecodeError bool
+
+	// RetryOnTruncation indicates if a remote flow is fetched again once when its gzip
+	// stream is truncated
+	RetryOnTruncation bool
+
+	// MaxReadRetries is the maximum number of times a remote flow is fetched again
+	// when reading the response fails midway (ex. connection reset), 0 disables retries
+	MaxReadRetries int
+
+	// RetryDecider overrides the classification of the failed fetches retried up to
+	// MaxReadRetries times, only the failures reading the response are retried if not set
+	RetryDecider RetryDecider
+
+	// MaxResumes is the maximum number of times an interrupted flow download is resumed
+	// using a range request when the server supports them, 0 disables resuming
+	MaxResumes int
+
+	// RequireHTTPS indicates if flows can only be fetched using https, http uris are rejected
+	RequireHTTPS bool
+
+	// AllowedHosts are the hosts flows can be fetched from, flows can be fetched from
+	// any host if not set.  A host prefixed with '*.' matches all its subdomains.  The
+	// hosts the requests are redirected to are checked too.
+	AllowedHosts []string
+
+	// DeniedHosts are the hosts flows can't be fetched from, they take precedence
+	// over the AllowedHosts
+	DeniedHosts []string
+
+	// DiskCache is the disk cache consulted before fetching a flow from the server,
+	// flows aren't cached on disk if not set
+	DiskCache *DiskCache
+
+	// Proxy is the SOCKS5 proxy flows are fetched through, flows are fetched
+	// directly if not set
+	Proxy *SOCKS5Proxy
+
+	// Compiler compiles the fetched flow source (ex. Jsonnet or CUE) to the flow JSON
+	// before it is unmarshalled, fetched flows are expected to be JSON if not set
+	Compiler SourceCompiler
+
+	// FlowPath is the dot separated path of the flow within the fetched JSON (ex.
+	// data.flow for a {"data":{"flow":{...}}} wrapper), the fetched JSON is the flow if not set
+	FlowPath string
+
+	// HostTransport is the policy determining the hosts fetched using their own transport,
+	// isolating their connection pool so a slow host doesn't starve the connections to the
+	// others (ex. IsolateAllHosts), all the hosts share the client's transport if not set
+	HostTransport HostTransportPolicy
+
+	// Auth adds the credentials to the requests for the flows (ex. BearerToken, BasicAuth
+	// or a RefreshingToken), the requests are only authenticated by the Headers if not set
+	Auth Authenticator
+
+	// Timeout is the timeout of the requests for the flows, DefaultFetchTimeout is used
+	// if not set.  It is ignored if the provider was created with a client.
+	Timeout time.Duration
+
+	// TLSConfig is the TLS configuration used to fetch the https flows (ex. a private CA
+	// or a client certificate).  It is ignored if the provider was created with a client.
+	TLSConfig *tls.Config
+
+	// MaxFlowSize is the maximum size in bytes of a fetched flow, both as it is received
+	// and once uncompressed, 0 means no limit
+	MaxFlowSize int64
+
+	// StrictGzip indicates if data trailing the gzip stream of a flow (ex. padding) is
+	// rejected, it is ignored if not set
+	StrictGzip bool
+
+	client       *http.Client
+	configOnce   sync.Once
+	configClient *http.Client
+
+	hostMu      sync.Mutex
+	hostClients map[string]*http.Client
+}
+
+// NewBasicRemoteFlowProvider creates a BasicRemoteFlowProvider fetching flows using
+// the specified client and adding the specified headers to the requests, a client
+// with a DefaultFetchTimeout timeout is used if the client is nil
+func NewBasicRemoteFlowProvider(client *http.Client, headers map[string]string) *BasicRemoteFlowProvider {
+
+	if client == nil {
+		client = &http.Client{Timeout: DefaultFetchTimeout}
+	}
+
+	return &BasicRemoteFlowProvider{client: client, Headers: headers}
+}
+
+func (p *BasicRemoteFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	if p.RequireHTTPS && strings.HasPrefix(strings.ToLower(flowURI), uriSchemeHttp) {
+		httpsErr := fmt.Errorf("unable to get flow with uri '%s', https is required", redactURI(flowURI))
+		logger.Errorf(httpsErr.Error())
+		return nil, httpsErr
+	}
+
+	if !strings.HasPrefix(flowURI, uriSchemeFile) {
+		if err := p.checkHost(flowURI); err != nil {
+			hostErr := fmt.Errorf("unable to get flow with uri '%s', %s", redactURI(flowURI), err.Error())
+			logger.Errorf(hostErr.Error())
+			return nil, hostErr
+		}
+	}
+
+	flow, err := p.getFlow(flowURI)
+
+	for retries := 0; retries < p.MaxReadRetries; retries++ {
+		if !p.retryable(err) {
+			break
+		}
+		logger.Warnf("Unable to get flow with uri '%s', retrying", redactURI(flowURI))
+		flow, err = p.getFlow(flowURI)
+	}
+
+	if _, ok := err.(*decodeError); ok && p.RetryOnDecodeError && !strings.HasPrefix(flowURI, uriSchemeFile) {
+		logger.Warnf("Unable to decode flow with uri '%s', retrying", redactURI(flowURI))
+		flow, err = p.getFlow(flowURI)
+	}
+
+	if _, ok := err.(*TruncatedFlowError); ok && p.RetryOnTruncation && !strings.HasPrefix(flowURI, uriSchemeFile) {
+		logger.Warnf("Flow with uri '%s' is truncated, retrying", redactURI(flowURI))
+		flow, err = p.getFlow(flowURI)
+	}
+
+	return flow, err
+}
+
+// RetryDecider determines if a failed fetch of a flow is retried, resp is nil if no
+// response was received and err is nil if the response had an error status code.  The
+// body of the response is already closed.
+type RetryDecider func(resp *http.Response, err error) bool
+
+// readError is the error returned when the response of a remote flow couldn't be read
+type readError struct {
+	err   error
+	resp  *http.Response
+	cause error
+}
+
+func (e *readError) Error() string {
+	return e.err.Error()
+}
+
+// fetchError is the error returned when a remote flow couldn't be requested or the
+// response had an error status code
+type fetchError struct {
+	err   error
+	resp  *http.Response
+	cause error
+}
+
+func (e *fetchError) Error() string {
+	return e.err.Error()
+}
+
+// retryable determines if the flow is fetched again after the error, using the
+// RetryDecider if set
+func (p *BasicRemoteFlowProvider) retryable(err error) bool {
+
+	switch e := err.(type) {
+	case *readError:
+		return p.RetryDecider == nil || p.RetryDecider(e.resp, e.cause)
+	case *fetchError:
+		return p.RetryDecider != nil && p.RetryDecider(e.resp, e.cause)
+	}
+
+	return false
+}
+
+// TruncatedFlowError is the error returned when the gzip stream of a fetched flow ends
+// unexpectedly (ex. an interrupted transfer), as opposed to a malformed flow
+type TruncatedFlowError struct {
+	URI string
+}
+
+func (e *TruncatedFlowError) Error() string {
+	return fmt.Sprintf("error uncompressing flow with uri '%s', gzip stream is truncated", redactURI(e.URI))
+}
+
+// uncompressError is the error of a flow which couldn't be uncompressed, a truncated
+// gzip stream is reported as a TruncatedFlowError and the other failures as decode errors
+func uncompressError(flowURI string, err error) error {
+
+	if err == io.ErrUnexpectedEOF {
+		return &TruncatedFlowError{URI: flowURI}
+	}
+
+	return &decodeError{err: fmt.Errorf("error uncompressing flow with uri '%s', %s", redactURI(flowURI), err.Error())}
+}
+
+// decodeError is the error returned when a fetched flow couldn't be decoded
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return e.err.Error()
+}
+
+func (p *BasicRemoteFlowProvider) getFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	resolve, err := p.resolver(flowURI)
+	if err != nil {
+		resolveErr := fmt.Errorf("unable to get flow with uri '%s', %s", redactURI(flowURI), err.Error())
+		logger.Errorf(resolveErr.Error())
+		return nil, resolveErr
+	}
+
+	// the flow is decoded as it is received when it isn't processed before
+	if p.streamsHTTPFlow(flowURI) {
+		return p.decodeHTTPFlow(flowURI)
+	}
+
+	flowDefBytes, err := resolve(flowURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if isGzipped(flowDefBytes) {
+		flowDefBytes, err = unzip(flowDefBytes, p.StrictGzip)
+		if err != nil {
+			decompressErr := uncompressError(flowURI, err)
+			logger.Errorf(decompressErr.Error())
+			return nil, decompressErr
+		}
+	}
+
+	// the http flows are verified when they are fetched, before they are disk cached
+	if p.Verifier != nil && !p.verifiedOnFetch(flowURI) {
+		flowDefBytes, err = p.Verifier.Open(flowURI, flowDefBytes, nil)
+		if err != nil {
+			logger.Errorf(err.Error())
+			return nil, err
+		}
+	}
+
+	if p.Compiler != nil {
+		flowDefBytes, err = p.Compiler(flowURI, flowDefBytes)
+		if err != nil {
+			compileErr := fmt.Errorf("error compiling flow with uri '%s', %s", redactURI(flowURI), err.Error())
+			logger.Errorf(compileErr.Error())
+			return nil, compileErr
+		}
+	}
+
+	if p.FlowPath != "" {
+		flowDefBytes, err = extractFlowJSON(flowDefBytes, p.FlowPath)
+		if err != nil {
+			extractErr := fmt.Errorf("error extracting flow with uri '%s' at path '%s', %s", redactURI(flowURI), p.FlowPath, err.Error())
+			logger.Errorf(extractErr.Error())
+			return nil, &decodeError{err: extractErr}
+		}
+	}
+
+	var flow *definition.DefinitionRep
+	err = jsonCodec.Unmarshal(flowDefBytes, &flow)
+	if err != nil {
+		logger.Errorf(err.Error())
+		return nil, &decodeError{err: fmt.Errorf("error marshalling flow with uri '%s', %s", redactURI(flowURI), err.Error())}
+	}
+
+	return flow, nil
+}
+
+// getFileFlow reads the flow with the specified file uri
+func (p *BasicRemoteFlowProvider) getFileFlow(flowURI string) ([]byte, error) {
+
+	logger.Infof("Loading Local Flow: %s\n", redactURI(flowURI))
+	flowFilePath, _ := util.URLStringToFilePath(flowURI)
+
+	readBytes, err := ioutil.ReadFile(flowFilePath)
+	if err != nil {
+		readErr := fmt.Errorf("error reading flow with uri '%s', %s", redactURI(flowURI), err.Error())
+		logger.Errorf(readErr.Error())
+		return nil, readErr
+	}
+
+	return readBytes, nil
+}
+
+// getHTTPFlow gets the flow with the specified uri from the server, consulting the
+// disk cache first if one is configured
+func (p *BasicRemoteFlowProvider) getHTTPFlow(flowURI string) ([]byte, error) {
+
+	var cached *diskCacheEntry
+
+	if p.DiskCache != nil {
+		cached = p.DiskCache.load(flowURI)
+		if cached != nil && p.DiskCache.fresh(cached) {
+			logger.Debugf("Using disk cached flow: %s", redactURI(flowURI))
+			flowDefBytes, err := p.openCachedFlow(flowURI, cached)
+			if err == nil {
+				return flowDefBytes, nil
+			}
+			logger.Warnf("Refusing disk cached flow, %s", err.Error())
+			cached = nil
+		}
+	}
+
+	return p.fetchHTTPFlow(flowURI, cached)
+}
+
+// fetchHTTPFlow fetches the flow with the specified uri from the server, revalidating
+// the disk cached flow if there is one
+func (p *BasicRemoteFlowProvider) fetchHTTPFlow(flowURI string, cached *diskCacheEntry) ([]byte, error) {
+
+	flow, err := p.openHTTPFlow(flowURI, cached)
+	if err != nil {
+		return nil, err
+	}
+	defer flow.close()
+
+	if flow.notModified {
+		logger.Debugf("Disk cached flow not modified: %s", redactURI(flowURI))
+		flowDefBytes, err := p.openCachedFlow(flowURI, cached)
+		if err != nil {
+			// the cached flow is refused, so it is fetched again
+			logger.Warnf("Refusing disk cached flow, %s", err.Error())
+			return p.fetchHTTPFlow(flowURI, nil)
+		}
+		p.DiskCache.store(flowURI, cached.data, cached.ETag, []byte(cached.Signature))
+		return flowDefBytes, nil
+	}
+
+	fetchedBytes, err := ioutil.ReadAll(flow.reader)
+	if err != nil {
+		readErr := flow.readError(err)
+		logger.Errorf(readErr.Error())
+		return nil, readErr
+	}
+
+	flowDefBytes := fetchedBytes
+	if p.Verifier != nil {
+		flowDefBytes, err = p.Verifier.Open(flowURI, fetchedBytes, flow.signature)
+		if err != nil {
+			logger.Errorf(err.Error())
+			return nil, err
+		}
+	}
+
+	if p.DiskCache != nil {
+		// the flow is cached as fetched, so it is verified again when it is used
+		err = p.DiskCache.store(flowURI, fetchedBytes, flow.resp.Header.Get("ETag"), flow.signature)
+		if err != nil {
+			logger.Warnf("Unable to disk cache flow with uri '%s': %s", redactURI(flowURI), err.Error())
+		}
+	}
+
+	return flowDefBytes, nil
+}
+
+// openCachedFlow verifies the disk cached flow, returning the flow it contains
+func (p *BasicRemoteFlowProvider) openCachedFlow(flowURI string, cached *diskCacheEntry) ([]byte, error) {
+
+	if p.Verifier == nil {
+		return cached.data, nil
+	}
+
+	return p.Verifier.Open(flowURI, cached.data, []byte(cached.Signature))
+}
+
+// streamsHTTPFlow determines if the flow with the specified uri is decoded as it is
+// received, which is the case of the http flows which aren't verified, cached or
+// transformed before they are decoded
+func (p *BasicRemoteFlowProvider) streamsHTTPFlow(flowURI string) bool {
+
+	if _, ok := jsonCodec.(JSONStreamCodec); !ok {
+		return false
+	}
+
+	return p.verifiedOnFetch(flowURI) && p.Verifier == nil && p.DiskCache == nil && p.Compiler == nil && p.FlowPath == ""
+}
+
+// decodeHTTPFlow gets the flow with the specified uri from the server, decoding it as
+// it is received
+func (p *BasicRemoteFlowProvider) decodeHTTPFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	flow, err := p.openHTTPFlow(flowURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer flow.close()
+
+	br := bufio.NewReader(flow.reader)
+
+	var r io.Reader = br
+	gzipped := false
+	if next, _ := br.Peek(2); isGzipped(next) {
+		zr, err := newGzipReader(br, p.StrictGzip)
+		if err != nil {
+			return nil, flowStreamError(flow, err, true)
+		}
+		defer zr.Close()
+		r = limitSize(zr, p.MaxFlowSize)
+		gzipped = true
+	}
+
+	// the failures reading the flow are told apart from those decoding it
+	src := &errRecorder{r: r}
+
+	var rep *definition.DefinitionRep
+	err = jsonCodec.(JSONStreamCodec).Decode(src, &rep)
+	if err != nil {
+		if src.err != nil {
+			return nil, flowStreamError(flow, src.err, gzipped)
+		}
+		logger.Errorf(err.Error())
+		return nil, &decodeError{err: fmt.Errorf("error marshalling flow with uri '%s', %s", redactURI(flowURI), err.Error())}
+	}
+
+	// the decoder stops at the end of the flow, the rest of the stream is only checked
+	// for trailing data when it is rejected
+	if gzipped && p.StrictGzip {
+		if src.err == nil {
+			io.Copy(ioutil.Discard, src)
+		}
+		if src.err != nil {
+			return nil, flowStreamError(flow, src.err, gzipped)
+		}
+	}
+
+	return rep, nil
+}
+
+// flowStreamError is the error of a flow which couldn't be read as it was decoded, a
+// gzipped flow is uncompressed as it is decoded
+func flowStreamError(flow *httpFlow, err error, gzipped bool) error {
+
+	var streamErr error
+	if gzipped && (flow.body == nil || flow.body.err == nil) && err != errFlowTooLarge {
+		streamErr = uncompressError(flow.uri, err)
+	} else {
+		streamErr = flow.readError(err)
+	}
+
+	logger.Errorf(streamErr.Error())
+	return streamErr
+}
+
+// httpFlow is the flow of a response, it is uncompressed as it is read
+type httpFlow struct {
+	uri    string
+	resp   *http.Response
+	reader io.Reader
+
+	// body records the failures reading the body of the response
+	body *errRecorder
+
+	// compressed is the error prefix of the flows failing to uncompress, empty if the
+	// flow isn't compressed
+	compressed string
+
+	signature   []byte
+	maxSize     int64
+	notModified bool
+	closers     []io.Closer
+}
+
+func (f *httpFlow) close() {
+	for _, closer := range f.closers {
+		closer.Close()
+	}
+}
+
+// readError is the error of a flow which couldn't be read, the failures of the transfer
+// are retryable while the flows which couldn't be uncompressed are decode errors
+func (f *httpFlow) readError(err error) error {
+
+	if f.body != nil && f.body.err != nil {
+		readErr := fmt.Errorf("error reading flow response body with uri '%s', %s", redactURI(f.uri), f.body.err.Error())
+		return &readError{err: readErr, resp: f.resp, cause: f.body.err}
+	}
+
+	if err == errFlowTooLarge {
+		return fmt.Errorf("flow with uri '%s' exceeds the maximum size of %d bytes", redactURI(f.uri), f.maxSize)
+	}
+
+	if f.compressed == "" {
+		readErr := fmt.Errorf("error reading flow response body with uri '%s', %s", redactURI(f.uri), err.Error())
+		return &readError{err: readErr, resp: f.resp, cause: err}
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		return uncompressError(f.uri, err)
+	}
+
+	if corruptErr, ok := err.(base64.CorruptInputError); ok {
+		err = fmt.Errorf("invalid base64 encoding, %s", corruptErr.Error())
+	}
+
+	return &decodeError{err: fmt.Errorf("%s with uri '%s', %s", f.compressed, redactURI(f.uri), err.Error())}
+}
+
+// errRecorder records the first error other than io.EOF of the reader
+type errRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errRecorder) Read(p []byte) (int, error) {
+
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF && e.err == nil {
+		e.err = err
+	}
+
+	return n, err
+}
+
+// openHTTPFlow requests the flow with the specified uri from the server, the body of the
+// response is only buffered when it has to be resumed or is a multipart response
+func (p *BasicRemoteFlowProvider) openHTTPFlow(flowURI string, cached *diskCacheEntry) (*httpFlow, error) {
+
+	req, err := p.flowRequest(flowURI, cached)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.hostClient(req.URL.Host).Do(req)
+
+	// the token was likely revoked or expired early, fetch again once with a new token
+	if refresher, ok := p.Auth.(TokenRefresher); ok && err == nil && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		refresher.Invalidate()
+		logger.Debugf("Flow with uri '%s' unauthorized, retrying with a refreshed token", redactURI(flowURI))
+
+		req, err = p.flowRequest(flowURI, cached)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = p.hostClient(req.URL.Host).Do(req)
+	}
+
+	if err != nil {
+		err = redactURLError(err)
+		getErr := fmt.Errorf("error getting flow with uri '%s', %s", redactURI(flowURI), err.Error())
+		logger.Errorf(getErr.Error())
+		return nil, &fetchError{err: getErr, cause: err}
+	}
+
+	flow := &httpFlow{uri: flowURI, resp: resp, maxSize: p.MaxFlowSize, closers: []io.Closer{resp.Body}}
+
+	logger.Infof("response Status:", resp.Status)
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		flow.notModified = true
+		return flow, nil
+	}
+
+	if resp.StatusCode >= 300 {
+		//not found
+		flow.close()
+		getErr := fmt.Errorf("error getting flow with uri '%s', status code %d", redactURI(flowURI), resp.StatusCode)
+		logger.Errorf(getErr.Error())
+		return nil, &fetchError{err: getErr, resp: resp}
+	}
+
+	flow.body = &errRecorder{r: resp.Body}
+	flow.signature = []byte(resp.Header.Get("flow-signature"))
+
+	var body io.Reader = limitSize(flow.body, p.MaxFlowSize)
+
+	encoding := flowEncoding(resp.Header.Get("flow-compressed"))
+
+	// a raw gzip body, the explicit flow-compressed header takes precedence
+	gzipped := encoding == "" && strings.EqualFold(strings.TrimSpace(resp.Header.Get("Content-Encoding")), "gzip")
+
+	contentType := resp.Header.Get("Content-Type")
+	resumable := p.MaxResumes > 0 && req.Method == http.MethodGet && resp.Header.Get("Accept-Ranges") == "bytes"
+
+	if resumable || isMultipart(contentType) {
+		data, err := ioutil.ReadAll(body)
+		if err != nil && resumable && flow.body.err != nil {
+			logger.Warnf("Download of flow with uri '%s' interrupted after %d bytes, resuming", redactURI(flowURI), len(data))
+			data, err = p.resumeDownload(req, resp, data)
+			if err != nil {
+				flow.body.err = err
+			}
+		}
+		if err != nil {
+			flow.close()
+			readErr := flow.readError(err)
+			logger.Errorf(readErr.Error())
+			return nil, readErr
+		}
+		flow.body = nil
+
+		if gzipped {
+			gzipped = false
+			data, err = unzip(data, p.StrictGzip)
+			if err != nil {
+				flow.close()
+				flow.compressed = "error uncompressing flow"
+				decompressErr := flow.readError(err)
+				logger.Errorf(decompressErr.Error())
+				return nil, decompressErr
+			}
+		}
+
+		if isMultipart(contentType) {
+			part, err := extractMultipartFlow(contentType, data)
+			if err != nil {
+				flow.close()
+				partErr := fmt.Errorf("error reading multipart flow response with uri '%s', %s", redactURI(flowURI), err.Error())
+				logger.Errorf(partErr.Error())
+				return nil, partErr
+			}
+
+			if p.SignatureVerifier != nil {
+				err = p.SignatureVerifier(part.flow, part.signature)
+				if err != nil {
+					flow.close()
+					verifyErr := fmt.Errorf("error verifying signature of flow with uri '%s', %s", redactURI(flowURI), err.Error())
+					logger.Errorf(verifyErr.Error())
+					return nil, verifyErr
+				}
+			}
+
+			data = part.flow
+			flow.signature = part.signature
+			if encoding == "" {
+				encoding = part.encoding
+			}
+		}
+
+		body = bytes.NewReader(data)
+	}
+
+	if gzipped {
+		encoding = EncodingGzip
+		flow.compressed = "error uncompressing flow"
+	} else if encoding != "" {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+		flow.compressed = "error decoding compressed flow"
+	}
+
+	if encoding != "" {
+		ur, err := newUncompressReader(encoding, body, p.StrictGzip)
+		if err != nil {
+			flow.close()
+			decompressErr := flow.readError(err)
+			logger.Errorf(decompressErr.Error())
+			return nil, decompressErr
+		}
+		flow.closers = append(flow.closers, ur)
+		body = limitSize(ur, p.MaxFlowSize)
+	}
+
+	flow.reader = body
+
+	return flow, nil
+}
+
+// resumeDownload resumes the interrupted download of the flow using range requests,
+// returning the reassembled body.  The full body is used if the server ignores the
+// range (ex. the flow changed since the download started).
+func (p *BasicRemoteFlowProvider) resumeDownload(req *http.Request, resp *http.Response, partial []byte) ([]byte, error) {
+
+	validator := resp.Header.Get("ETag")
+	if validator == "" {
+		validator = resp.Header.Get("Last-Modified")
+	}
+
+	body := partial
+	var err error
+
+	for resumes := 0; resumes < p.MaxResumes; resumes++ {
+
+		rangeReq, reqErr := http.NewRequest(http.MethodGet, req.URL.String(), nil)
+		if reqErr != nil {
+			return nil, reqErr
+		}
+		for name, value := range p.Headers {
+			rangeReq.Header.Set(name, value)
+		}
+		if authErr := p.authenticate(rangeReq); authErr != nil {
+			return nil, authErr
+		}
+		rangeReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(body)))
+		if validator != "" {
+			rangeReq.Header.Set("If-Range", validator)
+		}
+
+		rangeResp, doErr := p.hostClient(rangeReq.URL.Host).Do(rangeReq)
+		if doErr != nil {
+			err = redactURLError(doErr)
+			continue
+		}
+
+		var chunk []byte
+		chunk, err = ioutil.ReadAll(rangeResp.Body)
+		rangeResp.Body.Close()
+
+		switch rangeResp.StatusCode {
+		case http.StatusPartialContent:
+			body = append(body, chunk...)
+		case http.StatusOK:
+			body = chunk
+		default:
+			return nil, fmt.Errorf("unable to resume download, status code %d", rangeResp.StatusCode)
+		}
+
+		if err == nil {
+			return body, nil
+		}
+	}
+
+	return body, err
+}
+
+// flowRequest creates the request for the flow with the headers and credentials
+// of the provider
+func (p *BasicRemoteFlowProvider) flowRequest(flowURI string, cached *diskCacheEntry) (*http.Request, error) {
+
+	req, err := p.newRequest(flowURI)
+	if err != nil {
+		reqErr := fmt.Errorf("error creating request for flow with uri '%s', %s", redactURI(flowURI), err.Error())
+		logger.Errorf(reqErr.Error())
+		return nil, reqErr
+	}
+
+	for name, value := range p.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if err := p.authenticate(req); err != nil {
+		authErr := fmt.Errorf("error authenticating request for flow with uri '%s', %s", redactURI(flowURI), err.Error())
+		logger.Errorf(authErr.Error())
+		return nil, authErr
+	}
+
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	return req, nil
+}
+
+// httpClient returns the client used to fetch remote flows
+func (p *BasicRemoteFlowProvider) httpClient() *http.Client {
+	if p.client != nil {
+		return p.client
+	}
+	if p.Proxy != nil || p.TLSConfig != nil || p.Timeout > 0 {
+		p.configOnce.Do(func() {
+			timeout := DefaultFetchTimeout
+			if p.Timeout > 0 {
+				timeout = p.Timeout
+			}
+
+			transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+			if p.Proxy != nil {
+				transport = p.Proxy.transport()
+			}
+			transport.TLSClientConfig = p.TLSConfig
+
+			p.configClient = &http.Client{Transport: transport, Timeout: timeout}
+		})
+		return p.configClient
+	}
+	return defaultFetchClient
+}
+
+// defaultFetchClient is the client used by providers which weren't created with a client
+var defaultFetchClient = &http.Client{Timeout: DefaultFetchTimeout}
+
+// EncodeAndZip gzips and base64 encodes the flow, producing the body of a flow served
+// with the flow-compressed header
+func EncodeAndZip(flowBytes []byte) (string, error) {
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+
+	if _, err := w.Write(flowBytes); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decodeAndUnzip decodes the base64 encoded and gzipped flow
+func decodeAndUnzip(encoded string) ([]byte, error) {
+	return decodeAndUncompress(EncodingGzip, encoded, false)
+}
+
+// unzip uncompresses the gzip stream, concatenated gzip members are all uncompressed.
+// Data trailing the stream is rejected if strict, ignored otherwise.
+func unzip(compressed []byte, strict bool) ([]byte, error) {
+	return uncompress(EncodingGzip, bytes.NewReader(compressed), strict)
 }
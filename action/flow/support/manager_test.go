@@ -0,0 +1,466 @@
+package support
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	_ "github.com/TIBCOSoftware/flogo-contrib/action/flow/test"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFlowJSON = `{
+  "name": "Test Flow",
+  "model": "test",
+  "tasks": [
+    {
+      "id": "log_1",
+      "name": "Log Start",
+      "activity": {
+        "ref": "test-log",
+        "input": {
+          "message": "flow started"
+        }
+      }
+    },
+    {
+      "id": "log_2",
+      "name": "Log End",
+      "activity": {
+        "ref": "test-log",
+        "input": {
+          "message": "flow done"
+        }
+      }
+    }
+  ],
+  "links": [
+    { "id": 1, "from": "log_1", "to": "log_2" }
+  ]
+}`
+
+// testFlowProvider is a definition.Provider serving flows from memory that
+// keeps track of the number of times each flow was requested
+type testFlowProvider struct {
+	mu    sync.Mutex
+	flows map[string]string
+	calls map[string]int
+}
+
+func newTestFlowProvider(flows map[string]string) *testFlowProvider {
+	return &testFlowProvider{flows: flows, calls: make(map[string]int)}
+}
+
+func (p *testFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	p.mu.Lock()
+	p.calls[flowURI]++
+	flowJSON, exists := p.flows[flowURI]
+	p.mu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("flow '%s' not found", flowURI)
+	}
+
+	var defRep *definition.DefinitionRep
+	err := json.Unmarshal([]byte(flowJSON), &defRep)
+	return defRep, err
+}
+
+func (p *testFlowProvider) callCount(flowURI string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[flowURI]
+}
+
+func TestLoadResourceNilConfig(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	var err error
+	assert.NotPanics(t, func() {
+		err = fm.LoadResource(nil)
+	})
+	assert.NotNil(t, err)
+}
+
+func (p *testFlowProvider) setFlow(flowURI string, flowJSON string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flows[flowURI] = flowJSON
+}
+
+func (p *testFlowProvider) removeFlow(flowURI string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.flows, flowURI)
+}
+
+func TestGetFlowDefaultScheme(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{DefaultScheme: "res://"})
+
+	err := fm.LoadResource(&resource.Config{ID: "orderFlow", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+
+	flow, err := fm.GetFlow("orderFlow")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+	assert.Equal(t, 0, provider.callCount("orderFlow"))
+}
+
+func TestGetFlowMultiScheme(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"https://flows.example.com/orderFlow": testFlowJSON})
+	fm := NewFlowManager(provider)
+
+	schemes := []string{"res://", "https://flows.example.com/"}
+
+	flow, err := fm.GetFlowMultiScheme("orderFlow", schemes)
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+	assert.Equal(t, 1, provider.callCount("https://flows.example.com/orderFlow"))
+
+	// the first scheme with the flow wins
+	err = fm.LoadResource(&resource.Config{ID: "orderFlow", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+
+	resFlow, err := fm.GetFlowMultiScheme("orderFlow", schemes)
+	assert.Nil(t, err)
+	assert.True(t, resFlow == fm.GetResource("orderFlow"))
+
+	_, err = fm.GetFlowMultiScheme("missingFlow", schemes)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "flow 'missingFlow' not found")
+	assert.Contains(t, err.Error(), "flow not found for uri 'res://missingFlow'")
+}
+
+func TestGetFlowNotFound(t *testing.T) {
+
+	fm := NewFlowManager(&nilFlowProvider{})
+
+	flow, err := fm.GetFlow("res://doesnotexist")
+	assert.Nil(t, flow)
+	assert.NotNil(t, err)
+	assert.Equal(t, "flow not found for uri 'res://doesnotexist'", err.Error())
+
+	flow, err = fm.GetFlow("http://flows/doesnotexist")
+	assert.Nil(t, flow)
+	assert.NotNil(t, err)
+	assert.Equal(t, "flow not found for uri 'http://flows/doesnotexist'", err.Error())
+}
+
+// nilFlowProvider is a provider which never finds a flow, but doesn't return an error
+type nilFlowProvider struct {
+}
+
+func (p *nilFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+	return nil, nil
+}
+
+func TestMaterializeFlowNilRep(t *testing.T) {
+
+	fm := NewFlowManagerWithOptions(&nilFlowProvider{}, &ManagerOptions{SkipValidation: true})
+
+	var err error
+	assert.NotPanics(t, func() {
+		_, err = fm.GetFlow("http://flows/nil")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, "flow not found for uri 'http://flows/nil'", err.Error())
+
+	assert.NotPanics(t, func() {
+		_, err = fm.materializeFlow(nil)
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, "unable to materialize flow, flow definition not provided", err.Error())
+
+	assert.NotPanics(t, func() {
+		err = fm.LoadResource(&resource.Config{ID: "null", Data: []byte("null")})
+	})
+	assert.NotNil(t, err)
+}
+
+func TestGetFileFlow(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "flows")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(testFlowJSON))
+	w.Close()
+
+	files := map[string][]byte{
+		"flow.json":    []byte(testFlowJSON),
+		"flow.json.gz": buf.Bytes(),
+		"empty.json":   {},
+		"short.json":   {0x1f},
+	}
+	for name, content := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), content, 0644))
+	}
+
+	provider := &BasicRemoteFlowProvider{}
+
+	rep, err := provider.GetFlow("file://" + filepath.Join(dir, "flow.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+
+	rep, err = provider.GetFlow("file://" + filepath.Join(dir, "flow.json.gz"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+
+	// short files are treated as uncompressed
+	_, err = provider.GetFlow("file://" + filepath.Join(dir, "empty.json"))
+	assert.NotNil(t, err)
+
+	_, err = provider.GetFlow("file://" + filepath.Join(dir, "short.json"))
+	assert.NotNil(t, err)
+}
+
+func TestLoadResourceConcurrentGetFlow(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			err := fm.LoadResource(&resource.Config{ID: fmt.Sprintf("flow%d", i%5), Data: []byte(testFlowJSON)})
+			assert.Nil(t, err)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			fm.GetFlow(fmt.Sprintf("res://flow%d", i%5))
+			fm.GetResource(fmt.Sprintf("flow%d", i%5))
+		}
+	}()
+
+	wg.Wait()
+
+	flow, err := fm.GetFlow("res://flow4")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+}
+
+func TestEncodeAndZip(t *testing.T) {
+
+	encoded, err := EncodeAndZip([]byte(testFlowJSON))
+	assert.Nil(t, err)
+
+	decoded, err := decodeAndUnzip(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(decoded))
+
+	_, err = decodeAndUnzip("not base64!")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "illegal base64 data")
+}
+
+func TestUnzipTrailingData(t *testing.T) {
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(testFlowJSON))
+	w.Close()
+
+	padded := append(buf.Bytes(), 0, 0, 0, 0)
+
+	unzipped, err := unzip(padded, false)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(unzipped))
+
+	_, err = unzip(padded, true)
+	assert.NotNil(t, err)
+	assert.Equal(t, "unexpected 4 bytes of data after the gzip stream", err.Error())
+
+	// concatenated gzip members aren't trailing data
+	unzipped, err = unzip(append(buf.Bytes(), buf.Bytes()...), true)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON+testFlowJSON, string(unzipped))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(padded)
+	}))
+	defer server.Close()
+
+	// the providers reject the trailing data independently
+	rep, err := (&BasicRemoteFlowProvider{}).GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+
+	_, err = (&BasicRemoteFlowProvider{StrictGzip: true}).GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unexpected 4 bytes of data after the gzip stream")
+}
+
+// concurrencyFlowProvider serves the test flow, tracking the peak number of concurrent requests
+type concurrencyFlowProvider struct {
+	mu           sync.Mutex
+	active, peak int
+}
+
+func (p *concurrencyFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	p.mu.Lock()
+	p.active++
+	if p.active > p.peak {
+		p.peak = p.active
+	}
+	p.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	p.mu.Lock()
+	p.active--
+	p.mu.Unlock()
+
+	var defRep *definition.DefinitionRep
+	err := json.Unmarshal([]byte(testFlowJSON), &defRep)
+	return defRep, err
+}
+
+func TestMaxConcurrentFetches(t *testing.T) {
+
+	const maxFetches = 2
+
+	provider := &concurrencyFlowProvider{}
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{MaxConcurrentFetches: maxFetches})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uri := fmt.Sprintf("http://flows/flow%d", i)
+			if i%2 == 0 {
+				_, err := fm.FlowSchemaVersion(uri)
+				assert.Nil(t, err)
+			} else {
+				assert.Len(t, fm.PreloadFlows([]string{uri}), 0)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.True(t, provider.peak > 0)
+	assert.True(t, provider.peak <= maxFetches)
+}
+
+// gatedFlowProvider serves the test flow once the gate is opened, signaling each request
+type gatedFlowProvider struct {
+	concurrencyFlowProvider
+	started chan string
+	gate    chan struct{}
+}
+
+func (p *gatedFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+	p.started <- flowURI
+	<-p.gate
+	return p.concurrencyFlowProvider.GetFlow(flowURI)
+}
+
+func TestMaxConcurrentFetchesGetFlow(t *testing.T) {
+
+	const maxFetches = 2
+
+	provider := &gatedFlowProvider{started: make(chan string, 8), gate: make(chan struct{})}
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{MaxConcurrentFetches: maxFetches})
+
+	// a cached flow is fetched before the slots are taken
+	close(provider.gate)
+	_, err := fm.GetFlow("http://flows/cached")
+	assert.Nil(t, err)
+	<-provider.started
+	provider.gate = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := fm.GetFlow(fmt.Sprintf("http://flows/flow%d", i))
+			assert.Nil(t, err)
+		}(i)
+	}
+
+	// only the gets holding a slot reach the provider
+	<-provider.started
+	<-provider.started
+	select {
+	case uri := <-provider.started:
+		t.Fatalf("fetch of flow '%s' exceeded the limit", uri)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the gets waiting for a slot don't block the gets of the cached flows
+	cachedDone := make(chan error, 1)
+	go func() {
+		_, err := fm.GetFlow("http://flows/cached")
+		cachedDone <- err
+	}()
+	select {
+	case err := <-cachedDone:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("get of a cached flow blocked by the fetches waiting for a slot")
+	}
+
+	// nor are they stuck when their context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fm.GetFlowWithContext(ctx, "http://flows/cancelled")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error waiting to fetch flow with uri 'http://flows/cancelled'")
+
+	close(provider.gate)
+	wg.Wait()
+
+	assert.Equal(t, maxFetches, provider.peak)
+}
+
+func TestExprLanguage(t *testing.T) {
+
+	exprFlowJSON := func(language, expr string) []byte {
+		flowJSON := strings.Replace(testFlowJSON, `"model": "test",`, `"model": "test", "exprLanguage": "`+language+`",`, 1)
+		return []byte(strings.Replace(flowJSON, `"from": "log_1"`, `"type": "expression", "value": "`+expr+`", "from": "log_1"`, 1))
+	}
+
+	fm := NewFlowManager(nil)
+
+	err := fm.LoadResource(&resource.Config{ID: "expr", Data: exprFlowJSON("expr", "$flow.order?.total > 100 ? true : false")})
+	assert.Nil(t, err)
+
+	flow, err := fm.GetFlow("res://expr")
+	assert.Nil(t, err)
+	assert.Equal(t, "expr", flow.ExprLanguage())
+
+	err = fm.LoadResource(&resource.Config{ID: "invalid", Data: exprFlowJSON("expr", "$flow.order >")})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid expression of link")
+
+	err = fm.LoadResource(&resource.Config{ID: "unknown", Data: exprFlowJSON("cel", "true")})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unsupported link expression language 'cel'")
+}
@@ -0,0 +1,124 @@
+package support
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// MetricFetchLatency is the latency, in seconds, of remote flow fetches
+	MetricFetchLatency = "flow_fetch_latency_seconds"
+	// MetricFetchErrors is the number of remote flow fetches that failed
+	MetricFetchErrors = "flow_fetch_errors_total"
+	// MetricStaleServed is the number of times an expired flow was served because it couldn't be refreshed
+	MetricStaleServed = "flow_stale_served_total"
+	// MetricMaterializeErrors is the number of flows that failed to materialize
+	MetricMaterializeErrors = "flow_materialize_errors_total"
+
+	// MetricInstancesStarted is the number of flow instances started
+	MetricInstancesStarted = "flow_instances_started_total"
+	// MetricInstancesCompleted is the number of flow instances that completed successfully
+	MetricInstancesCompleted = "flow_instances_completed_total"
+	// MetricInstancesFailed is the number of flow instances that failed
+	MetricInstancesFailed = "flow_instances_failed_total"
+	// MetricInstanceDuration is the duration, in seconds, of the execution of flow instances
+	MetricInstanceDuration = "flow_instance_duration_seconds"
+	// MetricActivityDuration is the duration, in seconds, of the evaluation of activities
+	MetricActivityDuration = "flow_activity_duration_seconds"
+	// MetricWorkQueueDepth is the number of work items queued at each step of flow instances
+	MetricWorkQueueDepth = "flow_work_queue_depth"
+	// MetricInstancesRejected is the number of flow instances rejected because the run queue was full
+	MetricInstancesRejected = "flow_instances_rejected_total"
+	// MetricInstancesShed is the number of queued flow instances dropped for newer ones
+	MetricInstancesShed = "flow_instances_shed_total"
+
+	// LabelScheme is the label containing the uri scheme of the flow (ex. http)
+	LabelScheme = "scheme"
+	// LabelCategory is the label containing the category of a materialization failure
+	LabelCategory = "category"
+	// LabelFlow is the label containing the name of the flow
+	LabelFlow = "flow"
+	// LabelActivity is the label containing the ref of the activity
+	LabelActivity = "activity"
+)
+
+// The categories of materialization failures
+const (
+	FailurePipeline        = "pipeline"
+	FailureParse           = "parse"
+	FailureValidate        = "validate"
+	FailureLinkExpr        = "link-expr"
+	FailureActivityResolve = "activity-resolve"
+)
+
+// MaterializeError is the error returned when a flow fails to materialize
+type MaterializeError struct {
+	// Category is the category of the failure (ex. FailureValidate)
+	Category string
+	Err      error
+}
+
+func (e *MaterializeError) Error() string {
+	return e.Err.Error()
+}
+
+// Metrics is the interface used by the FlowManager to record metrics, it can
+// be implemented to expose the metrics using a metrics system (ex. Prometheus)
+type Metrics interface {
+	// Observe records an observation (ex. latency) of the metric with the specified labels
+	Observe(name string, labels map[string]string, value float64)
+
+	// Inc increments the counter metric with the specified labels
+	Inc(name string, labels map[string]string)
+}
+
+// noopMetrics is the Metrics used when metrics aren't configured
+type noopMetrics struct {
+}
+
+func (noopMetrics) Observe(name string, labels map[string]string, value float64) {
+}
+
+func (noopMetrics) Inc(name string, labels map[string]string) {
+}
+
+// recordFetch records the latency and outcome of a remote flow fetch
+func (fm *FlowManager) recordFetch(uri string, start time.Time, err error) {
+
+	labels := map[string]string{LabelScheme: uriScheme(uri)}
+
+	fm.metrics.Observe(MetricFetchLatency, labels, fm.now().Sub(start).Seconds())
+	if err != nil {
+		fm.metrics.Inc(MetricFetchErrors, labels)
+	}
+}
+
+// uriScheme returns the scheme of the uri (ex. http), empty if the uri doesn't have one
+func uriScheme(uri string) string {
+	idx := strings.Index(uri, "://")
+	if idx < 0 {
+		return ""
+	}
+	return uri[:idx]
+}
+
+// materializeFailure records the materialization failure and returns it as a MaterializeError
+func (fm *FlowManager) materializeFailure(category string, err error) error {
+
+	fm.metrics.Inc(MetricMaterializeErrors, map[string]string{LabelCategory: category})
+
+	return &MaterializeError{Category: category, Err: err}
+}
+
+// validationCategory categorizes the validation errors, flows with only unresolved
+// activities are activity-resolve failures
+func validationCategory(errs []error) string {
+
+	for _, err := range errs {
+		if validationErr, ok := err.(*ValidationError); !ok || !validationErr.unresolvedActivity {
+			return FailureValidate
+		}
+	}
+
+	return FailureActivityResolve
+}
@@ -0,0 +1,104 @@
+package support
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetric struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// testMetrics is a Metrics that keeps all the recorded metrics
+type testMetrics struct {
+	mu           sync.Mutex
+	observations []*testMetric
+	counters     []*testMetric
+}
+
+func (m *testMetrics) Observe(name string, labels map[string]string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observations = append(m.observations, &testMetric{name: name, labels: labels, value: value})
+}
+
+func (m *testMetrics) Inc(name string, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters = append(m.counters, &testMetric{name: name, labels: labels, value: 1})
+}
+
+func (m *testMetrics) schemes(metrics []*testMetric, name string) []string {
+	var schemes []string
+	for _, metric := range metrics {
+		if metric.name == name {
+			schemes = append(schemes, metric.labels[LabelScheme])
+		}
+	}
+	return schemes
+}
+
+func TestFetchMetricsSchemeLabel(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"file:///flows/flow.json": testFlowJSON,
+		"http://flows/flow":       testFlowJSON,
+	})
+
+	metrics := &testMetrics{}
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Metrics: metrics})
+
+	_, err := fm.GetFlow("file:///flows/flow.json")
+	assert.Nil(t, err)
+	_, err = fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+	_, err = fm.GetFlow("http://flows/missing")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []string{"file", "http", "http"}, metrics.schemes(metrics.observations, MetricFetchLatency))
+	assert.Equal(t, []string{"http"}, metrics.schemes(metrics.counters, MetricFetchErrors))
+}
+
+func (m *testMetrics) categories(name string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var categories []string
+	for _, metric := range m.counters {
+		if metric.name == name {
+			categories = append(categories, metric.labels[LabelCategory])
+		}
+	}
+	return categories
+}
+
+func TestMaterializeFailureCategories(t *testing.T) {
+
+	definition.SetLinkExprManagerFactory(&panicLinkExprManagerFactory{panicOn: 1})
+	defer definition.SetLinkExprManagerFactory(nil)
+
+	metrics := &testMetrics{}
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{Metrics: metrics})
+
+	err := fm.LoadResource(&resource.Config{ID: "flow", Data: []byte(testFlowJSON)})
+	assert.NotNil(t, err)
+
+	materializeErr, ok := err.(*MaterializeError)
+	assert.True(t, ok)
+	assert.Equal(t, FailureLinkExpr, materializeErr.Category)
+
+	err = fm.LoadResource(&resource.Config{ID: "invalid", Data: []byte(invalidFlowJSON)})
+	assert.NotNil(t, err)
+
+	unknownActivityJSON := strings.Replace(testFlowJSON, `"ref": "test-log"`, `"ref": "unknown-activity"`, 1)
+	err = fm.LoadResource(&resource.Config{ID: "unknown", Data: []byte(unknownActivityJSON)})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []string{FailureLinkExpr, FailureValidate, FailureActivityResolve}, metrics.categories(MetricMaterializeErrors))
+}
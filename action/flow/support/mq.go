@@ -0,0 +1,168 @@
+package support
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+const uriSchemeMQ = "mq://"
+
+// DefaultMQTimeout is the default duration a flow request waits for its reply
+const DefaultMQTimeout = 10 * time.Second
+
+// MQMessage is a message published to or received from a message queue
+type MQMessage struct {
+	// CorrelationID correlates a reply with its request
+	CorrelationID string
+
+	// ReplyTo is the topic the reply to a request should be published to
+	ReplyTo string
+
+	Payload []byte
+}
+
+// MQClient is a client of a message broker (ex. MQTT or AMQP)
+type MQClient interface {
+	// Publish publishes the message to the topic
+	Publish(topic string, msg *MQMessage) error
+
+	// Subscribe subscribes the handler to the messages published to the topic,
+	// the returned function cancels the subscription
+	Subscribe(topic string, handler func(msg *MQMessage)) (unsubscribe func(), err error)
+}
+
+// MQFlowProvider is a definition.Provider that gets flows with 'mq://<topic>/<flowId>'
+// uris by publishing a request for the flow to the topic and awaiting the reply
+type MQFlowProvider struct {
+	// Client is the broker client used to publish the requests and receive the replies
+	Client MQClient
+
+	// ReplyTopic is the topic the replies are published to, defaults to '<topic>/reply'
+	ReplyTopic string
+
+	// Timeout is the duration to wait for a reply, defaults to DefaultMQTimeout
+	Timeout time.Duration
+}
+
+// GetFlow implements definition.Provider.GetFlow
+func (p *MQFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+	return p.GetFlowWithContext(context.Background(), flowURI)
+}
+
+// GetFlowWithContext implements definition.ContextProvider.GetFlowWithContext
+func (p *MQFlowProvider) GetFlowWithContext(ctx context.Context, flowURI string) (*definition.DefinitionRep, error) {
+
+	topic, flowID, err := parseMQURI(flowURI)
+	if err != nil {
+		return nil, err
+	}
+
+	flowBytes, err := p.request(ctx, topic, flowID)
+	if err != nil {
+		reqErr := fmt.Errorf("error requesting flow with uri '%s', %s", flowURI, err.Error())
+		logger.Errorf(reqErr.Error())
+		return nil, reqErr
+	}
+
+	var flow *definition.DefinitionRep
+	err = jsonCodec.Unmarshal(flowBytes, &flow)
+	if err != nil {
+		logger.Errorf(err.Error())
+		return nil, fmt.Errorf("error marshalling flow with uri '%s', %s", flowURI, err.Error())
+	}
+
+	return flow, nil
+}
+
+// request publishes a request for the flow to the topic and waits for the correlated reply
+func (p *MQFlowProvider) request(ctx context.Context, topic, flowID string) ([]byte, error) {
+
+	if p.Client == nil {
+		return nil, errors.New("message queue client not configured")
+	}
+
+	correlationID, err := newCorrelationID()
+	if err != nil {
+		return nil, err
+	}
+
+	replyTopic := p.ReplyTopic
+	if replyTopic == "" {
+		replyTopic = topic + "/reply"
+	}
+
+	replies := make(chan []byte, 1)
+
+	unsubscribe, err := p.Client.Subscribe(replyTopic, func(msg *MQMessage) {
+		if msg.CorrelationID != correlationID {
+			return
+		}
+		select {
+		case replies <- msg.Payload:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer unsubscribe()
+
+	err = p.Client.Publish(topic, &MQMessage{CorrelationID: correlationID, ReplyTo: replyTopic, Payload: []byte(flowID)})
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultMQTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case reply := <-replies:
+		if len(reply) == 0 {
+			return nil, errors.New("flow not found")
+		}
+		return reply, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no reply received within %s", timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// parseMQURI splits a 'mq://<topic>/<flowId>' uri in its topic and flow id, the
+// topic can contain '/' separated levels
+func parseMQURI(flowURI string) (topic, flowID string, err error) {
+
+	if !strings.HasPrefix(flowURI, uriSchemeMQ) {
+		return "", "", fmt.Errorf("unsupported flow uri '%s'", flowURI)
+	}
+
+	path := flowURI[len(uriSchemeMQ):]
+
+	idx := strings.LastIndex(path, "/")
+	if idx <= 0 || idx == len(path)-1 {
+		return "", "", fmt.Errorf("invalid flow uri '%s', expected 'mq://<topic>/<flowId>'", flowURI)
+	}
+
+	return path[:idx], path[idx+1:], nil
+}
+
+func newCorrelationID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
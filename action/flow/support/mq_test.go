@@ -0,0 +1,119 @@
+package support
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBroker is an in-memory MQClient, requests are answered by the registered responders
+type fakeBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[int]func(msg *MQMessage)
+	nextID      int
+	flows       map[string]string
+	requests    []string
+}
+
+func newFakeBroker(flows map[string]string) *fakeBroker {
+	return &fakeBroker{subscribers: make(map[string]map[int]func(msg *MQMessage)), flows: flows}
+}
+
+func (b *fakeBroker) Publish(topic string, msg *MQMessage) error {
+
+	b.mu.Lock()
+	b.requests = append(b.requests, topic)
+	var handlers []func(msg *MQMessage)
+	for _, handler := range b.subscribers[topic] {
+		handlers = append(handlers, handler)
+	}
+	flow, exists := b.flows[string(msg.Payload)]
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+
+	// reply asynchronously like a remote responder would
+	if exists && msg.ReplyTo != "" {
+		go func() {
+			// a reply to another request is ignored
+			b.Publish(msg.ReplyTo, &MQMessage{CorrelationID: "other", Payload: []byte("{}")})
+			b.Publish(msg.ReplyTo, &MQMessage{CorrelationID: msg.CorrelationID, Payload: []byte(flow)})
+		}()
+	}
+
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(topic string, handler func(msg *MQMessage)) (func(), error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[int]func(msg *MQMessage))
+	}
+	id := b.nextID
+	b.nextID++
+	b.subscribers[topic][id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[topic], id)
+	}, nil
+}
+
+func TestMQFlowProvider(t *testing.T) {
+
+	broker := newFakeBroker(map[string]string{"orders": testFlowJSON})
+	provider := &MQFlowProvider{Client: broker, Timeout: time.Second}
+
+	rep, err := provider.GetFlow("mq://flows/registry/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, "flows/registry", broker.requests[0])
+
+	// the reply subscription is cancelled once the reply is received
+	broker.mu.Lock()
+	assert.Len(t, broker.subscribers["flows/registry/reply"], 0)
+	broker.mu.Unlock()
+}
+
+func TestMQFlowProviderTimeout(t *testing.T) {
+
+	provider := &MQFlowProvider{Client: newFakeBroker(nil), Timeout: 20 * time.Millisecond}
+
+	_, err := provider.GetFlow("mq://flows/orders")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no reply received within 20ms")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	provider.Timeout = time.Minute
+	_, err = provider.GetFlowWithContext(ctx, "mq://flows/orders")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "context canceled")
+}
+
+func TestParseMQURI(t *testing.T) {
+
+	topic, flowID, err := parseMQURI("mq://flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "flows", topic)
+	assert.Equal(t, "orders", flowID)
+
+	_, _, err = parseMQURI("mq://orders")
+	assert.NotNil(t, err)
+
+	_, _, err = parseMQURI("mq://flows/")
+	assert.NotNil(t, err)
+
+	_, _, err = parseMQURI("http://flows/orders")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,96 @@
+package support
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+const (
+	partNameFlow      = "flow"
+	partNameSignature = "signature"
+)
+
+// flowPart is the flow (and optional signature) extracted from a multipart response
+type flowPart struct {
+	flow      []byte
+	encoding  string
+	signature []byte
+}
+
+// isMultipart determines if the specified content type is a multipart content type
+func isMultipart(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "multipart/")
+}
+
+// extractMultipartFlow extracts the flow part and the optional signature part from
+// a multipart body.  The flow part is the part named 'flow', or else the first JSON
+// part, the signature part is either the part named 'signature' or a part
+// with a signature content type.
+func extractMultipartFlow(contentType string, body []byte) (*flowPart, error) {
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart boundary not specified")
+	}
+
+	result := &flowPart{}
+	// the part named flow is preferred over the first JSON part
+	foundFlow, foundNamed := false, false
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partBytes, err := ioutil.ReadAll(part)
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+		switch {
+		case part.FormName() == partNameSignature || strings.Contains(partType, "signature"):
+			result.signature = partBytes
+		case !foundNamed && part.FormName() == partNameFlow:
+			result.flow = partBytes
+			result.encoding = flowEncoding(part.Header.Get("flow-compressed"))
+			foundFlow, foundNamed = true, true
+		case !foundFlow && isJSONMediaType(partType):
+			result.flow = partBytes
+			result.encoding = flowEncoding(part.Header.Get("flow-compressed"))
+			foundFlow = true
+		}
+	}
+
+	if !foundFlow {
+		return nil, errors.New("multipart response does not contain a flow part")
+	}
+
+	return result, nil
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
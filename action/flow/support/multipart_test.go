@@ -0,0 +1,121 @@
+package support
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSignature = "test-signature"
+
+func newMultipartFlowServer(t *testing.T) *httptest.Server {
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	flowHeader := textproto.MIMEHeader{}
+	flowHeader.Set("Content-Disposition", `form-data; name="flow"`)
+	flowHeader.Set("Content-Type", "application/json")
+	part, err := writer.CreatePart(flowHeader)
+	assert.Nil(t, err)
+	part.Write([]byte(testFlowJSON))
+
+	sigHeader := textproto.MIMEHeader{}
+	sigHeader.Set("Content-Disposition", `form-data; name="signature"`)
+	sigHeader.Set("Content-Type", "application/pgp-signature")
+	part, err = writer.CreatePart(sigHeader)
+	assert.Nil(t, err)
+	part.Write([]byte(testSignature))
+
+	writer.Close()
+
+	contentType := writer.FormDataContentType()
+	body := buf.Bytes()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	}))
+}
+
+func TestGetFlowMultipart(t *testing.T) {
+
+	server := newMultipartFlowServer(t)
+	defer server.Close()
+
+	var verifiedFlow, verifiedSig []byte
+
+	provider := &BasicRemoteFlowProvider{}
+	provider.SignatureVerifier = func(flow []byte, signature []byte) error {
+		verifiedFlow = flow
+		verifiedSig = signature
+		return nil
+	}
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.NotNil(t, rep)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Len(t, rep.Tasks, 2)
+
+	assert.Equal(t, testFlowJSON, string(verifiedFlow))
+	assert.Equal(t, testSignature, string(verifiedSig))
+}
+
+func TestGetFlowMultipartInvalidSignature(t *testing.T) {
+
+	server := newMultipartFlowServer(t)
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{}
+	provider.SignatureVerifier = func(flow []byte, signature []byte) error {
+		return errors.New("invalid signature")
+	}
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Nil(t, rep)
+}
+
+// multipartBody writes the parts, each a name (empty for no name) and content
+func multipartBody(t *testing.T, parts ...[2]string) (string, []byte) {
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	for _, content := range parts {
+		header := textproto.MIMEHeader{}
+		if content[0] != "" {
+			header.Set("Content-Disposition", `form-data; name="`+content[0]+`"`)
+		}
+		header.Set("Content-Type", "application/json")
+		part, err := writer.CreatePart(header)
+		assert.Nil(t, err)
+		part.Write([]byte(content[1]))
+	}
+
+	writer.Close()
+
+	return writer.FormDataContentType(), buf.Bytes()
+}
+
+func TestExtractMultipartFlow(t *testing.T) {
+
+	// the part named flow is preferred over a preceding JSON part
+	contentType, body := multipartBody(t, [2]string{"metadata", `{"version": 2}`}, [2]string{"flow", testFlowJSON})
+	part, err := extractMultipartFlow(contentType, body)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(part.flow))
+
+	// the first JSON part is the flow when no part is named flow
+	contentType, body = multipartBody(t, [2]string{"", testFlowJSON}, [2]string{"metadata", `{"version": 2}`})
+	part, err = extractMultipartFlow(contentType, body)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(part.flow))
+}
@@ -0,0 +1,71 @@
+package support
+
+import (
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+// NameResolver resolves the logical name of a flow to its uri (ex. using a service registry)
+type NameResolver interface {
+	// ResolveName resolves the name to a flow uri, an empty uri is returned if the
+	// name is unknown
+	ResolveName(name string) (string, error)
+}
+
+// RegisterFlowName registers the uri of the flow with the specified logical name
+func (fm *FlowManager) RegisterFlowName(name string, uri string) {
+
+	fm.namesMu.Lock()
+	defer fm.namesMu.Unlock()
+
+	if fm.names == nil {
+		fm.names = make(map[string]string)
+	}
+	fm.names[name] = uri
+}
+
+// GetFlowByName gets the flow with the specified logical name, names which aren't
+// registered are resolved using the NameResolver of the manager and cached
+func (fm *FlowManager) GetFlowByName(name string) (*definition.Definition, error) {
+
+	uri, err := fm.resolveName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fm.GetFlow(uri)
+}
+
+func (fm *FlowManager) resolveName(name string) (string, error) {
+
+	fm.namesMu.Lock()
+	defer fm.namesMu.Unlock()
+
+	if uri, exists := fm.names[name]; exists {
+		return uri, nil
+	}
+
+	if uri, exists := fm.resolvedNames[name]; exists {
+		return uri, nil
+	}
+
+	if fm.nameResolver == nil {
+		return "", fmt.Errorf("flow name '%s' not registered", name)
+	}
+
+	uri, err := fm.nameResolver.ResolveName(name)
+	if err != nil {
+		return "", fmt.Errorf("error resolving flow name '%s', %s", name, err.Error())
+	}
+	if uri == "" {
+		return "", fmt.Errorf("flow name '%s' not found", name)
+	}
+
+	if fm.resolvedNames == nil {
+		fm.resolvedNames = make(map[string]string)
+	}
+	fm.resolvedNames[name] = uri
+
+	return uri, nil
+}
@@ -0,0 +1,48 @@
+package support
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// testNameResolver resolves names using a map, counting the resolutions
+type testNameResolver struct {
+	uris  map[string]string
+	calls int
+}
+
+func (r *testNameResolver) ResolveName(name string) (string, error) {
+	r.calls++
+	return r.uris[name], nil
+}
+
+func TestGetFlowByName(t *testing.T) {
+
+	resolver := &testNameResolver{uris: map[string]string{"orders": "res://orders_v2"}}
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{NameResolver: resolver})
+
+	for _, id := range []string{"orders_v1", "orders_v2"} {
+		err := fm.LoadResource(&resource.Config{ID: id, Data: []byte(testFlowJSON)})
+		assert.Nil(t, err)
+	}
+
+	fm.RegisterFlowName("legacyOrders", "res://orders_v1")
+
+	flow, err := fm.GetFlowByName("legacyOrders")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+	assert.Equal(t, 0, resolver.calls)
+
+	// unknown to the static registry, resolved and cached
+	for i := 0; i < 2; i++ {
+		flow, err = fm.GetFlowByName("orders")
+		assert.Nil(t, err)
+		assert.NotNil(t, flow)
+	}
+	assert.Equal(t, 1, resolver.calls)
+
+	_, err = fm.GetFlowByName("unknown")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,91 @@
+package support
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// getOverrideRep gets the flow from the override directory, overridden is false if
+// the directory doesn't have a file for the flow.  The override is verified like the
+// fetched flows when the manager has a verifier, with the detached signature taken
+// from the <file>.sig file if there is one.
+func (fm *FlowManager) getOverrideRep(uri string) (defRep *definition.DefinitionRep, overridden bool, err error) {
+
+	file := overrideFile(fm.overrideDir, uri)
+	if file == "" {
+		return nil, false, nil
+	}
+
+	flowDefBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		readErr := fmt.Errorf("error reading override of flow with uri '%s' from '%s', %s", uri, file, err.Error())
+		logger.Errorf(readErr.Error())
+		return nil, true, readErr
+	}
+
+	logger.Infof("Using local override '%s' of flow with uri '%s'", file, uri)
+
+	if isGzipped(flowDefBytes) {
+		flowDefBytes, err = unzip(flowDefBytes, fm.strictGzip)
+		if err != nil {
+			decompressErr := fmt.Errorf("error uncompressing override of flow with uri '%s', %s", uri, err.Error())
+			logger.Errorf(decompressErr.Error())
+			return nil, true, decompressErr
+		}
+	}
+
+	if fm.verifier != nil {
+		signature, err := ioutil.ReadFile(file + ".sig")
+		if err != nil && !os.IsNotExist(err) {
+			readErr := fmt.Errorf("error reading signature of override of flow with uri '%s' from '%s', %s", uri, file, err.Error())
+			logger.Errorf(readErr.Error())
+			return nil, true, readErr
+		}
+
+		flowDefBytes, err = fm.verifier.Open(uri, flowDefBytes, bytes.TrimSpace(signature))
+		if err != nil {
+			logger.Errorf(err.Error())
+			return nil, true, fmt.Errorf("refusing override '%s', %s", file, err.Error())
+		}
+	}
+
+	err = jsonCodec.Unmarshal(flowDefBytes, &defRep)
+	if err != nil {
+		logger.Errorf(err.Error())
+		return nil, true, fmt.Errorf("error marshalling override of flow with uri '%s', %s", uri, err.Error())
+	}
+
+	return defRep, true, nil
+}
+
+// overrideFile returns the file of the directory overriding the flow, an empty string
+// is returned if there is none
+func overrideFile(dir string, uri string) string {
+
+	name := uri
+	if u, err := url.Parse(uri); err == nil && u.Path != "" {
+		name = u.Path
+	}
+
+	name = path.Base(name)
+	if name == "." || name == "/" {
+		return ""
+	}
+
+	for _, candidate := range []string{name + ".json", name} {
+		file := filepath.Join(dir, candidate)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,76 @@
+package support
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFlowOverrideDir(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "overrides")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	localFlowJSON := strings.Replace(testFlowJSON, `"Test Flow"`, `"Local Flow"`, 1)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "orderFlow.json"), []byte(localFlowJSON), 0644))
+
+	provider := newTestFlowProvider(map[string]string{
+		"https://flows.example.com/flows/orderFlow":   testFlowJSON,
+		"https://flows.example.com/flows/paymentFlow": testFlowJSON,
+	})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{OverrideDir: dir})
+
+	// the local override shadows the remote flow
+	flow, err := fm.GetFlow("https://flows.example.com/flows/orderFlow")
+	assert.Nil(t, err)
+	assert.Equal(t, "Local Flow", flow.Name())
+	assert.Equal(t, 0, provider.callCount("https://flows.example.com/flows/orderFlow"))
+
+	// flows without an override are fetched from the provider
+	flow, err = fm.GetFlow("https://flows.example.com/flows/paymentFlow")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", flow.Name())
+	assert.Equal(t, 1, provider.callCount("https://flows.example.com/flows/paymentFlow"))
+
+	// an invalid override isn't silently replaced by the remote flow
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "brokenFlow"), []byte("{"), 0644))
+
+	_, err = fm.GetFlow("https://flows.example.com/flows/brokenFlow")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error marshalling override of flow with uri 'https://flows.example.com/flows/brokenFlow'")
+}
+
+func TestGetFlowOverrideDirVerified(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "overrides")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	verifier, key := newTestVerifier(t, true)
+
+	localFlowJSON := strings.Replace(testFlowJSON, `"Test Flow"`, `"Local Flow"`, 1)
+	header, _, signature := signES256(t, key, "flows", []byte(localFlowJSON))
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "orderFlow.json"), []byte(localFlowJSON), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "orderFlow.json.sig"), []byte(header+".."+signature+"\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "paymentFlow.json"), []byte(localFlowJSON), 0644))
+
+	provider := newTestFlowProvider(map[string]string{})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{OverrideDir: dir, Verifier: verifier})
+
+	// a signed override is used
+	flow, err := fm.GetFlow("https://flows.example.com/flows/orderFlow")
+	assert.Nil(t, err)
+	assert.Equal(t, "Local Flow", flow.Name())
+
+	// an unsigned override is refused by a strict verifier
+	_, err = fm.GetFlow("https://flows.example.com/flows/paymentFlow")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not signed")
+	assert.Equal(t, 0, provider.callCount("https://flows.example.com/flows/paymentFlow"))
+}
@@ -0,0 +1,53 @@
+package support
+
+import (
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+// Stage is a stage of the flow post-processing pipeline (ex. migrate, interpolate, validate)
+type Stage interface {
+	// Process processes the flow definition, returning the processed definition.  An error
+	// stops the pipeline and the flow fails to load.
+	Process(rep *definition.DefinitionRep) (*definition.DefinitionRep, error)
+}
+
+// StageFunc is an adapter to allow the use of a function as a Stage
+type StageFunc func(rep *definition.DefinitionRep) (*definition.DefinitionRep, error)
+
+// Process implements Stage.Process
+func (f StageFunc) Process(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+	return f(rep)
+}
+
+// Pipeline is an ordered set of stages run against each flow during load, before it is materialized
+type Pipeline struct {
+	stages []Stage
+}
+
+// NewPipeline creates a pipeline running the specified stages in order
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// AddStage adds a stage to the end of the pipeline
+func (p *Pipeline) AddStage(stage Stage) {
+	p.stages = append(p.stages, stage)
+}
+
+// Run runs the stages of the pipeline in order against the flow definition, stopping
+// at the first stage that returns an error
+func (p *Pipeline) Run(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+
+	var err error
+
+	for i, stage := range p.stages {
+		rep, err = stage.Process(rep)
+		if err != nil {
+			return nil, fmt.Errorf("error processing flow in stage %d, %s", i, err.Error())
+		}
+	}
+
+	return rep, nil
+}
@@ -0,0 +1,58 @@
+package support
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func recordingStage(name string, order *[]string, err error) Stage {
+	return StageFunc(func(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+		*order = append(*order, name)
+		if err != nil {
+			return nil, err
+		}
+		rep.Name = rep.Name + "+" + name
+		return rep, nil
+	})
+}
+
+func TestPipelineOrder(t *testing.T) {
+
+	var order []string
+
+	pipeline := NewPipeline(recordingStage("migrate", &order, nil), recordingStage("interpolate", &order, nil))
+	pipeline.AddStage(recordingStage("validate", &order, nil))
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{Pipeline: pipeline})
+
+	err := fm.LoadResource(&resource.Config{ID: "flow1", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"migrate", "interpolate", "validate"}, order)
+
+	flow := fm.GetResource("flow1").(*definition.Definition)
+	assert.Equal(t, "Test Flow+migrate+interpolate+validate", flow.Name())
+}
+
+func TestPipelineShortCircuit(t *testing.T) {
+
+	var order []string
+
+	pipeline := NewPipeline(
+		recordingStage("migrate", &order, nil),
+		recordingStage("validate", &order, errors.New("invalid flow")),
+		recordingStage("fold", &order, nil),
+	)
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{Pipeline: pipeline})
+
+	err := fm.LoadResource(&resource.Config{ID: "flow1", Data: []byte(testFlowJSON)})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []string{"migrate", "validate"}, order)
+	assert.Nil(t, fm.GetResource("flow1"))
+}
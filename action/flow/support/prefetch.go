@@ -0,0 +1,63 @@
+package support
+
+import (
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// prefetchSubflows queues the subflows of the accessed flow to be fetched in the
+// background, the subflows of a flow are only queued the first time it is accessed
+func (fm *FlowManager) prefetchSubflows(uri string, flow *definition.Definition) {
+
+	fm.prefetchMu.Lock()
+	defer fm.prefetchMu.Unlock()
+
+	if fm.prefetched[uri] == flow {
+		return
+	}
+
+	if fm.prefetched == nil {
+		fm.prefetched = make(map[string]*definition.Definition)
+		fm.prefetchPending = make(map[string]bool)
+	}
+	fm.prefetched[uri] = flow
+
+	for _, subflowURI := range flow.SubflowURIs() {
+		if fm.prefetchPending[subflowURI] {
+			continue
+		}
+		fm.prefetchPending[subflowURI] = true
+		fm.prefetchQueue = append(fm.prefetchQueue, subflowURI)
+	}
+
+	if len(fm.prefetchQueue) > 0 && !fm.prefetching {
+		fm.prefetching = true
+		go fm.runPrefetch()
+	}
+}
+
+// runPrefetch fetches the queued subflows until the queue is empty
+func (fm *FlowManager) runPrefetch() {
+
+	for {
+		fm.prefetchMu.Lock()
+		if len(fm.prefetchQueue) == 0 {
+			fm.prefetching = false
+			fm.prefetchMu.Unlock()
+			return
+		}
+		uri := fm.prefetchQueue[0]
+		fm.prefetchQueue = fm.prefetchQueue[1:]
+		fm.prefetchMu.Unlock()
+
+		logger.Debugf("Prefetching subflow with uri '%s'", uri)
+
+		if _, err := fm.GetFlow(uri); err != nil {
+			logger.Warnf("Unable to prefetch subflow with uri '%s': %s", uri, err.Error())
+		}
+
+		fm.prefetchMu.Lock()
+		delete(fm.prefetchPending, uri)
+		fm.prefetchMu.Unlock()
+	}
+}
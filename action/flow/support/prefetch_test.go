@@ -0,0 +1,100 @@
+package support
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/activity"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	activity.Register(newTestSubflowActivity())
+}
+
+type testSubflowActivity struct {
+	metadata *activity.Metadata
+}
+
+func newTestSubflowActivity() activity.Activity {
+	metadata := &activity.Metadata{ID: "test-subflow"}
+	metadata.Settings = map[string]*data.Attribute{
+		"flowURI": data.NewZeroAttribute("flowURI", data.TypeString),
+	}
+	return &testSubflowActivity{metadata: metadata}
+}
+
+func (a *testSubflowActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *testSubflowActivity) Eval(context activity.Context) (done bool, err error) {
+	return true, nil
+}
+
+const parentFlowJSON = `{
+  "name": "Parent Flow",
+  "model": "test",
+  "tasks": [
+    {
+      "id": "start_child",
+      "activity": {
+        "ref": "test-subflow",
+        "settings": {
+          "flowURI": "http://flows/child"
+        }
+      }
+    }
+  ]
+}`
+
+// waitForCalls waits for the flow to be requested from the provider the specified number of times
+func waitForCalls(provider *testFlowProvider, uri string, calls int) bool {
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if provider.callCount(uri) >= calls {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestGetFlowPrefetchSubflows(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/parent": parentFlowJSON,
+		// the child starts the parent, the cycle stops at the cached parent
+		"http://flows/child": strings.Replace(strings.Replace(parentFlowJSON, "Parent Flow", "Child Flow", 1), "http://flows/child", "http://flows/parent", 1),
+	})
+
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{PrefetchSubflows: true})
+
+	flow, err := fm.GetFlow("http://flows/parent")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://flows/child"}, flow.SubflowURIs())
+
+	assert.True(t, waitForCalls(provider, "http://flows/child", 1))
+
+	// the prefetched subflow is served from the cache
+	child, err := fm.GetFlow("http://flows/child")
+	assert.Nil(t, err)
+	assert.Equal(t, "Child Flow", child.Name())
+	assert.Equal(t, 1, provider.callCount("http://flows/child"))
+	assert.Equal(t, 1, provider.callCount("http://flows/parent"))
+}
+
+func TestGetFlowNoPrefetch(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/parent": parentFlowJSON})
+	fm := NewFlowManager(provider)
+
+	_, err := fm.GetFlow("http://flows/parent")
+	assert.Nil(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 0, provider.callCount("http://flows/child"))
+}
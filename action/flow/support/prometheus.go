@@ -0,0 +1,205 @@
+package support
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default upper bounds, in seconds, of the buckets of the histograms
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics is a Metrics keeping the metrics in memory and exposing them in the
+// Prometheus text format.  Counters are recorded using Inc and histograms using Observe,
+// it is an http.Handler meant to be mounted on the metrics endpoint scraped by Prometheus.
+type PrometheusMetrics struct {
+	mu         sync.Mutex
+	buckets    []float64
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusMetrics creates a PrometheusMetrics whose histograms have the specified
+// bucket upper bounds, DefaultBuckets are used if not specified
+func NewPrometheusMetrics(buckets []float64) *PrometheusMetrics {
+
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &PrometheusMetrics{
+		buckets:    sorted,
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+// Observe implements Metrics.Observe
+func (m *PrometheusMetrics) Observe(name string, labels map[string]string, value float64) {
+
+	key := formatLabels(labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, exists := m.histograms[name]
+	if !exists {
+		series = make(map[string]*histogram)
+		m.histograms[name] = series
+	}
+
+	h, exists := series[key]
+	if !exists {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		series[key] = h
+	}
+
+	for i, bound := range m.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// Inc implements Metrics.Inc
+func (m *PrometheusMetrics) Inc(name string, labels map[string]string) {
+
+	key := formatLabels(labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, exists := m.counters[name]
+	if !exists {
+		series = make(map[string]float64)
+		m.counters[name] = series
+	}
+
+	series[key]++
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+
+	var buf bytes.Buffer
+
+	m.mu.Lock()
+
+	for _, name := range sortedKeys(m.counters) {
+		fmt.Fprintf(&buf, "# TYPE %s counter\n", name)
+
+		series := m.counters[name]
+		keys := make([]string, 0, len(series))
+		for key := range series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fmt.Fprintf(&buf, "%s%s %s\n", name, wrapLabels(key), formatValue(series[key]))
+		}
+	}
+
+	for _, name := range sortedKeys(m.histograms) {
+		fmt.Fprintf(&buf, "# TYPE %s histogram\n", name)
+
+		series := m.histograms[name]
+		keys := make([]string, 0, len(series))
+		for key := range series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			h := series[key]
+			for i, bound := range m.buckets {
+				fmt.Fprintf(&buf, "%s_bucket%s %d\n", name, wrapLabels(joinLabels(key, `le="`+formatValue(bound)+`"`)), h.counts[i])
+			}
+			fmt.Fprintf(&buf, "%s_bucket%s %d\n", name, wrapLabels(joinLabels(key, `le="+Inf"`)), h.count)
+			fmt.Fprintf(&buf, "%s_sum%s %s\n", name, wrapLabels(key), formatValue(h.sum))
+			fmt.Fprintf(&buf, "%s_count%s %d\n", name, wrapLabels(key), h.count)
+		}
+	}
+
+	m.mu.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP, serving the metrics in the Prometheus text format
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// formatLabels formats the labels sorted by name (ex. flow="orders",status="failed")
+func formatLabels(labels map[string]string) string {
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(labels[name]) + `"`
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func joinLabels(labels string, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+
+	var keys []string
+
+	switch series := m.(type) {
+	case map[string]map[string]float64:
+		for key := range series {
+			keys = append(keys, key)
+		}
+	case map[string]map[string]*histogram:
+		for key := range series {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
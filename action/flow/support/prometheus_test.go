@@ -0,0 +1,51 @@
+package support
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+
+	metrics := NewPrometheusMetrics([]float64{1, 0.1})
+
+	metrics.Inc(MetricInstancesStarted, map[string]string{LabelFlow: "orders"})
+	metrics.Inc(MetricInstancesStarted, map[string]string{LabelFlow: "orders"})
+	metrics.Inc(MetricInstancesFailed, map[string]string{LabelFlow: `say "hi"`})
+	metrics.Observe(MetricActivityDuration, map[string]string{LabelFlow: "orders", LabelActivity: "log"}, 0.05)
+	metrics.Observe(MetricActivityDuration, map[string]string{LabelActivity: "log", LabelFlow: "orders"}, 0.5)
+	metrics.Observe(MetricWorkQueueDepth, nil, 2)
+
+	buf := &bytes.Buffer{}
+	_, err := metrics.WriteTo(buf)
+	assert.Nil(t, err)
+
+	expected := `# TYPE flow_instances_failed_total counter
+flow_instances_failed_total{flow="say \"hi\""} 1
+# TYPE flow_instances_started_total counter
+flow_instances_started_total{flow="orders"} 2
+# TYPE flow_activity_duration_seconds histogram
+flow_activity_duration_seconds_bucket{activity="log",flow="orders",le="0.1"} 1
+flow_activity_duration_seconds_bucket{activity="log",flow="orders",le="1"} 2
+flow_activity_duration_seconds_bucket{activity="log",flow="orders",le="+Inf"} 2
+flow_activity_duration_seconds_sum{activity="log",flow="orders"} 0.55
+flow_activity_duration_seconds_count{activity="log",flow="orders"} 2
+# TYPE flow_work_queue_depth histogram
+flow_work_queue_depth_bucket{le="0.1"} 0
+flow_work_queue_depth_bucket{le="1"} 0
+flow_work_queue_depth_bucket{le="+Inf"} 1
+flow_work_queue_depth_sum 2
+flow_work_queue_depth_count 1
+`
+	assert.Equal(t, expected, buf.String())
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+	assert.Equal(t, expected, recorder.Body.String())
+}
@@ -0,0 +1,55 @@
+package support
+
+import (
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// RawFlowProvider is an optional interface of a flow provider returning the raw flow
+// bytes and whether they are gzip compressed.  The manager decodes the flow itself,
+// trusting the compressed flag instead of sniffing the bytes.
+type RawFlowProvider interface {
+	GetFlowBytes(flowURI string) (data []byte, compressed bool, err error)
+}
+
+// getRawFlowRep gets and decodes the flow from a raw flow provider, verifying its
+// signature if a verifier is specified.  Data trailing a gzipped flow is rejected if strict.
+func getRawFlowRep(provider RawFlowProvider, flowURI string, verifier *FlowVerifier, strict bool) (*definition.DefinitionRep, error) {
+
+	flowDefBytes, compressed, err := provider.GetFlowBytes(flowURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if compressed {
+		flowDefBytes, err = unzip(flowDefBytes, strict)
+		if err != nil {
+			decompressErr := uncompressError(flowURI, err)
+			logger.Errorf(decompressErr.Error())
+			return nil, decompressErr
+		}
+	}
+
+	if len(flowDefBytes) == 0 {
+		return nil, nil
+	}
+
+	if verifier != nil {
+		flowDefBytes, err = verifier.Open(flowURI, flowDefBytes, nil)
+		if err != nil {
+			logger.Errorf(err.Error())
+			return nil, err
+		}
+	}
+
+	var flow *definition.DefinitionRep
+	err = jsonCodec.Unmarshal(flowDefBytes, &flow)
+	if err != nil {
+		logger.Errorf(err.Error())
+		return nil, &decodeError{err: fmt.Errorf("error marshalling flow with uri '%s', %s", flowURI, err.Error())}
+	}
+
+	return flow, nil
+}
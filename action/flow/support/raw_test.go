@@ -0,0 +1,46 @@
+package support
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRawFlowProvider serves the flow bytes with the declared compression
+type testRawFlowProvider struct {
+	data       []byte
+	compressed bool
+}
+
+func (p *testRawFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+	return nil, errors.New("raw flow provider should be used")
+}
+
+func (p *testRawFlowProvider) GetFlowBytes(flowURI string) ([]byte, bool, error) {
+	return p.data, p.compressed, nil
+}
+
+func TestGetFlowRawFlowProvider(t *testing.T) {
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(testFlowJSON))
+	w.Close()
+
+	fm := NewFlowManager(&testRawFlowProvider{data: buf.Bytes(), compressed: true})
+
+	flow, err := fm.GetFlow("http://flows/compressed")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", flow.Name())
+
+	// the declared compression is trusted, the bytes aren't sniffed
+	fm = NewFlowManager(&testRawFlowProvider{data: buf.Bytes(), compressed: false})
+
+	_, err = fm.GetFlow("http://flows/undeclared")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error marshalling flow with uri 'http://flows/undeclared'")
+}
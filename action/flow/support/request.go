@@ -0,0 +1,54 @@
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// FlowQuery is the flow uri a JSON query is built from by a QueryBuilder
+type FlowQuery struct {
+	URI    string     `json:"uri"`
+	Scheme string     `json:"scheme"`
+	Host   string     `json:"host"`
+	Path   string     `json:"path"`
+	Query  url.Values `json:"query,omitempty"`
+}
+
+// QueryBuilder builds the JSON query to POST to get the flow, the returned value is
+// marshalled to JSON (ex. a struct or a map)
+type QueryBuilder func(query *FlowQuery) (interface{}, error)
+
+// newRequest creates the request used to get the flow with the specified uri, a
+// POST with the built query as body if a query builder is configured and a GET
+// otherwise
+func (p *BasicRemoteFlowProvider) newRequest(flowURI string) (*http.Request, error) {
+
+	if p.Query == nil {
+		return http.NewRequest("GET", flowURI, nil)
+	}
+
+	u, err := url.Parse(flowURI)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := p.Query(&FlowQuery{URI: flowURI, Scheme: u.Scheme, Host: u.Host, Path: u.Path, Query: u.Query()})
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", flowURI, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
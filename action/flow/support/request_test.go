@@ -0,0 +1,389 @@
+package support
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFlowPostQuery(t *testing.T) {
+
+	var method, body string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		body = string(reqBody)
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	type flowQuery struct {
+		Flow    string `json:"flow"`
+		Version string `json:"version"`
+	}
+
+	provider := &BasicRemoteFlowProvider{Query: func(query *FlowQuery) (interface{}, error) {
+		return map[string]interface{}{"query": &flowQuery{Flow: query.Path, Version: query.Query.Get("version")}}, nil
+	}}
+
+	rep, err := provider.GetFlow(server.URL + "/flows/orders?version=2")
+	assert.Nil(t, err)
+	assert.NotNil(t, rep)
+	assert.Equal(t, "Test Flow", rep.Name)
+
+	assert.Equal(t, "POST", method)
+	assert.Equal(t, `{"query":{"flow":"/flows/orders","version":"2"}}`, body)
+
+	// the values of the uri are escaped
+	_, err = provider.GetFlow(server.URL + `/flows/orders?version=2","admin":"true`)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"query":{"flow":"/flows/orders","version":"2\",\"admin\":\"true"}}`, body)
+
+	// the uri is posted if the builder returns it
+	provider.Query = func(query *FlowQuery) (interface{}, error) { return query, nil }
+
+	_, err = provider.GetFlow(server.URL + "/flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"uri":"`+server.URL+`/flows/orders","scheme":"http","host":"`+strings.TrimPrefix(server.URL, "http://")+`","path":"/flows/orders"}`, body)
+}
+
+func TestGetFlowGetByDefault(t *testing.T) {
+
+	var method string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{}
+
+	_, err := provider.GetFlow(server.URL + "/flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "GET", method)
+}
+
+func newFlakyFlowServer(calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		if *calls == 1 {
+			// truncated flow
+			w.Write([]byte(testFlowJSON[:len(testFlowJSON)/2]))
+			return
+		}
+		w.Write([]byte(testFlowJSON))
+	}))
+}
+
+func TestGetFlowRetryOnDecodeError(t *testing.T) {
+
+	calls := 0
+	server := newFlakyFlowServer(&calls)
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{RetryOnDecodeError: true}
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.NotNil(t, rep)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, 2, calls)
+}
+
+func TestGetFlowNoRetryOnDecodeError(t *testing.T) {
+
+	calls := 0
+	server := newFlakyFlowServer(&calls)
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{}
+
+	_, err := provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestGetFlowRequireHTTPS(t *testing.T) {
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testFlowJSON))
+	})
+
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	httpsServer := httptest.NewTLSServer(handler)
+	defer httpsServer.Close()
+
+	provider := &BasicRemoteFlowProvider{RequireHTTPS: true, client: httpsServer.Client()}
+
+	_, err := provider.GetFlow(httpServer.URL)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "https is required")
+
+	rep, err := provider.GetFlow(httpsServer.URL)
+	assert.Nil(t, err)
+	assert.NotNil(t, rep)
+}
+
+// newResettingFlowServer creates a server that drops the connection midway through
+// the body of the first n responses
+func newResettingFlowServer(resets int, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		if *calls <= resets {
+			w.Header().Set("Content-Length", strconv.Itoa(len(testFlowJSON)))
+			w.Write([]byte(testFlowJSON[:len(testFlowJSON)/2]))
+			w.(http.Flusher).Flush()
+
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(testFlowJSON))
+	}))
+}
+
+func TestGetFlowRetryOnReadError(t *testing.T) {
+
+	calls := 0
+	server := newResettingFlowServer(2, &calls)
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{MaxReadRetries: 2}
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, 3, calls)
+}
+
+func TestGetFlowReadRetriesExhausted(t *testing.T) {
+
+	calls := 0
+	server := newResettingFlowServer(2, &calls)
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{MaxReadRetries: 1}
+
+	_, err := provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestGetFlowRetryDecider(t *testing.T) {
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	// error status codes aren't retried by default
+	provider := &BasicRemoteFlowProvider{MaxReadRetries: 2}
+
+	_, err := provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	provider.RetryDecider = func(resp *http.Response, err error) bool {
+		return resp != nil && resp.StatusCode == http.StatusNotImplemented
+	}
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, 2, calls)
+
+	// the decider also classifies the read errors
+	resets := 0
+	resetServer := newResettingFlowServer(1, &resets)
+	defer resetServer.Close()
+
+	provider.RetryDecider = func(resp *http.Response, err error) bool {
+		return false
+	}
+
+	_, err = provider.GetFlow(resetServer.URL)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, resets)
+}
+
+func TestNewBasicRemoteFlowProvider(t *testing.T) {
+
+	var authorization, apiKey string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		apiKey = r.Header.Get("X-Api-Key")
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	provider := NewBasicRemoteFlowProvider(nil, map[string]string{"Authorization": "Bearer token", "X-Api-Key": "key"})
+	assert.Equal(t, DefaultFetchTimeout, provider.httpClient().Timeout)
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.NotNil(t, rep)
+	assert.Equal(t, "Bearer token", authorization)
+	assert.Equal(t, "key", apiKey)
+
+	client := &http.Client{Timeout: time.Second}
+	assert.True(t, NewBasicRemoteFlowProvider(client, nil).httpClient() == client)
+
+	// the zero value provider uses the default client
+	assert.Equal(t, DefaultFetchTimeout, (&BasicRemoteFlowProvider{}).httpClient().Timeout)
+}
+
+func TestGetFlowTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	provider := NewBasicRemoteFlowProvider(&http.Client{Timeout: 50 * time.Millisecond}, nil)
+
+	_, err := provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+}
+
+// newInterruptingRangeServer creates a server supporting range requests that drops
+// the connection midway through the body of the first response
+func newInterruptingRangeServer(ranges *[]string) *httptest.Server {
+	calls := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"v1"`)
+
+		if calls == 1 {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(testFlowJSON)))
+			w.Write([]byte(testFlowJSON[:len(testFlowJSON)/3]))
+			w.(http.Flusher).Flush()
+
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "flow.json", time.Time{}, strings.NewReader(testFlowJSON))
+	}))
+}
+
+func TestGetFlowResumeDownload(t *testing.T) {
+
+	var ranges []string
+	server := newInterruptingRangeServer(&ranges)
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{MaxResumes: 1}
+
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.NotNil(t, rep)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, []string{"bytes=" + strconv.Itoa(len(testFlowJSON)/3) + "-"}, ranges)
+}
+
+func TestGetFlowResumeDisabled(t *testing.T) {
+
+	var ranges []string
+	server := newInterruptingRangeServer(&ranges)
+	defer server.Close()
+
+	_, err := (&BasicRemoteFlowProvider{}).GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Len(t, ranges, 0)
+}
+
+func TestGetFlowContentEncodingGzip(t *testing.T) {
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(testFlowJSON))
+	w.Close()
+	gzipped := buf.Bytes()
+
+	encoded, err := EncodeAndZip([]byte(testFlowJSON))
+	assert.Nil(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.URL.Path == "/flows/both" {
+			w.Header().Set("flow-compressed", "true")
+			w.Write([]byte(encoded))
+			return
+		}
+		w.Write(gzipped)
+	}))
+	defer server.Close()
+
+	// keep the client from transparently uncompressing the body
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	provider := NewBasicRemoteFlowProvider(client, nil)
+
+	rep, err := provider.GetFlow(server.URL + "/flows/raw")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+
+	rep, err = provider.GetFlow(server.URL + "/flows/both")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+}
+
+func TestGetFlowTruncatedGzip(t *testing.T) {
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(testFlowJSON))
+	w.Close()
+	gzipped := buf.Bytes()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Write(gzipped[:len(gzipped)/2])
+			return
+		}
+		w.Write(gzipped)
+	}))
+	defer server.Close()
+
+	provider := &BasicRemoteFlowProvider{}
+
+	_, err := provider.GetFlow(server.URL + "/flows/orders")
+	assert.NotNil(t, err)
+	truncatedErr, ok := err.(*TruncatedFlowError)
+	assert.True(t, ok)
+	assert.Equal(t, server.URL+"/flows/orders", truncatedErr.URI)
+
+	calls = 0
+	provider = &BasicRemoteFlowProvider{RetryOnTruncation: true}
+
+	rep, err := provider.GetFlow(server.URL + "/flows/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,101 @@
+package support
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// CompressResource returns a copy of the resource config with its flow compressed
+// (gzipped and base64 encoded), an already compressed config is returned as is
+func CompressResource(config *resource.Config) *resource.Config {
+
+	if config == nil || config.Compressed {
+		return config
+	}
+
+	encoded, err := EncodeAndZip(config.Data)
+	if err != nil {
+		logger.Errorf("error compressing resource with id '%s', %s", config.ID, err.Error())
+		return config
+	}
+
+	// the encoded flow is stored as a JSON string so the config remains valid JSON
+	data, _ := json.Marshal(encoded)
+
+	return &resource.Config{ID: config.ID, Compressed: true, Data: data}
+}
+
+// DecompressResource returns a copy of the resource config with its flow decompressed,
+// an uncompressed config is returned as is
+func DecompressResource(config *resource.Config) (*resource.Config, error) {
+
+	if config == nil || !config.Compressed {
+		return config, nil
+	}
+
+	encoding, encoded := resourceEncoding(config.Data)
+	decoded, err := decodeAndUncompress(encoding, encoded, false)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding compressed resource with id '%s', %s", config.ID, err.Error())
+	}
+
+	return &resource.Config{ID: config.ID, Data: decoded}, nil
+}
+
+// compressedData gets the encoded flow of a compressed resource, which is either a
+// JSON string or the bare base64 encoded flow
+func compressedData(data json.RawMessage) string {
+
+	var encoded string
+	if len(data) > 0 && data[0] == '"' && json.Unmarshal(data, &encoded) == nil {
+		return encoded
+	}
+
+	return string(data)
+}
+
+// CompressionStats are the sizes in bytes of a flow resource before and after compression
+type CompressionStats struct {
+	Original int
+	Encoded  int
+}
+
+// Ratio returns the ratio of the encoded size to the original size (ex. 0.25 when
+// compression saved 75% of the space)
+func (s *CompressionStats) Ratio() float64 {
+	if s.Original == 0 {
+		return 0
+	}
+	return float64(s.Encoded) / float64(s.Original)
+}
+
+// ResourceCompressionStats returns the size of the flow of the resource config before
+// and after compression, an uncompressed config is compressed to compute its stats
+func ResourceCompressionStats(config *resource.Config) (*CompressionStats, error) {
+
+	if config == nil {
+		return nil, errors.New("resource config not provided")
+	}
+
+	if config.Compressed {
+		encoding, encoded := resourceEncoding(config.Data)
+
+		decoded, err := decodeAndUncompress(encoding, encoded, false)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding compressed resource with id '%s', %s", config.ID, err.Error())
+		}
+
+		return &CompressionStats{Original: len(decoded), Encoded: len(encoded)}, nil
+	}
+
+	encoded, err := EncodeAndZip(config.Data)
+	if err != nil {
+		return nil, fmt.Errorf("error compressing resource with id '%s', %s", config.ID, err.Error())
+	}
+
+	return &CompressionStats{Original: len(config.Data), Encoded: len(encoded)}, nil
+}
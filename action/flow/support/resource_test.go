@@ -0,0 +1,73 @@
+package support
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressResourceRoundTrip(t *testing.T) {
+
+	config := &resource.Config{ID: "flow", Data: []byte(testFlowJSON)}
+
+	compressed := CompressResource(config)
+	assert.True(t, compressed.Compressed)
+	assert.Equal(t, "flow", compressed.ID)
+
+	// the compressed config is still valid JSON
+	_, err := json.Marshal(compressed)
+	assert.Nil(t, err)
+
+	// compressing twice is a no-op
+	assert.Equal(t, compressed, CompressResource(compressed))
+
+	decompressed, err := DecompressResource(compressed)
+	assert.Nil(t, err)
+	assert.False(t, decompressed.Compressed)
+	assert.Equal(t, testFlowJSON, string(decompressed.Data))
+
+	uncompressed, err := DecompressResource(decompressed)
+	assert.Nil(t, err)
+	assert.Equal(t, decompressed, uncompressed)
+}
+
+func TestLoadCompressedResource(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	err := fm.LoadResource(CompressResource(&resource.Config{ID: "flow", Data: []byte(testFlowJSON)}))
+	assert.Nil(t, err)
+
+	flow, err := fm.GetFlow("res://flow")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", flow.Name())
+}
+
+func TestDecompressResourceInvalid(t *testing.T) {
+
+	_, err := DecompressResource(&resource.Config{ID: "flow", Compressed: true, Data: []byte(`"not compressed"`)})
+	assert.NotNil(t, err)
+}
+
+func TestResourceCompressionStats(t *testing.T) {
+
+	flowJSON := []byte(testFlowJSON)
+	config := &resource.Config{ID: "flow", Data: flowJSON}
+
+	encoded, err := EncodeAndZip(flowJSON)
+	assert.Nil(t, err)
+
+	stats, err := ResourceCompressionStats(config)
+	assert.Nil(t, err)
+	assert.Equal(t, len(flowJSON), stats.Original)
+	assert.Equal(t, len(encoded), stats.Encoded)
+	assert.InDelta(t, float64(len(encoded))/float64(len(flowJSON)), stats.Ratio(), 0.0001)
+	assert.True(t, stats.Ratio() < 1)
+
+	// the stats of the compressed config are the same
+	compressedStats, err := ResourceCompressionStats(CompressResource(config))
+	assert.Nil(t, err)
+	assert.Equal(t, stats, compressedStats)
+}
@@ -0,0 +1,124 @@
+package support
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+// SchemeResolver resolves the raw, possibly gzipped, flow with the specified uri
+type SchemeResolver func(uri string) ([]byte, error)
+
+var (
+	schemeResolversMu sync.RWMutex
+	schemeResolvers   = make(map[string]SchemeResolver)
+)
+
+// RegisterSchemeResolver registers the resolver used by BasicRemoteFlowProvider to get
+// the flows with uris using the specified scheme (ex. "s3" or "s3://"), replacing any
+// resolver previously registered for it, a nil resolver unregisters it.  The file and
+// http(s) schemes are resolved by the built-in resolvers of the provider unless a
+// resolver is registered for them.
+func RegisterSchemeResolver(scheme string, resolver SchemeResolver) {
+
+	schemeResolversMu.Lock()
+	defer schemeResolversMu.Unlock()
+
+	if resolver == nil {
+		delete(schemeResolvers, normalizeScheme(scheme))
+		return
+	}
+	schemeResolvers[normalizeScheme(scheme)] = resolver
+}
+
+// getSchemeResolver gets the resolver registered for the specified scheme
+func getSchemeResolver(scheme string) (SchemeResolver, bool) {
+
+	schemeResolversMu.RLock()
+	defer schemeResolversMu.RUnlock()
+
+	resolver, exists := schemeResolvers[normalizeScheme(scheme)]
+	return resolver, exists
+}
+
+var (
+	flowProvidersMu sync.RWMutex
+	flowProviders   = make(map[string]definition.Provider)
+)
+
+// RegisterFlowProvider registers the provider used by the FlowManagers to get the flows
+// with uris using the specified scheme (ex. "git" or "git://"), replacing any provider
+// previously registered for it, a nil provider unregisters it.  The flows with uris
+// using other schemes are retrieved using the provider of the manager.
+func RegisterFlowProvider(scheme string, provider definition.Provider) {
+
+	flowProvidersMu.Lock()
+	defer flowProvidersMu.Unlock()
+
+	if provider == nil {
+		delete(flowProviders, normalizeScheme(scheme))
+		return
+	}
+	flowProviders[normalizeScheme(scheme)] = provider
+}
+
+// getFlowProvider gets the provider registered for the specified scheme
+func getFlowProvider(scheme string) (definition.Provider, bool) {
+
+	flowProvidersMu.RLock()
+	defer flowProvidersMu.RUnlock()
+
+	provider, exists := flowProviders[normalizeScheme(scheme)]
+	return provider, exists
+}
+
+// provider gets the provider of the flow uri, a provider registered for the scheme
+// of the uri takes precedence over the provider of the manager
+func (fm *FlowManager) provider(uri string) definition.Provider {
+
+	if scheme := uriScheme(uri); scheme != "" {
+		if provider, exists := getFlowProvider(scheme); exists {
+			return provider
+		}
+	}
+
+	return fm.flowProvider
+}
+
+// resolver gets the resolver for the scheme of the flow uri, a registered resolver
+// takes precedence over a built-in one
+func (p *BasicRemoteFlowProvider) resolver(flowURI string) (SchemeResolver, error) {
+
+	scheme := normalizeScheme(uriScheme(flowURI))
+
+	if resolver, exists := getSchemeResolver(scheme); exists {
+		return resolver, nil
+	}
+
+	switch scheme {
+	case "file":
+		return p.getFileFlow, nil
+	case "http", "https":
+		return p.getHTTPFlow, nil
+	}
+
+	return nil, fmt.Errorf("no resolver registered for scheme '%s://'", scheme)
+}
+
+// verifiedOnFetch determines if the flows with the specified uri are verified when they
+// are fetched, which is the case of the http flows unless their scheme was overridden
+func (p *BasicRemoteFlowProvider) verifiedOnFetch(flowURI string) bool {
+
+	scheme := normalizeScheme(uriScheme(flowURI))
+	if _, exists := getSchemeResolver(scheme); exists {
+		return false
+	}
+
+	return scheme == "http" || scheme == "https"
+}
+
+func normalizeScheme(scheme string) string {
+	return strings.ToLower(strings.TrimSuffix(scheme, "://"))
+}
@@ -0,0 +1,130 @@
+package support
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterSchemeResolver(t *testing.T) {
+
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	w.Write([]byte(testFlowJSON))
+	w.Close()
+
+	flows := map[string][]byte{
+		"mem://flows/plain":   []byte(testFlowJSON),
+		"mem://flows/gzipped": gzipped.Bytes(),
+	}
+
+	RegisterSchemeResolver("mem://", func(uri string) ([]byte, error) {
+		flow, exists := flows[uri]
+		if !exists {
+			return nil, fmt.Errorf("flow '%s' not found", uri)
+		}
+		return flow, nil
+	})
+	defer RegisterSchemeResolver("mem", nil)
+
+	provider := &BasicRemoteFlowProvider{}
+
+	for _, uri := range []string{"mem://flows/plain", "mem://flows/gzipped"} {
+		rep, err := provider.GetFlow(uri)
+		assert.Nil(t, err)
+		assert.Equal(t, "Test Flow", rep.Name)
+	}
+
+	_, err := provider.GetFlow("mem://flows/missing")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "flow 'mem://flows/missing' not found")
+}
+
+func TestGetFlowUnknownScheme(t *testing.T) {
+
+	_, err := (&BasicRemoteFlowProvider{}).GetFlow("s3://bucket/flow.json")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no resolver registered for scheme 's3://'")
+}
+
+func TestRegisterSchemeResolverOverridesBuiltin(t *testing.T) {
+
+	RegisterSchemeResolver("file", func(uri string) ([]byte, error) {
+		return []byte(testFlowJSON), nil
+	})
+	defer RegisterSchemeResolver("file", nil)
+
+	rep, err := (&BasicRemoteFlowProvider{}).GetFlow("file:///does/not/exist.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+}
+
+func TestGetFlowCompiler(t *testing.T) {
+
+	// the stub source language is 'name = <flow name>'
+	source := map[string][]byte{"mem://flows/source": []byte("name = Compiled Flow")}
+
+	RegisterSchemeResolver("mem", func(uri string) ([]byte, error) {
+		return source[uri], nil
+	})
+	defer RegisterSchemeResolver("mem", nil)
+
+	var compiledURI string
+
+	provider := &BasicRemoteFlowProvider{
+		Compiler: func(flowURI string, src []byte) ([]byte, error) {
+			compiledURI = flowURI
+			parts := strings.SplitN(string(src), " = ", 2)
+			if len(parts) != 2 || parts[0] != "name" {
+				return nil, errors.New("syntax error")
+			}
+			return []byte(strings.Replace(testFlowJSON, "Test Flow", parts[1], 1)), nil
+		},
+	}
+
+	rep, err := provider.GetFlow("mem://flows/source")
+	assert.Nil(t, err)
+	assert.Equal(t, "Compiled Flow", rep.Name)
+	assert.Equal(t, "mem://flows/source", compiledURI)
+
+	source["mem://flows/invalid"] = []byte("flow: invalid")
+
+	_, err = provider.GetFlow("mem://flows/invalid")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error compiling flow with uri 'mem://flows/invalid', syntax error")
+}
+
+func TestRegisterFlowProvider(t *testing.T) {
+
+	gitProvider := newTestFlowProvider(map[string]string{"git://flows/orderFlow": testFlowJSON})
+
+	RegisterFlowProvider("git://", gitProvider)
+	defer RegisterFlowProvider("git", nil)
+
+	defaultProvider := newTestFlowProvider(map[string]string{"http://flows/orderFlow": testFlowJSON})
+	fm := NewFlowManager(defaultProvider)
+
+	flow, err := fm.GetFlow("git://flows/orderFlow")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+	assert.Equal(t, 1, gitProvider.callCount("git://flows/orderFlow"))
+	assert.Equal(t, 0, defaultProvider.callCount("git://flows/orderFlow"))
+
+	// the other schemes fall back to the provider of the manager
+	flow, err = fm.GetFlow("http://flows/orderFlow")
+	assert.Nil(t, err)
+	assert.NotNil(t, flow)
+	assert.Equal(t, 1, defaultProvider.callCount("http://flows/orderFlow"))
+	assert.Equal(t, 0, gitProvider.callCount("http://flows/orderFlow"))
+
+	RegisterFlowProvider("GIT", nil)
+
+	_, err = fm.GetFlow("git://flows/paymentFlow")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, defaultProvider.callCount("git://flows/paymentFlow"))
+}
@@ -0,0 +1,94 @@
+package support
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// URLSigner produces a fresh signed url (ex. a presigned S3 or GCS url) for the flow
+// with the specified logical id
+type URLSigner func(id string) (string, error)
+
+// SignedURLProvider is a definition.Provider that gets flows stored behind expiring
+// signed urls, a fresh url is signed for each fetch
+type SignedURLProvider struct {
+	// Signer signs the url of the flow with the logical id used as uri
+	Signer URLSigner
+
+	// Fetcher fetches the flows from the signed urls, a BasicRemoteFlowProvider with
+	// the default client is used if not set
+	Fetcher *BasicRemoteFlowProvider
+}
+
+// NewSignedURLProvider creates a SignedURLProvider using the specified signer
+func NewSignedURLProvider(signer URLSigner) *SignedURLProvider {
+	return &SignedURLProvider{Signer: signer}
+}
+
+// GetFlow implements definition.Provider.GetFlow
+func (p *SignedURLProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	if p.Signer == nil {
+		return nil, fmt.Errorf("unable to get flow with uri '%s', signer not configured", flowURI)
+	}
+
+	signedURL, err := p.Signer(flowURI)
+	if err != nil {
+		signErr := fmt.Errorf("error signing url of flow with uri '%s', %s", flowURI, err.Error())
+		logger.Errorf(signErr.Error())
+		return nil, signErr
+	}
+
+	if !strings.HasPrefix(signedURL, uriSchemeHttp) && !strings.HasPrefix(signedURL, "https://") {
+		return nil, fmt.Errorf("unable to get flow with uri '%s', signed url isn't an http url", flowURI)
+	}
+
+	fetcher := p.Fetcher
+	if fetcher == nil {
+		fetcher = &BasicRemoteFlowProvider{}
+	}
+
+	// the fetcher redacts the credentials of the signed url before reporting it
+	flow, err := fetcher.GetFlow(signedURL)
+	if err != nil {
+		return nil, fmt.Errorf("error getting flow with uri '%s' from signed url, %s", flowURI, err.Error())
+	}
+
+	return flow, nil
+}
+
+// redactURI removes the credentials from the uri so it can be logged, the user info and
+// the query (ex. the signature of a presigned url) are redacted
+func redactURI(uri string) string {
+
+	u, err := url.Parse(uri)
+	if err != nil || (u.User == nil && u.RawQuery == "") {
+		return uri
+	}
+
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("redacted")
+	}
+	if redacted.RawQuery != "" {
+		redacted.RawQuery = "redacted"
+	}
+
+	return redacted.String()
+}
+
+// redactURLError redacts the url reported by the error of a request
+func redactURLError(err error) error {
+
+	if urlErr, ok := err.(*url.Error); ok {
+		redacted := *urlErr
+		redacted.URL = redactURI(urlErr.URL)
+		return &redacted
+	}
+
+	return err
+}
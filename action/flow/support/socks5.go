@@ -0,0 +1,172 @@
+package support
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+const (
+	socks5Version         = 0x05
+	socks5AuthNone        = 0x00
+	socks5AuthPassword    = 0x02
+	socks5AuthUnsupported = 0xff
+	socks5CmdConnect      = 0x01
+	socks5AddrIPv4        = 0x01
+	socks5AddrDomain      = 0x03
+	socks5AddrIPv6        = 0x04
+)
+
+// SOCKS5Proxy is a SOCKS5 proxy the flows are fetched through (RFC 1928), only
+// the CONNECT command is supported
+type SOCKS5Proxy struct {
+	// Address is the host:port of the proxy
+	Address string
+
+	// Username and Password are used to authenticate with the proxy (RFC 1929),
+	// no authentication is used if Username isn't set
+	Username string
+	Password string
+}
+
+// Dial connects to the address through the proxy
+func (s *SOCKS5Proxy) Dial(network, addr string) (net.Conn, error) {
+
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("socks5: unsupported network '%s'", network)
+	}
+
+	conn, err := net.Dial("tcp", s.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.connect(conn, addr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// transport creates a transport which dials through the proxy
+func (s *SOCKS5Proxy) transport() *http.Transport {
+	return &http.Transport{Dial: s.Dial}
+}
+
+func (s *SOCKS5Proxy) connect(conn net.Conn, addr string) error {
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 0xffff {
+		return fmt.Errorf("socks5: invalid port '%s'", portStr)
+	}
+
+	method := byte(socks5AuthNone)
+	if s.Username != "" {
+		method = socks5AuthPassword
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("socks5: unexpected protocol version %d", reply[0])
+	}
+	if reply[1] != method {
+		return errors.New("socks5: no acceptable authentication method")
+	}
+
+	if method == socks5AuthPassword {
+		if err := s.authenticate(conn); err != nil {
+			return err
+		}
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5AddrIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5AddrIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("socks5: host name too long '%s'", host)
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		return fmt.Errorf("socks5: connect to '%s' failed, reply code %d", addr, header[1])
+	}
+
+	// discard the bound address
+	var addrLen int
+	switch header[3] {
+	case socks5AddrIPv4:
+		addrLen = net.IPv4len
+	case socks5AddrIPv6:
+		addrLen = net.IPv6len
+	case socks5AddrDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return err
+		}
+		addrLen = int(l[0])
+	default:
+		return fmt.Errorf("socks5: unknown address type %d", header[3])
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
+
+func (s *SOCKS5Proxy) authenticate(conn net.Conn) error {
+
+	if len(s.Username) > 255 || len(s.Password) > 255 {
+		return errors.New("socks5: username or password too long")
+	}
+
+	req := []byte{0x01, byte(len(s.Username))}
+	req = append(req, s.Username...)
+	req = append(req, byte(len(s.Password)))
+	req = append(req, s.Password...)
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return errors.New("socks5: authentication failed")
+	}
+
+	return nil
+}
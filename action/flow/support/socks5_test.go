@@ -0,0 +1,149 @@
+package support
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSOCKS5Proxy starts a minimal SOCKS5 proxy supporting CONNECT with
+// either no authentication or the specified username/password
+func newTestSOCKS5Proxy(t *testing.T, username, password string, connects *int32) net.Listener {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSOCKS5(conn, username, password, connects)
+		}
+	}()
+
+	return l
+}
+
+func serveTestSOCKS5(conn net.Conn, username, password string, connects *int32) {
+	defer conn.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+
+	method := byte(socks5AuthNone)
+	if username != "" {
+		method = socks5AuthPassword
+	}
+	if methods[0] != method {
+		conn.Write([]byte{socks5Version, socks5AuthUnsupported})
+		return
+	}
+	conn.Write([]byte{socks5Version, method})
+
+	if method == socks5AuthPassword {
+		buf := make([]byte, 2)
+		io.ReadFull(conn, buf)
+		user := make([]byte, buf[1])
+		io.ReadFull(conn, user)
+		io.ReadFull(conn, buf[:1])
+		pass := make([]byte, buf[0])
+		io.ReadFull(conn, pass)
+		if string(user) != username || string(pass) != password {
+			conn.Write([]byte{0x01, 0x01})
+			return
+		}
+		conn.Write([]byte{0x01, 0x00})
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+
+	var host string
+	switch req[3] {
+	case socks5AddrIPv4:
+		ip := make([]byte, net.IPv4len)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case socks5AddrDomain:
+		l := make([]byte, 1)
+		io.ReadFull(conn, l)
+		name := make([]byte, l[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))))
+	if err != nil {
+		conn.Write([]byte{socks5Version, 0x05, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+
+	atomic.AddInt32(connects, 1)
+	conn.Write([]byte{socks5Version, 0, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func newTestFlowServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testFlowJSON))
+	}))
+}
+
+func TestGetFlowThroughSOCKS5Proxy(t *testing.T) {
+
+	server := newTestFlowServer()
+	defer server.Close()
+
+	var connects int32
+	proxy := newTestSOCKS5Proxy(t, "", "", &connects)
+	defer proxy.Close()
+
+	provider := &BasicRemoteFlowProvider{Proxy: &SOCKS5Proxy{Address: proxy.Addr().String()}}
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connects))
+}
+
+func TestGetFlowThroughSOCKS5ProxyWithAuth(t *testing.T) {
+
+	server := newTestFlowServer()
+	defer server.Close()
+
+	var connects int32
+	proxy := newTestSOCKS5Proxy(t, "flogo", "secret", &connects)
+	defer proxy.Close()
+
+	provider := &BasicRemoteFlowProvider{Proxy: &SOCKS5Proxy{Address: proxy.Addr().String(), Username: "flogo", Password: "secret"}}
+	rep, err := provider.GetFlow(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", rep.Name)
+
+	provider = &BasicRemoteFlowProvider{Proxy: &SOCKS5Proxy{Address: proxy.Addr().String(), Username: "flogo", Password: "wrong"}}
+	_, err = provider.GetFlow(server.URL)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connects))
+}
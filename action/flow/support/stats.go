@@ -0,0 +1,47 @@
+package support
+
+import (
+	"time"
+)
+
+// flowStats are the access statistics of a flow
+type flowStats struct {
+	lastAccess time.Time
+	hits       int64
+}
+
+// recordAccess records a successful access to the flow with the specified uri
+func (fm *FlowManager) recordAccess(uri string) {
+
+	fm.statsMu.Lock()
+	defer fm.statsMu.Unlock()
+
+	if fm.stats == nil {
+		fm.stats = make(map[string]*flowStats)
+	}
+
+	stats, exists := fm.stats[uri]
+	if !exists {
+		stats = &flowStats{}
+		fm.stats[uri] = stats
+	}
+
+	stats.lastAccess = fm.now()
+	stats.hits++
+}
+
+// FlowStats returns the time the flow with the specified uri was last successfully
+// requested and the number of times it was requested (ex. to tune the cache TTL).
+// False is returned if the flow was never requested.
+func (fm *FlowManager) FlowStats(uri string) (lastAccess time.Time, hits int64, ok bool) {
+
+	fm.statsMu.Lock()
+	defer fm.statsMu.Unlock()
+
+	stats, exists := fm.stats[uri]
+	if !exists {
+		return time.Time{}, 0, false
+	}
+
+	return stats.lastAccess, stats.hits, true
+}
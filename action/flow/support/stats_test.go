@@ -0,0 +1,47 @@
+package support
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowStats(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+	fm := NewFlowManager(provider)
+
+	now := time.Now()
+	fm.now = func() time.Time { return now }
+
+	_, _, ok := fm.FlowStats("http://flows/flow")
+	assert.False(t, ok)
+
+	fm.GetFlow("http://flows/flow")
+
+	lastAccess, hits, ok := fm.FlowStats("http://flows/flow")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), hits)
+	assert.Equal(t, now, lastAccess)
+
+	now = now.Add(time.Minute)
+	fm.GetFlow("http://flows/flow")
+
+	lastAccess, hits, _ = fm.FlowStats("http://flows/flow")
+	assert.Equal(t, int64(2), hits)
+	assert.Equal(t, now, lastAccess)
+
+	fm.LoadResource(&resource.Config{ID: "flow1", Data: []byte(testFlowJSON)})
+	fm.GetFlow("res://flow1")
+
+	_, hits, ok = fm.FlowStats("res://flow1")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), hits)
+
+	// failed requests aren't recorded
+	fm.GetFlow("http://flows/missing")
+	_, _, ok = fm.FlowStats("http://flows/missing")
+	assert.False(t, ok)
+}
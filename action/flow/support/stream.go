@@ -0,0 +1,102 @@
+package support
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// ChunkStream is a stream of flow chunks, the client side of a streaming
+// rpc (ex. a gRPC server-streaming client) can be adapted to it
+type ChunkStream interface {
+	// Recv receives the next chunk of the flow, io.EOF is returned when the
+	// whole flow has been received
+	Recv() ([]byte, error)
+}
+
+// StreamFlowProvider is a definition.Provider that gets flows that are delivered
+// as a stream of chunks, used for very large flows
+type StreamFlowProvider struct {
+	// Open opens the chunk stream for the flow with the specified uri, the context is
+	// cancelled once the flow is received or the provider stops receiving it, so the
+	// stream must be bound to it (ex. the context of a gRPC stream)
+	Open func(ctx context.Context, flowURI string) (ChunkStream, error)
+
+	// MaxSize is the maximum size in bytes of a reassembled flow, 0 means no limit
+	MaxSize int
+
+	// StrictGzip indicates if data trailing the gzip stream of a flow (ex. padding) is
+	// rejected, it is ignored if not set
+	StrictGzip bool
+}
+
+// GetFlow implements definition.Provider.GetFlow
+func (p *StreamFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+	return p.GetFlowWithContext(context.Background(), flowURI)
+}
+
+// GetFlowWithContext implements definition.ContextProvider.GetFlowWithContext
+func (p *StreamFlowProvider) GetFlowWithContext(ctx context.Context, flowURI string) (*definition.DefinitionRep, error) {
+
+	// releases the stream when it isn't received until its end
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := p.Open(ctx, flowURI)
+	if err != nil {
+		openErr := fmt.Errorf("error opening flow stream with uri '%s', %s", flowURI, err.Error())
+		logger.Errorf(openErr.Error())
+		return nil, openErr
+	}
+
+	var buf bytes.Buffer
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			recvErr := fmt.Errorf("error receiving flow stream with uri '%s', %s", flowURI, err.Error())
+			logger.Errorf(recvErr.Error())
+			return nil, recvErr
+		}
+
+		buf.Write(chunk)
+
+		if p.MaxSize > 0 && buf.Len() > p.MaxSize {
+			sizeErr := fmt.Errorf("flow stream with uri '%s' exceeds the maximum size of %d bytes", flowURI, p.MaxSize)
+			logger.Errorf(sizeErr.Error())
+			return nil, sizeErr
+		}
+	}
+
+	flowDefBytes := buf.Bytes()
+
+	if isGzipped(flowDefBytes) {
+		flowDefBytes, err = unzip(flowDefBytes, p.StrictGzip)
+		if err != nil {
+			decompressErr := uncompressError(flowURI, err)
+			logger.Errorf(decompressErr.Error())
+			return nil, decompressErr
+		}
+	}
+
+	var flow *definition.DefinitionRep
+	err = jsonCodec.Unmarshal(flowDefBytes, &flow)
+	if err != nil {
+		logger.Errorf(err.Error())
+		return nil, &decodeError{err: fmt.Errorf("error marshalling flow with uri '%s', %s", flowURI, err.Error())}
+	}
+
+	return flow, nil
+}
+
+// isGzipped determines if the bytes start with the gzip magic number
+func isGzipped(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b
+}
@@ -0,0 +1,172 @@
+package support
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// flowStreamServer is a gRPC server streaming the flows in chunks of 16 bytes, the uri
+// of the flow is the request and the chunks are the replies
+type flowStreamServer struct {
+	flows map[string][]byte
+	// released is closed once a stream ended before the whole flow was sent
+	released chan struct{}
+}
+
+func (s *flowStreamServer) getFlow(srv interface{}, stream grpc.ServerStream) error {
+
+	var uri wrappers.StringValue
+	if err := stream.RecvMsg(&uri); err != nil {
+		return err
+	}
+
+	flow, exists := s.flows[uri.Value]
+	if !exists {
+		return status.Errorf(codes.NotFound, "flow '%s' not found", uri.Value)
+	}
+
+	for start := 0; start < len(flow); start += 16 {
+		end := start + 16
+		if end > len(flow) {
+			end = len(flow)
+		}
+		if err := stream.SendMsg(&wrappers.BytesValue{Value: flow[start:end]}); err != nil {
+			close(s.released)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// grpcChunkStream adapts the client side of the flow stream to a ChunkStream
+type grpcChunkStream struct {
+	grpc.ClientStream
+}
+
+func (s *grpcChunkStream) Recv() ([]byte, error) {
+	var chunk wrappers.BytesValue
+	if err := s.RecvMsg(&chunk); err != nil {
+		return nil, err
+	}
+	return chunk.Value, nil
+}
+
+// startFlowStreamServer starts the flow stream server on an in-memory connection,
+// returning a provider getting the flows from it
+func startFlowStreamServer(t *testing.T, flowServer *flowStreamServer) (*StreamFlowProvider, func()) {
+
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "flows.FlowService",
+		HandlerType: (*interface{})(nil),
+		Streams:     []grpc.StreamDesc{{StreamName: "GetFlow", Handler: flowServer.getFlow, ServerStreams: true}},
+	}, flowServer)
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+
+	dialer := func(string, time.Duration) (net.Conn, error) { return listener.Dial() }
+	conn, err := grpc.Dial("bufconn", grpc.WithDialer(dialer), grpc.WithInsecure())
+	assert.Nil(t, err)
+
+	provider := &StreamFlowProvider{}
+	provider.Open = func(ctx context.Context, flowURI string) (ChunkStream, error) {
+
+		stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/flows.FlowService/GetFlow")
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.SendMsg(&wrappers.StringValue{Value: flowURI}); err != nil {
+			return nil, err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return nil, err
+		}
+
+		return &grpcChunkStream{stream}, nil
+	}
+
+	return provider, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(b)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestStreamFlowProvider(t *testing.T) {
+
+	compressed := gzipBytes(t, []byte(testFlowJSON))
+
+	provider, stop := startFlowStreamServer(t, &flowStreamServer{flows: map[string][]byte{
+		"grpc://flows/plain":      []byte(testFlowJSON),
+		"grpc://flows/compressed": compressed,
+		"grpc://flows/padded":     append(compressed, 0, 0, 0, 0),
+		"grpc://flows/invalid":    []byte(`{"name": `),
+	}})
+	defer stop()
+
+	for _, uri := range []string{"grpc://flows/plain", "grpc://flows/compressed", "grpc://flows/padded"} {
+		rep, err := provider.GetFlow(uri)
+		assert.Nil(t, err)
+		if assert.NotNil(t, rep) {
+			assert.Equal(t, "Test Flow", rep.Name)
+			assert.Len(t, rep.Tasks, 2)
+		}
+	}
+
+	_, err := provider.GetFlow("grpc://flows/missing")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "flow 'grpc://flows/missing' not found")
+
+	_, err = provider.GetFlow("grpc://flows/invalid")
+	assert.IsType(t, &decodeError{}, err)
+
+	// the data trailing the gzip stream is rejected when strict
+	provider.StrictGzip = true
+	_, err = provider.GetFlow("grpc://flows/padded")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unexpected 4 bytes of data after the gzip stream")
+}
+
+func TestStreamFlowProviderMaxSize(t *testing.T) {
+
+	// the flow is larger than the flow control window, so the stream is released
+	// while it is being sent
+	large := bytes.Repeat([]byte(testFlowJSON), 4096)
+
+	flowServer := &flowStreamServer{flows: map[string][]byte{"grpc://flows/large": large}, released: make(chan struct{})}
+	provider, stop := startFlowStreamServer(t, flowServer)
+	defer stop()
+
+	provider.MaxSize = 64
+	_, err := provider.GetFlow("grpc://flows/large")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "exceeds the maximum size of 64 bytes")
+
+	// the stream is released once the provider stops receiving it
+	select {
+	case <-flowServer.released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not released")
+	}
+}
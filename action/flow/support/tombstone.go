@@ -0,0 +1,121 @@
+package support
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+// tombstone is the record of a deleted flow, kept so the flow can be restored
+type tombstone struct {
+	flow    *definition.Definition
+	rep     *definition.DefinitionRep
+	deleted time.Time
+}
+
+// DeleteFlow soft deletes the flow with the specified uri, leaving a tombstone so
+// the flow can be restored using RestoreFlow within the retention window.  A deleted
+// remote flow isn't fetched again until it is restored or its tombstone expires.
+func (fm *FlowManager) DeleteFlow(uri string) error {
+
+	var flow *definition.Definition
+	var rep *definition.DefinitionRep
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		id := uri[len(uriSchemeRes):]
+		flow, rep = fm.removeResFlow(id)
+	} else {
+		fm.rfMu.Lock()
+		if entry, exists := fm.remoteFlows[uri]; exists {
+			flow, rep = entry.flow, entry.rep
+			delete(fm.remoteFlows, uri)
+		}
+		fm.rfMu.Unlock()
+		fm.dropVersions(uri)
+	}
+
+	if flow == nil {
+		return fmt.Errorf("flow not found for uri '%s'", uri)
+	}
+
+	fm.tombMu.Lock()
+	defer fm.tombMu.Unlock()
+
+	if fm.tombstones == nil {
+		fm.tombstones = make(map[string]*tombstone)
+	}
+	fm.tombstones[uri] = &tombstone{flow: flow, rep: rep, deleted: fm.now()}
+
+	return nil
+}
+
+// RestoreFlow restores the deleted flow with the specified uri, a flow can only be
+// restored within the retention window
+func (fm *FlowManager) RestoreFlow(uri string) error {
+
+	fm.tombMu.Lock()
+	tomb := fm.liveTombstone(uri)
+	delete(fm.tombstones, uri)
+	fm.tombMu.Unlock()
+
+	if tomb == nil {
+		return fmt.Errorf("deleted flow not found for uri '%s'", uri)
+	}
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		id := uri[len(uriSchemeRes):]
+		fm.setResFlow(id, tomb.flow, tomb.rep)
+		return nil
+	}
+
+	fm.rfMu.Lock()
+	defer fm.rfMu.Unlock()
+
+	if fm.remoteFlows == nil {
+		fm.remoteFlows = make(map[string]*cacheEntry)
+	}
+	fm.cacheRemoteFlow(uri, fm.newCacheEntry(tomb.flow, tomb.rep, fm.cacheConfig.TTL))
+
+	return nil
+}
+
+// Tombstone determines if the flow with the specified uri was deleted and is still
+// within the retention window, returning the time it was deleted
+func (fm *FlowManager) Tombstone(uri string) (deleted time.Time, exists bool) {
+
+	fm.tombMu.Lock()
+	defer fm.tombMu.Unlock()
+
+	if tomb := fm.liveTombstone(uri); tomb != nil {
+		return tomb.deleted, true
+	}
+
+	return time.Time{}, false
+}
+
+func (fm *FlowManager) clearTombstone(uri string) {
+
+	fm.tombMu.Lock()
+	defer fm.tombMu.Unlock()
+
+	delete(fm.tombstones, uri)
+}
+
+// liveTombstone gets the tombstone of the flow, purging it if the retention window
+// has passed, tombMu must be held by the caller
+func (fm *FlowManager) liveTombstone(uri string) *tombstone {
+
+	tomb, exists := fm.tombstones[uri]
+	if !exists {
+		return nil
+	}
+
+	if fm.tombstoneRetention > 0 && !fm.now().Before(tomb.deleted.Add(fm.tombstoneRetention)) {
+		delete(fm.tombstones, uri)
+		return nil
+	}
+
+	return tomb
+}
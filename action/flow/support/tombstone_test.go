@@ -0,0 +1,86 @@
+package support
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAndRestoreFlow(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{TombstoneRetention: time.Hour})
+
+	err := fm.LoadResource(&resource.Config{ID: "flow", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+	_, err = fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+
+	for _, uri := range []string{"res://flow", "http://flows/flow"} {
+		assert.Nil(t, fm.DeleteFlow(uri))
+
+		_, deleted := fm.Tombstone(uri)
+		assert.True(t, deleted)
+	}
+
+	flow, _ := fm.GetFlow("res://flow")
+	assert.Nil(t, flow)
+
+	// deleted remote flows aren't fetched again
+	_, err = fm.GetFlow("http://flows/flow")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, provider.callCount("http://flows/flow"))
+
+	for _, uri := range []string{"res://flow", "http://flows/flow"} {
+		assert.Nil(t, fm.RestoreFlow(uri))
+
+		_, deleted := fm.Tombstone(uri)
+		assert.False(t, deleted)
+
+		flow, err := fm.GetFlow(uri)
+		assert.Nil(t, err)
+		assert.NotNil(t, flow)
+	}
+
+	assert.Equal(t, 1, provider.callCount("http://flows/flow"))
+	assert.NotNil(t, fm.DeleteFlow("res://missing"))
+}
+
+func TestTombstoneExpiry(t *testing.T) {
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{TombstoneRetention: time.Hour})
+
+	now := time.Now()
+	fm.now = func() time.Time { return now }
+
+	err := fm.LoadResource(&resource.Config{ID: "flow", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+	assert.Nil(t, fm.DeleteFlow("res://flow"))
+
+	now = now.Add(2 * time.Hour)
+
+	_, deleted := fm.Tombstone("res://flow")
+	assert.False(t, deleted)
+	assert.NotNil(t, fm.RestoreFlow("res://flow"))
+}
+
+func TestLoadDeletedFlow(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	err := fm.LoadResource(&resource.Config{ID: "flow", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+	assert.Nil(t, fm.DeleteFlow("res://flow"))
+
+	_, deleted := fm.Tombstone("res://flow")
+	assert.True(t, deleted)
+
+	// loading the flow again clears its tombstone
+	err = fm.LoadResource(&resource.Config{ID: "flow", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+
+	_, deleted = fm.Tombstone("res://flow")
+	assert.False(t, deleted)
+}
@@ -0,0 +1,80 @@
+package support
+
+import (
+	"sort"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+)
+
+// markAccessed records that the embedded flow with the specified id was requested
+func (fm *FlowManager) markAccessed(id string) {
+
+	fm.accessMu.Lock()
+	defer fm.accessMu.Unlock()
+
+	if fm.resAccessed == nil {
+		fm.resAccessed = make(map[string]bool)
+	}
+	fm.resAccessed[id] = true
+}
+
+// UnusedResources returns the sorted ids of the embedded flows which were loaded
+// but never requested
+func (fm *FlowManager) UnusedResources() []string {
+
+	fm.accessMu.Lock()
+	defer fm.accessMu.Unlock()
+
+	fm.resMu.RLock()
+	defer fm.resMu.RUnlock()
+
+	var unused []string
+	for id := range fm.resFlows {
+		if !fm.resAccessed[id] {
+			unused = append(unused, id)
+		}
+	}
+	for id := range fm.lazyFlows {
+		if !fm.resAccessed[id] {
+			unused = append(unused, id)
+		}
+	}
+
+	sort.Strings(unused)
+	return unused
+}
+
+// AllActivityRefs returns the sorted, distinct refs of the activities used by the
+// embedded flows, including those used by their error handlers
+func (fm *FlowManager) AllActivityRefs() []string {
+
+	found := make(map[string]bool)
+
+	addRefs := func(tasks []*definition.Task) {
+		for _, task := range tasks {
+			if activityCfg := task.ActivityConfig(); activityCfg != nil && activityCfg.Activity != nil {
+				found[activityCfg.Ref()] = true
+			}
+		}
+	}
+
+	fm.materializeAll()
+
+	fm.resMu.RLock()
+	defer fm.resMu.RUnlock()
+
+	for _, flow := range fm.resFlows {
+		addRefs(flow.Tasks())
+		if errorHandler := flow.GetErrorHandler(); errorHandler != nil {
+			addRefs(errorHandler.Tasks())
+		}
+	}
+
+	refs := make([]string, 0, len(found))
+	for ref := range found {
+		refs = append(refs, ref)
+	}
+
+	sort.Strings(refs)
+	return refs
+}
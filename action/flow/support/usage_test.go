@@ -0,0 +1,54 @@
+package support
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnusedResources(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	for _, id := range []string{"orders", "payments", "refunds", "shipping"} {
+		err := fm.LoadResource(&resource.Config{ID: id, Data: []byte(testFlowJSON)})
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, []string{"orders", "payments", "refunds", "shipping"}, fm.UnusedResources())
+
+	_, err := fm.GetFlow("res://orders")
+	assert.Nil(t, err)
+	assert.NotNil(t, fm.GetResource("refunds"))
+
+	assert.Equal(t, []string{"payments", "shipping"}, fm.UnusedResources())
+}
+
+func TestAllActivityRefs(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+	assert.Len(t, fm.AllActivityRefs(), 0)
+
+	counterFlowJSON := `{
+  "name": "Counter Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "counter_1", "activity": { "ref": "test-counter", "input": { "counterName": "orders" } } }
+  ],
+  "errorHandler": {
+    "tasks": [
+      { "id": "rest_1", "activity": { "ref": "test-rest", "input": { "uri": "http://errors.example.com" } } }
+    ]
+  }
+}`
+
+	err := fm.LoadResource(&resource.Config{ID: "log", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+	err = fm.LoadResource(&resource.Config{ID: "counter", Data: []byte(counterFlowJSON)})
+	assert.Nil(t, err)
+	err = fm.LoadResource(&resource.Config{ID: "rest", Data: []byte(restFlowJSON)})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"test-counter", "test-log", "test-rest"}, fm.AllActivityRefs())
+}
@@ -0,0 +1,506 @@
+package support
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/core/activity"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// repValidator validates a flow definition representation, returning all the problems found
+type repValidator func(rep *definition.DefinitionRep) []error
+
+// repValidators are the validators run against a flow before it is materialized
+var repValidators = []repValidator{
+	validateRepTasks,
+	validateRepLinks,
+	validateRepGraph,
+	validateRepMappings,
+	validateRepConcurrency,
+}
+
+// ValidationError is a problem found validating a flow, identifying the task or
+// link of the flow with the problem
+type ValidationError struct {
+	// TaskID is the id of the task with the problem, empty if it isn't about a task
+	TaskID string
+
+	// Link is the index of the link with the problem, -1 if it isn't about a link
+	Link int
+
+	// ErrorHandler indicates if the task or link belongs to the error handler
+	ErrorHandler bool
+
+	// Msg describes the problem
+	Msg string
+
+	// unresolvedActivity indicates if the problem is an activity which can't be resolved
+	unresolvedActivity bool
+}
+
+func (e *ValidationError) Error() string {
+
+	if e.TaskID != "" {
+		return fmt.Sprintf("task '%s': %s", e.TaskID, e.Msg)
+	}
+	if e.Link >= 0 {
+		return fmt.Sprintf("link[%d]: %s", e.Link, e.Msg)
+	}
+	return e.Msg
+}
+
+func taskError(taskID string, errorHandler bool, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{TaskID: taskID, Link: -1, ErrorHandler: errorHandler, Msg: fmt.Sprintf(format, args...)}
+}
+
+func linkError(link int, errorHandler bool, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Link: link, ErrorHandler: errorHandler, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ValidateRep validates the flow definition representation using the same validators
+// used when materializing a flow, returning all the problems found
+func ValidateRep(rep *definition.DefinitionRep) []error {
+
+	if rep == nil {
+		return []error{errors.New("flow definition not provided")}
+	}
+
+	// deprecated flow format isn't validated
+	if rep.RootTask != nil {
+		return nil
+	}
+
+	var errs []error
+	for _, validator := range repValidators {
+		errs = append(errs, validator(rep)...)
+	}
+
+	return errs
+}
+
+// Validate validates the flow definition representation without materializing or
+// caching it, returning all the problems found as they would be reported when the
+// flow is loaded by the manager (ex. to validate flows from tooling).  The problems
+// found in the tasks and links are returned as ValidationErrors.
+func (fm *FlowManager) Validate(rep *definition.DefinitionRep) []error {
+
+	errs := ValidateRep(rep)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if err := fm.checkSchemaVersion(rep); err != nil {
+		return []error{err}
+	}
+
+	if fm.validateSettings {
+		if errs := ValidateActivitySettings(rep); len(errs) > 0 {
+			return errs
+		}
+	}
+
+	def, err := definition.NewDefinition(rep)
+	if err != nil {
+		return []error{fmt.Errorf("error unmarshalling flow: %s", err.Error())}
+	}
+
+	if err := definition.Validate(def); err != nil {
+		return []error{err}
+	}
+
+	return nil
+}
+
+// FlowValidationResult is the result of validating a flow
+type FlowValidationResult struct {
+	URI    string
+	Errors []error
+}
+
+// ValidateAllStream validates the flows with the specified uris in order, streaming
+// each result as soon as the flow is validated.  The channel is closed once all the
+// flows are validated or the context is cancelled.
+func (fm *FlowManager) ValidateAllStream(ctx context.Context, uris []string) <-chan FlowValidationResult {
+
+	results := make(chan FlowValidationResult)
+
+	go func() {
+		defer close(results)
+
+		for _, uri := range uris {
+			if ctx.Err() != nil {
+				return
+			}
+
+			result := FlowValidationResult{URI: uri, Errors: fm.validateFlow(uri)}
+			if ctx.Err() != nil {
+				return
+			}
+
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return results
+}
+
+// ValidateHandlerFlows verifies that the flows referenced by the trigger handlers can
+// be resolved, returning an error for each unresolved flow.  Resolved remote flows are
+// cached, so it can be used at startup to fail fast and warm the cache.
+func (fm *FlowManager) ValidateHandlerFlows(uris []string) []error {
+
+	var errs []error
+	checked := make(map[string]bool, len(uris))
+
+	for _, uri := range uris {
+		if checked[uri] {
+			continue
+		}
+		checked[uri] = true
+
+		if _, err := fm.GetFlow(uri); err != nil {
+			errs = append(errs, fmt.Errorf("handler flow '%s' can't be resolved, %s", uri, err.Error()))
+		}
+	}
+
+	return errs
+}
+
+// ValidateReferences verifies that the subflows referenced by the flows with the specified
+// uris are part of the set, returning an error for each dangling reference and for each
+// flow of the set which can't be resolved
+func (fm *FlowManager) ValidateReferences(uris []string) []error {
+
+	var errs []error
+
+	set := make(map[string]bool, len(uris))
+	for _, uri := range uris {
+		set[uri] = true
+	}
+
+	for _, uri := range uris {
+		flow, err := fm.GetFlow(uri)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("flow '%s' can't be resolved, %s", uri, err.Error()))
+			continue
+		}
+
+		for _, subflowURI := range flow.SubflowURIs() {
+			if !set[subflowURI] {
+				errs = append(errs, fmt.Errorf("flow '%s': subflow '%s' not found", uri, subflowURI))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateFlow validates the flow with the specified uri, embedded flows were already
+// validated when they were loaded
+func (fm *FlowManager) validateFlow(uri string) []error {
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		if fm.getResFlow(uri[len(uriSchemeRes):]) == nil {
+			return []error{fmt.Errorf("flow not found for uri '%s'", uri)}
+		}
+		return nil
+	}
+
+	rep, err := fm.getFlowRep(context.Background(), uri)
+	if err != nil {
+		return []error{err}
+	}
+
+	return ValidateRep(rep)
+}
+
+func validateRepTasks(rep *definition.DefinitionRep) []error {
+
+	errs := validateTaskReps(rep.Tasks, false)
+
+	if rep.ErrorHandler != nil {
+		errs = append(errs, validateTaskReps(rep.ErrorHandler.Tasks, true)...)
+	}
+
+	return errs
+}
+
+func validateTaskReps(taskReps []*definition.TaskRep, errorHandler bool) []error {
+
+	var errs []error
+	ids := make(map[string]bool, len(taskReps))
+
+	for _, taskRep := range taskReps {
+
+		if taskRep.ID == "" {
+			errs = append(errs, &ValidationError{Link: -1, ErrorHandler: errorHandler, Msg: "task id not specified"})
+			continue
+		}
+
+		if ids[taskRep.ID] {
+			errs = append(errs, taskError(taskRep.ID, errorHandler, "duplicate task id"))
+		}
+		ids[taskRep.ID] = true
+
+		if taskRep.ActivityCfgRep != nil {
+			ref := taskRep.ActivityCfgRep.Ref
+			if ref == "" {
+				errs = append(errs, taskError(taskRep.ID, errorHandler, "activity not specified"))
+			} else if replacement, deprecated := ReplacementRef(ref); deprecated {
+				if activity.Get(ref) == nil {
+					resolveErr := taskError(taskRep.ID, errorHandler, "activity '%s' has been replaced by '%s'", ref, replacement)
+					resolveErr.unresolvedActivity = true
+					errs = append(errs, resolveErr)
+				} else {
+					logger.Warnf("Task '%s' uses deprecated activity '%s', use '%s' instead", taskRep.ID, ref, replacement)
+				}
+			} else if activity.Get(ref) == nil {
+				resolveErr := taskError(taskRep.ID, errorHandler, "unsupported activity '%s'", ref)
+				resolveErr.unresolvedActivity = true
+				errs = append(errs, resolveErr)
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateRepLinks(rep *definition.DefinitionRep) []error {
+
+	errs := validateLinkReps(rep.Tasks, rep.Links, false)
+
+	if rep.ErrorHandler != nil {
+		errs = append(errs, validateLinkReps(rep.ErrorHandler.Tasks, rep.ErrorHandler.Links, true)...)
+	}
+
+	return errs
+}
+
+func validateLinkReps(taskReps []*definition.TaskRep, linkReps []*definition.LinkRep, errorHandler bool) []error {
+
+	var errs []error
+	ids := make(map[string]bool, len(taskReps))
+
+	for _, taskRep := range taskReps {
+		ids[taskRep.ID] = true
+	}
+
+	for i, linkRep := range linkReps {
+		if !ids[linkRep.FromID] {
+			errs = append(errs, linkError(i, errorHandler, "from task '%s' not found", linkRep.FromID))
+		}
+		if !ids[linkRep.ToID] {
+			errs = append(errs, linkError(i, errorHandler, "to task '%s' not found", linkRep.ToID))
+		}
+	}
+
+	return errs
+}
+
+// validateRepGraph validates that the links of the flow don't form cycles and that all
+// the tasks are reachable from the starting tasks (the tasks without incoming links)
+func validateRepGraph(rep *definition.DefinitionRep) []error {
+
+	errs := validateGraph(rep.Tasks, rep.Links, false)
+
+	if rep.ErrorHandler != nil {
+		errs = append(errs, validateGraph(rep.ErrorHandler.Tasks, rep.ErrorHandler.Links, true)...)
+	}
+
+	return errs
+}
+
+func validateGraph(taskReps []*definition.TaskRep, linkReps []*definition.LinkRep, errorHandler bool) []error {
+
+	var errs []error
+
+	outgoing := make(map[string][]int)
+	incoming := make(map[string]bool)
+
+	for i, linkRep := range linkReps {
+		outgoing[linkRep.FromID] = append(outgoing[linkRep.FromID], i)
+		incoming[linkRep.ToID] = true
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(taskReps))
+
+	// depth first walk from the task, a link to a task being visited closes a cycle
+	var walk func(id string)
+	walk = func(id string) {
+		state[id] = visiting
+		for _, i := range outgoing[id] {
+			to := linkReps[i].ToID
+			switch state[to] {
+			case visiting:
+				errs = append(errs, linkError(i, errorHandler, "link from task '%s' to task '%s' creates a cycle", id, to))
+			case unvisited:
+				walk(to)
+			}
+		}
+		state[id] = visited
+	}
+
+	started := false
+	for _, taskRep := range taskReps {
+		if taskRep.ID != "" && !incoming[taskRep.ID] && state[taskRep.ID] == unvisited {
+			started = true
+			walk(taskRep.ID)
+		}
+	}
+
+	// a flow without starting task is reported by the validation of the definition
+	if !started {
+		return errs
+	}
+
+	for _, taskRep := range taskReps {
+		if taskRep.ID != "" && state[taskRep.ID] == unvisited {
+			errs = append(errs, taskError(taskRep.ID, errorHandler, "unreachable from the starting tasks"))
+			state[taskRep.ID] = visited
+		}
+	}
+
+	return errs
+}
+
+// validateRepMappings validates that the input and output mappings of the activities have a target
+func validateRepMappings(rep *definition.DefinitionRep) []error {
+
+	errs := validateMappings(rep.Tasks, false)
+
+	if rep.ErrorHandler != nil {
+		errs = append(errs, validateMappings(rep.ErrorHandler.Tasks, true)...)
+	}
+
+	return errs
+}
+
+func validateMappings(taskReps []*definition.TaskRep, errorHandler bool) []error {
+
+	var errs []error
+
+	for _, taskRep := range taskReps {
+		if taskRep.ActivityCfgRep == nil || taskRep.ActivityCfgRep.Mappings == nil {
+			continue
+		}
+
+		mappings := taskRep.ActivityCfgRep.Mappings
+
+		for i, mapping := range mappings.Input {
+			if mapping == nil || mapping.MapTo == "" {
+				errs = append(errs, taskError(taskRep.ID, errorHandler, "input mapping[%d] has no target", i))
+			}
+		}
+		for i, mapping := range mappings.Output {
+			if mapping == nil || mapping.MapTo == "" {
+				errs = append(errs, taskError(taskRep.ID, errorHandler, "output mapping[%d] has no target", i))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateRepConcurrency validates the concurrency limit of the flow
+func validateRepConcurrency(rep *definition.DefinitionRep) []error {
+
+	if rep.MaxConcurrency < 0 {
+		return []error{fmt.Errorf("maxConcurrency must not be negative, got %d", rep.MaxConcurrency)}
+	}
+
+	return nil
+}
+
+// ValidateActivitySettings validates the settings and inputs of the tasks of the flow
+// against the metadata of their activities, normalizing the values to the types
+// declared by the metadata.  Unknown settings or inputs and values that can't be
+// coerced are returned as errors, tasks with unregistered activities are skipped.
+func ValidateActivitySettings(rep *definition.DefinitionRep) []error {
+
+	errs := validateActivitySettings(rep.Tasks)
+
+	if rep.ErrorHandler != nil {
+		errs = append(errs, validateActivitySettings(rep.ErrorHandler.Tasks)...)
+	}
+
+	return errs
+}
+
+func validateActivitySettings(taskReps []*definition.TaskRep) []error {
+
+	var errs []error
+
+	for _, taskRep := range taskReps {
+		if taskRep.ActivityCfgRep == nil {
+			continue
+		}
+
+		act := activity.Get(taskRep.ActivityCfgRep.Ref)
+		if act == nil || act.Metadata() == nil {
+			continue
+		}
+
+		md := act.Metadata()
+
+		errs = append(errs, normalizeValues(taskRep.ID, "setting", taskRep.ActivityCfgRep.Settings, md.Settings, false)...)
+		errs = append(errs, normalizeValues(taskRep.ID, "input", taskRep.ActivityCfgRep.InputAttrs, md.Input, md.DynamicIO)...)
+	}
+
+	return errs
+}
+
+// normalizeValues coerces the values to the types of their attributes, values with
+// unknown names are errors unless the activity has dynamic IO
+func normalizeValues(taskID string, kind string, values map[string]interface{}, attrs map[string]*data.Attribute, dynamic bool) []error {
+
+	var errs []error
+
+	for name, value := range values {
+		attr, exists := attrs[name]
+		if !exists || attr == nil {
+			if !dynamic {
+				errs = append(errs, fmt.Errorf("task '%s': unknown %s '%s'", taskID, kind, name))
+			}
+			continue
+		}
+
+		// resolved or mapped at runtime
+		if strVal, ok := value.(string); ok && len(strVal) > 0 && (strVal[0] == '$' || strVal[0] == '=') {
+			continue
+		}
+
+		coerced, err := data.CoerceToValue(value, attr.Type())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("task '%s': %s '%s' is not of type %s", taskID, kind, name, attr.Type().String()))
+			continue
+		}
+		values[name] = coerced
+	}
+
+	return errs
+}
+
+// joinErrors combines the errors into a single error listing all the problems
+func joinErrors(msg string, errs []error) error {
+
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Errorf("%s: %s", msg, strings.Join(msgs, "; "))
+}
@@ -0,0 +1,327 @@
+package support
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+const invalidFlowJSON = `{
+  "name": "Invalid Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "log_1", "activity": { "ref": "test-log" } },
+    { "id": "log_1", "activity": { "ref": "test-log" } },
+    { "id": "unknown_1", "activity": { "ref": "unknown-activity" } }
+  ],
+  "links": [
+    { "from": "log_1", "to": "log_2" }
+  ]
+}`
+
+func unmarshalRep(t *testing.T, flowJSON string) *definition.DefinitionRep {
+	var rep *definition.DefinitionRep
+	err := json.Unmarshal([]byte(flowJSON), &rep)
+	assert.Nil(t, err)
+	return rep
+}
+
+func TestValidateRepValid(t *testing.T) {
+	errs := ValidateRep(unmarshalRep(t, testFlowJSON))
+	assert.Len(t, errs, 0)
+}
+
+func TestValidateRepInvalid(t *testing.T) {
+
+	errs := ValidateRep(unmarshalRep(t, invalidFlowJSON))
+	assert.Len(t, errs, 3)
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	assert.Contains(t, msgs, "task 'log_1': duplicate task id")
+	assert.Contains(t, msgs, "task 'unknown_1': unsupported activity 'unknown-activity'")
+	assert.Contains(t, msgs, "link[0]: to task 'log_2' not found")
+}
+
+func TestValidateRepStructuredErrors(t *testing.T) {
+
+	errs := ValidateRep(unmarshalRep(t, invalidFlowJSON))
+	assert.Len(t, errs, 3)
+
+	for _, err := range errs {
+		validationErr, ok := err.(*ValidationError)
+		assert.True(t, ok)
+		if validationErr.Link >= 0 {
+			assert.Equal(t, 0, validationErr.Link)
+			assert.Equal(t, "", validationErr.TaskID)
+		} else {
+			assert.NotEqual(t, "", validationErr.TaskID)
+		}
+	}
+}
+
+func TestValidateRepGraph(t *testing.T) {
+
+	cyclicFlowJSON := `{
+  "name": "Cyclic Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "start", "activity": { "ref": "test-log" } },
+    { "id": "log_1", "activity": { "ref": "test-log" } },
+    { "id": "log_2", "activity": { "ref": "test-log" } },
+    { "id": "loop_1", "activity": { "ref": "test-log" } },
+    { "id": "loop_2", "activity": { "ref": "test-log" } }
+  ],
+  "links": [
+    { "from": "start", "to": "log_1" },
+    { "from": "log_1", "to": "log_2" },
+    { "from": "log_2", "to": "log_1" },
+    { "from": "loop_1", "to": "loop_2" },
+    { "from": "loop_2", "to": "loop_1" }
+  ]
+}`
+
+	errs := ValidateRep(unmarshalRep(t, cyclicFlowJSON))
+	assert.Len(t, errs, 3)
+
+	assert.Equal(t, "link[2]: link from task 'log_2' to task 'log_1' creates a cycle", errs[0].Error())
+	assert.Equal(t, 2, errs[0].(*ValidationError).Link)
+
+	assert.Equal(t, "task 'loop_1': unreachable from the starting tasks", errs[1].Error())
+	assert.Equal(t, "loop_2", errs[2].(*ValidationError).TaskID)
+}
+
+func TestValidateRepMappings(t *testing.T) {
+
+	mappingFlowJSON := `{
+  "name": "Mapping Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "log_1", "activity": { "ref": "test-log", "mappings": { "input": [{ "type": 1, "value": "$flow.msg" }] } } }
+  ],
+  "errorHandler": {
+    "tasks": [
+      { "id": "log_2", "activity": { "ref": "test-log", "mappings": { "output": [{ "type": 1, "value": "$.message", "mapTo": "" }] } } }
+    ]
+  }
+}`
+
+	errs := ValidateRep(unmarshalRep(t, mappingFlowJSON))
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "task 'log_1': input mapping[0] has no target", errs[0].Error())
+	assert.False(t, errs[0].(*ValidationError).ErrorHandler)
+	assert.Equal(t, "task 'log_2': output mapping[0] has no target", errs[1].Error())
+	assert.True(t, errs[1].(*ValidationError).ErrorHandler)
+}
+
+func TestFlowManagerValidate(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{})
+	fm := NewFlowManager(provider)
+
+	assert.Len(t, fm.Validate(unmarshalRep(t, testFlowJSON)), 0)
+	assert.Len(t, fm.Validate(unmarshalRep(t, invalidFlowJSON)), 3)
+	assert.Len(t, fm.Validate(nil), 1)
+
+	noStartJSON := strings.Replace(testFlowJSON, `{ "id": 1, "from": "log_1", "to": "log_2" }`,
+		`{ "id": 1, "from": "log_1", "to": "log_2" }, { "id": 2, "from": "log_2", "to": "log_1" }`, 1)
+
+	errs := fm.Validate(unmarshalRep(t, noStartJSON))
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "no starting task")
+
+	// the validated flows aren't cached
+	assert.Len(t, fm.remoteFlows, 0)
+}
+
+func TestValidateRepConcurrency(t *testing.T) {
+
+	errs := ValidateRep(unmarshalRep(t, `{"name": "Flow", "model": "test", "maxConcurrency": -1, "tasks": []}`))
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "maxConcurrency must not be negative, got -1", errs[0].Error())
+}
+
+func TestValidateRepNil(t *testing.T) {
+	errs := ValidateRep(nil)
+	assert.Len(t, errs, 1)
+}
+
+func TestMaterializeFlowInvalid(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+	_, err := fm.materializeFlow(unmarshalRep(t, invalidFlowJSON))
+	assert.NotNil(t, err)
+}
+
+func TestMaterializeFlowNoStartingTask(t *testing.T) {
+
+	cyclicFlowJSON := `{
+  "name": "Cyclic Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "log_1", "activity": { "ref": "test-log", "input": { "message": "one" } } },
+    { "id": "log_2", "activity": { "ref": "test-log", "input": { "message": "two" } } }
+  ],
+  "links": [
+    { "from": "log_1", "to": "log_2" },
+    { "from": "log_2", "to": "log_1" }
+  ]
+}`
+
+	fm := NewFlowManager(nil)
+	err := fm.LoadResource(&resource.Config{ID: "cyclic", Data: []byte(cyclicFlowJSON)})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no starting task")
+
+	// validation can be disabled to load partial flows
+	fm = NewFlowManagerWithOptions(nil, &ManagerOptions{SkipValidation: true})
+	err = fm.LoadResource(&resource.Config{ID: "cyclic", Data: []byte(cyclicFlowJSON)})
+	assert.Nil(t, err)
+}
+
+// blockingFlowProvider blocks fetching the flow with the specified uri until released
+type blockingFlowProvider struct {
+	*testFlowProvider
+	blockURI string
+	release  chan struct{}
+}
+
+func (p *blockingFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+	if flowURI == p.blockURI {
+		<-p.release
+	}
+	return p.testFlowProvider.GetFlow(flowURI)
+}
+
+func TestValidateAllStream(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/valid":   testFlowJSON,
+		"http://flows/invalid": invalidFlowJSON,
+	})
+	fm := NewFlowManager(provider)
+
+	uris := []string{"http://flows/valid", "http://flows/invalid", "http://flows/missing"}
+
+	var results []FlowValidationResult
+	for result := range fm.ValidateAllStream(context.Background(), uris) {
+		results = append(results, result)
+	}
+
+	assert.Len(t, results, 3)
+	for i, result := range results {
+		assert.Equal(t, uris[i], result.URI)
+	}
+	assert.Len(t, results[0].Errors, 0)
+	assert.Len(t, results[1].Errors, 3)
+	assert.Len(t, results[2].Errors, 1)
+}
+
+func TestValidateAllStreamCancel(t *testing.T) {
+
+	provider := &blockingFlowProvider{
+		testFlowProvider: newTestFlowProvider(map[string]string{
+			"http://flows/first":  testFlowJSON,
+			"http://flows/second": testFlowJSON,
+			"http://flows/third":  testFlowJSON,
+		}),
+		blockURI: "http://flows/second",
+		release:  make(chan struct{}),
+	}
+	fm := NewFlowManager(provider)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := fm.ValidateAllStream(ctx, []string{"http://flows/first", "http://flows/second", "http://flows/third"})
+
+	result := <-results
+	assert.Equal(t, "http://flows/first", result.URI)
+
+	cancel()
+	close(provider.release)
+
+	count := 0
+	for range results {
+		count++
+	}
+
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 0, provider.callCount("http://flows/third"))
+}
+
+func TestValidateActivitySettings(t *testing.T) {
+
+	misconfiguredJSON := `{
+  "name": "Misconfigured Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "log_1", "activity": { "ref": "test-log", "settings": { "level": "debug" }, "input": { "message": 42 } } },
+    { "id": "log_2", "activity": { "ref": "test-log", "input": { "mesage": "typo", "message": "$env[MESSAGE]" } } }
+  ]
+}`
+
+	rep := unmarshalRep(t, misconfiguredJSON)
+
+	errs := ValidateActivitySettings(rep)
+	assert.Len(t, errs, 2)
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	assert.Contains(t, msgs, "task 'log_1': unknown setting 'level'")
+	assert.Contains(t, msgs, "task 'log_2': unknown input 'mesage'")
+
+	// values are normalized to the declared types
+	assert.Equal(t, "42", rep.Tasks[0].ActivityCfgRep.InputAttrs["message"])
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/misconfigured": misconfiguredJSON})
+
+	_, err := NewFlowManager(provider).GetFlow("http://flows/misconfigured")
+	assert.Nil(t, err)
+
+	_, err = NewFlowManagerWithOptions(provider, &ManagerOptions{ValidateSettings: true}).GetFlow("http://flows/misconfigured")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown setting 'level'")
+}
+
+func TestValidateHandlerFlows(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/orders": testFlowJSON})
+	fm := NewFlowManager(provider)
+
+	err := fm.LoadResource(&resource.Config{ID: "payments", Data: []byte(testFlowJSON)})
+	assert.Nil(t, err)
+
+	errs := fm.ValidateHandlerFlows([]string{"res://payments", "http://flows/orders", "res://missing", "http://flows/missing", "res://missing"})
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs[0].Error(), "handler flow 'res://missing' can't be resolved")
+	assert.Contains(t, errs[1].Error(), "handler flow 'http://flows/missing' can't be resolved")
+
+	assert.Len(t, fm.ValidateHandlerFlows([]string{"res://payments", "http://flows/orders"}), 0)
+}
+
+func TestValidateReferences(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+
+	childFlowJSON := strings.Replace(parentFlowJSON, "http://flows/child", "res://missing", 1)
+
+	assert.Nil(t, fm.LoadResource(&resource.Config{ID: "parent", Data: []byte(strings.Replace(parentFlowJSON, "http://flows/child", "res://child", 1))}))
+	assert.Nil(t, fm.LoadResource(&resource.Config{ID: "child", Data: []byte(childFlowJSON)}))
+
+	errs := fm.ValidateReferences([]string{"res://parent", "res://child"})
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "flow 'res://child': subflow 'res://missing' not found", errs[0].Error())
+
+	errs = fm.ValidateReferences([]string{"res://parent"})
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "flow 'res://parent': subflow 'res://child' not found", errs[0].Error())
+}
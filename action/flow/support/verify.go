@@ -0,0 +1,284 @@
+package support
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	// the hashes of the supported algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// FlowVerifier verifies the JWS signatures of flows against a set of public keys, the
+// RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384 and ES512 algorithms are
+// supported.  A flow is either signed by a detached JWS (RFC 7515 Appendix F) of its
+// JSON or is itself a compact JWS with the flow JSON as payload.
+type FlowVerifier struct {
+	// Strict indicates if unsigned flows are refused, they are only logged as a warning
+	// if not set.  Flows with an invalid signature are always refused.
+	Strict bool
+
+	mu   sync.RWMutex
+	keys []*verifierKey
+}
+
+type verifierKey struct {
+	id  string
+	key crypto.PublicKey
+}
+
+// jwsHeader is the protected header of a JWS
+type jwsHeader struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+// signedResource is the data of a flow resource signed by a detached JWS
+// (ex. {"flow": {...}, "signature": "eyJhbGciOiJFUzI1NiJ9..MEUCIQ..."})
+type signedResource struct {
+	Flow      json.RawMessage `json:"flow"`
+	Signature string          `json:"signature"`
+}
+
+// NewFlowVerifier creates a FlowVerifier, keys are added using AddKey or AddPEMKey
+func NewFlowVerifier(strict bool) *FlowVerifier {
+	return &FlowVerifier{Strict: strict}
+}
+
+// AddKey adds a public key (*rsa.PublicKey or *ecdsa.PublicKey) flows can be signed
+// with, the id is matched against the 'kid' header of the signatures.  Signatures
+// without a 'kid' are verified using all the keys.
+func (v *FlowVerifier) AddKey(id string, key crypto.PublicKey) error {
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return fmt.Errorf("unsupported public key type '%T'", key)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.keys = append(v.keys, &verifierKey{id: id, key: key})
+	return nil
+}
+
+// AddPEMKey adds a PEM encoded PKIX public key ('PUBLIC KEY' block), see AddKey
+func (v *FlowVerifier) AddPEMKey(id string, pemBytes []byte) error {
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return fmt.Errorf("invalid public key '%s', no PEM block found", id)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid public key '%s', %s", id, err.Error())
+	}
+
+	return v.AddKey(id, key)
+}
+
+// Verify verifies the detached JWS signature of the flow, it can be used as the
+// SignatureVerifier of a BasicRemoteFlowProvider
+func (v *FlowVerifier) Verify(flow []byte, signature []byte) error {
+
+	if len(signature) == 0 {
+		return errors.New("flow is not signed")
+	}
+
+	parts := strings.Split(strings.TrimSpace(string(signature)), ".")
+	if len(parts) != 3 {
+		return errors.New("signature is not a JWS")
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(flow)
+	if parts[1] != "" && parts[1] != payload {
+		return errors.New("signature is not a signature of the flow")
+	}
+
+	return v.verifyJWS(parts[0], payload, parts[2])
+}
+
+// Open verifies the signature of the flow with the specified uri, returning the verified
+// flow JSON.  The signature is the detached signature of the flow if it was provided
+// separately, otherwise the flow is expected to be either a compact JWS or a signed
+// resource ({"flow": {...}, "signature": "<detached JWS>"}).
+func (v *FlowVerifier) Open(flowURI string, data []byte, signature []byte) ([]byte, error) {
+
+	if len(signature) > 0 {
+		if err := v.Verify(data, signature); err != nil {
+			return nil, fmt.Errorf("invalid signature of flow with uri '%s', %s", redactURI(flowURI), err.Error())
+		}
+		return data, nil
+	}
+
+	trimmed := bytes.TrimSpace(data)
+
+	var quoted string
+	if len(trimmed) > 0 && trimmed[0] == '"' && json.Unmarshal(trimmed, &quoted) == nil {
+		trimmed = []byte(quoted)
+	}
+
+	if parts := strings.Split(string(trimmed), "."); len(parts) == 3 && !bytes.HasPrefix(trimmed, []byte("{")) {
+		// a compact JWS with the flow as payload
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err == nil {
+			err = v.verifyJWS(parts[0], parts[1], parts[2])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature of flow with uri '%s', %s", redactURI(flowURI), err.Error())
+		}
+		return payload, nil
+	}
+
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		var signed signedResource
+		if json.Unmarshal(trimmed, &signed) == nil && len(signed.Flow) > 0 && signed.Signature != "" {
+			if err := v.Verify(signed.Flow, []byte(signed.Signature)); err != nil {
+				return nil, fmt.Errorf("invalid signature of flow with uri '%s', %s", redactURI(flowURI), err.Error())
+			}
+			return signed.Flow, nil
+		}
+	}
+
+	if v.Strict {
+		return nil, fmt.Errorf("flow with uri '%s' is not signed", redactURI(flowURI))
+	}
+
+	logger.Warnf("Flow with uri '%s' is not signed", redactURI(flowURI))
+	return data, nil
+}
+
+// verifyJWS verifies the signature of the base64url encoded header and payload
+func (v *FlowVerifier) verifyJWS(encodedHeader, encodedPayload, encodedSignature string) error {
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(encodedHeader)
+	if err != nil {
+		return fmt.Errorf("invalid JWS header, %s", err.Error())
+	}
+
+	var header jwsHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return fmt.Errorf("invalid JWS header, %s", err.Error())
+	}
+
+	if len(header.Crit) > 0 {
+		return fmt.Errorf("unsupported critical JWS header parameters '%s'", strings.Join(header.Crit, ","))
+	}
+
+	hash, err := jwsHash(header.Alg)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return fmt.Errorf("invalid JWS signature, %s", err.Error())
+	}
+
+	digest := hash.New()
+	digest.Write([]byte(encodedHeader + "." + encodedPayload))
+	hashed := digest.Sum(nil)
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, key := range v.keys {
+		if header.Kid != "" && key.id != header.Kid {
+			continue
+		}
+		if verifySignature(header.Alg, hash, key.key, hashed, signature) {
+			return nil
+		}
+	}
+
+	if header.Kid != "" {
+		return fmt.Errorf("signature doesn't match key '%s'", header.Kid)
+	}
+	return errors.New("signature doesn't match any key")
+}
+
+// jwsAlgorithms are the hashes of the supported JWS algorithms
+var jwsAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// ecdsaCurveBits are the sizes of the curves of the ECDSA algorithms
+var ecdsaCurveBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+func jwsHash(alg string) (crypto.Hash, error) {
+
+	hash, ok := jwsAlgorithms[alg]
+	if !ok {
+		return 0, fmt.Errorf("unsupported JWS algorithm '%s'", alg)
+	}
+
+	return hash, nil
+}
+
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, hashed []byte, signature []byte) bool {
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(pub, hash, hashed, signature) == nil
+		case "PS":
+			return rsa.VerifyPSS(pub, hash, hashed, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		// the curve must match the algorithm (ex. P-256 for ES256)
+		bits := pub.Curve.Params().BitSize
+		if ecdsaCurveBits[alg] != bits {
+			return false
+		}
+		size := (bits + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(pub, hashed, r, s)
+	}
+
+	return false
+}
+
+// checkVerifiable checks that the signatures of the flows of the provider can be verified
+// when a verifier is configured, in strict mode the flows of the providers which don't
+// verify signatures are refused
+func (fm *FlowManager) checkVerifiable(flowProvider definition.Provider, flowURI string) error {
+
+	if fm.verifier == nil {
+		return nil
+	}
+
+	if provider, ok := flowProvider.(*BasicRemoteFlowProvider); ok && provider.Verifier != nil {
+		return nil
+	}
+
+	if fm.verifier.Strict {
+		return fmt.Errorf("unable to verify flow with uri '%s', provider doesn't verify signatures", redactURI(flowURI))
+	}
+
+	logger.Warnf("Flow with uri '%s' is not verified, provider doesn't verify signatures", redactURI(flowURI))
+	return nil
+}
@@ -0,0 +1,186 @@
+package support
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// signES256 signs the payload returning the header, payload and signature of the compact JWS
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, payload []byte) (string, string, string) {
+
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": kid})
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+
+	hashed := crypto.SHA256.New()
+	hashed.Write([]byte(encodedHeader + "." + encodedPayload))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, hashed.Sum(nil))
+	assert.Nil(t, err)
+
+	signature := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(signature[32-len(rBytes):32], rBytes)
+	copy(signature[64-len(sBytes):], sBytes)
+
+	return encodedHeader, encodedPayload, base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestVerifier(t *testing.T, strict bool) (*FlowVerifier, *ecdsa.PrivateKey) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	verifier := NewFlowVerifier(strict)
+	assert.Nil(t, verifier.AddKey("flows", &key.PublicKey))
+
+	return verifier, key
+}
+
+func TestFlowVerifierDetached(t *testing.T) {
+
+	verifier, key := newTestVerifier(t, true)
+
+	header, _, signature := signES256(t, key, "flows", []byte(testFlowJSON))
+	detached := header + ".." + signature
+
+	flow, err := verifier.Open("res://flow:test", []byte(testFlowJSON), []byte(detached))
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(flow))
+
+	_, err = verifier.Open("res://flow:test", []byte(strings.Replace(testFlowJSON, "Test Flow", "Tampered", 1)), []byte(detached))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid signature")
+}
+
+func TestFlowVerifierEmbedded(t *testing.T) {
+
+	verifier, key := newTestVerifier(t, true)
+
+	header, payload, signature := signES256(t, key, "flows", []byte(testFlowJSON))
+
+	flow, err := verifier.Open("res://flow:test", []byte(header+"."+payload+"."+signature), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(flow))
+
+	// a signature of another key
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	header, payload, signature = signES256(t, otherKey, "flows", []byte(testFlowJSON))
+
+	_, err = verifier.Open("res://flow:test", []byte(header+"."+payload+"."+signature), nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "doesn't match key 'flows'")
+}
+
+func TestFlowVerifierUnsigned(t *testing.T) {
+
+	strict, _ := newTestVerifier(t, true)
+	_, err := strict.Open("res://flow:test", []byte(testFlowJSON), nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not signed")
+
+	lenient, _ := newTestVerifier(t, false)
+	flow, err := lenient.Open("res://flow:test", []byte(testFlowJSON), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(flow))
+}
+
+func TestFlowVerifierPEMKey(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+
+	verifier := NewFlowVerifier(true)
+	assert.Nil(t, verifier.AddPEMKey("rsa", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	assert.NotNil(t, verifier.AddPEMKey("invalid", []byte("not a key")))
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(testFlowJSON))
+
+	hashed := crypto.SHA256.New()
+	hashed.Write([]byte(header + "." + payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed.Sum(nil))
+	assert.Nil(t, err)
+
+	flow, err := verifier.Open("res://flow:test", []byte(header+"."+payload+"."+base64.RawURLEncoding.EncodeToString(signature)), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, testFlowJSON, string(flow))
+
+	// the none algorithm is never accepted
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	_, err = verifier.Open("res://flow:test", []byte(none+"."+payload+"."), nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unsupported JWS algorithm 'none'")
+}
+
+func TestLoadSignedResource(t *testing.T) {
+
+	verifier, key := newTestVerifier(t, true)
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{Verifier: verifier})
+
+	header, _, signature := signES256(t, key, "flows", []byte(testFlowJSON))
+	// the flow is signed as it appears in the resource
+	signed := []byte(`{"flow": ` + testFlowJSON + `, "signature": "` + header + ".." + signature + `"}`)
+
+	err := fm.LoadResource(&resource.Config{ID: "flow:signed", Data: signed})
+	assert.Nil(t, err)
+
+	flow, err := fm.GetFlow("res://flow:signed")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", flow.Name())
+
+	err = fm.LoadResource(&resource.Config{ID: "flow:unsigned", Data: []byte(testFlowJSON)})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not signed")
+}
+
+func TestGetFlowSignatureHeader(t *testing.T) {
+
+	verifier, key := newTestVerifier(t, true)
+	header, _, signature := signES256(t, key, "flows", []byte(testFlowJSON))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/signed" {
+			w.Header().Set("flow-signature", header+".."+signature)
+		}
+		w.Write([]byte(testFlowJSON))
+	}))
+	defer server.Close()
+
+	fm := NewFlowManagerWithOptions(nil, &ManagerOptions{Verifier: verifier})
+
+	flow, err := fm.GetFlow(server.URL + "/signed")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow", flow.Name())
+
+	_, err = fm.GetFlow(server.URL + "/unsigned")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not signed")
+}
+
+func TestStrictVerifierRefusesUnverifiedProvider(t *testing.T) {
+
+	verifier, _ := newTestVerifier(t, true)
+	fm := NewFlowManagerWithOptions(&BasicRemoteFlowProvider{}, &ManagerOptions{Verifier: verifier})
+
+	_, err := fm.GetFlow("file:///flows/test.json")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "provider doesn't verify signatures")
+}
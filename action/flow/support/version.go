@@ -0,0 +1,283 @@
+package support
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// CurrentSchemaVersion is the current flow schema version, flows declaring an older
+// (or no) schema version are reported as out-of-date by SchemaDriftReport
+const CurrentSchemaVersion = "1.0.0"
+
+const driftOutdated = " (outdated)"
+
+// FlowSchemaVersion returns the schema version declared by the flow with the specified
+// uri, an empty version is returned if the flow doesn't declare one.  Remote flows are
+// fetched from the provider, but aren't materialized or cached.
+func (fm *FlowManager) FlowSchemaVersion(uri string) (string, error) {
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		flow := fm.getResFlow(strings.TrimPrefix(uri, uriSchemeRes))
+		if flow == nil {
+			return "", fmt.Errorf("flow not found for uri '%s'", uri)
+		}
+		return flow.SchemaVersion(), nil
+	}
+
+	defRep, err := fm.getFlowRep(context.Background(), uri)
+	if err != nil {
+		return "", err
+	}
+
+	return defRep.SchemaVersion, nil
+}
+
+// SchemaDriftReport maps the flows with the specified uris to their schema version,
+// versions older than CurrentSchemaVersion are suffixed with ' (outdated)' (ex.
+// '0.9.0 (outdated)') and flows which couldn't be loaded map to 'error: <reason>'
+func (fm *FlowManager) SchemaDriftReport(uris []string) map[string]string {
+
+	report := make(map[string]string, len(uris))
+
+	for _, uri := range uris {
+		version, err := fm.FlowSchemaVersion(uri)
+		if err != nil {
+			report[uri] = "error: " + err.Error()
+			continue
+		}
+
+		if version == "" {
+			report[uri] = "none" + driftOutdated
+		} else if compareVersions(version, CurrentSchemaVersion) < 0 {
+			report[uri] = version + driftOutdated
+		} else {
+			report[uri] = version
+		}
+	}
+
+	return report
+}
+
+// checkSchemaVersion checks that the schema version declared by the flow is supported,
+// a newer version is an error in strict mode and a warning otherwise
+func (fm *FlowManager) checkSchemaVersion(flowRep *definition.DefinitionRep) error {
+
+	if flowRep == nil || flowRep.SchemaVersion == "" || compareVersions(flowRep.SchemaVersion, CurrentSchemaVersion) <= 0 {
+		return nil
+	}
+
+	if fm.strictSchemaVersion {
+		return fmt.Errorf("flow '%s' declares schema version '%s' which is newer than the supported version '%s'", flowRep.Name, flowRep.SchemaVersion, CurrentSchemaVersion)
+	}
+
+	logger.Warnf("Flow '%s' declares schema version '%s' which is newer than the supported version '%s', it may not load correctly", flowRep.Name, flowRep.SchemaVersion, CurrentSchemaVersion)
+	return nil
+}
+
+// GetFlowVersions gets the specified versions of the flow with the specified uri from
+// a definition.VersionedProvider, returning the flows keyed by version.  The versions
+// are materialized but not cached, an error is returned if any version can't be loaded.
+func (fm *FlowManager) GetFlowVersions(id string, versions []string) (map[string]*definition.Definition, error) {
+
+	provider, ok := fm.provider(id).(definition.VersionedProvider)
+	if !ok {
+		return nil, fmt.Errorf("unable to get versions of flow '%s', provider doesn't support versions", id)
+	}
+
+	flows := make(map[string]*definition.Definition, len(versions))
+
+	for _, version := range versions {
+		if _, exists := flows[version]; exists {
+			continue
+		}
+
+		release, _ := fm.acquireFetch(context.Background())
+		defRep, err := provider.GetFlowVersion(id, version)
+		release()
+
+		if err == nil && defRep == nil {
+			err = errors.New("flow not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting version '%s' of flow '%s', %s", version, id, err.Error())
+		}
+
+		flow, err := fm.materializeFlow(defRep)
+		if err != nil {
+			return nil, fmt.Errorf("error getting version '%s' of flow '%s', %s", version, id, err.Error())
+		}
+
+		flows[version] = flow
+	}
+
+	return flows, nil
+}
+
+// GetFlowVersion gets the specified version of the flow with the specified uri, the
+// current flow is returned if the version is empty.  The versions are kept materialized
+// per flow, so several versions of a flow can run side by side (ex. canary rollouts).
+// The versions of an embedded flow are the ones it was loaded with, the versions of a
+// remote flow are fetched from the provider if it is a definition.VersionedProvider.
+func (fm *FlowManager) GetFlowVersion(uri string, version string) (*definition.Definition, error) {
+
+	if version == "" {
+		return fm.GetFlow(uri)
+	}
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		// the versions of a lazily loaded flow are known once it is materialized
+		fm.getResFlow(uri[len(uriSchemeRes):])
+	}
+
+	if flow := fm.getVersion(uri, version); flow != nil {
+		return flow, nil
+	}
+
+	if strings.HasPrefix(uri, uriSchemeRes) {
+		return nil, fmt.Errorf("version '%s' of flow '%s' not found", version, uri)
+	}
+
+	if _, ok := fm.provider(uri).(definition.VersionedProvider); ok {
+		flows, err := fm.GetFlowVersions(uri, []string{version})
+		if err != nil {
+			return nil, err
+		}
+		fm.storeVersion(uri, flows[version])
+		return flows[version], nil
+	}
+
+	flow, err := fm.GetFlow(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if flow.Version() != version {
+		return nil, fmt.Errorf("version '%s' of flow '%s' not found, the current version is '%s'", version, uri, flow.Version())
+	}
+
+	fm.storeVersion(uri, flow)
+	return flow, nil
+}
+
+// splitResVersion splits the version from an embedded flow uri (ex. res://flow:orders?version=2)
+func splitResVersion(uri string) (base string, version string) {
+
+	idx := strings.Index(uri, "?")
+	if idx < 0 {
+		return uri, ""
+	}
+
+	query, err := url.ParseQuery(uri[idx+1:])
+	if err != nil {
+		return uri, ""
+	}
+
+	return uri[:idx], query.Get("version")
+}
+
+// storeVersion keeps the flow as the version it declares of the flow with the specified uri
+func (fm *FlowManager) storeVersion(uri string, flow *definition.Definition) {
+
+	if flow == nil || flow.Version() == "" {
+		return
+	}
+
+	fm.versionsMu.Lock()
+	defer fm.versionsMu.Unlock()
+
+	if fm.versions == nil {
+		fm.versions = make(map[string]map[string]*definition.Definition)
+	}
+	if fm.versions[uri] == nil {
+		fm.versions[uri] = make(map[string]*definition.Definition)
+	}
+	fm.versions[uri][flow.Version()] = flow
+}
+
+func (fm *FlowManager) getVersion(uri string, version string) *definition.Definition {
+
+	fm.versionsMu.Lock()
+	defer fm.versionsMu.Unlock()
+
+	return fm.versions[uri][version]
+}
+
+// dropVersions drops the versions of the flow once it is evicted, invalidated or deleted,
+// so the materialized versions don't outlive the flow
+func (fm *FlowManager) dropVersions(uri string) {
+
+	fm.versionsMu.Lock()
+	defer fm.versionsMu.Unlock()
+
+	delete(fm.versions, uri)
+}
+
+// dropRemoteVersions drops the versions of all the remote flows
+func (fm *FlowManager) dropRemoteVersions() {
+
+	fm.versionsMu.Lock()
+	defer fm.versionsMu.Unlock()
+
+	for uri := range fm.versions {
+		if !strings.HasPrefix(uri, uriSchemeRes) {
+			delete(fm.versions, uri)
+		}
+	}
+}
+
+// compareVersions compares two dotted flow versions (ex. 1.2.0, v2), returning -1, 0
+// or 1.  Numeric segments are compared numerically, others lexically, and missing
+// segments are considered 0.
+func compareVersions(a, b string) int {
+
+	aParts := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bParts := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := "0", "0"
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+
+		aNum, aErr := strconv.Atoi(aPart)
+		bNum, bErr := strconv.Atoi(bPart)
+
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+		case aPart != bPart:
+			if aPart < bPart {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// isOlderFlow determines if the flow is an older version of the current flow, flows
+// which don't declare a version are never considered older
+func isOlderFlow(flow, current *definition.Definition) bool {
+
+	if flow.Version() == "" || current.Version() == "" {
+		return false
+	}
+
+	return compareVersions(flow.Version(), current.Version()) < 0
+}
@@ -0,0 +1,283 @@
+package support
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/app/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func withSchemaVersion(flowJSON string, version string) string {
+	return strings.Replace(flowJSON, `"name": "Test Flow",`, `"name": "Test Flow", "schemaVersion": "`+version+`",`, 1)
+}
+
+func TestFlowSchemaVersion(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/v1":   withSchemaVersion(testFlowJSON, "1.0.0"),
+		"http://flows/v2":   withSchemaVersion(testFlowJSON, "2.0.0"),
+		"http://flows/none": testFlowJSON,
+	})
+
+	fm := NewFlowManager(provider)
+
+	version, err := fm.FlowSchemaVersion("http://flows/v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0", version)
+
+	version, err = fm.FlowSchemaVersion("http://flows/v2")
+	assert.Nil(t, err)
+	assert.Equal(t, "2.0.0", version)
+
+	version, err = fm.FlowSchemaVersion("http://flows/none")
+	assert.Nil(t, err)
+	assert.Equal(t, "", version)
+
+	_, err = fm.FlowSchemaVersion("http://flows/missing")
+	assert.NotNil(t, err)
+
+	// remote flows aren't materialized
+	assert.Len(t, fm.remoteFlows, 0)
+}
+
+func TestFlowSchemaVersionResource(t *testing.T) {
+
+	fm := NewFlowManager(nil)
+	err := fm.LoadResource(&resource.Config{ID: "flow1", Data: []byte(withSchemaVersion(testFlowJSON, "1.1.0"))})
+	assert.Nil(t, err)
+
+	version, err := fm.FlowSchemaVersion("res://flow1")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.0", version)
+}
+
+func TestSchemaDriftReport(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{
+		"http://flows/old":     withSchemaVersion(testFlowJSON, "0.9.0"),
+		"http://flows/current": withSchemaVersion(testFlowJSON, CurrentSchemaVersion),
+		"http://flows/newer":   withSchemaVersion(testFlowJSON, "1.1"),
+		"http://flows/none":    testFlowJSON,
+	})
+
+	fm := NewFlowManager(provider)
+	err := fm.LoadResource(&resource.Config{ID: "flow1", Data: []byte(withSchemaVersion(testFlowJSON, "0.5"))})
+	assert.Nil(t, err)
+
+	report := fm.SchemaDriftReport([]string{"http://flows/old", "http://flows/current", "http://flows/newer",
+		"http://flows/none", "res://flow1", "http://flows/missing"})
+
+	assert.Len(t, report, 6)
+	assert.Equal(t, "0.9.0 (outdated)", report["http://flows/old"])
+	assert.Equal(t, CurrentSchemaVersion, report["http://flows/current"])
+	assert.Equal(t, "1.1", report["http://flows/newer"])
+	assert.Equal(t, "none (outdated)", report["http://flows/none"])
+	assert.Equal(t, "0.5 (outdated)", report["res://flow1"])
+	assert.True(t, strings.HasPrefix(report["http://flows/missing"], "error: "))
+}
+
+// reorderingFlowProvider serves increasingly older versions of a flow on each
+// fetch, simulating reloads completing out of order
+type reorderingFlowProvider struct {
+	mu      sync.Mutex
+	version int
+}
+
+func (p *reorderingFlowProvider) GetFlow(flowURI string) (*definition.DefinitionRep, error) {
+
+	p.mu.Lock()
+	version := p.version
+	p.version--
+	p.mu.Unlock()
+
+	flowJSON := strings.Replace(testFlowJSON, `"name": "Test Flow",`, fmt.Sprintf(`"name": "Test Flow", "version": "1.%d.0",`, version), 1)
+
+	var defRep *definition.DefinitionRep
+	err := json.Unmarshal([]byte(flowJSON), &defRep)
+	return defRep, err
+}
+
+func TestReloadKeepsNewestVersion(t *testing.T) {
+
+	fm := NewFlowManager(&reorderingFlowProvider{version: 10})
+
+	flow, err := fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.10.0", flow.Version())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			flow, err := fm.ReloadFlow("http://flows/flow")
+			assert.Nil(t, err)
+			assert.Equal(t, "1.10.0", flow.Version())
+		}()
+	}
+	wg.Wait()
+
+	flow, err = fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.10.0", flow.Version())
+}
+
+func TestCompareVersions(t *testing.T) {
+
+	assert.Equal(t, 0, compareVersions("1.2.0", "1.2"))
+	assert.Equal(t, -1, compareVersions("1.9.0", "1.10.0"))
+	assert.Equal(t, 1, compareVersions("v2", "1.99"))
+	assert.Equal(t, -1, compareVersions("1.0.0-alpha", "1.0.0-beta"))
+}
+
+func TestMaterializeFlowFutureSchemaVersion(t *testing.T) {
+
+	futureFlowJSON := withSchemaVersion(testFlowJSON, "2.0.0")
+
+	fm := NewFlowManager(nil)
+	err := fm.LoadResource(&resource.Config{ID: "future", Data: []byte(futureFlowJSON)})
+	assert.Nil(t, err)
+
+	fm = NewFlowManagerWithOptions(nil, &ManagerOptions{StrictSchemaVersion: true})
+	err = fm.LoadResource(&resource.Config{ID: "future", Data: []byte(futureFlowJSON)})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "flow 'Test Flow' declares schema version '2.0.0' which is newer than the supported version '1.0.0'")
+
+	err = fm.LoadResource(&resource.Config{ID: "current", Data: []byte(withSchemaVersion(testFlowJSON, CurrentSchemaVersion))})
+	assert.Nil(t, err)
+}
+
+// testVersionedFlowProvider serves the versions of the flows from memory keyed by uri@version
+type testVersionedFlowProvider struct {
+	*testFlowProvider
+}
+
+func (p *testVersionedFlowProvider) GetFlowVersion(flowURI string, version string) (*definition.DefinitionRep, error) {
+	return p.GetFlow(flowURI + "@" + version)
+}
+
+func TestGetFlowVersions(t *testing.T) {
+
+	provider := &testVersionedFlowProvider{newTestFlowProvider(map[string]string{
+		"http://flows/orders@1.0.0": strings.Replace(testFlowJSON, `"name": "Test Flow",`, `"name": "Test Flow", "version": "1.0.0",`, 1),
+		"http://flows/orders@1.1.0": strings.Replace(testFlowJSON, `"name": "Test Flow",`, `"name": "Test Flow", "version": "1.1.0",`, 1),
+	})}
+
+	fm := NewFlowManager(provider)
+
+	flows, err := fm.GetFlowVersions("http://flows/orders", []string{"1.1.0", "1.0.0"})
+	assert.Nil(t, err)
+	assert.Len(t, flows, 2)
+	assert.Equal(t, "1.1.0", flows["1.1.0"].Version())
+	assert.Equal(t, "1.0.0", flows["1.0.0"].Version())
+
+	_, err = fm.GetFlowVersions("http://flows/orders", []string{"1.1.0", "0.9.0"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error getting version '0.9.0' of flow 'http://flows/orders'")
+
+	_, err = NewFlowManager(newTestFlowProvider(nil)).GetFlowVersions("http://flows/orders", []string{"1.0.0"})
+	assert.NotNil(t, err)
+}
+
+func TestGetFlowVersion(t *testing.T) {
+
+	provider := &testVersionedFlowProvider{newTestFlowProvider(map[string]string{
+		"http://flows/orders@1.0.0": strings.Replace(testFlowJSON, `"name": "Test Flow",`, `"name": "Test Flow", "version": "1.0.0",`, 1),
+	})}
+	fm := NewFlowManager(provider)
+
+	// the versions are kept materialized
+	flow, err := fm.GetFlowVersion("http://flows/orders", "1.0.0")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0", flow.Version())
+
+	again, err := fm.GetFlowVersion("http://flows/orders", "1.0.0")
+	assert.Nil(t, err)
+	assert.True(t, again == flow)
+	assert.Equal(t, 1, provider.callCount("http://flows/orders@1.0.0"))
+
+	// the embedded flows keep the versions they were loaded with
+	for _, version := range []string{"1", "2"} {
+		flowJSON := strings.Replace(testFlowJSON, `"name": "Test Flow",`, `"name": "Test Flow", "version": "`+version+`",`, 1)
+		assert.Nil(t, fm.LoadResource(&resource.Config{ID: "flow:orders", Data: []byte(flowJSON)}))
+	}
+
+	current, err := fm.GetFlowVersion("res://flow:orders", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", current.Version())
+
+	v1, err := fm.GetFlow("res://flow:orders?version=1")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", v1.Version())
+
+	v2, err := fm.GetFlowVersion("res://flow:orders", "2")
+	assert.Nil(t, err)
+	assert.True(t, v2 == current)
+
+	_, err = fm.GetFlow("res://flow:orders?version=3")
+	assert.NotNil(t, err)
+	assert.Equal(t, "version '3' of flow 'res://flow:orders' not found", err.Error())
+
+	// without a versioned provider only the current version is available
+	fm = NewFlowManager(newTestFlowProvider(map[string]string{"http://flows/orders": provider.flows["http://flows/orders@1.0.0"]}))
+
+	flow, err = fm.GetFlowVersion("http://flows/orders", "1.0.0")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0", flow.Version())
+
+	_, err = fm.GetFlowVersion("http://flows/orders", "2.0.0")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the current version is '1.0.0'")
+}
+
+func TestVersionsDroppedWithFlow(t *testing.T) {
+
+	flows := make(map[string]string)
+	for _, uri := range []string{"http://flows/orders", "http://flows/payments"} {
+		flows[uri] = strings.Replace(testFlowJSON, `"name": "Test Flow",`, `"name": "Test Flow", "version": "1.0.0",`, 1)
+	}
+	versioned := func(fm *FlowManager, uri string) bool {
+		return fm.getVersion(uri, "1.0.0") != nil
+	}
+
+	clock := &fakeClock{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fm := NewFlowManagerWithOptions(newTestFlowProvider(flows), &ManagerOptions{Cache: CacheConfig{TTL: time.Minute, MaxEntries: 1}, Clock: clock})
+
+	// the versions of the least recently used flow are dropped when it is evicted
+	_, err := fm.GetFlowVersion("http://flows/orders", "1.0.0")
+	assert.Nil(t, err)
+	assert.True(t, versioned(fm, "http://flows/orders"))
+
+	_, err = fm.GetFlowVersion("http://flows/payments", "1.0.0")
+	assert.Nil(t, err)
+	assert.False(t, versioned(fm, "http://flows/orders"))
+	assert.True(t, versioned(fm, "http://flows/payments"))
+
+	// and when it expires
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, 1, fm.EvictExpired())
+	assert.False(t, versioned(fm, "http://flows/payments"))
+
+	// or is invalidated
+	_, err = fm.GetFlowVersion("http://flows/orders", "1.0.0")
+	assert.Nil(t, err)
+	fm.Invalidate("http://flows/orders")
+	assert.False(t, versioned(fm, "http://flows/orders"))
+
+	_, err = fm.GetFlowVersion("http://flows/orders", "1.0.0")
+	assert.Nil(t, err)
+	fm.InvalidateAll()
+	assert.False(t, versioned(fm, "http://flows/orders"))
+
+	// or is deleted
+	_, err = fm.GetFlowVersion("http://flows/orders", "1.0.0")
+	assert.Nil(t, err)
+	assert.Nil(t, fm.DeleteFlow("http://flows/orders"))
+	assert.False(t, versioned(fm, "http://flows/orders"))
+}
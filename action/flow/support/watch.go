@@ -0,0 +1,233 @@
+package support
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultWatchInterval is the default interval the watched flows are polled at
+const DefaultWatchInterval = 5 * time.Second
+
+// ReloadFunc is called when a watched flow changed and was swapped in, old is nil if the
+// flow wasn't cached.  Running instances keep using the old definition, new instances use
+// the new one.
+type ReloadFunc func(uri string, old *definition.Definition, new *definition.Definition)
+
+// WatchOptions is the configuration of the watch of flows
+type WatchOptions struct {
+	// Interval is the interval the flows are polled at, DefaultWatchInterval is used if not set
+	Interval time.Duration
+
+	// OnReload is called when a watched flow is reloaded
+	OnReload ReloadFunc
+}
+
+// Watch polls the remote flows with the specified uris, reloading the flows which changed.
+// The files of the file:// flows are watched for changes, and only read again when the
+// file system notifies a change or when their modification time or size changes, the
+// other flows are fetched again and swapped in if their definition changed.  Embedded
+// flows (res://) aren't watched, use ReloadFlow to refresh them.  The returned function
+// stops the watch.
+func (fm *FlowManager) Watch(uris []string, options WatchOptions) (stop func()) {
+
+	interval := options.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	w := &flowWatcher{fm: fm, onReload: options.OnReload, files: make(map[string]fileStamp)}
+
+	for _, uri := range uris {
+		if strings.HasPrefix(uri, uriSchemeRes) {
+			logger.Warnf("Embedded flow with uri '%s' can't be watched", uri)
+			continue
+		}
+		w.uris = append(w.uris, uri)
+		if strings.HasPrefix(uri, uriSchemeFile) {
+			w.files[uri], _ = statFlowFile(uri)
+		}
+	}
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+
+	notifier, err := w.watchFiles()
+	if err != nil {
+		logger.Warnf("Unable to watch the flow files for changes, polling them: %s", err.Error())
+	} else if notifier != nil {
+		events, errs = notifier.Events, notifier.Errors
+	}
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		if notifier != nil {
+			defer notifier.Close()
+		}
+		for {
+			select {
+			case <-ticker.C:
+				w.poll()
+			case event := <-events:
+				w.fileChanged(event.Name)
+			case err := <-errs:
+				logger.Warnf("Error watching the flow files for changes: %s", err.Error())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// fileStamp identifies the version of a flow file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFlowFile(uri string) (fileStamp, error) {
+
+	info, err := os.Stat(flowFilePath(uri))
+	if err != nil {
+		return fileStamp{}, err
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+func flowFilePath(uri string) string {
+	return filepath.Clean(uri[len(uriSchemeFile):])
+}
+
+// flowWatcher polls the watched flows
+type flowWatcher struct {
+	fm       *FlowManager
+	uris     []string
+	onReload ReloadFunc
+
+	// files are the last seen stamps of the files of the file:// flows
+	files map[string]fileStamp
+}
+
+// watchFiles watches the directories of the files of the file:// flows, so the files
+// replaced by editors (ex. written to a temporary file and renamed) are still noticed.
+// Nil is returned if no file flow is watched.
+func (w *flowWatcher) watchFiles() (*fsnotify.Watcher, error) {
+
+	dirs := make(map[string]bool)
+	for uri := range w.files {
+		dirs[filepath.Dir(flowFilePath(uri))] = true
+	}
+
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	for dir := range dirs {
+		if err := notifier.Add(dir); err != nil {
+			notifier.Close()
+			return nil, err
+		}
+	}
+
+	return notifier, nil
+}
+
+// fileChanged reloads the file flows of the file the file system notified a change of
+func (w *flowWatcher) fileChanged(name string) {
+
+	name = filepath.Clean(name)
+
+	for _, uri := range w.uris {
+		if !strings.HasPrefix(uri, uriSchemeFile) || flowFilePath(uri) != name {
+			continue
+		}
+
+		stamp, err := statFlowFile(uri)
+		if err != nil {
+			// the file is being replaced, it is reloaded once it is created again
+			continue
+		}
+		w.files[uri] = stamp
+
+		w.reload(uri)
+	}
+}
+
+// poll reloads the watched flows which changed since the last poll
+func (w *flowWatcher) poll() {
+
+	for _, uri := range w.uris {
+		if strings.HasPrefix(uri, uriSchemeFile) {
+			stamp, err := statFlowFile(uri)
+			if err != nil || stamp == w.files[uri] {
+				continue
+			}
+			w.files[uri] = stamp
+		}
+
+		w.reload(uri)
+	}
+}
+
+// reload refreshes the watched flow, calling onReload if it changed
+func (w *flowWatcher) reload(uri string) {
+
+	old, flow, err := w.fm.refreshFlow(uri)
+	if err != nil {
+		logger.Warnf("Unable to reload watched flow with uri '%s': %s", uri, err.Error())
+		return
+	}
+
+	if flow != old {
+		logger.Infof("Reloaded watched flow with uri '%s'", uri)
+		if w.onReload != nil {
+			w.onReload(uri, old, flow)
+		}
+	}
+}
+
+// refreshFlow fetches the remote flow again, it is only materialized and swapped in if
+// its definition changed.  The cached flow is returned as the new flow if it didn't change.
+func (fm *FlowManager) refreshFlow(uri string) (old *definition.Definition, flow *definition.Definition, err error) {
+
+	fm.rfMu.Lock()
+	entry, exists := fm.remoteFlows[uri]
+	fm.rfMu.Unlock()
+
+	materialize := fm.materializeFlow
+
+	if exists {
+		old = entry.flow
+		materialize = func(flowRep *definition.DefinitionRep) (*definition.Definition, error) {
+			// the fetched rep is compared to the cached one as fetched, before the pipeline
+			if raw, err := json.Marshal(flowRep); err == nil && entry.raw != nil && bytes.Equal(raw, entry.raw) {
+				return entry.flow, nil
+			}
+			return fm.materializeFlow(flowRep)
+		}
+	}
+
+	flow, err = fm.fetchRemoteFlow(context.Background(), uri, materialize)
+	return old, flow, err
+}
@@ -0,0 +1,153 @@
+package support
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/definition"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchFileFlow(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watch")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "flow.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(testFlowJSON), 0644))
+
+	uri := "file://" + file
+	fm := NewFlowManager(nil)
+
+	old, err := fm.GetFlow(uri)
+	assert.Nil(t, err)
+
+	reloaded := make(chan *definition.Definition, 1)
+	stop := fm.Watch([]string{uri}, WatchOptions{Interval: 10 * time.Millisecond, OnReload: func(uri string, oldFlow, newFlow *definition.Definition) {
+		assert.True(t, oldFlow == old)
+		reloaded <- newFlow
+	}})
+	defer stop()
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte(strings.Replace(testFlowJSON, "Test Flow", "Edited Flow", 1)), 0644))
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(file, later, later))
+
+	select {
+	case flow := <-reloaded:
+		assert.Equal(t, "Edited Flow", flow.Name())
+	case <-time.After(5 * time.Second):
+		t.Fatal("flow wasn't reloaded")
+	}
+
+	// running instances keep the old definition, new gets see the new one
+	assert.Equal(t, "Test Flow", old.Name())
+
+	flow, err := fm.GetFlow(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, "Edited Flow", flow.Name())
+}
+
+func TestWatchPollUnchanged(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+	fm := NewFlowManager(provider)
+
+	old, err := fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+
+	reloads := 0
+	w := &flowWatcher{fm: fm, uris: []string{"http://flows/flow"}, files: make(map[string]fileStamp),
+		onReload: func(uri string, oldFlow, newFlow *definition.Definition) { reloads++ }}
+
+	// an unchanged flow isn't swapped
+	w.poll()
+	assert.Equal(t, 0, reloads)
+	assert.Equal(t, 2, provider.callCount("http://flows/flow"))
+
+	flow, _ := fm.GetFlow("http://flows/flow")
+	assert.True(t, flow == old)
+
+	provider.setFlow("http://flows/flow", strings.Replace(testFlowJSON, "Test Flow", "Edited Flow", 1))
+
+	w.poll()
+	assert.Equal(t, 1, reloads)
+
+	flow, _ = fm.GetFlow("http://flows/flow")
+	assert.Equal(t, "Edited Flow", flow.Name())
+}
+
+func TestWatchFileFlowNotified(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watch")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "flow.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(testFlowJSON), 0644))
+
+	uri := "file://" + file
+	fm := NewFlowManager(nil)
+
+	_, err = fm.GetFlow(uri)
+	assert.Nil(t, err)
+
+	// the interval is too long for the change to be polled
+	reloaded := make(chan *definition.Definition, 1)
+	stop := fm.Watch([]string{uri}, WatchOptions{Interval: time.Hour, OnReload: func(uri string, oldFlow, newFlow *definition.Definition) {
+		reloaded <- newFlow
+	}})
+	defer stop()
+
+	// the file is replaced like editors do
+	tmp := filepath.Join(dir, "flow.json.tmp")
+	assert.Nil(t, ioutil.WriteFile(tmp, []byte(strings.Replace(testFlowJSON, "Test Flow", "Edited Flow", 1)), 0644))
+	assert.Nil(t, os.Rename(tmp, file))
+
+	select {
+	case flow := <-reloaded:
+		assert.Equal(t, "Edited Flow", flow.Name())
+	case <-time.After(5 * time.Second):
+		t.Fatal("flow wasn't reloaded")
+	}
+}
+
+func TestWatchPollUnchangedWithPipeline(t *testing.T) {
+
+	provider := newTestFlowProvider(map[string]string{"http://flows/flow": testFlowJSON})
+
+	// the stage modifies the rep in place
+	pipeline := NewPipeline(StageFunc(func(rep *definition.DefinitionRep) (*definition.DefinitionRep, error) {
+		rep.Name = rep.Name + " (processed)"
+		return rep, nil
+	}))
+	fm := NewFlowManagerWithOptions(provider, &ManagerOptions{Pipeline: pipeline})
+
+	old, err := fm.GetFlow("http://flows/flow")
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Flow (processed)", old.Name())
+
+	reloads := 0
+	w := &flowWatcher{fm: fm, uris: []string{"http://flows/flow"}, files: make(map[string]fileStamp),
+		onReload: func(uri string, oldFlow, newFlow *definition.Definition) { reloads++ }}
+
+	// the flow isn't swapped as it didn't change on the server
+	w.poll()
+	assert.Equal(t, 0, reloads)
+
+	flow, _ := fm.GetFlow("http://flows/flow")
+	assert.True(t, flow == old)
+
+	provider.setFlow("http://flows/flow", strings.Replace(testFlowJSON, "Test Flow", "Edited Flow", 1))
+
+	w.poll()
+	assert.Equal(t, 1, reloads)
+
+	flow, _ = fm.GetFlow("http://flows/flow")
+	assert.Equal(t, "Edited Flow (processed)", flow.Name())
+}
@@ -0,0 +1,24 @@
+// Package zstd adds the zstd content encoding of compressed flows, it is imported for
+// its side effect:
+//
+//	import _ "github.com/TIBCOSoftware/flogo-contrib/action/flow/support/zstd"
+//
+// The flows are decoded by github.com/DataDog/zstd, which uses cgo.
+package zstd
+
+import (
+	"io"
+
+	"github.com/DataDog/zstd"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/support"
+)
+
+func init() {
+	support.RegisterDecompressor(support.EncodingZstd, decompress)
+}
+
+// decompress returns a reader decoding the stream, the resources of the decoder are
+// released when it is closed
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	return zstd.NewReader(r), nil
+}
@@ -0,0 +1,42 @@
+package zstd
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DataDog/zstd"
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/support"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFlowJSON = `{
+  "name": "Zstd Flow",
+  "model": "test",
+  "tasks": [
+    { "id": "log_1", "activity": { "ref": "test-log", "input": { "message": "log 1" } } }
+  ]
+}`
+
+func TestGetFlowZstd(t *testing.T) {
+
+	compressed, err := zstd.Compress(nil, []byte(testFlowJSON))
+	assert.Nil(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("flow-compressed", support.EncodingZstd)
+		w.Write([]byte(base64.StdEncoding.EncodeToString(compressed)))
+	}))
+	defer server.Close()
+
+	rep, err := (&support.BasicRemoteFlowProvider{}).GetFlow(server.URL + "/flows/zstd")
+	assert.Nil(t, err)
+	assert.Equal(t, "Zstd Flow", rep.Name)
+
+	// the flow is read before it is decoded
+	compiler := func(flowURI string, source []byte) ([]byte, error) { return source, nil }
+	rep, err = (&support.BasicRemoteFlowProvider{Compiler: compiler}).GetFlow(server.URL + "/flows/zstd")
+	assert.Nil(t, err)
+	assert.Equal(t, "Zstd Flow", rep.Name)
+}
@@ -13,6 +13,7 @@ func NewTestModel() *model.FlowModel {
 	m := model.New("test")
 	m.RegisterFlowBehavior(&simple.FlowBehavior{})
 	m.RegisterDefaultTaskBehavior("basic", &simple.TaskBehavior{})
+	m.RegisterTaskBehavior("join", &simple.JoinTaskBehavior{})
 
 	return m
 }
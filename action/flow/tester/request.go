@@ -39,6 +39,64 @@ func (rp *RequestProcessor) StartFlow(startRequest *StartRequest) (results map[s
 	factory := action.GetFactory(FLOW_REF)
 	act, _ := factory.New(&action.Config{})
 
+	inputs := startInputs(startRequest)
+
+	execOptions := &instance.ExecOptions{Interceptor: startRequest.Interceptor, Patch: startRequest.Patch}
+	ro := &instance.RunOptions{Op: instance.OpStart, ReturnID: true, FlowURI: startRequest.FlowURI, ExecOptions: execOptions}
+	attr, _ := data.NewAttribute("_run_options", data.TypeAny, ro)
+	inputs[attr.Name()] = attr
+
+	return rp.runner.Execute(context.Background(), act, inputs)
+}
+
+// DebugFlow handles a DebugRequest for a FlowInstance.  The instance is started
+// with a Debugger, which is returned once the instance pauses or is done.
+func (rp *RequestProcessor) DebugFlow(debugRequest *DebugRequest) (*instance.Debugger, error) {
+
+	logger.Debugf("Tester debugging flow")
+
+	factory := action.GetFactory(FLOW_REF)
+	act, _ := factory.New(&action.Config{})
+
+	debugger := instance.NewDebugger()
+
+	for _, breakpoint := range debugRequest.Breakpoints {
+		if err := debugger.SetBreakpoint(breakpoint.TaskID, breakpoint.Points...); err != nil {
+			return nil, err
+		}
+	}
+
+	for taskID, outputs := range debugRequest.Mocks {
+		debugger.Mock(taskID, outputs)
+	}
+
+	inputs := startInputs(&debugRequest.StartRequest)
+
+	execOptions := &instance.ExecOptions{Interceptor: debugRequest.Interceptor, Patch: debugRequest.Patch, Debugger: debugger}
+	ro := &instance.RunOptions{Op: instance.OpStart, ReturnID: true, FlowURI: debugRequest.FlowURI, ExecOptions: execOptions}
+	attr, _ := data.NewAttribute("_run_options", data.TypeAny, ro)
+	inputs[attr.Name()] = attr
+
+	result := make(chan error, 1)
+
+	go func() {
+		_, err := rp.runner.Execute(context.Background(), act, inputs)
+		// the debugger isn't detached if the instance failed to start
+		debugger.Detach()
+		result <- err
+	}()
+
+	if _, err := debugger.Wait(context.Background()); err == instance.ErrDetached {
+		if err := <-result; err != nil {
+			return nil, err
+		}
+	}
+
+	return debugger, nil
+}
+
+func startInputs(startRequest *StartRequest) map[string]*data.Attribute {
+
 	var inputs map[string]*data.Attribute
 
 	if len(startRequest.Attrs) > 0 {
@@ -67,12 +125,7 @@ func (rp *RequestProcessor) StartFlow(startRequest *StartRequest) (results map[s
 		inputs = make(map[string]*data.Attribute, 1)
 	}
 
-	execOptions := &instance.ExecOptions{Interceptor: startRequest.Interceptor, Patch: startRequest.Patch}
-	ro := &instance.RunOptions{Op: instance.OpStart, ReturnID: true, FlowURI: startRequest.FlowURI, ExecOptions: execOptions}
-	attr, _ := data.NewAttribute("_run_options", data.TypeAny, ro)
-	inputs[attr.Name()] = attr
-
-	return rp.runner.Execute(context.Background(), act, inputs)
+	return inputs
 }
 
 // RestartFlow handles a RestartRequest for a FlowInstance.  This will
@@ -143,6 +196,27 @@ type StartRequest struct {
 	ReplyTo     string                 `json:"replyTo"`
 }
 
+// DebugRequest describes a request for debugging a FlowInstance, the mocks are the
+// outputs replacing the activities of the tasks, keyed by task id
+type DebugRequest struct {
+	StartRequest
+	Breakpoints []*instance.Breakpoint            `json:"breakpoints"`
+	Mocks       map[string]map[string]interface{} `json:"mocks"`
+}
+
+// PatchRequest describes the working data patched in a paused FlowInstance
+type PatchRequest struct {
+	Inputs  map[string]interface{} `json:"inputs"`
+	Outputs map[string]interface{} `json:"outputs"`
+	Attrs   map[string]interface{} `json:"attrs"`
+}
+
+// DebugResponse describes the state of a debugged FlowInstance, Pause is nil if it isn't paused
+type DebugResponse struct {
+	ID    string          `json:"id"`
+	Pause *instance.Pause `json:"pause"`
+}
+
 // RestartRequest describes a request for restarting a FlowInstance
 // todo: can be merged into StartRequest
 type RestartRequest struct {
@@ -1,8 +1,12 @@
 package tester
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/instance"
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/service"
@@ -58,6 +62,20 @@ func (et *RestEngineTester) init(settings map[string]string) {
 	router.OPTIONS("/flow/resume", handleOption)
 	router.POST("/flow/resume", et.ResumeFlow)
 
+	router.OPTIONS("/flow/debug", handleOption)
+	router.POST("/flow/debug", et.DebugFlow)
+
+	router.OPTIONS("/flow/debug/:id", handleOption)
+	router.GET("/flow/debug/:id", et.GetDebugState)
+
+	router.OPTIONS("/flow/debug/:id/:command", handleOption)
+	router.POST("/flow/debug/:id/:command", et.DebugCommand)
+	router.PUT("/flow/debug/:id/:command", et.PatchData)
+
+	router.OPTIONS("/flow/debug/:id/:command/:taskId", handleOption)
+	router.PUT("/flow/debug/:id/:command/:taskId", et.SetTaskDebug)
+	router.DELETE("/flow/debug/:id/:command/:taskId", et.ClearTaskDebug)
+
 	router.OPTIONS("/status", handleOption)
 	router.GET("/status", et.Status)
 
@@ -205,6 +223,244 @@ func (et *RestEngineTester) ResumeFlow(w http.ResponseWriter, r *http.Request, _
 	}
 }
 
+// DebugFlow starts a new Flow Instance with a debugger (POST "/flow/debug"), it responds
+// once the instance pauses or is done.
+//
+// To post a debug flow, try this at a shell:
+// $ curl -H "Content-Type: application/json" -X POST -d '{"flowUri":"base","breakpoints":[{"taskId":"log_2","points":["before"]}]}' http://localhost:8080/flow/debug
+func (et *RestEngineTester) DebugFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	req := &DebugRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	debugger, err := et.reqProcessor.DebugFlow(req)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger.Debugf("Debugging Instance [ID:%s] for %s", debugger.InstanceID(), req.FlowURI)
+
+	writeDebugResponse(w, debugger)
+}
+
+// GetDebugState gets the state of a debugged Flow Instance (GET "/flow/debug/:id"), the
+// optional 'wait' query parameter is the number of seconds to wait for the instance to pause.
+//
+// $ curl http://localhost:8080/flow/debug/<id>?wait=30
+func (et *RestEngineTester) GetDebugState(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	debugger, ok := getDebugger(w, ps)
+	if !ok {
+		return
+	}
+
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+
+		seconds, err := strconv.Atoi(wait)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid wait '%s'", wait), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(seconds)*time.Second)
+		debugger.Wait(ctx)
+		cancel()
+	}
+
+	writeDebugResponse(w, debugger)
+}
+
+// DebugCommand resumes a paused Flow Instance (POST "/flow/debug/:id/:command"), the
+// command is one of 'continue', 'step', 'abort' or 'detach'.
+//
+// $ curl -X POST http://localhost:8080/flow/debug/<id>/step
+func (et *RestEngineTester) DebugCommand(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	debugger, ok := getDebugger(w, ps)
+	if !ok {
+		return
+	}
+
+	var err error
+
+	switch command := ps.ByName("command"); command {
+	case "continue":
+		err = debugger.Continue()
+	case "step":
+		err = debugger.Step()
+	case "abort":
+		debugger.Abort()
+	case "detach":
+		debugger.Detach()
+	default:
+		http.Error(w, fmt.Sprintf("unsupported debug command '%s'", command), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchData patches the working data of a paused Flow Instance (PUT "/flow/debug/:id/data").
+//
+// $ curl -H "Content-Type: application/json" -X PUT -d '{"inputs":{"message":"patched"}}' http://localhost:8080/flow/debug/<id>/data
+func (et *RestEngineTester) PatchData(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	if ps.ByName("command") != "data" {
+		http.NotFound(w, r)
+		return
+	}
+
+	debugger, ok := getDebugger(w, ps)
+	if !ok {
+		return
+	}
+
+	req := &PatchRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = patchData(debugger, req)
+
+	if err == instance.ErrNotPaused {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeDebugResponse(w, debugger)
+}
+
+// SetTaskDebug sets the breakpoint or the mock of a task of a debugged Flow Instance
+// (PUT "/flow/debug/:id/breakpoints/:taskId" or PUT "/flow/debug/:id/mocks/:taskId").
+//
+// $ curl -H "Content-Type: application/json" -X PUT -d '{"points":["before","after"]}' http://localhost:8080/flow/debug/<id>/breakpoints/log_2
+// $ curl -H "Content-Type: application/json" -X PUT -d '{"message":"mocked"}' http://localhost:8080/flow/debug/<id>/mocks/log_2
+func (et *RestEngineTester) SetTaskDebug(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	debugger, ok := getDebugger(w, ps)
+	if !ok {
+		return
+	}
+
+	taskID := ps.ByName("taskId")
+
+	switch ps.ByName("command") {
+	case "breakpoints":
+		breakpoint := &instance.Breakpoint{}
+		if err := json.NewDecoder(r.Body).Decode(breakpoint); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := debugger.SetBreakpoint(taskID, breakpoint.Points...); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case "mocks":
+		var outputs map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&outputs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		debugger.Mock(taskID, outputs)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ClearTaskDebug removes the breakpoint or the mock of a task of a debugged Flow Instance
+// (DELETE "/flow/debug/:id/breakpoints/:taskId" or DELETE "/flow/debug/:id/mocks/:taskId").
+func (et *RestEngineTester) ClearTaskDebug(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	debugger, ok := getDebugger(w, ps)
+	if !ok {
+		return
+	}
+
+	switch ps.ByName("command") {
+	case "breakpoints":
+		debugger.ClearBreakpoint(ps.ByName("taskId"))
+	case "mocks":
+		debugger.Unmock(ps.ByName("taskId"))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func patchData(debugger *instance.Debugger, req *PatchRequest) error {
+
+	for name, value := range req.Inputs {
+		if _, err := debugger.SetInput(name, value); err != nil {
+			return err
+		}
+	}
+
+	for name, value := range req.Outputs {
+		if _, err := debugger.SetOutput(name, value); err != nil {
+			return err
+		}
+	}
+
+	for name, value := range req.Attrs {
+		if _, err := debugger.SetAttr(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getDebugger(w http.ResponseWriter, ps httprouter.Params) (*instance.Debugger, bool) {
+
+	debugger, exists := instance.GetDebugger(ps.ByName("id"))
+	if !exists {
+		http.Error(w, fmt.Sprintf("flow instance '%s' is not being debugged", ps.ByName("id")), http.StatusNotFound)
+	}
+
+	return debugger, exists
+}
+
+func writeDebugResponse(w http.ResponseWriter, debugger *instance.Debugger) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.Encode(&DebugResponse{ID: debugger.InstanceID(), Pause: debugger.Paused()})
+}
+
 // Status is a basic health check for the server to determine if it is up
 func (et *RestEngineTester) Status(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 
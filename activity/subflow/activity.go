@@ -2,6 +2,7 @@ package subflow
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow/instance"
 	"github.com/TIBCOSoftware/flogo-lib/core/activity"
@@ -14,12 +15,14 @@ var log = logger.GetLogger("activity-flogo-subFlow")
 
 const (
 	settingFlowURI = "flowURI"
+	ivFlowURI      = "flowURI"
 )
 
 // SubFlowActivity is an Activity that is used to start a sub-flow, can only be used within the
-// context of an flow
+// context of an flow.  The sub-flow is either fixed by the flowURI setting or resolved at
+// runtime from the flowURI input (ex. "res://flow:handler_" + $flow.eventType)
 // settings: {flowURI}
-// input : {sub-flow's input}
+// input : {flowURI, sub-flow's input}
 // output: {sub-flow's output}
 type SubFlowActivity struct {
 	metadata *activity.Metadata
@@ -37,34 +40,61 @@ func (a *SubFlowActivity) Metadata() *activity.Metadata {
 
 func (a *SubFlowActivity) IOMetadata(ctx activity.Context) (*data.IOMetadata, error) {
 	//todo this can be moved to an "init" to optimize
-	setting, set := ctx.GetSetting(settingFlowURI)
+	if setting, set := ctx.GetSetting(settingFlowURI); set && setting != "" {
+		return instance.GetFlowIOMetadata(setting.(string))
+	}
+
+	// the input scope doesn't exist yet, so the uri is resolved from its input mapping
+	value, set, err := instance.ResolveInput(ctx, ivFlowURI)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve flowURI input, %s", err.Error())
+	}
 	if !set {
 		return nil, errors.New("flowURI not set")
 	}
 
-	flowURI := setting.(string)
+	flowURI, err := data.CoerceToString(value)
+	if err != nil || flowURI == "" {
+		return nil, fmt.Errorf("invalid flowURI '%v'", value)
+	}
+
+	ioMd, err := getSubFlowIOMetadata(flowURI)
+	if err != nil {
+		return nil, err
+	}
+
+	// the flowURI input is kept along with the inputs of the sub-flow
+	dynamicMd := &data.IOMetadata{Input: make(map[string]*data.Attribute), Output: ioMd.Output}
+	for name, attr := range ioMd.Input {
+		dynamicMd.Input[name] = attr
+	}
+	dynamicMd.Input[ivFlowURI] = data.NewZeroAttribute(ivFlowURI, data.TypeString)
 
-	return instance.GetFlowIOMetadata(flowURI)
+	return dynamicMd, nil
 }
 
 // Eval implements api.Activity.Eval - Invokes a REST Operation
 func (a *SubFlowActivity) Eval(ctx activity.Context) (done bool, err error) {
 
 	//todo move to init
-	setting, set := ctx.GetSetting(settingFlowURI)
-
-	if !set {
-		return false, errors.New("flowURI not set")
+	flowURI, dynamic, err := getFlowURI(ctx)
+	if err != nil {
+		return false, err
 	}
 
-	flowURI := setting.(string)
 	log.Debugf("Starting SubFlow: %s", flowURI)
 
-	ioMd, err := instance.GetFlowIOMetadata(flowURI)
+	ioMd, err := getSubFlowIOMetadata(flowURI)
 	if err != nil {
 		return false, err
 	}
 
+	if dynamic {
+		if err := validateInputs(ctx, flowURI, ioMd); err != nil {
+			return false, err
+		}
+	}
+
 	inputs := make(map[string]*data.Attribute)
 
 	if ioMd != nil {
@@ -73,7 +103,7 @@ func (a *SubFlowActivity) Eval(ctx activity.Context) (done bool, err error) {
 			value := ctx.GetInput(name)
 			newAttr, err := data.NewAttribute(attr.Name(), attr.Type(), value)
 			if err != nil {
-				return false, err
+				return false, fmt.Errorf("invalid input '%s' of subflow '%s', %s", name, flowURI, err.Error())
 			}
 
 			inputs[name] = newAttr
@@ -88,3 +118,60 @@ func (a *SubFlowActivity) Eval(ctx activity.Context) (done bool, err error) {
 
 	return false, nil
 }
+
+// getFlowURI gets the uri of the sub-flow, the flowURI setting or else the flowURI input
+func getFlowURI(ctx activity.Context) (flowURI string, dynamic bool, err error) {
+
+	if setting, set := ctx.GetSetting(settingFlowURI); set && setting != "" {
+		return setting.(string), false, nil
+	}
+
+	value := ctx.GetInput(ivFlowURI)
+	if value == nil {
+		return "", true, errors.New("flowURI not set")
+	}
+
+	flowURI, err = data.CoerceToString(value)
+	if err != nil || flowURI == "" {
+		return "", true, fmt.Errorf("invalid flowURI '%v'", value)
+	}
+
+	return flowURI, true, nil
+}
+
+// getSubFlowIOMetadata gets the metadata of the sub-flow from the flow manager, so that
+// remote and cached flows can be started too
+func getSubFlowIOMetadata(flowURI string) (*data.IOMetadata, error) {
+
+	ioMd, err := instance.GetFlowIOMetadata(flowURI)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve subflow '%s', %s", flowURI, err.Error())
+	}
+
+	if ioMd == nil {
+		ioMd = &data.IOMetadata{}
+	}
+
+	return ioMd, nil
+}
+
+// validateInputs ensures that the inputs mapped by the task are declared by the sub-flow
+// it resolved to, since a dynamic sub-flow can't be validated with the definition
+func validateInputs(ctx activity.Context, flowURI string, ioMd *data.IOMetadata) error {
+
+	mapped, err := instance.MappedInputs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range mapped {
+		if name == ivFlowURI {
+			continue
+		}
+		if _, declared := ioMd.Input[name]; !declared {
+			return fmt.Errorf("subflow '%s' has no input '%s'", flowURI, name)
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"sync"
 	"testing"
 
 	"github.com/TIBCOSoftware/flogo-contrib/action/flow"
@@ -100,6 +101,14 @@ var jsonFlowRes2 = `{
 }`
 
 var jsonFlow1 = `{
+  "metadata": {
+    "output": [
+      {
+        "name": "value",
+        "type": "string"
+      }
+    ]
+  },
   "attributes": [],
   "tasks": [
     {
@@ -112,14 +121,95 @@ var jsonFlow1 = `{
         "mappings": {
           "input": [
             { "type": 2, "value": "test", "mapTo": "in" }
-          ],
-          "output": [
+          ]
+        }
+      }
+    },
+    {
+      "id": "return",
+      "activity": {
+        "ref": "return",
+        "mappings": {
+          "input": [
+            { "type": 1, "value": "$activity[runFlow].value", "mapTo": "value" }
           ]
         }
       }
     }
   ],
   "links": [
+    { "id": 1, "from": "runFlow", "to": "return" }
+  ]
+}
+`
+
+// jsonFlowDynamic is formatted with the uri of the sub-flow to start
+var jsonFlowDynamic = `{
+  "metadata": {
+    "output": [
+      {
+        "name": "value",
+        "type": "string"
+      }
+    ]
+  },
+  "attributes": [
+    { "name": "target", "type": "string", "value": "%s" }
+  ],
+  "tasks": [
+    {
+      "id": "runFlow",
+      "activity": {
+        "ref": "github.com/TIBCOSoftware/flogo-contrib/activity/subflow",
+        "mappings": {
+          "input": [
+            { "type": 1, "value": "$flow.target", "mapTo": "flowURI" },
+            { "type": 2, "value": "test", "mapTo": "in" }
+          ]
+        }
+      }
+    },
+    {
+      "id": "return",
+      "activity": {
+        "ref": "return",
+        "mappings": {
+          "input": [
+            { "type": 1, "value": "$activity[runFlow].value", "mapTo": "value" }
+          ]
+        }
+      }
+    }
+  ],
+  "links": [
+    { "id": 1, "from": "runFlow", "to": "return" }
+  ]
+}
+`
+
+var jsonFlowUnresolved = `{
+  "attributes": [],
+  "tasks": [
+    {
+      "id": "runFlow",
+      "activity": {
+        "ref": "github.com/TIBCOSoftware/flogo-contrib/activity/subflow",
+        "mappings": {
+          "input": [
+            { "type": 2, "value": "res://flow:missing", "mapTo": "flowURI" }
+          ]
+        }
+      }
+    },
+    {
+      "id": "return",
+      "activity": {
+        "ref": "return"
+      }
+    }
+  ],
+  "links": [
+    { "id": 1, "from": "runFlow", "to": "return" }
   ]
 }
 `
@@ -153,13 +243,68 @@ var jsonFlow2 = `{
           ]
         }
       }
+    },
+    {
+      "id": "return",
+      "activity": {
+        "ref": "return",
+        "mappings": {
+          "input": [
+            { "type": 1, "value": "$flow.in", "mapTo": "value" }
+          ]
+        }
+      }
     }
   ],
   "links": [
+    { "id": 1, "from": "log", "to": "return" }
   ]
 }
 `
 
+var setupOnce sync.Once
+
+// setup registers the activities and loads the flows shared by the sub-flow tests
+func setup() action.Factory {
+
+	setupOnce.Do(func() {
+		activity.Register(NewActivity(getActivityMetadata()))
+		activity.Register(NewLogActivity())
+		activity.Register(NewReturnActivity())
+
+		f := action.GetFactory(flow.FLOW_REF)
+		af := f.(*flow.ActionFactory)
+		af.Init()
+
+		resources := []*resource.Config{
+			{ID: "flow:flow1", Data: []byte(jsonFlow1)},
+			{ID: "flow:flow2", Data: []byte(jsonFlow2)},
+			{ID: "flow:dynamic", Data: []byte(fmt.Sprintf(jsonFlowDynamic, "res://flow:flow2"))},
+			{ID: "flow:unresolved", Data: []byte(jsonFlowUnresolved)},
+		}
+		for _, rConfig := range resources {
+			if err := resource.Load(rConfig); err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	return action.GetFactory(flow.FLOW_REF)
+}
+
+// runFlow runs the flow resource with the specified uri
+func runFlow(t *testing.T, flowURI string) (map[string]*data.Attribute, error) {
+
+	f := setup()
+
+	flowAction, err := f.New(&action.Config{Data: []byte(`{"flowURI":"` + flowURI + `"}`)})
+	assert.Nil(t, err)
+	assert.NotNil(t, flowAction)
+
+	dr := runner.NewDirect()
+	return dr.Execute(context.Background(), flowAction, nil)
+}
+
 func TestCreate(t *testing.T) {
 
 	act := NewActivity(getActivityMetadata())
@@ -190,36 +335,28 @@ func TestDynamicIO(t *testing.T) {
 
 func TestSubFlow(t *testing.T) {
 
-	act := NewActivity(getActivityMetadata())
-
-	if act == nil {
-		t.Error("Activity Not Created")
-		t.Fail()
-		return
+	results, err := runFlow(t, "res://flow:flow1")
+	assert.Nil(t, err)
+	if assert.NotNil(t, results["value"]) {
+		assert.Equal(t, "test", results["value"].Value())
 	}
+}
 
-	activity.Register(act)
-	activity.Register(NewLogActivity())
-
-	f := action.GetFactory(flow.FLOW_REF)
-	af := f.(*flow.ActionFactory)
-	af.Init()
+func TestDynamicSubFlow(t *testing.T) {
 
-	rConfig1 := &resource.Config{ID: "flow:flow1", Data: []byte(jsonFlow1)}
-	rConfig2 := &resource.Config{ID: "flow:flow2", Data: []byte(jsonFlow2)}
-	err := resource.Load(rConfig1)
+	results, err := runFlow(t, "res://flow:dynamic")
 	assert.Nil(t, err)
-	err = resource.Load(rConfig2)
-	assert.Nil(t, err)
-	flowAction, err := f.New(&action.Config{Data: []byte(`{"flowURI":"res://flow:flow1"}`)})
+	if assert.NotNil(t, results["value"]) {
+		assert.Equal(t, "test", results["value"].Value())
+	}
+}
 
-	assert.Nil(t, err)
-	assert.NotNil(t, flowAction)
+func TestDynamicSubFlowUnresolved(t *testing.T) {
 
-	dr := runner.NewDirect()
-	results, err := dr.Execute(context.Background(), flowAction, nil)
-	assert.Nil(t, err)
-	assert.NotNil(t, results)
+	_, err := runFlow(t, "res://flow:unresolved")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unable to resolve subflow 'res://flow:missing'")
+	}
 }
 
 //DUMMY TEST ACTIVITIES
@@ -252,3 +389,30 @@ func (a *LogActivity) Eval(context activity.Context) (done bool, err error) {
 	fmt.Println("Message :", message)
 	return true, nil
 }
+
+type ReturnActivity struct {
+	metadata *activity.Metadata
+}
+
+// NewReturnActivity creates a new ReturnActivity
+func NewReturnActivity() activity.Activity {
+	metadata := &activity.Metadata{ID: "return", ProducesResult: true}
+	input := map[string]*data.Attribute{
+		"value": data.NewZeroAttribute("value", data.TypeString),
+	}
+	metadata.Input = input
+	return &ReturnActivity{metadata: metadata}
+}
+
+// Metadata returns the activity's metadata
+func (a *ReturnActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+// Eval implements api.Activity.Eval - Returns the value
+func (a *ReturnActivity) Eval(context activity.Context) (done bool, err error) {
+
+	value, _ := data.NewAttribute("value", data.TypeString, context.GetInput("value"))
+	context.ActivityHost().Return(map[string]*data.Attribute{"value": value}, nil)
+	return true, nil
+}
@@ -0,0 +1,276 @@
+package grpc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// serviceDesc describes a service declared by the descriptor set
+type serviceDesc struct {
+	name     string
+	fullName string
+	file     string
+	methods  map[string]*methodDesc
+}
+
+// methodDesc describes a method of a service
+type methodDesc struct {
+	name            string
+	service         *serviceDesc
+	input           *messageDesc
+	output          *messageDesc
+	clientStreaming bool
+	serverStreaming bool
+}
+
+func (m *methodDesc) fullName() string {
+	return m.service.fullName + "." + m.name
+}
+
+// messageDesc describes a message type, its fields are ordered by number
+type messageDesc struct {
+	fullName string
+	mapEntry bool
+	fields   []*fieldDesc
+	byNumber map[int32]*fieldDesc
+	byName   map[string]*fieldDesc
+}
+
+// fieldDesc describes a field of a message, the message or enum of the field is set if
+// it is of a message or enum type
+type fieldDesc struct {
+	name     string
+	jsonName string
+	number   int32
+	kind     descriptor.FieldDescriptorProto_Type
+	repeated bool
+	packed   bool
+	message  *messageDesc
+	enum     *enumDesc
+}
+
+// enumDesc describes an enum type
+type enumDesc struct {
+	fullName string
+	names    map[int32]string
+	numbers  map[string]int32
+}
+
+// loadServices reads the descriptor set, returning its services keyed by fully qualified
+// name.  The descriptor set is compiled from the proto files by protoc with the imports
+// included (ex. protoc --include_imports --descriptor_set_out=petstore.protoset petstore.proto).
+func loadServices(descriptorSetFile string) (map[string]*serviceDesc, error) {
+
+	setBytes, err := ioutil.ReadFile(descriptorSetFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading descriptor set '%s', %s", descriptorSetFile, err.Error())
+	}
+
+	var set descriptor.FileDescriptorSet
+	if err := proto.Unmarshal(setBytes, &set); err != nil {
+		return nil, fmt.Errorf("error parsing descriptor set '%s', %s", descriptorSetFile, err.Error())
+	}
+
+	services, err := newServiceDescs(set.GetFile())
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set '%s', %s", descriptorSetFile, err.Error())
+	}
+
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services found in descriptor set '%s'", descriptorSetFile)
+	}
+
+	return services, nil
+}
+
+// descriptors resolves the types of the fields and methods of the files, the types are
+// keyed by their fully qualified name with a leading dot (ex. .petstore.Pet)
+type descriptors struct {
+	messages map[string]*messageDesc
+	enums    map[string]*enumDesc
+
+	// the message protos to complete once all the types are known
+	protos map[*messageDesc]*descriptor.DescriptorProto
+	proto3 map[*messageDesc]bool
+}
+
+func newServiceDescs(files []*descriptor.FileDescriptorProto) (map[string]*serviceDesc, error) {
+
+	d := &descriptors{
+		messages: make(map[string]*messageDesc),
+		enums:    make(map[string]*enumDesc),
+		protos:   make(map[*messageDesc]*descriptor.DescriptorProto),
+		proto3:   make(map[*messageDesc]bool),
+	}
+
+	for _, file := range files {
+		scope := file.GetPackage()
+		d.addEnums(scope, file.GetEnumType())
+		d.addMessages(scope, file.GetMessageType(), file.GetSyntax() == "proto3")
+	}
+
+	for md, mp := range d.protos {
+		if err := d.addFields(md, mp, d.proto3[md]); err != nil {
+			return nil, err
+		}
+	}
+
+	services := make(map[string]*serviceDesc)
+
+	for _, file := range files {
+		for _, sp := range file.GetService() {
+			sd := &serviceDesc{
+				name:     sp.GetName(),
+				fullName: qualifiedName(file.GetPackage(), sp.GetName()),
+				file:     file.GetName(),
+				methods:  make(map[string]*methodDesc),
+			}
+
+			for _, mp := range sp.GetMethod() {
+				input, output := d.messages[mp.GetInputType()], d.messages[mp.GetOutputType()]
+				if input == nil || output == nil {
+					return nil, fmt.Errorf("types of method '%s.%s' not found, the imports must be included in the descriptor set", sd.fullName, mp.GetName())
+				}
+
+				sd.methods[mp.GetName()] = &methodDesc{
+					name:            mp.GetName(),
+					service:         sd,
+					input:           input,
+					output:          output,
+					clientStreaming: mp.GetClientStreaming(),
+					serverStreaming: mp.GetServerStreaming(),
+				}
+			}
+
+			services[sd.fullName] = sd
+		}
+	}
+
+	return services, nil
+}
+
+func (d *descriptors) addEnums(scope string, enums []*descriptor.EnumDescriptorProto) {
+
+	for _, ep := range enums {
+		ed := &enumDesc{fullName: qualifiedName(scope, ep.GetName()), names: make(map[int32]string), numbers: make(map[string]int32)}
+
+		for _, value := range ep.GetValue() {
+			// the first name of an aliased value is its name
+			if _, exists := ed.names[value.GetNumber()]; !exists {
+				ed.names[value.GetNumber()] = value.GetName()
+			}
+			ed.numbers[value.GetName()] = value.GetNumber()
+		}
+
+		d.enums["."+ed.fullName] = ed
+	}
+}
+
+func (d *descriptors) addMessages(scope string, messages []*descriptor.DescriptorProto, proto3 bool) {
+
+	for _, mp := range messages {
+		md := &messageDesc{
+			fullName: qualifiedName(scope, mp.GetName()),
+			mapEntry: mp.GetOptions().GetMapEntry(),
+			byNumber: make(map[int32]*fieldDesc),
+			byName:   make(map[string]*fieldDesc),
+		}
+
+		d.messages["."+md.fullName] = md
+		d.protos[md] = mp
+		d.proto3[md] = proto3
+
+		d.addEnums(md.fullName, mp.GetEnumType())
+		d.addMessages(md.fullName, mp.GetNestedType(), proto3)
+	}
+}
+
+func (d *descriptors) addFields(md *messageDesc, mp *descriptor.DescriptorProto, proto3 bool) error {
+
+	for _, fp := range mp.GetField() {
+		fd := &fieldDesc{
+			name:     fp.GetName(),
+			jsonName: fp.GetJsonName(),
+			number:   fp.GetNumber(),
+			kind:     fp.GetType(),
+			repeated: fp.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+		}
+
+		if fd.jsonName == "" {
+			fd.jsonName = jsonName(fd.name)
+		}
+
+		switch fd.kind {
+		case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+			if fd.message = d.messages[fp.GetTypeName()]; fd.message == nil {
+				return fmt.Errorf("type '%s' of field '%s.%s' not found", fp.GetTypeName(), md.fullName, fd.name)
+			}
+		case descriptor.FieldDescriptorProto_TYPE_ENUM:
+			if fd.enum = d.enums[fp.GetTypeName()]; fd.enum == nil {
+				return fmt.Errorf("type '%s' of field '%s.%s' not found", fp.GetTypeName(), md.fullName, fd.name)
+			}
+		case descriptor.FieldDescriptorProto_TYPE_GROUP:
+			return fmt.Errorf("group field '%s.%s' is not supported", md.fullName, fd.name)
+		}
+
+		// the repeated scalars of proto3 are packed unless disabled
+		if fd.repeated && isPackable(fd.kind) {
+			if fp.GetOptions() != nil && fp.GetOptions().Packed != nil {
+				fd.packed = fp.GetOptions().GetPacked()
+			} else {
+				fd.packed = proto3
+			}
+		}
+
+		md.fields = append(md.fields, fd)
+		md.byNumber[fd.number] = fd
+		md.byName[fd.name] = fd
+		md.byName[fd.jsonName] = fd
+	}
+
+	sort.Slice(md.fields, func(i, j int) bool { return md.fields[i].number < md.fields[j].number })
+
+	return nil
+}
+
+func qualifiedName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func isPackable(kind descriptor.FieldDescriptorProto_Type) bool {
+	switch kind {
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES, descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return false
+	}
+	return true
+}
+
+// jsonName is the lower camel case JSON name protoc gives to the field
+func jsonName(name string) string {
+
+	var b bytes.Buffer
+	upper := false
+
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper && 'a' <= c && c <= 'z':
+			b.WriteRune(c - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(c)
+			upper = false
+		}
+	}
+
+	return b.String()
+}
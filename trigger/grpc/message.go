@@ -0,0 +1,702 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// message is a message of a type declared by the descriptor set, it is decoded to its
+// JSON representation and encoded from it.  It implements proto.Marshaler and
+// proto.Unmarshaler so it is sent and received by the proto codec of grpc.
+type message struct {
+	desc *messageDesc
+
+	// content is the JSON representation of a received message
+	content map[string]interface{}
+
+	// encoded is the wire encoding of a message to send
+	encoded []byte
+}
+
+func newMessage(md *messageDesc) *message {
+	return &message{desc: md}
+}
+
+// Reset implements proto.Message.Reset
+func (m *message) Reset() {
+	m.content, m.encoded = nil, nil
+}
+
+// String implements proto.Message.String
+func (m *message) String() string {
+	return m.desc.fullName
+}
+
+// ProtoMessage implements proto.Message.ProtoMessage
+func (*message) ProtoMessage() {
+}
+
+// Marshal implements proto.Marshaler.Marshal
+func (m *message) Marshal() ([]byte, error) {
+	return m.encoded, nil
+}
+
+// Unmarshal implements proto.Unmarshaler.Unmarshal
+func (m *message) Unmarshal(b []byte) error {
+
+	content, err := decodeMessage(m.desc, b)
+	if err != nil {
+		return err
+	}
+
+	m.content, m.encoded = content, b
+	return nil
+}
+
+// setJSON encodes the message from its JSON representation, the fields are named by
+// their JSON or proto names
+func (m *message) setJSON(jsonBytes []byte) error {
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	if value == nil {
+		m.content, m.encoded = nil, nil
+		return nil
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected an object, got %s", string(jsonBytes))
+	}
+
+	encoded, err := encodeMessage(nil, m.desc, object)
+	if err != nil {
+		return err
+	}
+
+	m.content, m.encoded = object, encoded
+	return nil
+}
+
+// the wire types of the fields
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated message")
+
+// decodeMessage decodes the wire encoding of the message to its JSON representation: the
+// fields are named by their JSON names, the 64 bit integers are strings, the enums are
+// their names, the bytes are base64 strings and the maps are objects.  The unknown fields
+// are skipped.
+func decodeMessage(md *messageDesc, b []byte) (map[string]interface{}, error) {
+
+	content := make(map[string]interface{})
+
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		b = b[n:]
+
+		number, wireType := int32(tag>>3), int(tag&7)
+
+		value, rest, err := readValue(b, wireType)
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+
+		fd := md.byNumber[number]
+		if fd == nil {
+			continue
+		}
+
+		if err := decodeField(content, fd, wireType, value); err != nil {
+			return nil, fmt.Errorf("invalid field '%s', %s", fd.name, err.Error())
+		}
+	}
+
+	return content, nil
+}
+
+// readValue reads the value of the wire type, a varint or a fixed value is returned as
+// an uint64 and a length delimited value as its bytes
+func readValue(b []byte, wireType int) (interface{}, []byte, error) {
+
+	switch wireType {
+	case wireVarint:
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, nil, errTruncated
+		}
+		return v, b[n:], nil
+	case wireFixed64:
+		if len(b) < 8 {
+			return nil, nil, errTruncated
+		}
+		return binary.LittleEndian.Uint64(b), b[8:], nil
+	case wireFixed32:
+		if len(b) < 4 {
+			return nil, nil, errTruncated
+		}
+		return uint64(binary.LittleEndian.Uint32(b)), b[4:], nil
+	case wireBytes:
+		size, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < size {
+			return nil, nil, errTruncated
+		}
+		return b[n : n+int(size)], b[n+int(size):], nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported wire type %d", wireType)
+}
+
+func decodeField(content map[string]interface{}, fd *fieldDesc, wireType int, value interface{}) error {
+
+	if fd.message != nil && fd.message.mapEntry {
+		if wireType != wireBytes {
+			return fmt.Errorf("unexpected wire type %d", wireType)
+		}
+		entry, err := decodeMapEntry(fd.message, value.([]byte))
+		if err != nil {
+			return err
+		}
+
+		entries, _ := content[fd.jsonName].(map[string]interface{})
+		if entries == nil {
+			entries = make(map[string]interface{})
+			content[fd.jsonName] = entries
+		}
+		entries[entry.key] = entry.value
+		return nil
+	}
+
+	// a packed repeated field holds several values
+	if packed, ok := value.([]byte); ok && fd.repeated && isPackable(fd.kind) {
+		values, _ := content[fd.jsonName].([]interface{})
+		for len(packed) > 0 {
+			var item interface{}
+			var err error
+			if item, packed, err = readValue(packed, scalarWireType(fd.kind)); err != nil {
+				return err
+			}
+			v, err := decodeScalar(fd, item)
+			if err != nil {
+				return err
+			}
+			values = append(values, v)
+		}
+		content[fd.jsonName] = values
+		return nil
+	}
+
+	var v interface{}
+	var err error
+
+	if wireType != scalarWireType(fd.kind) {
+		return fmt.Errorf("unexpected wire type %d", wireType)
+	}
+
+	if fd.kind == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		v, err = decodeMessage(fd.message, value.([]byte))
+	} else {
+		v, err = decodeScalar(fd, value)
+	}
+	if err != nil {
+		return err
+	}
+
+	if fd.repeated {
+		values, _ := content[fd.jsonName].([]interface{})
+		content[fd.jsonName] = append(values, v)
+		return nil
+	}
+
+	content[fd.jsonName] = v
+	return nil
+}
+
+type mapEntry struct {
+	key   string
+	value interface{}
+}
+
+func decodeMapEntry(md *messageDesc, b []byte) (*mapEntry, error) {
+
+	content, err := decodeMessage(md, b)
+	if err != nil {
+		return nil, err
+	}
+
+	keyField, valueField := md.byNumber[1], md.byNumber[2]
+	if keyField == nil || valueField == nil {
+		return nil, fmt.Errorf("invalid map entry '%s'", md.fullName)
+	}
+
+	key, exists := content[keyField.jsonName]
+	if !exists {
+		key = zeroValue(keyField)
+	}
+	value, exists := content[valueField.jsonName]
+	if !exists {
+		value = zeroValue(valueField)
+	}
+
+	// the keys of the JSON object are the keys of the map as strings
+	if f, ok := key.(float64); ok {
+		key = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return &mapEntry{key: fmt.Sprint(key), value: value}, nil
+}
+
+// zeroValue is the JSON representation of the default value of the field
+func zeroValue(fd *fieldDesc) interface{} {
+
+	switch fd.kind {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return map[string]interface{}{}
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return ""
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return false
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		v, _ := decodeScalar(fd, uint64(0))
+		return v
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "0"
+	}
+
+	return float64(0)
+}
+
+func scalarWireType(kind descriptor.FieldDescriptorProto_Type) int {
+
+	switch kind {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return wireFixed64
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return wireFixed32
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES, descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return wireBytes
+	}
+
+	return wireVarint
+}
+
+// decodeScalar converts the value read from the wire to its JSON representation
+func decodeScalar(fd *fieldDesc, value interface{}) (interface{}, error) {
+
+	if b, ok := value.([]byte); ok {
+		if fd.kind == descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return base64.StdEncoding.EncodeToString(b), nil
+		}
+		return string(b), nil
+	}
+
+	v := value.(uint64)
+
+	switch fd.kind {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return jsonFloat(math.Float64frombits(v)), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return jsonFloat(float64(math.Float32frombits(uint32(v)))), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return strconv.FormatInt(int64(v), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return strconv.FormatUint(v, 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return strconv.FormatInt(int64(v>>1)^-int64(v&1), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return float64(int32(v)), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return float64(uint32(v)), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return float64(int32(uint32(v)>>1) ^ -int32(v&1)), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return v != 0, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if name, ok := fd.enum.names[int32(v)]; ok {
+			return name, nil
+		}
+		return float64(int32(v)), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", fd.kind)
+}
+
+// jsonFloat represents the special values of a float by their JSON names
+func jsonFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// encodeMessage appends the wire encoding of the JSON object to the buffer, the fields
+// are encoded in the order of their numbers
+func encodeMessage(buf []byte, md *messageDesc, object map[string]interface{}) ([]byte, error) {
+
+	for name := range object {
+		if md.byName[name] == nil {
+			return nil, fmt.Errorf("unknown field '%s'", name)
+		}
+	}
+
+	for _, fd := range md.fields {
+		value, exists := object[fd.jsonName]
+		if !exists {
+			value, exists = object[fd.name]
+		}
+		if !exists || value == nil {
+			continue
+		}
+
+		var err error
+		if buf, err = encodeField(buf, fd, value); err != nil {
+			return nil, fmt.Errorf("invalid field '%s', %s", fd.name, err.Error())
+		}
+	}
+
+	return buf, nil
+}
+
+func encodeField(buf []byte, fd *fieldDesc, value interface{}) ([]byte, error) {
+
+	if fd.message != nil && fd.message.mapEntry {
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("expected an object")
+		}
+
+		// the entries are encoded in the order of their keys
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			entry, err := encodeMapEntry(fd.message, key, entries[key])
+			if err != nil {
+				return nil, err
+			}
+			buf = appendTag(buf, fd.number, wireBytes)
+			buf = appendBytes(buf, entry)
+		}
+
+		return buf, nil
+	}
+
+	if !fd.repeated {
+		return encodeValue(buf, fd, value)
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("expected an array")
+	}
+
+	if fd.packed {
+		var packed []byte
+		for _, item := range items {
+			var err error
+			if packed, err = appendScalar(packed, fd, item); err != nil {
+				return nil, err
+			}
+		}
+		buf = appendTag(buf, fd.number, wireBytes)
+		return appendBytes(buf, packed), nil
+	}
+
+	for _, item := range items {
+		var err error
+		if buf, err = encodeValue(buf, fd, item); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf, nil
+}
+
+func encodeMapEntry(md *messageDesc, key string, value interface{}) ([]byte, error) {
+
+	keyField, valueField := md.byNumber[1], md.byNumber[2]
+	if keyField == nil || valueField == nil {
+		return nil, fmt.Errorf("invalid map entry '%s'", md.fullName)
+	}
+
+	var keyValue interface{} = key
+	if keyField.kind == descriptor.FieldDescriptorProto_TYPE_BOOL {
+		b, err := strconv.ParseBool(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid map key '%s'", key)
+		}
+		keyValue = b
+	}
+
+	entry, err := encodeValue(nil, keyField, keyValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		return entry, nil
+	}
+
+	return encodeValue(entry, valueField, value)
+}
+
+// encodeValue appends the tag and the encoding of a single value of the field
+func encodeValue(buf []byte, fd *fieldDesc, value interface{}) ([]byte, error) {
+
+	if fd.kind == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("expected an object")
+		}
+		nested, err := encodeMessage(nil, fd.message, object)
+		if err != nil {
+			return nil, err
+		}
+		buf = appendTag(buf, fd.number, wireBytes)
+		return appendBytes(buf, nested), nil
+	}
+
+	buf = appendTag(buf, fd.number, scalarWireType(fd.kind))
+	return appendScalar(buf, fd, value)
+}
+
+// appendScalar appends the encoding of the JSON representation of a scalar, the numbers
+// are either JSON numbers or strings
+func appendScalar(buf []byte, fd *fieldDesc, value interface{}) ([]byte, error) {
+
+	switch fd.kind {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.New("expected a string")
+		}
+		return appendBytes(buf, []byte(s)), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.New("expected a base64 string")
+		}
+		b, err := decodeBase64(s)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(buf, b), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, errors.New("expected a boolean")
+		}
+		if b {
+			return appendVarint(buf, 1), nil
+		}
+		return appendVarint(buf, 0), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if name, ok := value.(string); ok {
+			number, exists := fd.enum.numbers[name]
+			if !exists {
+				return nil, fmt.Errorf("unknown value '%s' of enum '%s'", name, fd.enum.fullName)
+			}
+			return appendVarint(buf, uint64(int64(number))), nil
+		}
+		v, err := parseInt(value, 32)
+		if err != nil {
+			return nil, err
+		}
+		return appendVarint(buf, uint64(v)), nil
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		f, err := parseFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		if fd.kind == descriptor.FieldDescriptorProto_TYPE_FLOAT {
+			return appendFixed32(buf, math.Float32bits(float32(f))), nil
+		}
+		return appendFixed64(buf, math.Float64bits(f)), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		bits := 64
+		if fd.kind == descriptor.FieldDescriptorProto_TYPE_UINT32 || fd.kind == descriptor.FieldDescriptorProto_TYPE_FIXED32 {
+			bits = 32
+		}
+		v, err := parseUint(value, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch fd.kind {
+		case descriptor.FieldDescriptorProto_TYPE_FIXED32:
+			return appendFixed32(buf, uint32(v)), nil
+		case descriptor.FieldDescriptorProto_TYPE_FIXED64:
+			return appendFixed64(buf, v), nil
+		}
+		return appendVarint(buf, v), nil
+	}
+
+	bits := 64
+	switch fd.kind {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		bits = 32
+	}
+
+	v, err := parseInt(value, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fd.kind {
+	case descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return appendVarint(buf, uint64(v<<1)^uint64(v>>63)), nil
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return appendFixed32(buf, uint32(v)), nil
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return appendFixed64(buf, uint64(v)), nil
+	}
+
+	// the negative int32 are sign extended
+	return appendVarint(buf, uint64(v)), nil
+}
+
+func appendTag(buf []byte, number int32, wireType int) []byte {
+	return appendVarint(buf, uint64(number)<<3|uint64(wireType))
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func appendFixed32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendFixed64(buf []byte, v uint64) []byte {
+	return appendFixed32(appendFixed32(buf, uint32(v)), uint32(v>>32))
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = appendVarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// numberString is the text of a JSON number or of a number in a string
+func numberString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("expected a number, got %v", value)
+}
+
+func parseInt(value interface{}, bits int) (int64, error) {
+
+	s, err := numberString(value)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(s, 10, bits)
+	if err == nil {
+		return v, nil
+	}
+
+	// an integral number in exponent or decimal notation (ex. 1e3 or 2.0)
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil || f != math.Trunc(f) || f < -math.Exp2(float64(bits-1)) || f >= math.Exp2(float64(bits-1)) {
+		return 0, fmt.Errorf("invalid %d bit integer '%s'", bits, s)
+	}
+
+	return int64(f), nil
+}
+
+func parseUint(value interface{}, bits int) (uint64, error) {
+
+	s, err := numberString(value)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err == nil {
+		return v, nil
+	}
+
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil || f != math.Trunc(f) || f < 0 || f >= math.Exp2(float64(bits)) {
+		return 0, fmt.Errorf("invalid %d bit unsigned integer '%s'", bits, s)
+	}
+
+	return uint64(f), nil
+}
+
+func parseFloat(value interface{}) (float64, error) {
+
+	s, err := numberString(value)
+	if err != nil {
+		return 0, err
+	}
+
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s'", s)
+	}
+
+	return f, nil
+}
+
+// decodeBase64 decodes standard or URL safe base64, padded or not
+func decodeBase64(s string) ([]byte, error) {
+
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if b, err := encoding.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid base64 value '%s'", s)
+}
@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPetDesc(t *testing.T) *messageDesc {
+	return testMethod(t, "GetPet").output
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+
+	pet := newMessage(testPetDesc(t))
+
+	err := pet.setJSON([]byte(`{
+		"id": 7,
+		"name": "rex",
+		"kind": "DOG",
+		"tags": ["good", "boy"],
+		"scores": [3, -1, 2e1],
+		"visits": {"vet": 2, "groomer": 1},
+		"owner": {"name": "ann", "phone": "5551234567890"},
+		"weight": 12.5,
+		"photo": "cGhvdG8=",
+		"balance": -42
+	}`))
+	assert.Nil(t, err)
+
+	decoded := newMessage(testPetDesc(t))
+	encoded, err := pet.Marshal()
+	assert.Nil(t, err)
+	assert.Nil(t, decoded.Unmarshal(encoded))
+
+	expected := `{
+		"id": 7,
+		"name": "rex",
+		"kind": "DOG",
+		"tags": ["good", "boy"],
+		"scores": [3, -1, 20],
+		"visits": {"vet": 2, "groomer": 1},
+		"owner": {"name": "ann", "phone": "5551234567890"},
+		"weight": 12.5,
+		"photo": "cGhvdG8=",
+		"balance": "-42"
+	}`
+
+	content, err := json.Marshal(decoded.content)
+	assert.Nil(t, err)
+	assert.JSONEq(t, expected, string(content))
+}
+
+func TestMessageUnpackedRepeated(t *testing.T) {
+
+	md := testPetDesc(t)
+
+	// scores 1 and 2 encoded as separate fields, as when packing is disabled
+	decoded := newMessage(md)
+	assert.Nil(t, decoded.Unmarshal([]byte{5<<3 | wireVarint, 1, 5<<3 | wireVarint, 2}))
+	assert.Equal(t, []interface{}{float64(1), float64(2)}, decoded.content["scores"])
+
+	// the unknown fields are skipped
+	assert.Nil(t, decoded.Unmarshal([]byte{15<<3 | wireVarint, 1, 1<<3 | wireVarint, 3}))
+	assert.Equal(t, map[string]interface{}{"id": float64(3)}, decoded.content)
+
+	assert.NotNil(t, decoded.Unmarshal([]byte{2<<3 | wireBytes, 5, 'r'}))
+	assert.NotNil(t, decoded.Unmarshal([]byte{1<<3 | wireBytes, 0}))
+}
+
+func TestMessageInvalidJSON(t *testing.T) {
+
+	pet := newMessage(testPetDesc(t))
+
+	// the fields may be named by their proto names
+	assert.Nil(t, pet.setJSON([]byte(`{"id": 1, "name": null}`)))
+
+	assert.NotNil(t, pet.setJSON([]byte(`{"color": "brown"}`)))
+	assert.NotNil(t, pet.setJSON([]byte(`{"id": "one"}`)))
+	assert.NotNil(t, pet.setJSON([]byte(`{"id": 1.5}`)))
+	assert.NotNil(t, pet.setJSON([]byte(`{"id": 3000000000}`)))
+	assert.NotNil(t, pet.setJSON([]byte(`{"kind": "BIRD"}`)))
+	assert.NotNil(t, pet.setJSON([]byte(`{"tags": "good"}`)))
+	assert.NotNil(t, pet.setJSON([]byte(`{"owner": {"age": 3}}`)))
+	assert.NotNil(t, pet.setJSON([]byte(`[1, 2]`)))
+}
+
+func TestToMessage(t *testing.T) {
+
+	md := testPetDesc(t)
+
+	msg, err := toMessage(md, `{"name": "rex"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "rex", msg.content["name"])
+
+	msg, err = toMessage(md, map[string]interface{}{"id": 1, "tags": []string{"good"}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": json.Number("1"), "tags": []interface{}{"good"}}, msg.content)
+
+	_, err = toMessage(md, map[string]interface{}{"color": "brown"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "reply is not a 'petstore.Pet' message")
+}
+
+func TestJSONName(t *testing.T) {
+	assert.Equal(t, "petName", jsonName("pet_name"))
+	assert.Equal(t, "id", jsonName("id"))
+}
@@ -0,0 +1,294 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/instance"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newServiceDesc creates the description of the service serving the methods which have a
+// handler, the messages are of the types declared by the descriptor set
+func newServiceDesc(service *serviceDesc, handlers map[string]*trigger.Handler) *grpc.ServiceDesc {
+
+	sd := &grpc.ServiceDesc{
+		ServiceName: service.fullName,
+		HandlerType: (*interface{})(nil),
+		Metadata:    service.file,
+	}
+
+	for name, handler := range handlers {
+		method := service.methods[name]
+
+		if method.serverStreaming {
+			sd.Streams = append(sd.Streams, grpc.StreamDesc{
+				StreamName:    name,
+				Handler:       newStreamHandler(method, handler),
+				ServerStreams: true,
+			})
+			continue
+		}
+
+		sd.Methods = append(sd.Methods, grpc.MethodDesc{
+			MethodName: name,
+			Handler:    newUnaryHandler(method, handler),
+		})
+	}
+
+	return sd
+}
+
+func newUnaryHandler(method *methodDesc, handler *trigger.Handler) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+
+		request := newMessage(method.input)
+		if err := dec(request); err != nil {
+			return nil, err
+		}
+
+		replies, err := handle(ctx, method, handler, request)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(replies) > 1 {
+			return nil, status.Errorf(codes.Internal, "unary method '%s' replied %d messages", method.fullName(), len(replies))
+		}
+
+		return toMessage(method.output, replyItem(replies))
+	}
+}
+
+func newStreamHandler(method *methodDesc, handler *trigger.Handler) grpc.StreamHandler {
+
+	return func(srv interface{}, stream grpc.ServerStream) error {
+
+		request := newMessage(method.input)
+		if err := stream.RecvMsg(request); err != nil {
+			return err
+		}
+
+		// the replies of the flow are streamed as they are produced
+		replies := &streamReplies{method: method, stream: stream}
+		ctx := instance.NewReplyContext(stream.Context(), replies)
+
+		results, err := handle(ctx, method, handler, request)
+		if err == nil {
+			err = replies.send(results)
+		}
+		if err != nil {
+			return err
+		}
+
+		return replies.err
+	}
+}
+
+// streamReplies is the result handler sending the replies of the flow to the stream of
+// the call, the first failure ends the stream
+type streamReplies struct {
+	method *methodDesc
+	stream grpc.ServerStream
+
+	mutex sync.Mutex
+	err   error
+}
+
+// HandleResult implements action.ResultHandler.HandleResult
+func (r *streamReplies) HandleResult(results map[string]*data.Attribute, err error) {
+
+	if err != nil {
+		r.fail(status.Error(codes.Internal, err.Error()))
+		return
+	}
+
+	replyData, err := reply(results)
+	if err == nil {
+		err = r.send(replyMessages(replyData, true))
+	}
+	if err != nil {
+		r.fail(err)
+	}
+}
+
+// Done implements action.ResultHandler.Done
+func (r *streamReplies) Done() {
+}
+
+func (r *streamReplies) send(replies []interface{}) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+
+	for _, reply := range replies {
+		response, err := toMessage(r.method.output, reply)
+		if err != nil {
+			return err
+		}
+		if err := r.stream.SendMsg(response); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *streamReplies) fail(err error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// handle runs the handler of the call, returning the messages of the reply.  The reply
+// data of a server streaming method is an array of the messages to stream.
+func handle(ctx context.Context, method *methodDesc, handler *trigger.Handler, request *message) ([]interface{}, error) {
+
+	log.Debugf("Received call of '%s'", method.fullName())
+
+	content := request.content
+	if content == nil {
+		content = make(map[string]interface{})
+	}
+
+	triggerData := map[string]interface{}{
+		"service":  method.service.fullName,
+		"method":   method.name,
+		"metadata": incomingMetadata(ctx),
+		"content":  content,
+	}
+
+	results, err := handler.Handle(ctx, triggerData)
+	if err != nil {
+		log.Debugf("gRPC Trigger Error: %s", err.Error())
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	replyData, err := reply(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return replyMessages(replyData, method.serverStreaming), nil
+}
+
+// reply gets the data of the reply, a non zero code fails the call
+func reply(results map[string]*data.Attribute) (interface{}, error) {
+
+	var replyData interface{}
+	var replyCode int
+
+	if len(results) != 0 {
+		if dataAttr, ok := results["data"]; ok {
+			replyData = dataAttr.Value()
+		}
+		if codeAttr, ok := results["code"]; ok {
+			replyCode, _ = data.CoerceToInteger(codeAttr.Value())
+		}
+	}
+
+	if replyCode != int(codes.OK) {
+		return nil, status.Error(codes.Code(replyCode), replyMessage(replyData))
+	}
+
+	return replyData, nil
+}
+
+// replyMessages splits the reply data into the messages of the response, a streaming
+// method streams each of the items of an array
+func replyMessages(replyData interface{}, streaming bool) []interface{} {
+
+	if replyData == nil {
+		if streaming {
+			return nil
+		}
+		return []interface{}{nil}
+	}
+
+	if items, ok := replyData.([]interface{}); ok && streaming {
+		return items
+	}
+
+	return []interface{}{replyData}
+}
+
+func replyItem(replies []interface{}) interface{} {
+	if len(replies) == 0 {
+		return nil
+	}
+	return replies[0]
+}
+
+// replyMessage is the message of the status of a failed call
+func replyMessage(replyData interface{}) string {
+
+	switch t := replyData.(type) {
+	case nil:
+		return "call failed"
+	case string:
+		return t
+	case map[string]interface{}:
+		if message, ok := t["message"].(string); ok {
+			return message
+		}
+	}
+
+	return fmt.Sprintf("%v", replyData)
+}
+
+// toMessage converts the reply data (an object or a JSON string) to a message of the type
+func toMessage(md *messageDesc, replyData interface{}) (*message, error) {
+
+	msg := newMessage(md)
+
+	if replyData == nil {
+		return msg, nil
+	}
+
+	var jsonBytes []byte
+
+	if s, ok := replyData.(string); ok {
+		jsonBytes = []byte(s)
+	} else {
+		var err error
+		if jsonBytes, err = json.Marshal(replyData); err != nil {
+			return nil, status.Errorf(codes.Internal, "invalid reply, %s", err.Error())
+		}
+	}
+
+	if err := msg.setJSON(jsonBytes); err != nil {
+		return nil, status.Errorf(codes.Internal, "reply is not a '%s' message, %s", md.fullName, err.Error())
+	}
+
+	return msg, nil
+}
+
+// incomingMetadata gets the metadata of the call, the values of a key are comma separated
+func incomingMetadata(ctx context.Context) map[string]string {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	params := make(map[string]string, len(md))
+	for key, values := range md {
+		params[key] = strings.Join(values, ",")
+	}
+
+	return params
+}
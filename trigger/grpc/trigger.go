@@ -0,0 +1,192 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// log is the default package logger
+var log = logger.GetLogger("trigger-flogo-grpc")
+
+// GrpcTrigger gRPC trigger struct
+type GrpcTrigger struct {
+	metadata *trigger.Metadata
+	config   *trigger.Config
+	server   *grpc.Server
+	addr     string
+	listener net.Listener
+}
+
+// NewFactory create a new Trigger factory
+func NewFactory(md *trigger.Metadata) trigger.Factory {
+	return &GrpcFactory{metadata: md}
+}
+
+// GrpcFactory gRPC Trigger factory
+type GrpcFactory struct {
+	metadata *trigger.Metadata
+}
+
+// New Creates a new trigger instance for a given id
+func (f *GrpcFactory) New(config *trigger.Config) trigger.Trigger {
+	return &GrpcTrigger{metadata: f.metadata, config: config}
+}
+
+// Metadata implements trigger.Trigger.Metadata
+func (t *GrpcTrigger) Metadata() *trigger.Metadata {
+	return t.metadata
+}
+
+func (t *GrpcTrigger) Initialize(ctx trigger.InitContext) error {
+
+	if t.config.Settings == nil {
+		return fmt.Errorf("no Settings found for trigger '%s'", t.config.Id)
+	}
+
+	if _, ok := t.config.Settings["port"]; !ok {
+		return fmt.Errorf("no Port found for trigger '%s' in settings", t.config.Id)
+	}
+
+	descriptorSetFile := t.config.GetSetting("descriptorSetFile")
+	if descriptorSetFile == "" {
+		return fmt.Errorf("no descriptorSetFile found for trigger '%s' in settings", t.config.Id)
+	}
+
+	services, err := loadServices(descriptorSetFile)
+	if err != nil {
+		return err
+	}
+
+	var options []grpc.ServerOption
+
+	if t.config.GetSetting("enableTLS") == "true" {
+		creds, err := serverCredentials(t.config.GetSetting("certFile"), t.config.GetSetting("keyFile"))
+		if err != nil {
+			return fmt.Errorf("invalid TLS settings for trigger '%s', %s", t.config.Id, err.Error())
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+
+	t.server = grpc.NewServer(options...)
+
+	// the handlers of the methods of each service
+	serviceHandlers := make(map[string]map[string]*trigger.Handler)
+
+	// Init handlers
+	for _, handler := range ctx.GetHandlers() {
+
+		serviceName := handler.GetStringSetting("service")
+		methodName := handler.GetStringSetting("method")
+
+		method, err := findMethod(services, serviceName, methodName)
+		if err != nil {
+			return fmt.Errorf("invalid handler for trigger '%s', %s", t.config.Id, err.Error())
+		}
+
+		if method.clientStreaming {
+			return fmt.Errorf("invalid handler for trigger '%s', client streaming method '%s' is not supported", t.config.Id, method.fullName())
+		}
+
+		fqn := method.service.fullName
+		if serviceHandlers[fqn] == nil {
+			serviceHandlers[fqn] = make(map[string]*trigger.Handler)
+		}
+
+		log.Debugf("Registering handler [%s/%s]", fqn, method.name)
+		serviceHandlers[fqn][method.name] = handler
+	}
+
+	for fqn, handlers := range serviceHandlers {
+		t.server.RegisterService(newServiceDesc(services[fqn], handlers), t)
+	}
+
+	t.addr = ":" + t.config.GetSetting("port")
+	log.Debugf("Configured on port %s", t.config.Settings["port"])
+
+	return nil
+}
+
+// Start implements trigger.Trigger.Start
+func (t *GrpcTrigger) Start() error {
+
+	listener, err := net.Listen("tcp", t.addr)
+	if err != nil {
+		return err
+	}
+	t.listener = listener
+
+	go func() {
+		if err := t.server.Serve(listener); err != nil {
+			log.Errorf("gRPC server stopped: %s", err.Error())
+		}
+	}()
+
+	log.Infof("Listening on %s", t.addr)
+	return nil
+}
+
+// Stop implements trigger.Trigger.Stop, the pending calls are completed before it returns
+func (t *GrpcTrigger) Stop() error {
+
+	if t.server != nil {
+		t.server.GracefulStop()
+	}
+
+	return nil
+}
+
+// findMethod finds the method of the service by name, the service name is either the
+// fully qualified name of the service (ex. petstore.PetStore) or its simple name when
+// it is unique
+func findMethod(services map[string]*serviceDesc, serviceName, methodName string) (*methodDesc, error) {
+
+	if serviceName == "" || methodName == "" {
+		return nil, fmt.Errorf("service and method must be specified")
+	}
+
+	service := services[serviceName]
+
+	if service == nil {
+		for fqn, candidate := range services {
+			if candidate.name != serviceName {
+				continue
+			}
+			if service != nil {
+				return nil, fmt.Errorf("service name '%s' is ambiguous, use its fully qualified name", serviceName)
+			}
+			service = services[fqn]
+		}
+	}
+
+	if service == nil {
+		return nil, fmt.Errorf("service '%s' not found", serviceName)
+	}
+
+	method := service.methods[methodName]
+	if method == nil {
+		return nil, fmt.Errorf("method '%s' of service '%s' not found", methodName, service.fullName)
+	}
+
+	return method, nil
+}
+
+// serverCredentials loads the certificate and key PEM files of the server
+func serverCredentials(certFile, keyFile string) (credentials.TransportCredentials, error) {
+
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("certFile and keyFile must be specified")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}}), nil
+}
@@ -0,0 +1,325 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-contrib/action/flow/instance"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var jsonTestMetadata = getTestJsonMetadata()
+
+func getTestJsonMetadata() string {
+	jsonMetadataBytes, err := ioutil.ReadFile("trigger.json")
+	if err != nil {
+		panic("No Json Metadata found for trigger.json path")
+	}
+	return string(jsonMetadataBytes)
+}
+
+const testConfig string = `{
+  "id": "flogo-grpc",
+  "ref": "github.com/TIBCOSoftware/flogo-contrib/trigger/grpc",
+  "settings": {
+    "port": "50051",
+    "descriptorSetFile": "testdata/petstore.protoset"
+  },
+  "handlers": [
+    {
+      "actionId": "my_test_flow",
+      "settings": {
+        "service": "petstore.PetStore",
+        "method": "GetPet"
+      }
+    },
+    {
+      "actionId": "my_test_stream",
+      "settings": {
+        "service": "PetStore",
+        "method": "ListPets"
+      }
+    }
+  ]
+}
+`
+
+func TestReplyMessages(t *testing.T) {
+
+	pets := []interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2}}
+
+	assert.Equal(t, pets, replyMessages(pets, true))
+	assert.Equal(t, []interface{}{pets}, replyMessages(pets, false))
+	assert.Empty(t, replyMessages(nil, true))
+	assert.Equal(t, []interface{}{nil}, replyMessages(nil, false))
+}
+
+func TestReplyMessage(t *testing.T) {
+
+	assert.Equal(t, "pet not found", replyMessage("pet not found"))
+	assert.Equal(t, "pet not found", replyMessage(map[string]interface{}{"message": "pet not found"}))
+	assert.Equal(t, "call failed", replyMessage(nil))
+}
+
+// testHandler runs the function of the handler instead of an action
+type testHandler struct {
+	settings map[string]interface{}
+	handle   func(ctx context.Context, triggerData map[string]interface{}) (map[string]*data.Attribute, error)
+}
+
+func (h *testHandler) Handle(ctx context.Context, triggerData map[string]interface{}) (map[string]*data.Attribute, error) {
+	return h.handle(ctx, triggerData)
+}
+
+func (h *testHandler) GetSetting(setting string) (interface{}, bool) {
+	value, ok := h.settings[setting]
+	return value, ok
+}
+
+func (h *testHandler) GetOutput() map[string]interface{} { return nil }
+func (h *testHandler) GetStringSetting(setting string) string {
+	value, _ := h.settings[setting].(string)
+	return value
+}
+func (h *testHandler) String() string { return "test" }
+
+type testInitContext struct {
+	handlers []*trigger.Handler
+}
+
+func (ctx *testInitContext) GetHandlers() []*trigger.Handler {
+	return ctx.handlers
+}
+
+func replyResults(code int, replyData interface{}) map[string]*data.Attribute {
+	dataAttr, _ := data.NewAttribute("data", data.TypeAny, replyData)
+	codeAttr, _ := data.NewAttribute("code", data.TypeInteger, code)
+	return map[string]*data.Attribute{"data": dataAttr, "code": codeAttr}
+}
+
+// startTestServer initializes the trigger with the handlers and serves it in memory,
+// returning the connection calling it
+func startTestServer(t *testing.T, handlers ...*testHandler) (*grpc.ClientConn, func()) {
+
+	config := &trigger.Config{}
+	err := json.Unmarshal([]byte(testConfig), config)
+	assert.Nil(t, err)
+
+	initCtx := &testInitContext{}
+	for _, handler := range handlers {
+		initCtx.handlers = append(initCtx.handlers, trigger.NewHandlerAlt(handler))
+	}
+
+	tgr := NewFactory(trigger.NewMetadata(jsonTestMetadata)).New(config).(*GrpcTrigger)
+	err = tgr.Initialize(initCtx)
+	assert.Nil(t, err)
+
+	listener := bufconn.Listen(1024 * 1024)
+	go tgr.server.Serve(listener)
+
+	dialer := func(string, time.Duration) (net.Conn, error) { return listener.Dial() }
+	conn, err := grpc.Dial("bufconn", grpc.WithDialer(dialer), grpc.WithInsecure())
+	assert.Nil(t, err)
+
+	return conn, func() {
+		conn.Close()
+		tgr.Stop()
+	}
+}
+
+func testMethod(t *testing.T, name string) *methodDesc {
+	services, err := loadServices("testdata/petstore.protoset")
+	assert.Nil(t, err)
+	return services["petstore.PetStore"].methods[name]
+}
+
+// testRequest creates a request message from its JSON representation
+func testRequest(t *testing.T, method *methodDesc, jsonRequest string) *message {
+	request := newMessage(method.input)
+	assert.Nil(t, request.setJSON([]byte(jsonRequest)))
+	return request
+}
+
+// openTestStream calls the server streaming method
+func openTestStream(t *testing.T, conn *grpc.ClientConn, method *methodDesc, jsonRequest string) grpc.ClientStream {
+
+	stream, err := conn.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "/"+method.service.fullName+"/"+method.name)
+	assert.Nil(t, err)
+	assert.Nil(t, stream.SendMsg(testRequest(t, method, jsonRequest)))
+	assert.Nil(t, stream.CloseSend())
+
+	return stream
+}
+
+func TestLoadServices(t *testing.T) {
+
+	services, err := loadServices("testdata/petstore.protoset")
+	assert.Nil(t, err)
+	assert.Len(t, services, 1)
+	assert.NotNil(t, services["petstore.PetStore"].methods["ListPets"])
+	assert.Equal(t, "petstore.Pet", services["petstore.PetStore"].methods["ListPets"].output.fullName)
+
+	_, err = loadServices("testdata/missing.protoset")
+	assert.NotNil(t, err)
+
+	// a proto file isn't a descriptor set
+	_, err = loadServices("testdata/petstore.proto")
+	assert.NotNil(t, err)
+}
+
+func TestFindMethod(t *testing.T) {
+
+	services, err := loadServices("testdata/petstore.protoset")
+	assert.Nil(t, err)
+
+	method, err := findMethod(services, "petstore.PetStore", "GetPet")
+	assert.Nil(t, err)
+	assert.Equal(t, "petstore.PetStore.GetPet", method.fullName())
+
+	method, err = findMethod(services, "PetStore", "ListPets")
+	assert.Nil(t, err)
+	assert.True(t, method.serverStreaming)
+
+	_, err = findMethod(services, "PetStore", "DeletePet")
+	assert.NotNil(t, err)
+	_, err = findMethod(services, "Shop", "GetPet")
+	assert.NotNil(t, err)
+	_, err = findMethod(services, "", "GetPet")
+	assert.NotNil(t, err)
+
+	// the same simple name in two packages
+	services["v2.PetStore"] = services["petstore.PetStore"]
+	_, err = findMethod(services, "PetStore", "GetPet")
+	assert.NotNil(t, err)
+}
+
+func TestClientStreamingUnsupported(t *testing.T) {
+
+	config := &trigger.Config{}
+	err := json.Unmarshal([]byte(testConfig), config)
+	assert.Nil(t, err)
+
+	handler := &testHandler{settings: map[string]interface{}{"service": "PetStore", "method": "AddPets"}}
+	tgr := NewFactory(trigger.NewMetadata(jsonTestMetadata)).New(config).(*GrpcTrigger)
+	err = tgr.Initialize(&testInitContext{handlers: []*trigger.Handler{trigger.NewHandlerAlt(handler)}})
+	assert.NotNil(t, err)
+}
+
+func TestUnaryCall(t *testing.T) {
+
+	getPet := &testHandler{
+		settings: map[string]interface{}{"service": "petstore.PetStore", "method": "GetPet"},
+		handle: func(ctx context.Context, triggerData map[string]interface{}) (map[string]*data.Attribute, error) {
+			content := triggerData["content"].(map[string]interface{})
+			if content["id"] != float64(1) {
+				return replyResults(int(codes.NotFound), "pet not found"), nil
+			}
+			return replyResults(0, map[string]interface{}{"id": 1, "name": "rex"}), nil
+		},
+	}
+
+	conn, stop := startTestServer(t, getPet)
+	defer stop()
+
+	method := testMethod(t, "GetPet")
+
+	response := newMessage(method.output)
+	err := conn.Invoke(context.Background(), "/petstore.PetStore/GetPet", testRequest(t, method, `{"id": 1}`), response)
+	assert.Nil(t, err)
+	assert.Equal(t, "rex", response.content["name"])
+
+	err = conn.Invoke(context.Background(), "/petstore.PetStore/GetPet", testRequest(t, method, `{"id": 2}`), response)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "pet not found", status.Convert(err).Message())
+}
+
+func TestStreamCall(t *testing.T) {
+
+	received := make(chan struct{})
+
+	listPets := &testHandler{
+		settings: map[string]interface{}{"service": "PetStore", "method": "ListPets"},
+		handle: func(ctx context.Context, triggerData map[string]interface{}) (map[string]*data.Attribute, error) {
+			replies := instance.ReplyResultHandler(ctx, nil)
+			if replies == nil {
+				return nil, errors.New("no reply handler")
+			}
+
+			replies.HandleResult(replyResults(0, map[string]interface{}{"id": 1, "name": "rex"}), nil)
+
+			// the reply is streamed before the flow is done
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("reply not streamed")
+			}
+
+			replies.HandleResult(replyResults(0, []interface{}{map[string]interface{}{"id": 2, "name": "tom"}}), nil)
+			return replyResults(0, map[string]interface{}{"id": 3, "name": "felix"}), nil
+		},
+	}
+
+	conn, stop := startTestServer(t, listPets)
+	defer stop()
+
+	method := testMethod(t, "ListPets")
+	stream := openTestStream(t, conn, method, `{}`)
+
+	var names []interface{}
+	for {
+		response := newMessage(method.output)
+		err := stream.RecvMsg(response)
+		if err == io.EOF {
+			break
+		}
+		if !assert.Nil(t, err) {
+			break
+		}
+		names = append(names, response.content["name"])
+		if len(names) == 1 {
+			close(received)
+		}
+	}
+
+	assert.Equal(t, []interface{}{"rex", "tom", "felix"}, names)
+}
+
+func TestStreamReplyFailure(t *testing.T) {
+
+	listPets := &testHandler{
+		settings: map[string]interface{}{"service": "PetStore", "method": "ListPets"},
+		handle: func(ctx context.Context, triggerData map[string]interface{}) (map[string]*data.Attribute, error) {
+			replies := instance.ReplyResultHandler(ctx, nil)
+			replies.HandleResult(replyResults(0, map[string]interface{}{"id": 1, "name": "rex"}), nil)
+			replies.HandleResult(replyResults(int(codes.Unavailable), "store closed"), nil)
+			replies.HandleResult(replyResults(0, map[string]interface{}{"id": 2, "name": "tom"}), nil)
+			return nil, nil
+		},
+	}
+
+	conn, stop := startTestServer(t, listPets)
+	defer stop()
+
+	method := testMethod(t, "ListPets")
+	stream := openTestStream(t, conn, method, `{"kind": "dog"}`)
+
+	response := newMessage(method.output)
+	err := stream.RecvMsg(response)
+	assert.Nil(t, err)
+	assert.Equal(t, "rex", response.content["name"])
+
+	err = stream.RecvMsg(response)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,200 @@
+package kafkasub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// defaultMaxRetries is the number of times a failed message of a consumer group is retried
+const defaultMaxRetries = 3
+
+// groupRetryBackoff is the time to wait before rejoining the group after a session failed
+var groupRetryBackoff = 2 * time.Second
+
+// messageRetryBackoff is the time to wait before retrying a failed message
+var messageRetryBackoff = time.Second
+
+// groupHandler consumes the claims of a consumer group, the offset of a message is only
+// marked for commit once the handler of its topic succeeded, or once the retries of the
+// message failed and it was sent to the dead letter topic.  Otherwise the session ends,
+// so the message is redelivered when the group is rejoined.
+type groupHandler struct {
+	trigger *KafkaSubTrigger
+	// the topic handlers of the group, keyed by topic
+	handlers map[string]int
+}
+
+func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	log.Debugf("Consumer group session [%s] started with claims: [%v]", session.MemberID(), session.Claims())
+	return nil
+}
+
+func (h *groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	log.Debugf("Consumer group session [%s] ended", session.MemberID())
+	return nil
+}
+
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+
+	id, ok := h.handlers[claim.Topic()]
+	if !ok {
+		return fmt.Errorf("no handler for topic [%s]", claim.Topic())
+	}
+
+	for msg := range claim.Messages() {
+		if err := h.consume(session.Context(), id, msg); err != nil {
+			return err
+		}
+		session.MarkMessage(msg, "")
+	}
+
+	return nil
+}
+
+// consume runs the handler of the message, retrying it up to maxRetries times.  An error
+// is returned when the session ended while retrying or the retries failed and the message
+// couldn't be sent to a dead letter topic.
+func (h *groupHandler) consume(ctx context.Context, id int, msg *sarama.ConsumerMessage) error {
+
+	maxRetries := h.trigger.kafkaParms.handlers[id].maxRetries
+
+	err := runHandler(h.trigger, id, msg)
+	for retry := 1; err != nil && retry <= maxRetries; retry++ {
+		log.Warnf("%s, retrying [%d/%d]", err, retry, maxRetries)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(messageRetryBackoff):
+		}
+
+		err = runHandler(h.trigger, id, msg)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	return handleFailure(h.trigger, id, msg, err)
+}
+
+// startGroups joins the consumer groups of the handlers having a group
+func startGroups(t *KafkaSubTrigger) error {
+
+	groups := make(map[string]*groupHandler)
+	topics := make(map[string][]string)
+
+	for id, handler := range t.kafkaParms.handlers {
+		if handler.group == "" {
+			continue
+		}
+		gh, ok := groups[handler.group]
+		if !ok {
+			gh = &groupHandler{trigger: t, handlers: make(map[string]int)}
+			groups[handler.group] = gh
+		}
+		if _, exists := gh.handlers[handler.topic]; exists {
+			return fmt.Errorf("topic [%s] has more than one handler in consumer group [%s]", handler.topic, handler.group)
+		}
+		gh.handlers[handler.topic] = id
+		topics[handler.group] = append(topics[handler.group], handler.topic)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancelGroups = cancel
+
+	for groupID, gh := range groups {
+		group, err := sarama.NewConsumerGroup(t.kafkaParms.brokers, groupID, t.kafkaConfig)
+		if err != nil {
+			return fmt.Errorf("failed to join Kafka consumer group [%s] for reason [%s]", groupID, err)
+		}
+		t.consumerGroups = append(t.consumerGroups, group)
+
+		log.Debugf("Joined consumer group [%s] for topics [%v]", groupID, topics[groupID])
+
+		t.groupsDone.Add(2)
+		go logGroupErrors(t, groupID, group)
+		go consumeGroup(ctx, t, groupID, group, topics[groupID], gh)
+	}
+
+	return nil
+}
+
+// consumeGroup consumes the topics until the trigger is stopped, a session ends on a
+// rebalance or a message which failed and couldn't be sent to a dead letter topic
+func consumeGroup(ctx context.Context, t *KafkaSubTrigger, groupID string, group sarama.ConsumerGroup, topics []string, gh *groupHandler) {
+	defer t.groupsDone.Done()
+
+	for {
+		err := group.Consume(ctx, topics, gh)
+		if err == sarama.ErrClosedConsumerGroup || ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			// the session ended on a rebalance
+			continue
+		}
+
+		log.Warnf("Consumer group [%s] got error: [%s]", groupID, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(groupRetryBackoff):
+		}
+	}
+}
+
+func logGroupErrors(t *KafkaSubTrigger, groupID string, group sarama.ConsumerGroup) {
+	defer t.groupsDone.Done()
+
+	for err := range group.Errors() {
+		log.Warnf("Consumer group [%s] got error: [%s]", groupID, err)
+	}
+}
+
+// stopGroups leaves the consumer groups, committing the marked offsets
+func stopGroups(t *KafkaSubTrigger) {
+	if t.cancelGroups != nil {
+		t.cancelGroups()
+	}
+	for _, group := range t.consumerGroups {
+		if err := group.Close(); err != nil {
+			log.Warnf("Closing consumer group failed for reason [%s]", err)
+		}
+	}
+	t.groupsDone.Wait()
+	t.consumerGroups = nil
+	log.Debug("Closed consumer groups")
+}
+
+// sendToDeadLetter sends the message which failed to be handled to the dead letter topic
+func sendToDeadLetter(t *KafkaSubTrigger, topic string, msg *sarama.ConsumerMessage, cause error) error {
+
+	if t.deadLetterProducer == nil {
+		return fmt.Errorf("no dead letter producer")
+	}
+
+	dlMsg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	if msg.Key != nil {
+		dlMsg.Key = sarama.ByteEncoder(msg.Key)
+	}
+
+	if t.kafkaConfig.Version.IsAtLeast(sarama.V0_11_0_0) {
+		dlMsg.Headers = []sarama.RecordHeader{
+			{Key: []byte("flogo-source-topic"), Value: []byte(msg.Topic)},
+			{Key: []byte("flogo-source-partition"), Value: []byte(fmt.Sprintf("%d", msg.Partition))},
+			{Key: []byte("flogo-source-offset"), Value: []byte(fmt.Sprintf("%d", msg.Offset))},
+			{Key: []byte("flogo-error"), Value: []byte(cause.Error())},
+		}
+	}
+
+	_, _, err := t.deadLetterProducer.SendMessage(dlMsg)
+	return err
+}
@@ -0,0 +1,121 @@
+package kafkasub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSession records the messages marked by the group handler
+type testSession struct {
+	ctx    context.Context
+	marked []int64
+	// calls is the number of handler calls when each message was marked
+	calls   []int
+	handler *testHandler
+}
+
+func (s *testSession) Claims() map[string][]int32 { return nil }
+func (s *testSession) MemberID() string           { return "member" }
+func (s *testSession) GenerationID() int32        { return 1 }
+func (s *testSession) Context() context.Context   { return s.ctx }
+
+func (s *testSession) MarkOffset(topic string, partition int32, offset int64, metadata string)  {}
+func (s *testSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {}
+
+func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+	s.calls = append(s.calls, s.handler.calls)
+}
+
+type testClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *testClaim) Topic() string                            { return "syslog" }
+func (c *testClaim) Partition() int32                         { return 0 }
+func (c *testClaim) InitialOffset() int64                     { return 0 }
+func (c *testClaim) HighWaterMarkOffset() int64               { return 1 }
+func (c *testClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// testHandler fails the first calls of the flow
+type testHandler struct {
+	failures int
+	calls    int
+}
+
+func (h *testHandler) Handle(ctx context.Context, triggerData map[string]interface{}) (map[string]*data.Attribute, error) {
+	h.calls++
+	if h.calls <= h.failures {
+		return nil, errors.New("flow failed")
+	}
+	return nil, nil
+}
+
+func (h *testHandler) GetSetting(setting string) (interface{}, bool) { return nil, false }
+func (h *testHandler) GetOutput() map[string]interface{}             { return nil }
+func (h *testHandler) GetStringSetting(setting string) string        { return "" }
+func (h *testHandler) String() string                                { return "test" }
+
+// consumeClaim consumes a claim of a single message with the group handler
+func consumeClaim(ctx context.Context, handler *testHandler, topicHandler _topichandler) (*testSession, error) {
+
+	tgr := &KafkaSubTrigger{
+		metadata:   trigger.NewMetadata(jsonTestMetadata),
+		handlers:   []*trigger.Handler{trigger.NewHandlerAlt(handler)},
+		kafkaParms: _kafkaParms{handlers: []_topichandler{topicHandler}},
+	}
+	gh := &groupHandler{trigger: tgr, handlers: map[string]int{"syslog": 0}}
+
+	claim := &testClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "syslog", Offset: 42, Value: []byte("message")}
+	close(claim.messages)
+
+	session := &testSession{ctx: ctx, handler: handler}
+	return session, gh.ConsumeClaim(session, claim)
+}
+
+func TestGroupHandlerRetries(t *testing.T) {
+
+	defer func(backoff time.Duration) { messageRetryBackoff = backoff }(messageRetryBackoff)
+	messageRetryBackoff = 10 * time.Millisecond
+
+	// the message is marked once the handler succeeded
+	handler := &testHandler{failures: 2}
+	start := time.Now()
+	session, err := consumeClaim(context.Background(), handler, _topichandler{topic: "syslog", maxRetries: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, handler.calls)
+	assert.Equal(t, []int64{42}, session.marked)
+	assert.Equal(t, []int{3}, session.calls)
+	assert.True(t, time.Since(start) >= 2*messageRetryBackoff)
+
+	// without a dead letter topic the message isn't marked once the retries failed
+	handler = &testHandler{failures: 10}
+	session, err = consumeClaim(context.Background(), handler, _topichandler{topic: "syslog", maxRetries: 2})
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, handler.calls)
+	assert.Empty(t, session.marked)
+
+	// the message isn't marked when it couldn't be sent to the dead letter topic
+	handler = &testHandler{failures: 10}
+	session, err = consumeClaim(context.Background(), handler, _topichandler{topic: "syslog", deadLetterTopic: "failed"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, handler.calls)
+	assert.Empty(t, session.marked)
+
+	// nor when the session ends while retrying
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler = &testHandler{failures: 10}
+	session, err = consumeClaim(ctx, handler, _topichandler{topic: "syslog", maxRetries: 3})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, handler.calls)
+	assert.Empty(t, session.marked)
+}
@@ -0,0 +1,38 @@
+package kafkasub
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+
+	"github.com/xdg/scram"
+)
+
+var (
+	scramSHA256 scram.HashGeneratorFcn = func() hash.Hash { return sha256.New() }
+	scramSHA512 scram.HashGeneratorFcn = func() hash.Hash { return sha512.New() }
+)
+
+// scramClient implements sarama.SCRAMClient for the SASL SCRAM mechanisms
+type scramClient struct {
+	*scram.Client
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) (err error) {
+	c.Client, err = c.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.ClientConversation = c.Client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.ClientConversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.ClientConversation.Done()
+}
@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -21,10 +22,12 @@ import (
 var log = logger.GetLogger("trigger-flogo-kafkasub")
 
 type _topichandler struct {
-	topic      string
-	offset     int64
-	group      string
-	partitions []int32
+	topic           string
+	offset          int64
+	group           string
+	partitions      []int32
+	deadLetterTopic string
+	maxRetries      int
 }
 
 type _kafkaParms struct {
@@ -43,6 +46,10 @@ type KafkaSubTrigger struct {
 	kafkaConfig        *sarama.Config
 	kafkaConsumer      *sarama.Consumer
 	partitionConsumers *map[string]sarama.PartitionConsumer
+	consumerGroups     []sarama.ConsumerGroup
+	cancelGroups       context.CancelFunc
+	groupsDone         sync.WaitGroup
+	deadLetterProducer sarama.SyncProducer
 }
 
 //NewFactory create a new Trigger factory
@@ -88,13 +95,31 @@ func (t *KafkaSubTrigger) Start() error {
 	signals := make(chan os.Signal, 1)
 	t.signals = &signals
 	signal.Notify(*t.signals, os.Interrupt)
+
+	if hasDeadLetterTopics(t) {
+		producer, err := sarama.NewSyncProducer(t.kafkaParms.brokers, t.kafkaConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create Kafka dead letter producer for reason [%s]", err)
+		}
+		t.deadLetterProducer = producer
+	}
+
 	err := run(t)
+	if err != nil {
+		return err
+	}
 	//log.Debug("KafkaSubTrigger Started")
-	return err
+	return startGroups(t)
 }
 
 // Stop implements ext.Trigger.Stop
 func (t *KafkaSubTrigger) Stop() error {
+	stopGroups(t)
+	if t.deadLetterProducer != nil {
+		t.deadLetterProducer.Close()
+		t.deadLetterProducer = nil
+		log.Debug("Closed dead letter producer")
+	}
 	//unsubscribe from topic
 	if t.partitionConsumers == nil {
 		log.Debug("Closed called for a subscriber with no running consumers")
@@ -114,6 +139,9 @@ func (t *KafkaSubTrigger) Stop() error {
 }
 
 func run(t *KafkaSubTrigger) error {
+	if !hasPartitionHandlers(t) {
+		return nil
+	}
 	kafkaConsumer, err := sarama.NewConsumer(t.kafkaParms.brokers, t.kafkaConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create Kafka consumer for reason [%s]", err)
@@ -122,6 +150,10 @@ func run(t *KafkaSubTrigger) error {
 	consumers := make(map[string]sarama.PartitionConsumer)
 	t.partitionConsumers = &consumers
 	for id, handler := range t.kafkaParms.handlers {
+		if handler.group != "" {
+			// consumed by its consumer group
+			continue
+		}
 		validPartitions, err := kafkaConsumer.Partitions(handler.topic)
 		if err != nil {
 			return fmt.Errorf("failed to get valid partitions for topic [%s] for reason [%s].  Aborting subscriber",
@@ -228,9 +260,24 @@ func initKafkaParams(t *KafkaSubTrigger) error {
 		}
 		config := tls.Config{
 			RootCAs:            trustPool,
-			InsecureSkipVerify: true}
+			InsecureSkipVerify: t.config.GetSetting("skipVerify") == "true"}
 		t.kafkaConfig.Net.TLS.Enable = true
 		t.kafkaConfig.Net.TLS.Config = &config
+	} else if t.config.GetSetting("enableTLS") == "true" {
+		// verified using the system trusted certificates
+		t.kafkaConfig.Net.TLS.Enable = true
+		t.kafkaConfig.Net.TLS.Config = &tls.Config{InsecureSkipVerify: t.config.GetSetting("skipVerify") == "true"}
+	}
+	// client certificate
+	if certFile := t.config.GetSetting("certFile"); certFile != "" {
+		if !t.kafkaConfig.Net.TLS.Enable {
+			return fmt.Errorf("certFile [%s] requires a TLS connection, set enableTLS or truststore", certFile)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, t.config.GetSetting("keyFile"))
+		if err != nil {
+			return fmt.Errorf("Failed to load client certificate [%s] for reason [%s]", certFile, err)
+		}
+		t.kafkaConfig.Net.TLS.Config.Certificates = []tls.Certificate{cert}
 	}
 	// SASL
 	if t.config.Settings["user"] != nil {
@@ -245,9 +292,24 @@ func initKafkaParams(t *KafkaSubTrigger) error {
 			t.kafkaConfig.Net.SASL.Enable = true
 			t.kafkaConfig.Net.SASL.User = user
 			t.kafkaConfig.Net.SASL.Password = password
+
+			err := setSASLMechanism(t.kafkaConfig, t.config.GetSetting("saslMechanism"))
+			if err != nil {
+				return err
+			}
 		}
 	}
 
+	// offset of the consumer groups without a committed offset
+	switch reset := t.config.GetSetting("offsetReset"); reset {
+	case "", "newest":
+		t.kafkaConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
+	case "oldest":
+		t.kafkaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	default:
+		return fmt.Errorf("offsetReset [%s] is invalid, must be newest or oldest", reset)
+	}
+
 	// _topichandlers section
 	if len(t.handlers) == 0 {
 		return fmt.Errorf("Kafka trigger requires at least one handler containing a valid topic name")
@@ -296,11 +358,112 @@ func initKafkaParams(t *KafkaSubTrigger) error {
 		//group
 		if handler.GetStringSetting("group") != "" {
 			t.kafkaParms.handlers[handlerNum].group = handler.GetStringSetting("group")
+			if t.kafkaParms.handlers[handlerNum].partitions != nil {
+				log.Warnf("Partitions specified for handler [%s] are ignored, the partitions are assigned by consumer group [%s]",
+					handler, t.kafkaParms.handlers[handlerNum].group)
+			}
+		}
+
+		//dead letter topic
+		t.kafkaParms.handlers[handlerNum].deadLetterTopic = handler.GetStringSetting("deadLetterTopic")
+
+		//retries of the failed messages of consumer groups
+		t.kafkaParms.handlers[handlerNum].maxRetries = defaultMaxRetries
+		if handler.GetStringSetting("maxRetries") != "" {
+			i, err := strconv.Atoi(handler.GetStringSetting("maxRetries"))
+			if err != nil || i < 0 {
+				return fmt.Errorf("maxRetries [%s] specified for handler [%s] is not a valid number",
+					handler.GetStringSetting("maxRetries"), handler)
+			}
+			t.kafkaParms.handlers[handlerNum].maxRetries = i
+		}
+	}
+
+	if hasDeadLetterTopics(t) {
+		t.kafkaConfig.Producer.Return.Successes = true
+		t.kafkaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	}
+
+	return initKafkaVersion(t)
+}
+
+// initKafkaVersion sets the Kafka version of the protocol, by default the minimum
+// version supporting the configured features
+func initKafkaVersion(t *KafkaSubTrigger) error {
+	var minVersion sarama.KafkaVersion
+	var feature string
+
+	if hasGroupHandlers(t) {
+		minVersion, feature = sarama.V0_10_2_0, "consumer groups"
+	}
+	if mechanism := t.kafkaConfig.Net.SASL.Mechanism; t.kafkaConfig.Net.SASL.Enable && mechanism != sarama.SASLTypePlaintext {
+		minVersion, feature = sarama.V1_0_0_0, string(mechanism)
+	}
+
+	if version := t.config.GetSetting("version"); version != "" {
+		kafkaVersion, err := sarama.ParseKafkaVersion(version)
+		if err != nil {
+			return fmt.Errorf("version [%s] is invalid for reason [%s]", version, err)
+		}
+		if feature != "" && !kafkaVersion.IsAtLeast(minVersion) {
+			return fmt.Errorf("version [%s] does not support %s, requires at least [%s]", version, feature, minVersion)
+		}
+		t.kafkaConfig.Version = kafkaVersion
+	} else if feature != "" && !t.kafkaConfig.Version.IsAtLeast(minVersion) {
+		t.kafkaConfig.Version = minVersion
+	}
+
+	return nil
+}
+
+// setSASLMechanism sets the SASL mechanism, PLAIN by default
+func setSASLMechanism(config *sarama.Config, mechanism string) error {
+	switch strings.ToUpper(mechanism) {
+	case "", sarama.SASLTypePlaintext:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{HashGeneratorFcn: scramSHA256}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{HashGeneratorFcn: scramSHA512}
 		}
+	default:
+		return fmt.Errorf("saslMechanism [%s] is not supported, must be PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", mechanism)
 	}
 	return nil
 }
 
+func hasPartitionHandlers(t *KafkaSubTrigger) bool {
+	for _, handler := range t.kafkaParms.handlers {
+		if handler.group == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGroupHandlers(t *KafkaSubTrigger) bool {
+	for _, handler := range t.kafkaParms.handlers {
+		if handler.group != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDeadLetterTopics(t *KafkaSubTrigger) bool {
+	for _, handler := range t.kafkaParms.handlers {
+		if handler.deadLetterTopic != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func getCerts(trustStore string) (*x509.CertPool, error) {
 	certPool := x509.NewCertPool()
 	fileInfo, err := os.Stat(trustStore)
@@ -347,40 +510,85 @@ func validateBrokerUrl(broker string) error {
 	return nil
 }
 
+// onMessage runs the handlers of the topic of the message which don't have a consumer group
 func onMessage(t *KafkaSubTrigger, msg *sarama.ConsumerMessage) {
 	if msg == nil {
 		return
 	}
 
+	for id, handler := range t.kafkaParms.handlers {
+		if handler.group != "" || handler.topic != msg.Topic {
+			continue
+		}
+		if err := handleMessage(t, id, msg); err != nil {
+			log.Errorf("%s, message lost", err)
+		}
+	}
+}
+
+// handleMessage runs the handler of the message, a message which failed to be handled is
+// sent to the dead letter topic of the handler if any.  An error is returned when the
+// message was neither handled nor sent to the dead letter topic.
+func handleMessage(t *KafkaSubTrigger, id int, msg *sarama.ConsumerMessage) error {
+
+	err := runHandler(t, id, msg)
+	if err == nil {
+		return nil
+	}
+
+	return handleFailure(t, id, msg, err)
+}
+
+// runHandler runs the handler of the message
+func runHandler(t *KafkaSubTrigger, id int, msg *sarama.ConsumerMessage) error {
+
 	log.Debugf("Kafka subscriber triggering job from topic [%s] on partition [%d] with key [%s] at offset [%d]",
 		msg.Topic, msg.Partition, msg.Key, msg.Offset)
 
-	for _, handler := range t.handlers {
+	handler := t.handlers[id]
 
-		//actionID := action.Get(handler.ActionId)
-		//log.Debugf("Found action: '%+x' for ActionID: %s", actionID, handler.ActionId)
-		if t.metadata == nil {
-			log.Infof("Kafka subscriber running in test mode received message on topic [%s] on partition [%d] with key [%s] at offset [%d]",
-				msg.Topic, msg.Partition, msg.Key, msg.Offset)
-			log.Infof("Content: [%s]", string(msg.Value))
+	//actionID := action.Get(handler.ActionId)
+	//log.Debugf("Found action: '%+x' for ActionID: %s", actionID, handler.ActionId)
+	if t.metadata == nil {
+		log.Infof("Kafka subscriber running in test mode received message on topic [%s] on partition [%d] with key [%s] at offset [%d]",
+			msg.Topic, msg.Partition, msg.Key, msg.Offset)
+		log.Infof("Content: [%s]", string(msg.Value))
 
-			return
-		}
+		return nil
+	}
 
-		data := make(map[string]interface{})
-		data["message"] = string(msg.Value)
+	data := make(map[string]interface{})
+	data["message"] = string(msg.Value)
 
-		//if(t.metadata.Metadata.OutPuts
-		startAttrs, errorAttrs := t.metadata.OutputsToAttrs(data, true)
-		if errorAttrs != nil || startAttrs == nil {
-			log.Errorf("Failed to create output attributes for kafka message for handler [%s] for reason [%s] message lost", handler, errorAttrs)
-		}
+	//if(t.metadata.Metadata.OutPuts
+	startAttrs, errorAttrs := t.metadata.OutputsToAttrs(data, true)
+	if errorAttrs != nil || startAttrs == nil {
+		log.Errorf("Failed to create output attributes for kafka message for handler [%s] for reason [%s]", handler, errorAttrs)
+	}
 
-		_, err := handler.Handle(context.Background(), data)
+	_, err := handler.Handle(context.Background(), data)
+	if err != nil {
+		return fmt.Errorf("Run action for handler [%s] failed at offset [%d] of partition [%d] for reason [%s]",
+			handler, msg.Offset, msg.Partition, err)
+	}
 
-		if err != nil {
-			log.Errorf("Run action for handler [%s] failed for reason [%s] message lost", handler, err)
-		}
+	return nil
+}
+
+// handleFailure sends the message which failed to be handled to the dead letter topic of
+// the handler, an error is returned when the handler doesn't have one or sending failed
+func handleFailure(t *KafkaSubTrigger, id int, msg *sarama.ConsumerMessage, err error) error {
+
+	deadLetterTopic := t.kafkaParms.handlers[id].deadLetterTopic
+	if deadLetterTopic == "" {
+		return err
 	}
 
+	log.Warnf("%s, sending message to dead letter topic [%s]", err, deadLetterTopic)
+
+	if dlErr := sendToDeadLetter(t, deadLetterTopic, msg, err); dlErr != nil {
+		return fmt.Errorf("%s and sending it to dead letter topic [%s] failed for reason [%s]", err, deadLetterTopic, dlErr)
+	}
+
+	return nil
 }
@@ -24,12 +24,15 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"testing"
 	"time"
 	golog "log"
 
+	"github.com/Shopify/sarama"
 	"github.com/TIBCOSoftware/flogo-lib/core/action"
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
 	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/stretchr/testify/assert"
 )
 
 var listentime time.Duration = 10
@@ -262,3 +265,35 @@ func TestFailingEndpoint(t *testing.T) {
 	runTest(&config, false, "TestFailingEndpoint", false)
 }
 */
+
+func TestSetSASLMechanism(t *testing.T) {
+	config := sarama.NewConfig()
+
+	assert.Nil(t, setSASLMechanism(config, ""))
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypePlaintext), config.Net.SASL.Mechanism)
+
+	assert.Nil(t, setSASLMechanism(config, "scram-sha-512"))
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA512), config.Net.SASL.Mechanism)
+	assert.NotNil(t, config.Net.SASL.SCRAMClientGeneratorFunc())
+
+	assert.NotNil(t, setSASLMechanism(config, "GSSAPI"))
+}
+
+func TestInitKafkaVersion(t *testing.T) {
+	tgr := &KafkaSubTrigger{
+		config:      &trigger.Config{Settings: map[string]interface{}{}},
+		kafkaConfig: sarama.NewConfig(),
+		kafkaParms:  _kafkaParms{handlers: []_topichandler{{topic: "syslog", group: "wcn"}}},
+	}
+
+	// consumer groups require at least 0.10.2
+	assert.Nil(t, initKafkaVersion(tgr))
+	assert.True(t, tgr.kafkaConfig.Version.IsAtLeast(sarama.V0_10_2_0))
+
+	tgr.config.Settings["version"] = "0.10.0.0"
+	assert.NotNil(t, initKafkaVersion(tgr))
+
+	tgr.config.Settings["version"] = "2.0.0"
+	assert.Nil(t, initKafkaVersion(tgr))
+	assert.True(t, tgr.kafkaConfig.Version.IsAtLeast(sarama.V1_0_0_0))
+}
@@ -0,0 +1,19 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+)
+
+type headerKey struct{}
+
+// HeaderFromContext returns the header of the request which triggered the handler, it
+// can be used by tracers to continue the trace of the request (ex. traceparent header)
+func HeaderFromContext(ctx context.Context) (http.Header, bool) {
+	header, ok := ctx.Value(headerKey{}).(http.Header)
+	return header, ok
+}
+
+func newRequestContext(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), headerKey{}, r.Header)
+}
@@ -21,6 +21,7 @@ const (
 	ACCESS_CONTROL_EXPOSE_HEADERS_HEADER    string = "Access-Control-Expose-Headers"
 	ACCESS_CONTROL_ALLOW_CREDENTIALS_HEADER string = "Access-Control-Allow-Credentials"
 	ACCESS_CONTROL_MAX_AGE_HEADER           string = "Access-Control-Max-Age"
+	VARY_HEADER                             string = "Vary"
 )
 
 // CORS interface
@@ -29,13 +30,28 @@ type Cors interface {
 	HandlePreflight(w http.ResponseWriter, r *http.Request)
 	// WriteCorsActualRequestHeaders writes the needed request headers for the CORS support
 	WriteCorsActualRequestHeaders(w http.ResponseWriter)
+	// WriteCorsHeaders writes the CORS headers of the actual request, echoing its Origin when allowed
+	WriteCorsHeaders(w http.ResponseWriter, r *http.Request)
+}
+
+// Config the CORS settings, the empty settings fall back to the environment variables
+type Config struct {
+	// AllowOrigins the allowed origins, an allowed Origin of a request is echoed
+	AllowOrigins []string
+	// AllowMethods the allowed methods of the preflight requests
+	AllowMethods []string
+	// AllowHeaders the allowed headers of the preflight requests
+	AllowHeaders []string
+	// AllowCredentials "true" if the credentials are allowed
+	AllowCredentials string
 }
 
 type cors struct {
 	// Prefix used for the CORS environment variables
 	Prefix string
 
-	log logger.Logger
+	config Config
+	log    logger.Logger
 }
 
 // make sure that the cors implements the Cors interface
@@ -46,6 +62,11 @@ func New(prefix string, log logger.Logger) Cors {
 	return cors{Prefix: prefix, log: log}
 }
 
+// NewWithConfig creates a Cors using the settings of the config
+func NewWithConfig(prefix string, config Config, log logger.Logger) Cors {
+	return cors{Prefix: prefix, config: config, log: log}
+}
+
 // HandlePreflight Handles the cors preflight request setting the right headers and responding to the request
 func (c cors) HandlePreflight(w http.ResponseWriter, r *http.Request) {
 	// Check if it has Origin Header
@@ -56,9 +77,16 @@ func (c cors) HandlePreflight(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	origin := r.Header.Get(ORIGIN_HEADER)
+	if c.allowOrigin(origin) == "" {
+		c.log.Infof("Invalid CORS preflight request, Origin '%s' not allowed", origin)
+		writeInvalidPreflightResponse(w)
+		return
+	}
+
 	// Check Access-Control-Request-Method header
 	requestMethodHeader := r.Header.Get(ACCESS_CONTROL_REQUEST_METHOD_HEADER)
-	if isValidAccessControlMethod(requestMethodHeader, c.Prefix, c.log) != true {
+	if isAllowedAccessControlMethod(requestMethodHeader, c.allowMethods(), c.log) != true {
 		// Invalid Access Control Method
 		writeInvalidPreflightResponse(w)
 		return
@@ -66,13 +94,13 @@ func (c cors) HandlePreflight(w http.ResponseWriter, r *http.Request) {
 
 	// Check Access-Control-Allow-Headers header
 	requestHeadersHeader := r.Header.Get(ACCESS_CONTROL_REQUEST_HEADER_HEADER)
-	if isValidAccessControlHeaders(requestHeadersHeader, c.Prefix, c.log) != true {
+	if isAllowedAccessControlHeaders(requestHeadersHeader, c.allowHeaders(), c.log) != true {
 		// Invalid Access Control Header
 		writeInvalidPreflightResponse(w)
 		return
 	}
 
-	writeValidPreflightResponse(w, c)
+	writeValidPreflightResponse(w, c, origin)
 }
 
 // HasOriginHeader returns true if the request has Origin header, false otherwise
@@ -86,12 +114,16 @@ func HasOriginHeader(r *http.Request) bool {
 
 // Check if the method name is valid and allowed by the environment variable
 func isValidAccessControlMethod(methodName string, prefix string, log logger.Logger) bool {
+	return isAllowedAccessControlMethod(methodName, GetCorsAllowMethods(prefix), log)
+}
+
+// Check if the method name is valid and one of the comma separated allowed methods
+func isAllowedAccessControlMethod(methodName string, allowedMethodsStr string, log logger.Logger) bool {
 	if methodName == "" {
 		log.Infof("Invalid Access Control Method for preflight request: '%s'", methodName)
 		return false
 	}
-	allowedMethodsEnv := GetCorsAllowMethods(prefix)
-	allowedMethods := strings.Split(allowedMethodsEnv, ",")
+	allowedMethods := strings.Split(allowedMethodsStr, ",")
 	log.Debugf("Allowed Methods '%s'", allowedMethods)
 	for i := range allowedMethods {
 		if strings.ToLower(strings.TrimSpace(allowedMethods[i])) == strings.ToLower(strings.TrimSpace(methodName)) {
@@ -104,11 +136,15 @@ func isValidAccessControlMethod(methodName string, prefix string, log logger.Log
 
 // Check if the headers are valid and allowed by the environment variable
 func isValidAccessControlHeaders(headersStr string, prefix string, log logger.Logger) bool {
+	return isAllowedAccessControlHeaders(headersStr, GetCorsAllowHeaders(prefix), log)
+}
+
+// Check if the headers are valid and in the comma separated allowed headers
+func isAllowedAccessControlHeaders(headersStr string, allowedHeadersStr string, log logger.Logger) bool {
 	if headersStr == "" {
 		return true
 	}
-	allowedHeadersEnv := GetCorsAllowHeaders(prefix)
-	allowedHeaders := strings.Split(allowedHeadersEnv, ",")
+	allowedHeaders := strings.Split(allowedHeadersStr, ",")
 
 	// Create a map for faster lookup
 	allowedHeadersMap := make(map[string]struct{}, len(allowedHeaders))
@@ -136,18 +172,18 @@ func writeInvalidPreflightResponse(w http.ResponseWriter) {
 }
 
 // Writes valid preflight response
-func writeValidPreflightResponse(w http.ResponseWriter, c cors) {
+func writeValidPreflightResponse(w http.ResponseWriter, c cors, origin string) {
 	// Write 200 with CORS headers
-	writeCorsPreflightHeaders(w, c)
+	writeCorsPreflightHeaders(w, c, origin)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 }
 
 // Writes the CORS preflight request headers (origin and credential)
-func writeCorsPreflightHeaders(w http.ResponseWriter, c cors) {
-	c.WriteCorsActualRequestHeaders(w)
-	w.Header().Set(ACCESS_CONTROL_ALLOW_METHODS_HEADER, GetCorsAllowMethods(c.Prefix))
-	w.Header().Set(ACCESS_CONTROL_ALLOW_HEADERS_HEADER, GetCorsAllowHeaders(c.Prefix))
+func writeCorsPreflightHeaders(w http.ResponseWriter, c cors, origin string) {
+	c.writeCorsHeaders(w, origin)
+	w.Header().Set(ACCESS_CONTROL_ALLOW_METHODS_HEADER, c.allowMethods())
+	w.Header().Set(ACCESS_CONTROL_ALLOW_HEADERS_HEADER, c.allowHeaders())
 	w.Header().Set(ACCESS_CONTROL_EXPOSE_HEADERS_HEADER, GetCorsExposeHeaders(c.Prefix))
 	maxAge := GetCorsMaxAge(c.Prefix)
 	if maxAge != "" {
@@ -157,9 +193,66 @@ func writeCorsPreflightHeaders(w http.ResponseWriter, c cors) {
 
 // Writes the CORS actual request headers (origin and credential)
 func (c cors) WriteCorsActualRequestHeaders(w http.ResponseWriter) {
-	w.Header().Set(ACCESS_CONTROL_ALLOW_ORIGIN_HEADER, GetCorsAllowOrigin(c.Prefix))
-	allowCredentials := GetCorsAllowCredentials(c.Prefix)
+	c.writeCorsHeaders(w, "")
+}
+
+// WriteCorsHeaders writes the CORS headers of the actual request, the Origin of the request
+// is echoed when it is one of the allowed origins
+func (c cors) WriteCorsHeaders(w http.ResponseWriter, r *http.Request) {
+	c.writeCorsHeaders(w, r.Header.Get(ORIGIN_HEADER))
+}
+
+// Writes the CORS headers of the request of the origin, the Allow-Origin header is
+// omitted when the origin isn't allowed
+func (c cors) writeCorsHeaders(w http.ResponseWriter, origin string) {
+	allowOrigin := c.allowOrigin(origin)
+	if allowOrigin == "" {
+		return
+	}
+	w.Header().Set(ACCESS_CONTROL_ALLOW_ORIGIN_HEADER, allowOrigin)
+	if allowOrigin != "*" && len(c.config.AllowOrigins) > 0 {
+		w.Header().Add(VARY_HEADER, ORIGIN_HEADER)
+	}
+	allowCredentials := c.allowCredentials()
 	if strings.TrimSpace(allowCredentials) == "true" {
 		w.Header().Set(ACCESS_CONTROL_ALLOW_CREDENTIALS_HEADER, strings.TrimSpace(allowCredentials))
 	}
 }
+
+// allowOrigin gets the Allow-Origin of the origin, empty if the origin isn't in the allowed origins
+func (c cors) allowOrigin(origin string) string {
+	if len(c.config.AllowOrigins) == 0 {
+		return GetCorsAllowOrigin(c.Prefix)
+	}
+	for _, allowed := range c.config.AllowOrigins {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+func (c cors) allowMethods() string {
+	if len(c.config.AllowMethods) == 0 {
+		return GetCorsAllowMethods(c.Prefix)
+	}
+	return strings.Join(c.config.AllowMethods, ", ")
+}
+
+func (c cors) allowHeaders() string {
+	if len(c.config.AllowHeaders) == 0 {
+		return GetCorsAllowHeaders(c.Prefix)
+	}
+	return strings.Join(c.config.AllowHeaders, ", ")
+}
+
+func (c cors) allowCredentials() string {
+	if c.config.AllowCredentials == "" {
+		return GetCorsAllowCredentials(c.Prefix)
+	}
+	return c.config.AllowCredentials
+}
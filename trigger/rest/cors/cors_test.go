@@ -306,3 +306,71 @@ func TestIsValidAccessControlHeadersFailInvalidHeaders(t *testing.T) {
 	assert.Equal(t, false, valid, "Headers should be invalid")
 
 }
+
+func TestHandlePreflightConfigAllowedOrigin(t *testing.T) {
+	r, _ := http.NewRequest("OPTIONS", "http://foo.com", nil)
+	r.Header.Set(ORIGIN_HEADER, "http://bar.com")
+	r.Header.Set(ACCESS_CONTROL_REQUEST_METHOD_HEADER, "PUT")
+	r.Header.Set(ACCESS_CONTROL_REQUEST_HEADER_HEADER, "X-Api-Key")
+
+	w := httptest.NewRecorder()
+
+	c := NewWithConfig(TEST_CORS_PREFIX, Config{
+		AllowOrigins:     []string{"http://foo.com", "http://bar.com"},
+		AllowMethods:     []string{"GET", "PUT"},
+		AllowHeaders:     []string{"X-Api-Key"},
+		AllowCredentials: "true",
+	}, log)
+	c.HandlePreflight(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "http://bar.com", w.HeaderMap.Get(ACCESS_CONTROL_ALLOW_ORIGIN_HEADER))
+	assert.Equal(t, ORIGIN_HEADER, w.HeaderMap.Get(VARY_HEADER))
+	assert.Equal(t, "GET, PUT", w.HeaderMap.Get(ACCESS_CONTROL_ALLOW_METHODS_HEADER))
+	assert.Equal(t, "X-Api-Key", w.HeaderMap.Get(ACCESS_CONTROL_ALLOW_HEADERS_HEADER))
+	assert.Equal(t, "true", w.HeaderMap.Get(ACCESS_CONTROL_ALLOW_CREDENTIALS_HEADER))
+}
+
+func TestHandlePreflightConfigInvalid(t *testing.T) {
+	c := NewWithConfig(TEST_CORS_PREFIX, Config{
+		AllowOrigins: []string{"http://foo.com"},
+		AllowMethods: []string{"GET"},
+	}, log)
+
+	// Origin not allowed
+	r, _ := http.NewRequest("OPTIONS", "http://foo.com", nil)
+	r.Header.Set(ORIGIN_HEADER, "http://bar.com")
+	r.Header.Set(ACCESS_CONTROL_REQUEST_METHOD_HEADER, "GET")
+	w := httptest.NewRecorder()
+	c.HandlePreflight(w, r)
+	assert.Equal(t, 1, len(w.HeaderMap), "Response should have only 1 header")
+
+	// Method not allowed
+	r.Header.Set(ORIGIN_HEADER, "http://foo.com")
+	r.Header.Set(ACCESS_CONTROL_REQUEST_METHOD_HEADER, "DELETE")
+	w = httptest.NewRecorder()
+	c.HandlePreflight(w, r)
+	assert.Equal(t, 1, len(w.HeaderMap), "Response should have only 1 header")
+}
+
+func TestWriteCorsHeadersConfig(t *testing.T) {
+	c := NewWithConfig(TEST_CORS_PREFIX, Config{AllowOrigins: []string{"http://foo.com"}}, log)
+
+	r, _ := http.NewRequest("GET", "http://foo.com", nil)
+	r.Header.Set(ORIGIN_HEADER, "http://foo.com")
+	w := httptest.NewRecorder()
+	c.WriteCorsHeaders(w, r)
+	assert.Equal(t, "http://foo.com", w.HeaderMap.Get(ACCESS_CONTROL_ALLOW_ORIGIN_HEADER))
+	assert.Equal(t, "", w.HeaderMap.Get(ACCESS_CONTROL_ALLOW_CREDENTIALS_HEADER))
+
+	r.Header.Set(ORIGIN_HEADER, "http://bar.com")
+	w = httptest.NewRecorder()
+	c.WriteCorsHeaders(w, r)
+	assert.Equal(t, "", w.HeaderMap.Get(ACCESS_CONTROL_ALLOW_ORIGIN_HEADER))
+
+	// no allow list falls back to the environment
+	c = New(TEST_CORS_PREFIX, log)
+	w = httptest.NewRecorder()
+	c.WriteCorsHeaders(w, r)
+	assert.Equal(t, CORS_ALLOW_ORIGIN_DEFAULT, w.HeaderMap.Get(ACCESS_CONTROL_ALLOW_ORIGIN_HEADER))
+}
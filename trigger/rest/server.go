@@ -1,7 +1,9 @@
 package rest
 
 import (
+	"context"
 	"crypto/md5"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -14,6 +16,9 @@ import (
 
 // Graceful shutdown HttpServer from: https://github.com/corneldamian/httpway/blob/master/server.go
 
+// DefaultShutdownTimeout the default time to wait for the pending requests on Stop
+const DefaultShutdownTimeout = 10 * time.Second
+
 // NewServer create a new server instance
 //param server - is a instance of http.Server, can be nil and a default one will be created
 func NewServer(addr string, handler http.Handler) *Server {
@@ -27,6 +32,9 @@ func NewServer(addr string, handler http.Handler) *Server {
 type Server struct {
 	*http.Server
 
+	// ShutdownTimeout the time to wait for the pending requests on Stop
+	ShutdownTimeout time.Duration
+
 	serverInstanceID string
 	listener         net.Listener
 	lastError        error
@@ -60,6 +68,10 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	if s.TLSConfig != nil {
+		listener = tls.NewListener(listener, s.TLSConfig)
+	}
+
 	hostname, _ := os.Hostname()
 	s.serverInstanceID = fmt.Sprintf("%x", md5.Sum([]byte(hostname+addr)))
 
@@ -81,7 +93,7 @@ func (s *Server) Start() error {
 
 		err := s.Serve(listener)
 		if err != nil {
-			if strings.Contains(err.Error(), "use of closed network connection") {
+			if err == http.ErrServerClosed || strings.Contains(err.Error(), "use of closed network connection") {
 				return
 			}
 
@@ -92,16 +104,32 @@ func (s *Server) Start() error {
 	return nil
 }
 
-// Stop sends stop command to the server
+// Stop stops the server, the pending requests are given the ShutdownTimeout to finish
+// after which their connections are closed
 func (s *Server) Stop() error {
 	if s.listener == nil {
 		return errors.New("Server not started")
 	}
 
-	if err := s.listener.Close(); err != nil {
-		return err
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		if err != context.DeadlineExceeded {
+			return err
+		}
+		pending := len(s.clientsGroup)
+		s.Close()
+		return fmt.Errorf("Stop error, timeout after %s waiting for %d client(s) to finish", timeout, pending)
+	}
+
+	s.serverGroup.Wait()
+
 	return s.lastError
 }
 
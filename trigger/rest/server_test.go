@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerStopDrainsRequests(t *testing.T) {
+
+	started := make(chan bool)
+	srv := NewServer("127.0.0.1:8092", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	srv.ShutdownTimeout = 2 * time.Second
+	assert.Nil(t, srv.Start())
+
+	codes := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:8092/")
+		if err != nil {
+			codes <- 0
+			return
+		}
+		resp.Body.Close()
+		codes <- resp.StatusCode
+	}()
+
+	<-started
+	assert.Nil(t, srv.Stop())
+	assert.Equal(t, http.StatusAccepted, <-codes)
+}
+
+func TestServerStopTimeout(t *testing.T) {
+
+	started := make(chan bool)
+	srv := NewServer("127.0.0.1:8093", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		time.Sleep(time.Second)
+	}))
+	srv.ShutdownTimeout = 50 * time.Millisecond
+	assert.Nil(t, srv.Start())
+
+	go http.Get("http://127.0.0.1:8093/")
+
+	<-started
+	err := srv.Stop()
+	assert.NotNil(t, err)
+}
+
+func TestServerTLSConfig(t *testing.T) {
+
+	_, err := serverTLSConfig("", "", "", "")
+	assert.NotNil(t, err)
+
+	_, err = serverTLSConfig("missing.pem", "missing.key", "", "")
+	assert.NotNil(t, err)
+}
@@ -2,13 +2,16 @@ package rest
 
 import (
 	"bytes"
-	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/TIBCOSoftware/flogo-contrib/trigger/rest/cors"
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
@@ -32,6 +35,7 @@ type RestTrigger struct {
 	//runner   action.Runner
 	server *Server
 	config *trigger.Config
+	cors   cors.Cors
 	//handlers []*handler.Handler
 }
 
@@ -68,6 +72,13 @@ func (t *RestTrigger) Initialize(ctx trigger.InitContext) error {
 
 	addr := ":" + t.config.GetSetting("port")
 
+	t.cors = cors.NewWithConfig(REST_CORS_PREFIX, cors.Config{
+		AllowOrigins:     splitList(t.config.GetSetting("corsAllowOrigins")),
+		AllowMethods:     splitList(t.config.GetSetting("corsAllowMethods")),
+		AllowHeaders:     splitList(t.config.GetSetting("corsAllowHeaders")),
+		AllowCredentials: t.config.GetSetting("corsAllowCredentials"),
+	}, log)
+
 	pathMap := make(map[string]string)
 
 	// Init handlers
@@ -85,7 +96,7 @@ func (t *RestTrigger) Initialize(ctx trigger.InitContext) error {
 
 		if _, ok := pathMap[path]; !ok {
 			pathMap[path] = path
-			router.OPTIONS(path, handleCorsPreflight) // for CORS
+			router.OPTIONS(path, newCorsPreflightHandler(t.cors)) // for CORS
 		}
 
 		//router.OPTIONS(path, handleCorsPreflight) // for CORS
@@ -95,6 +106,23 @@ func (t *RestTrigger) Initialize(ctx trigger.InitContext) error {
 	log.Debugf("Configured on port %s", t.config.Settings["port"])
 	t.server = NewServer(addr, router)
 
+	if t.config.GetSetting("enableTLS") == "true" {
+		tlsConfig, err := serverTLSConfig(t.config.GetSetting("certFile"), t.config.GetSetting("keyFile"),
+			t.config.GetSetting("caFile"), t.config.GetSetting("clientAuth"))
+		if err != nil {
+			return fmt.Errorf("invalid TLS settings for trigger '%s', %s", t.config.Id, err.Error())
+		}
+		t.server.TLSConfig = tlsConfig
+	}
+
+	if timeout, ok := t.config.Settings["shutdownTimeout"]; ok {
+		seconds, err := data.CoerceToInteger(timeout)
+		if err != nil {
+			return fmt.Errorf("invalid shutdownTimeout '%v' for trigger '%s'", timeout, t.config.Id)
+		}
+		t.server.ShutdownTimeout = time.Duration(seconds) * time.Second
+	}
+
 	return nil
 }
 
@@ -102,18 +130,20 @@ func (t *RestTrigger) Start() error {
 	return t.server.Start()
 }
 
-// Stop implements util.Managed.Stop
+// Stop implements util.Managed.Stop, the pending requests are drained for at most the shutdownTimeout
 func (t *RestTrigger) Stop() error {
 	return t.server.Stop()
 }
 
 // Handles the cors preflight request
-func handleCorsPreflight(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func newCorsPreflightHandler(c cors.Cors) httprouter.Handle {
 
-	log.Infof("Received [OPTIONS] request to CorsPreFlight: %+v", r)
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 
-	c := cors.New(REST_CORS_PREFIX, log)
-	c.HandlePreflight(w, r)
+		log.Infof("Received [OPTIONS] request to CorsPreFlight: %+v", r)
+
+		c.HandlePreflight(w, r)
+	}
 }
 
 // IDResponse id response object
@@ -127,8 +157,7 @@ func newActionHandler(rt *RestTrigger, handler *trigger.Handler) httprouter.Hand
 
 		log.Infof("Received request for id '%s'", rt.config.Id)
 
-		c := cors.New(REST_CORS_PREFIX, log)
-		c.WriteCorsActualRequestHeaders(w)
+		rt.cors.WriteCorsHeaders(w, r)
 
 		pathParams := make(map[string]string)
 		for _, param := range ps {
@@ -191,7 +220,7 @@ func newActionHandler(rt *RestTrigger, handler *trigger.Handler) httprouter.Hand
 			triggerData["content"] = content
 		}
 
-		results, err := handler.Handle(context.Background(), triggerData)
+		results, err := handler.Handle(newRequestContext(r), triggerData)
 
 		var replyData interface{}
 		var replyCode int
@@ -273,6 +302,73 @@ func validateHandler(triggerId string, handler *trigger.Handler) error {
 	return nil
 }
 
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":          tls.NoClientCert,
+	"request":       tls.RequestClientCert,
+	"verifyIfGiven": tls.VerifyClientCertIfGiven,
+	"require":       tls.RequireAndVerifyClientCert,
+}
+
+// serverTLSConfig creates the TLS config of the server, the client certificates are verified
+// against the certificates of the CA file, by default they are required when it is specified
+func serverTLSConfig(certFile, keyFile, caFile, clientAuth string) (*tls.Config, error) {
+
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("certFile and keyFile must be specified")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if clientAuth == "" {
+		clientAuth = "none"
+		if caFile != "" {
+			clientAuth = "require"
+		}
+	}
+
+	authType, ok := clientAuthTypes[clientAuth]
+	if !ok {
+		return nil, fmt.Errorf("invalid clientAuth '%s'", clientAuth)
+	}
+	config.ClientAuth = authType
+
+	if authType == tls.VerifyClientCertIfGiven || authType == tls.RequireAndVerifyClientCert {
+		if caFile == "" {
+			return nil, fmt.Errorf("caFile must be specified to verify the client certificates")
+		}
+
+		caCerts, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("no certificates found in caFile '%s'", caFile)
+		}
+	}
+
+	return config, nil
+}
+
+func splitList(value string) []string {
+
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
 func stringInList(str string, list []string) bool {
 	for _, value := range list {
 		if value == str {